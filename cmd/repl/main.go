@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ayushkgu/Internet-Services-Final-Project/sim"
+)
+
+// repl runs the suite with the console of sim/repl.go reading commands from stdin. Its rows go to a
+// file of their own, so a session never replaces the results of the tester in package main.
+func main() {
+	opts := sim.DefaultOptions()
+	opts.Repl = true
+	opts.Out = "repl_results.csv"
+	flag.StringVar(&opts.Out, "out", opts.Out, "CSV file the results are written to")
+	flag.IntVar(&opts.Test, "test", 0, "run only this test of the suite (1-based)")
+	flag.DurationVar(&opts.Hold, "hold", 0, "keep every PoW simulation taking transactions this long after its workload was sent")
+	flag.StringVar(&opts.ControlAddr, "control", "", "serve the endpoints pausing, stepping and resuming the simulation at this address")
+	flag.BoolVar(&opts.Paused, "paused", false, "with -control, start the suite paused")
+	flag.Parse()
+	opts.Args, opts.Flags = os.Args[1:], map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) { opts.Flags[f.Name] = f.Value.String() })
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := sim.Run(ctx, opts); err != nil {
+		stop()
+		fmt.Fprintln(os.Stderr, "repl:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ayushkgu/Internet-Services-Final-Project/sim"
+)
+
+// web serves the browser UI of sim/web.go; the simulations it runs take the tester's defaults
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address the web UI is served at")
+	timeout := flag.Duration("timeout", 0, "time limit per simulation, at most a minute (0 = a minute)")
+	flag.Parse()
+
+	opts := sim.DefaultOptions()
+	opts.Timeout = *timeout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := sim.ServeWeb(ctx, *addr, opts); err != nil {
+		stop()
+		fmt.Fprintln(os.Stderr, "web:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/ayushkgu/Internet-Services-Final-Project
+
+go 1.24.0
+
+require (
+	github.com/gdamore/tcell/v2 v2.13.10
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/crypto v0.46.0
+)
+
+require (
+	github.com/gdamore/encoding v1.0.1 // indirect
+	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/term v0.38.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
+)
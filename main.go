@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ayushkgu/Internet-Services-Final-Project/sim"
+)
+
+// main reads the flags of the benchmark suite and runs it, see sim/tester.go; cmd/web and cmd/repl
+// are smaller front ends to the same package
+func main() {
+	opts := sim.DefaultOptions()
+	c := &opts.Config
+	var webAddr, explorerAddr string
+	flag.StringVar(&c.StoreDir, "store-dir", c.StoreDir, "keep each node's blocks on disk in this directory instead of memory")
+	flag.StringVar(&c.Store, "store", c.Store, "how -store-dir keeps the blocks: bolt (a bbolt database per node, default) or file (an append-only file per node)")
+	midstate := true
+	flag.BoolVar(&midstate, "midstate", midstate, "SHA-256 mining hashes the part of a block before its nonce once (false: the whole block per nonce)")
+	flag.Func("hash", "hash function of the blocks and DAG transactions: sha256 (default), sha3 or blake2b", func(name string) error {
+		h, err := sim.ParseHasher(name)
+		c.Hasher = h
+		return err
+	})
+	flag.BoolVar(&opts.Resume, "resume", opts.Resume, "continue the suite after the last test recorded in the checkpoint file")
+	flag.StringVar(&opts.Out, "out", opts.Out, "CSV file the results are written to")
+	flag.BoolVar(&opts.Append, "append", opts.Append, "add the results to the end of -out instead of replacing it")
+	flag.BoolVar(&opts.OutTimestamp, "out-timestamp", opts.OutTimestamp, "put the start time in the name of -out, e.g. benchmark_results-20260102-150405.csv")
+	flag.DurationVar(&opts.Timeout, "timeout", opts.Timeout, "time limit per simulation for tests without their own Timeout (0 = none)")
+	flag.IntVar(&opts.Buffer, "buffer", opts.Buffer, "receiver channel capacity for tests without their own Buffer (0 = N for PoW / unbuffered for DAG, -1 = unbuffered)")
+	flag.BoolVar(&c.DirectDelivery, "direct-delivery", c.DirectDelivery, "DAG nodes broadcast without delivery queues, dropping transactions when the receiver is busy")
+	flag.StringVar(&c.TipSelection, "tip-selection", c.TipSelection, "DAG parent selection: uniform (default) or mcmc (cumulative-weight random walk)")
+	flag.Float64Var(&c.TipAlpha, "alpha", c.TipAlpha, "bias of the mcmc random walk towards heavy transactions (0 = unbiased)")
+	flag.Float64Var(&c.DoubleSpend, "double-spend", c.DoubleSpend, "share of DAG transactions (pow ones with -utxo) whose sender also spends the funds to another receiver (0-1)")
+	flag.IntVar(&c.SnapshotInterval, "snapshot-interval", c.SnapshotInterval, "DAG nodes prune confirmed history every this many added transactions (0 = never)")
+	flag.BoolVar(&c.SnapshotCheck, "snapshot-check", c.SnapshotCheck, "also keep the unpruned DAG view and count nodes whose confidence results differ")
+	flag.BoolVar(&c.Solidify, "solidify", c.Solidify, "DAG nodes request the missing parents of a transaction from their peers instead of rejecting it")
+	flag.BoolVar(&c.Avalanche, "avalanche", c.Avalanche, "DAG nodes settle double spends by repeatedly querying random peers (Snowball)")
+	flag.IntVar(&c.AvalancheK, "avalanche-k", c.AvalancheK, "peers an Avalanche query samples (0 = 10)")
+	flag.IntVar(&c.AvalancheBeta, "avalanche-beta", c.AvalancheBeta, "consecutive majorities an Avalanche node needs to decide (0 = 15)")
+	flag.StringVar(&c.ForkChoice, "fork-choice", c.ForkChoice, "PoW fork-choice rule: longest (default), heaviest (most total work, default with -retarget-interval) or ghost (heaviest subtree)")
+	flag.IntVar(&c.RetargetInterval, "retarget-interval", c.RetargetInterval, "retarget PoW difficulty every this many blocks, at least 2 (0 = fixed difficulty D)")
+	flag.DurationVar(&c.TargetBlockTime, "target-block-time", c.TargetBlockTime, "block time the PoW difficulty retargets towards, and -calibrate chooses D for")
+	flag.DurationVar(&opts.RoundBudget, "round-budget", opts.RoundBudget, "wall-clock time per test, each mode runs as many rounds R as fit its share (0 = the suite's R)")
+	flag.BoolVar(&opts.Calibrate, "calibrate", opts.Calibrate, "measure the hash rate and give every test the D whose block time is closest to -target-block-time")
+	flag.DurationVar(&c.TimeWarp, "time-warp", c.TimeWarp, "corrupt pow nodes stamp the last block of every retarget window this far in the future (0 = honest timestamps)")
+	flag.DurationVar(&c.MaxTimeDrift, "max-drift", c.MaxTimeDrift, "honest pow nodes reject blocks stamped more than this ahead of their clock or not after the median time past (0 = no check)")
+	flag.BoolVar(&c.Uncles, "uncles", c.Uncles, "PoW blocks reference recently orphaned blocks, which earn a partial reward")
+	flag.IntVar(&c.FinalityInterval, "finality-interval", c.FinalityInterval, "PoW nodes vote on a checkpoint every this many blocks to finalize it (0 = no finality gadget)")
+	flag.StringVar(&opts.ConfirmationReport, "confirmation-report", opts.ConfirmationReport, "also write the observed reversal probability per confirmation depth over all PoW simulations to this CSV file")
+	flag.Func("modes", "comma separated simulators every test runs: pow, dag, bft, raft, dpos, poa, committee, hybrid, rollup, swap, shard, sidechain, des (default pow,dag)", func(list string) error {
+		opts.Modes = strings.Split(list, ",")
+		return nil
+	})
+	flag.DurationVar(&c.BFTTimeout, "bft-timeout", c.BFTTimeout, "BFT step timeout in round 0, later rounds wait longer (0 = 20ms)")
+	flag.StringVar(&c.Schedule, "schedule", c.Schedule, "adversarial delivery of the BFT messages: random, split or proposals (default: none)")
+	flag.DurationVar(&c.Delta, "delta", c.Delta, "longest delay the -schedule adversary may put on a message")
+	flag.Uint64Var(&c.ScheduleSeed, "schedule-seed", c.ScheduleSeed, "seed of the -schedule adversary, the same seed replays the same schedule")
+	flag.Uint64Var(&c.Seed, "seed", c.Seed, "seed of the des simulator, the same seed gives the same run")
+	flag.DurationVar(&c.BlockTime, "block-time", c.BlockTime, "mean virtual time between the blocks of the des simulator (0 = 10s)")
+	flag.DurationVar(&c.Latency, "latency", c.Latency, "mean virtual delivery delay of the des simulator (0 = 1s)")
+	flag.Float64Var(&c.ChurnRate, "churn", c.ChurnRate, "probability that an honest pow node goes offline, or comes back, every time a block is mined")
+	flag.IntVar(&c.Joiners, "joiners", c.Joiners, "honest pow nodes that start offline and join through the churn (all join once every transaction is sent)")
+	flag.IntVar(&c.BanScore, "ban-score", c.BanScore, "misbehavior score at which an honest pow node bans a peer, every invalid block scores 10 (0 = no banning)")
+	flag.Float64Var(&c.InvalidBlocks, "invalid-blocks", c.InvalidBlocks, "probability that a corrupt pow node sends the honest nodes a forged block with every block it mines")
+	flag.StringVar(&c.Workload, "workload", c.Workload, "transactions of every round: uniform (default), poisson[:rate], zipf[:s], bursty[:k] or trace:<file> (CSV or JSON of round or timestamp, sender, receiver and amount)")
+	flag.BoolVar(&c.UTXO, "utxo", c.UTXO, "pow transactions spend the outputs of earlier ones, validated by the miners")
+	flag.StringVar(&c.Script, "script", c.Script, "script every pow transaction carries, evaluated by the miners: comma separated multisig:<m>-of-<n>, timelock:<h>, hashes:<k> or raw ops like \"SENDER SIGNED VERIFY\"")
+	flag.StringVar(&c.Channels, "channels", c.Channels, "n[:updates,window]: n pairs of pow nodes open payment channels, pay each other updates times per round off-chain (default 10) and settle on-chain, a corrupt party with a stale state that the other contests within window blocks (default 6)")
+	flag.StringVar(&c.Sequencer, "sequencer", c.Sequencer, "the sequencer of the rollup mode: honest, censor[:f] (leaves out honest users' transactions, default f=1) or withhold[:f] (withholds batch data, default f=0.5)")
+	flag.StringVar(&c.Swaps, "swaps", c.Swaps, "n[:timeout]: the swap mode swaps coins between a pow chain and a dag tangle n times (default 10) with hash-timelocks of timeout (default 500ms)")
+	flag.IntVar(&c.SwapDAGCorrupt, "swap-dag-corrupt", c.SwapDAGCorrupt, "corrupt nodes of the swap mode's dag tangle (-1 = as many as the pow chain's)")
+	flag.IntVar(&c.Shards, "shards", c.Shards, "pow chains of the shard mode, which the nodes are split into at random")
+	flag.Float64Var(&c.CrossShard, "cross-shard", c.CrossShard, "probability that a transaction of the shard mode pays a node of another shard, credited there by a receipt")
+	flag.IntVar(&c.SideDifficulty, "side-difficulty", c.SideDifficulty, "difficulty of the sidechain mode's sidechain (0 = one less than the main chain's, at least 1)")
+	flag.StringVar(&c.Checkpoints, "checkpoints", c.Checkpoints, "interval[:depth]: the sidechain commits the hash of every interval-th block (default 5) to the main chain once depth blocks deep (default 2)")
+	flag.Float64Var(&c.RBFRate, "rbf", c.RBFRate, "probability that the sender of a pow transaction replaces it with the next round, paying twice the fee")
+	flag.StringVar(&c.Amounts, "amounts", c.Amounts, "what every transaction transfers: fixed:<v>, lognormal[:mu,sigma] or pareto[:alpha[,xm]] (default none, a trace keeps its amounts)")
+	flag.StringVar(&c.Assets, "assets", c.Assets, "comma separated tokens every transaction moves one of, with optional weights, e.g. BTC:3,USDC (default a single currency)")
+	flag.StringVar(&c.LatencyMatrix, "latency-matrix", c.LatencyMatrix, "delay pow and des blocks between regions: continents, or a CSV file of one-way delays in ms (node i in region i mod rows)")
+	flag.Float64Var(&c.Bandwidth, "bandwidth", c.Bandwidth, "bytes per second of every pow and des link, blocks are 80 bytes plus 250 per transaction (0 = unlimited)")
+	flag.IntVar(&c.TxGossip, "tx-gossip", c.TxGossip, "start every pow transaction at its sender and relay it to this many random peers (0 = hand it to every node)")
+	flag.BoolVar(&c.FastMining, "fast-mining", c.FastMining, "sample PoW block times from an exponential distribution instead of hashing")
+	flag.Float64Var(&c.HashRate, "hashrate", c.HashRate, "hashes per second of every node with -fast-mining (0 = 1e6)")
+	flag.BoolVar(&c.VRFLeaders, "vrf-leaders", c.VRFLeaders, "elect BFT proposers by stake-weighted VRF sortition instead of round-robin")
+	flag.IntVar(&c.GrindAttempts, "grind", c.GrindAttempts, "seeds a corrupt VRF leader tries to make the next leader corrupt (0 = no grinding)")
+	flag.IntVar(&c.CommitteeSize, "committee-size", c.CommitteeSize, "nodes drawn to vote in each round of the committee simulator (0 = N/3)")
+	flag.Float64Var(&c.AttestQuorum, "attest-quorum", c.AttestQuorum, "share of all stake that has to attest a block of the hybrid simulator (0 = 2/3)")
+	flag.BoolVar(&c.NothingAtStake, "nothing-at-stake", c.NothingAtStake, "corrupt validators of the hybrid simulator attest the blocks of every fork, not only their own")
+	flag.Float64Var(&c.SlashFraction, "slash", c.SlashFraction, "share of its stake a hybrid validator loses for attesting two blocks at one height (0 = no slashing)")
+	flag.IntVar(&c.Sybils, "sybils", c.Sybils, "extra identities the corrupt nodes of pow, hybrid and poa spawn once the first block is mined")
+	flag.Float64Var(&c.BribeRate, "bribe", c.BribeRate, "probability that an honest pow miner takes the corrupt nodes' bribe and mines on the corrupt fork")
+	flag.Float64Var(&c.BribeFee, "bribe-fee", c.BribeFee, "bribe the corrupt nodes pay per block mined on their fork, in block rewards (0 = 1)")
+	flag.IntVar(&c.SpamRate, "spam", c.SpamRate, "tiny self-transactions every corrupt pow node floods the network with per round")
+	flag.IntVar(&c.MaxBlockTxs, "max-block-txs", c.MaxBlockTxs, "most transactions a pow or des block may include (0 = no limit)")
+	flag.StringVar(&c.Withhold, "withhold", c.Withhold, "when corrupt pow nodes release withheld blocks: private (default), lead:<k>, selfish, stubborn or stubborn-eq")
+	flag.IntVar(&c.LongRange, "long-range", c.LongRange, "end hybrid simulations with a long-range attack forking this many blocks below the tip (0 = no attack)")
+	flag.Float64Var(&c.ExitedStake, "exited-stake", c.ExitedStake, "share of all stake whose exited validators sold their keys to the long-range attacker (0 = every honest validator)")
+	flag.IntVar(&c.WSPeriod, "ws-period", c.WSPeriod, "new nodes trust the block this many blocks below the tip as weak-subjectivity checkpoint (0 = half of -long-range)")
+	flag.DurationVar(&c.RaftTimeout, "raft-timeout", c.RaftTimeout, "shortest Raft election timeout, heartbeats every quarter of it (0 = 20ms)")
+	flag.IntVar(&c.Delegates, "delegates", c.Delegates, "DPoS block producers elected by stake (0 = N/3)")
+	flag.DurationVar(&c.SlotTime, "slot-time", c.SlotTime, "time each DPoS delegate has to produce its block, and the PoA block period (0 = 5ms)")
+	flag.Float64Var(&c.CorruptStake, "corrupt-stake", c.CorruptStake, "share of all stake held by the corrupt nodes, 0 to 1 (0 = same stake for every node)")
+	flag.StringVar(&opts.Parquet, "parquet", opts.Parquet, "also write the results, the whole -out file, as Apache Parquet to this file when the run ends")
+	flag.StringVar(&opts.Long, "long", opts.Long, "also write the results in long format, one metric per row (config_id, sim_type, metric, value), to this CSV file")
+	flag.StringVar(&opts.TimeSeries, "timeseries", opts.TimeSeries, "also append a row per -timeseries-interval of every simulation (height, mempool, confirmed, forks) to this CSV file")
+	flag.DurationVar(&opts.TimeSeriesInterval, "timeseries-interval", opts.TimeSeriesInterval, "time between the rows of -timeseries")
+	flag.StringVar(&opts.NodeStats, "node-stats", opts.NodeStats, "also write per-node statistics (blocks mined/accepted, txs included, drops) to this CSV file")
+	flag.StringVar(&opts.BlockTree, "block-tree", opts.BlockTree, "also write the block tree of every simulation, forks and orphans included, to this directory")
+	flag.StringVar(&opts.BlockTreeFormat, "block-tree-format", opts.BlockTreeFormat, "format of -block-tree: dot (GraphViz, default) or mermaid")
+	flag.StringVar(&opts.EventsAddr, "events-ws", opts.EventsAddr, "stream the mined, accepted, reorg and confirmed events of the suite as JSON over a WebSocket at ws://<addr>/events")
+	flag.StringVar(&opts.Report, "report", opts.Report, "write an HTML report with tables and charts of the results file to this file once the suite stops")
+	flag.StringVar(&opts.Plots, "plots", opts.Plots, "write SVG charts of the results file (corrupt win rate vs corrupt %, latency vs p, ...) to this directory once the suite stops")
+	flag.StringVar(&opts.OTLPEndpoint, "otlp", opts.OTLPEndpoint, "export the lifecycle of every block as OpenTelemetry spans to this OTLP/HTTP collector (e.g. http://localhost:4318 for Jaeger)")
+	flag.StringVar(&opts.PprofAddr, "pprof", opts.PprofAddr, "serve the pprof endpoints at this address (e.g. localhost:6060) while the suite runs")
+	flag.BoolVar(&opts.Check, "check", opts.Check, "assert block, chain, DAG and balance invariants after every simulation, exit status 1 if one is violated")
+	flag.IntVar(&opts.Test, "test", opts.Test, "run only this test of the suite (1-based), e.g. to reproduce a -check violation")
+	flag.StringVar(&opts.Record, "record", opts.Record, "record the flags, every event and the result of every simulation to this trace file (JSON lines)")
+	flag.StringVar(&opts.Replay, "replay", opts.Replay, "replay the simulations of this trace with its recorded flags instead of running them (flags given here override them); des is rerun and compared with it")
+	flag.StringVar(&opts.ControlAddr, "control", opts.ControlAddr, "serve HTTP endpoints on this address (e.g. localhost:8082) to pause, step and resume the running simulation and inspect its state and block tree")
+	flag.BoolVar(&opts.Paused, "paused", opts.Paused, "with -control, start the suite paused")
+	flag.BoolVar(&opts.Repl, "repl", opts.Repl, "read commands from stdin while the suite runs (tx <from> <to> <value>, partition 0-4 | 5-9, heal, corrupt <node>, status) and apply them to the running PoW simulation")
+	flag.StringVar(&opts.TxAPIAddr, "tx-api", opts.TxAPIAddr, "serve POST /tx and GET /tx/{id} on this address (e.g. localhost:8083) to inject transactions into the running PoW simulation and poll their status")
+	flag.DurationVar(&opts.Hold, "hold", opts.Hold, "keep every PoW simulation taking transactions from -repl or -tx-api this long after its workload was sent")
+	flag.StringVar(&opts.Chains, "chains", opts.Chains, "also write the winning chain of every simulation to this directory, one JSON file each, for -explorer")
+	flag.StringVar(&explorerAddr, "explorer", "", "serve the chains in -chains at this address (e.g. localhost:8084) as a chain explorer API, instead of running the suite")
+	flag.StringVar(&opts.RPCAddr, "rpc", opts.RPCAddr, "serve a minimal Ethereum-style JSON-RPC API (eth_blockNumber, eth_getBlockByNumber, eth_sendTransaction, ...) on the running PoW simulation at this address (e.g. localhost:8545)")
+	flag.BoolVar(&opts.TUI, "tui", opts.TUI, "show a live dashboard of the suite in the terminal (progress, per-node heights, blocks, pending transactions) instead of the logs")
+	flag.StringVar(&webAddr, "web", "", "serve a browser UI at this address (e.g. localhost:8080) that runs single simulations, instead of running the suite")
+	level := slog.LevelInfo
+	flag.TextVar(&level, "log-level", level, "log level: debug, info, warn or error")
+	flag.Parse()
+	args := os.Args[1:]
+	if opts.Replay != "" {
+		recorded, err := sim.TraceArgs(opts.Replay)
+		if err != nil {
+			fail(fmt.Errorf("reading trace: %w", err))
+		}
+		args = append(recorded, args...) // the flags given now win
+		flag.CommandLine.Parse(args)
+	}
+	opts.Args, opts.Flags = args, flagValues(args)
+	c.NoMidstate = !midstate
+	sim.SetLogLevel(level)
+
+	// Ctrl-C / SIGTERM cancels the running simulation, finished tests are kept
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch {
+	case webAddr != "":
+		err = sim.ServeWeb(ctx, webAddr, opts)
+	case explorerAddr != "" && opts.Chains == "":
+		err = fmt.Errorf("explorer needs the chains directory, set -chains")
+	case explorerAddr != "":
+		err = sim.ServeExplorer(ctx, explorerAddr, opts.Chains)
+	default:
+		err = sim.Run(ctx, opts)
+	}
+	if err != nil {
+		stop()
+		fail(err)
+	}
+}
+
+// fail reports err and exits with status 1
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "tester:", err)
+	os.Exit(1)
+}
+
+// flagValues is the value of every flag after args were parsed. A flag.Func prints no value, so the
+// value given in args is taken for those, and "" if none was.
+func flagValues(args []string) map[string]string {
+	values := map[string]string{}
+	given := flag.NewFlagSet("", flag.ContinueOnError)
+	given.SetOutput(io.Discard)
+	flag.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			given.Bool(f.Name, false, "")
+		} else {
+			given.String(f.Name, "", "")
+		}
+	})
+	given.Parse(args)
+	given.Visit(func(f *flag.Flag) {
+		if values[f.Name] == "" {
+			values[f.Name] = f.Value.String()
+		}
+	})
+	return values
+}
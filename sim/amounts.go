@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// --- Transaction Amounts ---
+
+/*
+	What a payment transfers is its Amount, apart from its TxID (see txIDs). SimConfig.Amounts draws
+	the amounts of the workload's transactions from a distribution:
+	- fixed:<v>: every payment transfers v.
+	- lognormal[:mu,sigma]: exp of a normal with mean mu and deviation sigma (default 0,1, so a median
+	  of 1), the usual fit for retail payment sizes.
+	- pareto[:alpha[,xm]]: at least xm (default 1) with a power-law tail of exponent alpha (default
+	  1.16, where 20% of the payments carry 80% of the value), for whale-dominated chains.
+
+	A trace that gives amounts keeps them. Without SimConfig.Amounts the amounts stay 0. Value Sent and
+	Value Confirmed sum the amounts of the distinct transactions sent and confirmed, whatever side sent
+	them.
+*/
+
+// ValueStats reports the value of the transactions of a simulation
+type ValueStats struct {
+	Sent      Amount
+	Confirmed Amount
+	Percent   float64      // of the value sent
+	Assets    []AssetStats // with SimConfig.Assets or a trace with assets, see assets.go
+}
+
+// parseAmounts returns the distribution of spec drawing from rng (the global source if nil), nil for ""
+func parseAmounts(spec string, rng *rand.Rand) (func() float64, error) {
+	norm, exp := rand.NormFloat64, rand.ExpFloat64
+	if rng != nil {
+		norm, exp = rng.NormFloat64, rng.ExpFloat64
+	}
+	name, arg, _ := strings.Cut(spec, ":")
+	params := func(defaults ...float64) ([]float64, error) { // the comma separated numbers of arg
+		if arg == "" {
+			return defaults, nil
+		}
+		fields := strings.Split(arg, ",")
+		if len(fields) > len(defaults) {
+			return nil, fmt.Errorf("amounts %q: too many parameters", spec)
+		}
+		for k, field := range fields {
+			v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("amounts %q: %q is not a number", spec, field)
+			}
+			defaults[k] = v
+		}
+		return defaults, nil
+	}
+	switch name {
+	case "":
+		return nil, nil
+	case "fixed":
+		v, err := params(math.NaN())
+		if err != nil || math.IsNaN(v[0]) || v[0] <= 0 {
+			return nil, fmt.Errorf("amounts %q: fixed needs a positive amount", spec)
+		}
+		return func() float64 { return v[0] }, nil
+	case "lognormal":
+		v, err := params(0, 1)
+		if err != nil {
+			return nil, err
+		}
+		if v[1] < 0 {
+			return nil, fmt.Errorf("amounts %q: sigma must not be negative", spec)
+		}
+		return func() float64 { return math.Exp(v[0] + v[1]*norm()) }, nil
+	case "pareto":
+		v, err := params(1.16, 1)
+		if err != nil {
+			return nil, err
+		}
+		if v[0] <= 0 || v[1] <= 0 {
+			return nil, fmt.Errorf("amounts %q: alpha and xm must be positive", spec)
+		}
+		return func() float64 { return v[1] * math.Exp(exp()/v[0]) }, nil
+	}
+	return nil, fmt.Errorf("unknown amounts %q (fixed:<v>, lognormal[:mu,sigma] or pareto[:alpha[,xm]])", spec)
+}
+
+// withAmounts draws the amounts of w's transactions from cfg.Amounts
+func withAmounts(w Workload, cfg SimConfig, rng *rand.Rand) Workload {
+	draw, err := parseAmounts(cfg.Amounts, rng)
+	if err != nil || draw == nil {
+		return w
+	}
+	return mapWorkload(w, func(tx *Transaction) {
+		if tx.Amount == 0 { // a trace's amount stays
+			tx.Amount = amountOf(draw())
+		}
+	})
+}
+
+// valueTracker sums the value of the transactions sent and confirmed on a bus
+type valueTracker struct {
+	mu              sync.Mutex
+	sent, confirmed map[TxID]Transaction // by ID
+}
+
+func trackValue(bus *EventBus) *valueTracker {
+	t := &valueTracker{sent: make(map[TxID]Transaction), confirmed: make(map[TxID]Transaction)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if e.Kind == EventSent {
+			t.sent[e.Tx.TxID] = e.Tx
+		} else {
+			t.confirmed[e.Tx.TxID] = e.Tx
+		}
+	}, EventSent, EventConfirmed)
+	return t
+}
+
+func (t *valueTracker) result() ValueStats {
+	stats := ValueStats{Assets: assetStats(t.sent, t.confirmed)}
+	for _, tx := range t.sent {
+		stats.Sent += tx.Amount
+	}
+	for _, tx := range t.confirmed {
+		stats.Confirmed += tx.Amount
+	}
+	if stats.Sent > 0 {
+		stats.Percent = 100 * stats.Confirmed.Float() / stats.Sent.Float()
+	}
+	return stats
+}
+
+func printValueStats(stats ValueStats) {
+	if stats.Sent > 0 {
+		fmt.Printf("Value sent         = %.2f\n", stats.Sent.Float())
+		fmt.Printf("Value confirmed    = %.2f (%.2f%%)\n", stats.Confirmed.Float(), stats.Percent)
+	}
+	printAssetStats(stats.Assets)
+}
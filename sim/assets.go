@@ -0,0 +1,157 @@
+package sim
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --- Assets ---
+
+/*
+	By default every transaction moves the same currency. SimConfig.Assets lets several tokens circulate
+	at once: a comma separated list of names with optional weights, e.g. "BTC:3,USDC,GOLD:0.5", and
+	every transaction of the workload moves one of them, drawn by weight (a trace's asset column wins).
+	The simulators treat assets alike, so the per-asset statistics show whether the consensus favours
+	none of them, and with a trace how each token's traffic fares.
+
+	Every asset has its own balances: the tracker nets each node's confirmed payments per asset (the
+	transaction's Value, 1 if it has none), and with SimConfig.UTXO every node starts out with
+	utxoFunds of every listed asset, and a transaction may only spend outputs of its own asset (so a
+	trace's assets need to be listed too, or their payments go unfunded).
+*/
+
+// AssetStats reports the transactions of one asset
+type AssetStats struct {
+	Asset     string
+	Sent      int
+	Confirmed int
+	Percent   float64
+	Volume    Amount            // value of the confirmed transactions
+	Balances  map[string]Amount // node -> net confirmed payments received
+}
+
+// parseAssets returns the names and weights of spec, nil for ""
+func parseAssets(spec string) ([]string, []float64, error) {
+	if spec == "" {
+		return nil, nil, nil
+	}
+	names, weights := []string{}, []float64{}
+	for _, part := range strings.Split(spec, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(part), ":")
+		w := 1.0
+		if found {
+			v, err := strconv.ParseFloat(weight, 64)
+			if err != nil || !(v > 0) || math.IsInf(v, 0) {
+				return nil, nil, fmt.Errorf("assets %q: %q is not a positive weight", spec, weight)
+			}
+			w = v
+		}
+		if name == "" || slices.Contains(names, name) {
+			return nil, nil, fmt.Errorf("assets %q: every asset needs a name of its own", spec)
+		}
+		names, weights = append(names, name), append(weights, w)
+	}
+	return names, weights, nil
+}
+
+// withAssets gives every transaction of w one of the assets of cfg.Assets, drawing from rng (the global
+// source if nil)
+func withAssets(w Workload, cfg SimConfig, rng *rand.Rand) Workload {
+	names, weights, err := parseAssets(cfg.Assets)
+	if err != nil || names == nil {
+		return w
+	}
+	float := rand.Float64
+	if rng != nil {
+		float = rng.Float64
+	}
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	return mapWorkload(w, func(tx *Transaction) {
+		if tx.Asset != "" { // a trace's asset stays
+			return
+		}
+		x := float() * total
+		for k, weight := range weights {
+			if x < weight || k == len(weights)-1 {
+				tx.Asset = names[k]
+				return
+			}
+			x -= weight
+		}
+	})
+}
+
+// assetStats splits the sent and confirmed transactions (by ID) by asset, nil if none has an asset
+func assetStats(sent, confirmed map[TxID]Transaction) []AssetStats {
+	index := make(map[string]int)
+	stats := []AssetStats{}
+	of := func(tx Transaction) *AssetStats {
+		k, ok := index[tx.Asset]
+		if !ok {
+			k = len(stats)
+			index[tx.Asset] = k
+			stats = append(stats, AssetStats{Asset: tx.Asset, Balances: make(map[string]Amount)})
+		}
+		return &stats[k]
+	}
+	for _, tx := range sent {
+		of(tx).Sent++
+	}
+	for id, tx := range confirmed {
+		if _, ok := sent[id]; !ok { // not from the workload, e.g. a committee's own transactions
+			continue
+		}
+		s := of(tx)
+		v := tx.Amount
+		if v == 0 {
+			v = amountScale
+		}
+		s.Confirmed++
+		s.Volume += v
+		s.Balances[tx.Sender] -= v
+		s.Balances[tx.Receiver] += v
+	}
+	if len(stats) == 1 && stats[0].Asset == "" {
+		return nil
+	}
+	for k := range stats {
+		if stats[k].Sent > 0 {
+			stats[k].Percent = getPercentage(stats[k].Confirmed, stats[k].Sent)
+		}
+	}
+	slices.SortFunc(stats, func(a, b AssetStats) int { return strings.Compare(a.Asset, b.Asset) })
+	return stats
+}
+
+// formatAssets writes every asset's confirmed and sent transactions, e.g. "BTC=80/90 USDC=25/30"
+func formatAssets(stats []AssetStats) string {
+	parts := []string{}
+	for _, a := range stats {
+		parts = append(parts, fmt.Sprintf("%s=%d/%d", cmp.Or(a.Asset, "-"), a.Confirmed, a.Sent))
+	}
+	return strings.Join(parts, " ")
+}
+
+func printAssetStats(stats []AssetStats) {
+	for _, a := range stats {
+		line := fmt.Sprintf("Asset %-12s = %d/%d confirmed (%.2f%%), volume %.2f", cmp.Or(a.Asset, "-"), a.Confirmed, a.Sent, a.Percent, a.Volume.Float())
+		richest, most := "", Amount(math.MinInt64)
+		for node, balance := range a.Balances {
+			if richest == "" || balance > most || balance == most && node < richest {
+				richest, most = node, balance
+			}
+		}
+		if richest != "" {
+			line += fmt.Sprintf(", top balance %s %.2f", richest, most.Float())
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,254 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Avalanche ---
+
+/*
+	With SimConfig.Avalanche the DAG nodes settle double spends (see conflicts.go) by repeated
+	subsampled voting (Snowball) instead of keeping the heavier side on their own. Once every node
+	has built its view, each honest node starts out preferring the side with the larger cumulative
+	weight and then, in rounds, asks K random peers (AvalancheK, default 10) which side of each
+	undecided conflict set they prefer:
+	  - if at least alpha = 2K/3+1 of them answer the same side, that side gets a vote, and the node
+	    switches its preference to it once it has more votes than the current preference
+	  - after Beta consecutive rounds (AvalancheBeta, default 15) with a majority for the same side
+	    the node decides that side; any round without a majority resets the count
+	A query reads the peer's current preference directly, standing in for a request / response
+	round trip, so the nodes vote concurrently and see each other flip. A node that never saw a conflict
+	set starts without a preference and adopts the first side with a majority.
+
+	Corrupt nodes do not vote themselves. Asked about a conflict set they answer any side other than
+	the asking node's preference, trying to keep the honest nodes from reaching a majority. A conflict
+	set is finalized once every honest node decided it; nodes give up after AvalancheMaxRounds.
+*/
+
+const AvalancheMaxRounds = 500 // a node stops querying after this many rounds
+
+// AvalancheStats reports how the honest nodes settled the conflict sets by subsampled voting
+type AvalancheStats struct {
+	Conflicts       int           // conflict sets any node saw
+	Finalized       int           // conflict sets every honest node decided
+	Undecided       int           // conflict sets at least one honest node never decided
+	Split           int           // conflict sets honest nodes decided differently
+	AvgRounds       float64       // query rounds until a decision, over all honest decisions
+	MaxRounds       int           // most query rounds a decision took
+	ConvergenceTime time.Duration // from the start of voting to the last honest decision
+}
+
+// avalanche holds the preferences of every node, which the other nodes query
+type avalanche struct {
+	N, C, k, alpha, beta int
+	joined               sync.WaitGroup
+	mu                   []sync.Mutex
+	prefs                []map[TxID]string // node -> TxID -> preferred side (receiver)
+	sidesMu              sync.Mutex
+	sides                map[TxID][]string // TxID -> every side any node saw
+	started              sync.Once
+	votingStarted        time.Time
+}
+
+func newAvalanche(cfg SimConfig) *avalanche {
+	k := cfg.AvalancheK
+	if k <= 0 {
+		k = 10
+	}
+	k = max(1, min(k, cfg.N-1))
+	beta := cfg.AvalancheBeta
+	if beta <= 0 {
+		beta = 15
+	}
+	a := &avalanche{
+		N: cfg.N, C: cfg.C, k: k, alpha: min(k, 2*k/3+1), beta: beta,
+		mu:    make([]sync.Mutex, cfg.N),
+		prefs: make([]map[TxID]string, cfg.N),
+		sides: make(map[TxID][]string),
+	}
+	a.joined.Add(cfg.N)
+	return a
+}
+
+// join registers node i's conflict sets and initial preferences, then waits until every node did
+func (a *avalanche) join(i int, sides map[TxID]map[string][]string, prefs map[TxID]string) {
+	a.mu[i].Lock()
+	a.prefs[i] = maps.Clone(prefs)
+	a.mu[i].Unlock()
+	a.sidesMu.Lock()
+	for id, set := range sides {
+		for receiver := range set {
+			if !slices.Contains(a.sides[id], receiver) {
+				a.sides[id] = append(a.sides[id], receiver)
+			}
+		}
+	}
+	a.sidesMu.Unlock()
+	a.joined.Done()
+	a.joined.Wait()
+	a.started.Do(func() { a.votingStarted = time.Now() })
+}
+
+// resolve settles the conflict sets of node i's view by voting and returns the Hashes of the losing vertices,
+// like resolveConflicts
+func (a *avalanche) resolve(ctx context.Context, i int, HashMap map[string]Transaction, publish func(Event)) map[string]bool {
+	sides := conflictSets(HashMap)
+	a.join(i, sides, heaviestSides(HashMap, sides))
+	winners := a.vote(ctx, i, publish)
+	spends := spendSides(HashMap) // also drop a side the view holds alone if the vote went the other way
+	maps.DeleteFunc(spends, func(id TxID, _ map[string][]string) bool { return winners[id] == "" })
+	return dropLosers(HashMap, spends, winners, publish)
+}
+
+// answer is node j's reply to a query about conflict set id from a node preferring asker ("" = no answer)
+func (a *avalanche) answer(j int, id TxID, asker string) string {
+	if j < a.C {
+		for _, side := range a.sides[id] {
+			if side != asker {
+				return side
+			}
+		}
+		return ""
+	}
+	a.mu[j].Lock()
+	defer a.mu[j].Unlock()
+	return a.prefs[j][id]
+}
+
+// vote runs Snowball for honest node i over every conflict set and returns its final preferences.
+// Each decision is published as an EventDecided carrying the decided side and the rounds it took.
+func (a *avalanche) vote(ctx context.Context, i int, publish func(Event)) map[TxID]string {
+	a.mu[i].Lock()
+	prefs := maps.Clone(a.prefs[i])
+	a.mu[i].Unlock()
+	if i < a.C {
+		return prefs
+	}
+
+	type snowball struct {
+		votes   map[string]int
+		last    string
+		streak  int
+		decided bool
+	}
+	state := make(map[TxID]*snowball)
+	for id := range a.sides {
+		state[id] = &snowball{votes: make(map[string]int)}
+	}
+	undecided := len(state)
+	for round := 1; undecided > 0 && round <= AvalancheMaxRounds && ctx.Err() == nil; round++ {
+		for _, id := range slices.Sorted(maps.Keys(state)) {
+			s := state[id]
+			if s.decided {
+				continue
+			}
+			answers := make(map[string]int)
+			peers := rand.Perm(a.N - 1)[:a.k]
+			for _, j := range peers {
+				if j >= i { // skip node i itself
+					j++
+				}
+				if side := a.answer(j, id, prefs[id]); side != "" {
+					answers[side]++
+				}
+			}
+			majority := ""
+			for side, n := range answers {
+				if n >= a.alpha {
+					majority = side
+				}
+			}
+			if majority == "" {
+				s.streak = 0
+				continue
+			}
+			s.votes[majority]++
+			if prefs[id] == "" || s.votes[majority] > s.votes[prefs[id]] {
+				prefs[id] = majority
+				a.mu[i].Lock()
+				a.prefs[i][id] = majority
+				a.mu[i].Unlock()
+			}
+			if majority == s.last {
+				s.streak++
+			} else {
+				s.last, s.streak = majority, 1
+			}
+			if s.streak >= a.beta {
+				s.decided = true
+				undecided--
+				publish(Event{Kind: EventDecided, Tx: Transaction{TxID: id, Receiver: prefs[id]}, Round: round})
+			}
+		}
+	}
+	return prefs
+}
+
+// avalancheTracker collects the EventDecided decisions of the honest nodes
+type avalancheTracker struct {
+	mu        sync.Mutex
+	decisions map[TxID]map[string]int // TxID -> side -> honest nodes that decided it
+	rounds    []int
+	last      time.Time
+}
+
+func trackAvalanche(bus *EventBus) *avalancheTracker {
+	t := &avalancheTracker{decisions: make(map[TxID]map[string]int)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.decisions[e.Tx.TxID] == nil {
+			t.decisions[e.Tx.TxID] = make(map[string]int)
+		}
+		t.decisions[e.Tx.TxID][e.Tx.Receiver]++
+		t.rounds = append(t.rounds, e.Round)
+		t.last = e.Time
+	}, EventDecided)
+	return t
+}
+
+// result counts the outcome of every conflict set any node saw
+func (t *avalancheTracker) result(a *avalanche) AvalancheStats {
+	stats := AvalancheStats{Conflicts: len(a.sides)}
+	honest := a.N - a.C
+	for id := range a.sides {
+		decided := 0
+		for _, n := range t.decisions[id] {
+			decided += n
+		}
+		if decided < honest {
+			stats.Undecided++
+		} else {
+			stats.Finalized++
+		}
+		if len(t.decisions[id]) > 1 {
+			stats.Split++
+		}
+	}
+	total := 0
+	for _, r := range t.rounds {
+		total += r
+		stats.MaxRounds = max(stats.MaxRounds, r)
+	}
+	if len(t.rounds) > 0 {
+		stats.AvgRounds = float64(total) / float64(len(t.rounds))
+		stats.ConvergenceTime = t.last.Sub(a.votingStarted)
+	}
+	return stats
+}
+
+func printAvalancheStats(stats AvalancheStats) {
+	fmt.Println("Conflict sets      =", stats.Conflicts)
+	fmt.Println("Finalized          =", stats.Finalized)
+	fmt.Println("Undecided          =", stats.Undecided)
+	fmt.Println("Split decisions    =", stats.Split)
+	fmt.Printf("Avg query rounds   = %.1f\n", stats.AvgRounds)
+	fmt.Println("Max query rounds   =", stats.MaxRounds)
+	fmt.Println("Convergence time   =", stats.ConvergenceTime)
+}
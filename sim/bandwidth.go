@@ -0,0 +1,116 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// --- Block Propagation ---
+
+/*
+	With a latency matrix (see latencymatrix.go) or SimConfig.Bandwidth a PoW block no longer reaches
+	a peer the moment it is sent: it takes the delay between the regions of the two nodes plus the time
+	to push its bytes through the link, size / Bandwidth bytes per second. A block is blockHeaderBytes
+	plus txBytes per transaction, roughly Bitcoin's sizes, so big blocks propagate slower. Every link
+	has the full bandwidth to itself. The discrete-event engine uses the same delays in virtual time.
+
+	A delayed block is never dropped, it waits for room in the receiver's channel. The propagation time
+	of a block is how long it takes to reach the last peer it was sent to. Bigger blocks carry more
+	transactions per block but spend longer in flight, during which other nodes mine on a stale head:
+	a block size limit (SimConfig.MaxBlockTxs) trades throughput against forks.
+*/
+
+// blockHeaderBytes and txBytes size a block on the wire
+const (
+	blockHeaderBytes = 80
+	txBytes          = 250
+)
+
+// blockSize is the size of b on the wire in bytes
+func blockSize(b Block) int {
+	return blockHeaderBytes + txBytes*len(b.Transactions)
+}
+
+// PropagationStats reports how long blocks took to reach their peers
+type PropagationStats struct {
+	Bandwidth      float64       // bytes per second per link, 0 = unlimited
+	Blocks         int           // blocks sent with a delay
+	AvgBlockBytes  float64       // average size of those blocks
+	AvgPropagation time.Duration // average time for a block to reach its last peer
+	MaxPropagation time.Duration
+}
+
+// delayedLinks hands blocks over after the delay of their link
+type delayedLinks struct {
+	matrix    *latencyMatrix
+	bandwidth float64
+	inflight  sync.WaitGroup
+	mu        sync.Mutex
+	stats     PropagationStats
+	total     time.Duration
+	bytes     int
+}
+
+func newDelayedLinks(cfg SimConfig) *delayedLinks {
+	return &delayedLinks{matrix: newLatencyMatrix(cfg), bandwidth: max(0, cfg.Bandwidth), stats: PropagationStats{Bandwidth: max(0, cfg.Bandwidth)}}
+}
+
+// delayed is the time b takes from node i to node j
+func (l *delayedLinks) delayed(i, j int, b Block) time.Duration {
+	d := l.matrix.between(i, j)
+	if l.bandwidth > 0 {
+		d += time.Duration(float64(blockSize(b)) / l.bandwidth * float64(time.Second))
+	}
+	return d
+}
+
+// send hands b to receiver after delay d, or gives up once ctx is cancelled
+func (l *delayedLinks) send(ctx context.Context, d time.Duration, receiver chan Block, b Block) {
+	l.inflight.Add(1)
+	time.AfterFunc(d, func() {
+		defer l.inflight.Done()
+		select {
+		case receiver <- b:
+		case <-ctx.Done():
+		}
+	})
+}
+
+// record adds a block that took slowest to reach its last peer
+func (l *delayedLinks) record(b Block, slowest time.Duration) {
+	if slowest <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Blocks++
+	l.bytes += blockSize(b)
+	l.total += slowest
+	l.stats.MaxPropagation = max(l.stats.MaxPropagation, slowest)
+}
+
+// wait blocks until every block sent has been delivered
+func (l *delayedLinks) wait() {
+	l.inflight.Wait()
+}
+
+func (l *delayedLinks) result() PropagationStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	if stats.Blocks > 0 {
+		stats.AvgBlockBytes = float64(l.bytes) / float64(stats.Blocks)
+		stats.AvgPropagation = l.total / time.Duration(stats.Blocks)
+	}
+	return stats
+}
+
+func printPropagationStats(stats PropagationStats) {
+	fmt.Println("Bandwidth (B/s)    =", stats.Bandwidth)
+	fmt.Println("Delayed blocks     =", stats.Blocks)
+	fmt.Println("Avg block bytes    =", stats.AvgBlockBytes)
+	fmt.Println("Avg propagation    =", stats.AvgPropagation)
+	fmt.Println("Max propagation    =", stats.MaxPropagation)
+}
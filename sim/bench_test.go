@@ -0,0 +1,106 @@
+package sim
+
+import (
+	"context"
+	"testing"
+)
+
+// The benchmarks of the hot paths: hashing a block and a DAG transaction, mining each at difficulty 2
+// (256 hashes on average) with every hash function and with SHA-256 hashing the whole encoding per
+// nonce (whole, see midstate.go), and a small PoW and DAG simulation. On a single-core amd64 VM
+// (go1.27, ns/op):
+//
+//	CalculateHash                     1700
+//	ComputeHash                        500
+//	MineBlock/sha256                 42000
+//	MineBlock/sha256-whole          240000
+//	MineBlock/sha3                 1200000
+//	MineBlock/blake2b               440000
+//	MineTransaction/sha256           30000
+//	MineTransaction/sha256-whole     72000
+//	MineTransaction/sha3            330000
+//	MineTransaction/blake2b         120000
+//	SimulatePoWSmall                250000
+//	SimulateDAGSmall               1000000
+//
+// Compare a change with these numbers from the same machine, e.g. with benchstat.
+
+// benchTransaction is a DAG transaction approving two parents
+func benchTransaction() Transaction {
+	return Transaction{TxID: 101, Sender: "honest1", Receiver: "honest2", Parents: []string{
+		"00a1b2c3d4e5f60718293a4b5c6d7e8f00a1b2c3d4e5f60718293a4b5c6d7e8f", "00f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f"}}
+}
+
+// benchHashers are the hash functions the mining benchmarks run with, sha256-whole without the midstate
+var benchHashers = []struct {
+	name string
+	cfg  SimConfig
+}{
+	{"sha256", SimConfig{}},
+	{"sha256-whole", SimConfig{NoMidstate: true}},
+	{"sha3", SimConfig{Hasher: sha3Hasher{}}},
+	{"blake2b", SimConfig{Hasher: blake2bHasher{}}},
+}
+
+func BenchmarkCalculateHash(b *testing.B) {
+	b.ReportAllocs()
+	h, block := SimConfig{}.hashing(), benchBlock()
+	for k := range b.N {
+		block.Nonce = k
+		calculateHash(h, block)
+	}
+}
+
+func BenchmarkComputeHash(b *testing.B) {
+	b.ReportAllocs()
+	h, tx := SimConfig{}.hashing(), benchTransaction()
+	for k := range b.N {
+		tx.Nonce = k
+		computeHash(h, tx)
+	}
+}
+
+func BenchmarkMineBlock(b *testing.B) {
+	for _, bh := range benchHashers {
+		b.Run(bh.name, func(b *testing.B) {
+			b.ReportAllocs()
+			h, block := bh.cfg.hashing(), benchBlock()
+			for range b.N {
+				block.Timestamp++ // a new block, a new nonce search
+				block.Nonce = 0
+				mineBlock(context.Background(), h, block, 2)
+			}
+		})
+	}
+}
+
+func BenchmarkMineTransaction(b *testing.B) {
+	for _, bh := range benchHashers {
+		b.Run(bh.name, func(b *testing.B) {
+			b.ReportAllocs()
+			h, tx := bh.cfg.hashing(), benchTransaction()
+			for k := range b.N {
+				tx.TxID = TxID(k)
+				tx.Nonce = 0
+				mineTransaction(context.Background(), h, tx, 2)
+			}
+		})
+	}
+}
+
+// benchSimulation runs the simulation of mode with N=5, C=1, R=1, D=1, p=0.8 b.N times
+func benchSimulation(b *testing.B, mode string) {
+	b.ReportAllocs()
+	cfg := SimConfig{N: 5, C: 1, R: 1, D: 1, P: 0.8}
+	for range b.N {
+		simulators[mode](context.Background(), cfg)
+	}
+}
+
+func BenchmarkSimulatePoWSmall(b *testing.B) {
+	benchSimulation(b, "pow")
+}
+
+func BenchmarkSimulateDAGSmall(b *testing.B) {
+	benchSimulation(b, "dag")
+}
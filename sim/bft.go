@@ -0,0 +1,572 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Tendermint-style BFT ---
+
+/*
+	SimulateBFTCtx runs the nodes as validators of a single chain that is agreed on height by height,
+	in rounds of propose / prevote / precommit (Tendermint). The proposer of round r at height h is
+	node (h + r) mod N, or with SimConfig.VRFLeaders elected by stake (see vrf.go). With f = (N-1)/3 tolerated faults a quorum is 2f+1 votes:
+	  - the proposer broadcasts a block with its pending transactions (or the block it is locked on)
+	  - a node prevotes for the first proposal of the round, or nil if it is locked on another block or
+	    the proposal does not extend its chain
+	  - on a quorum of prevotes for a block that extends its chain a node locks on it and precommits it
+	  - on a quorum of precommits for a block, in any round, a node commits it and moves to the next height
+	Each step times out after BFTTimeout * (round + 1), voting nil or starting the next round, so a
+	silent proposer or a split vote costs a round (a liveness stall) but never blocks the chain.
+
+	Corrupt nodes equivocate: as proposer they send one block to half of the honest validators and a
+	conflicting one to the other half (and both to each other), and they prevote and precommit every
+	proposal they see.
+	Honest nodes committing different blocks at the same height is a safety violation, which takes
+	more than f corrupt nodes. So is a quorum deciding a block that does not extend an honest node's
+	chain, which happens once the honest nodes are split: the node refuses the block and halts, as
+	a Tendermint node does on a consensus failure, rather than commit a block it never saw the parent
+	of.
+
+	Messages go through mailboxes and are never dropped, but with SimConfig.Schedule an adversary
+	delays them (see scheduler.go). Once every sent transaction is committed, or
+	nothing new was committed for 50 timeouts after the last round of transactions, the nodes stop.
+*/
+
+type bftStep int
+
+const (
+	bftPropose bftStep = iota
+	bftPrevote
+	bftPrecommit
+)
+
+// bftMsg is a proposal or a vote. Votes carry the whole block so every node can commit it, a nil vote has no Hash.
+type bftMsg struct {
+	Step   bftStep
+	Height int
+	Round  int
+	From   int
+	Block  Block
+}
+
+type bftKey struct {
+	Height int
+	Round  int
+	Step   bftStep
+}
+
+// BFTStats reports the consensus rounds of a BFT simulation
+type BFTStats struct {
+	Heights          int // heights committed by honest nodes
+	Rounds           int // rounds needed over all committed heights
+	Stalls           int // rounds that ended without a commit, including the height still open at the end
+	MaxRounds        int // most rounds one height needed
+	Equivocations    int // conflicting proposals and votes sent by corrupt nodes
+	SafetyViolations int // heights at which honest nodes committed different blocks or refused the decided one
+}
+
+// bftQuorum is 2f+1 for the largest f with 3f < N
+func bftQuorum(N int) int {
+	return 2*((N-1)/3) + 1
+}
+
+// bftTimeout resolves cfg.BFTTimeout against the default
+func bftTimeout(cfg SimConfig) time.Duration {
+	if cfg.BFTTimeout <= 0 {
+		return 20 * time.Millisecond
+	}
+	return cfg.BFTTimeout
+}
+
+// bftTally collects the messages a node received for the heights it has not committed yet
+type bftTally struct {
+	blocks map[int]map[string]Block           // height -> Hash -> block of every proposal and vote
+	first  map[bftKey]string                  // first proposal of a round
+	votes  map[bftKey]map[string]map[int]bool // prevotes and precommits: Hash -> voters
+}
+
+func newBFTTally() *bftTally {
+	return &bftTally{
+		blocks: make(map[int]map[string]Block),
+		first:  make(map[bftKey]string),
+		votes:  make(map[bftKey]map[string]map[int]bool),
+	}
+}
+
+func (t *bftTally) add(m bftMsg) {
+	k := bftKey{m.Height, m.Round, m.Step}
+	if m.Block.Hash != "" {
+		if t.blocks[m.Height] == nil {
+			t.blocks[m.Height] = make(map[string]Block)
+		}
+		t.blocks[m.Height][m.Block.Hash] = m.Block
+	}
+	if m.Step == bftPropose {
+		if _, seen := t.first[k]; !seen {
+			t.first[k] = m.Block.Hash
+		}
+		return
+	}
+	if t.votes[k] == nil {
+		t.votes[k] = make(map[string]map[int]bool)
+	}
+	if t.votes[k][m.Block.Hash] == nil {
+		t.votes[k][m.Block.Hash] = make(map[int]bool)
+	}
+	t.votes[k][m.Block.Hash][m.From] = true
+}
+
+// quorum returns the Hash ("" for nil) that has at least q votes in k
+func (t *bftTally) quorum(k bftKey, q int) (string, bool) {
+	for hash, voters := range t.votes[k] {
+		if len(voters) >= q {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+// decided returns a block with a quorum of precommits in any round of height
+func (t *bftTally) decided(height, q int) (Block, int, bool) {
+	for k := range t.votes {
+		if k.Height != height || k.Step != bftPrecommit {
+			continue
+		}
+		if hash, ok := t.quorum(k, q); ok && hash != "" {
+			if b, known := t.blocks[height][hash]; known {
+				return b, k.Round, true
+			}
+		}
+	}
+	return Block{}, 0, false
+}
+
+// prune forgets everything below height
+func (t *bftTally) prune(height int) {
+	for k := range t.first {
+		if k.Height < height {
+			delete(t.first, k)
+		}
+	}
+	for k := range t.votes {
+		if k.Height < height {
+			delete(t.votes, k)
+		}
+	}
+	for h := range t.blocks {
+		if h < height {
+			delete(t.blocks, h)
+		}
+	}
+}
+
+func SimulateBFTCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "bft")
+	defer closeBus()
+
+	start := time.Now()
+	nodeCtx, stopNodes := context.WithCancel(ctx)
+	defer stopNodes()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan []bftMsg, N)
+	queues := make([]*mailbox[bftMsg], N)
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)
+		receivers[i] = make(chan []bftMsg)
+		queues[i] = newMailbox(receivers[i])
+	}
+	G := createGenesisBlock(cfg.hashing(), 0)
+	names := nodeNames(N, C)
+	q := bftQuorum(N)
+	timeout := bftTimeout(cfg)
+	var equivocations atomic.Int64
+	sched := newScheduler(cfg)
+	var election *vrfElection // only set if proposers are elected by VRF
+	if cfg.VRFLeaders {
+		election = newVRFElection(nodeStakes(N, C, cfg), C, cfg.GrindAttempts)
+	}
+	proposer := func(height, round int) int {
+		if election != nil {
+			return election.leader(height, round)
+		}
+		return (height + round) % N
+	}
+
+	var winnerMu sync.Mutex
+	var winner = []Block{G}
+	var winnerType = ""
+	bus.Subscribe(func(e Event) {
+		winnerMu.Lock()
+		defer winnerMu.Unlock()
+		if len(e.Chain) > len(winner) {
+			winner = e.Chain
+			winnerType = getLabel(nodeIndex(e.Node, C), C)
+		}
+	}, EventNodeDone)
+
+	for i := range N {
+		go func() {
+			defer wg.Done()
+			publish := func(e Event) {
+				e.Sim, e.Node = "BFT", names[i]
+				bus.Publish(e)
+			}
+			corrupt := getLabel(i, C) == "corrupt"
+			inbox, receiver := inboxes[i], receivers[i]
+			tally := newBFTTally()
+			chain := []Block{G}
+			pending := []Transaction{}
+			committed := make(map[TxID]bool)
+			height, round, step := 1, 0, bftPropose
+			var locked Block                  // no Hash = not locked
+			halted := false                   // a quorum decided a block that does not extend chain
+			sent := make(map[bftKey][]string) // corrupt: blocks already voted for
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			send := func(j int, m bftMsg) {
+				m.From = i
+				if sched != nil {
+					sched.deliver(i, j, m, func() { queues[j].send(m) })
+					return
+				}
+				queues[j].send(m)
+			}
+			broadcast := func(m bftMsg) {
+				for j := range N {
+					send(j, m)
+				}
+			}
+			vote := func(s bftStep, b Block) { // corrupt nodes vote when proposals arrive instead
+				if !corrupt {
+					broadcast(bftMsg{Step: s, Height: height, Round: round, Block: b})
+				}
+			}
+			enter := func(s bftStep) {
+				step = s
+				timer.Reset(timeout * time.Duration(round+1))
+			}
+			startRound := func(r int) {
+				round = r
+				enter(bftPropose)
+				if proposer(height, round) != i {
+					return
+				}
+				b := locked
+				if b.Hash == "" {
+					b = Block{Transactions: slices.Clone(pending), PrevHash: chain[len(chain)-1].Hash, Timestamp: time.Now().UnixNano(), Miner: names[i]}
+					b.Hash = calculateHash(cfg.hashing(), b)
+				}
+				publish(Event{Kind: EventMined, Block: b, Height: height})
+				if !corrupt {
+					broadcast(bftMsg{Step: bftPropose, Height: height, Round: round, Block: b})
+					return
+				}
+				twin := b // same height, different block
+				twin.Timestamp++
+				twin.Hash = calculateHash(cfg.hashing(), twin)
+				equivocations.Add(1)
+				for j := range N { // colluding corrupt nodes get both
+					if j%2 == 0 || getLabel(j, C) == "corrupt" {
+						send(j, bftMsg{Step: bftPropose, Height: height, Round: round, Block: b})
+					}
+					if j%2 == 1 || getLabel(j, C) == "corrupt" {
+						send(j, bftMsg{Step: bftPropose, Height: height, Round: round, Block: twin})
+					}
+				}
+			}
+			commit := func(b Block, r int) {
+				chain = append(chain, b)
+				for _, tx := range b.Transactions {
+					committed[tx.TxID] = true
+				}
+				pending = slices.DeleteFunc(pending, func(tx Transaction) bool { return committed[tx.TxID] })
+				publish(Event{Kind: EventCommit, Block: b, Height: height, Round: r})
+				locked = Block{}
+				height++
+				tally.prune(height)
+				clear(sent)
+				startRound(0)
+			}
+			equivocate := func(m bftMsg) { // corrupt: prevote and precommit every proposal of the current height
+				for _, s := range []bftStep{bftPrevote, bftPrecommit} {
+					k := bftKey{m.Height, m.Round, s}
+					if slices.Contains(sent[k], m.Block.Hash) {
+						continue
+					}
+					if len(sent[k]) > 0 {
+						equivocations.Add(1)
+					}
+					sent[k] = append(sent[k], m.Block.Hash)
+					broadcast(bftMsg{Step: s, Height: m.Height, Round: m.Round, Block: m.Block})
+				}
+			}
+			extends := func(b Block) bool {
+				return b.PrevHash == chain[len(chain)-1].Hash
+			}
+			advance := func() { // take every step the received messages allow
+				for !halted {
+					if b, r, ok := tally.decided(height, q); ok {
+						if !extends(b) {
+							publish(Event{Kind: EventRejected, Block: b, Height: height, Round: r})
+							halted = true
+							return
+						}
+						commit(b, r)
+						continue
+					}
+					switch step {
+					case bftPropose:
+						hash, ok := tally.first[bftKey{height, round, bftPropose}]
+						if !ok {
+							return
+						}
+						if locked.Hash != "" && locked.Hash != hash || !extends(tally.blocks[height][hash]) {
+							vote(bftPrevote, Block{})
+						} else {
+							vote(bftPrevote, tally.blocks[height][hash])
+						}
+						enter(bftPrevote)
+					case bftPrevote:
+						hash, ok := tally.quorum(bftKey{height, round, bftPrevote}, q)
+						if !ok {
+							return
+						}
+						b := tally.blocks[height][hash]
+						if hash != "" && !extends(b) {
+							b = Block{}
+						} else if hash != "" {
+							locked = b
+						}
+						vote(bftPrecommit, b)
+						enter(bftPrecommit)
+					case bftPrecommit:
+						hash, ok := tally.quorum(bftKey{height, round, bftPrecommit}, q)
+						if !ok || hash != "" { // a block quorum is committed above
+							return
+						}
+						startRound(round + 1)
+					}
+				}
+			}
+
+			startRound(0)
+			for {
+				select {
+				case <-nodeCtx.Done():
+					publish(Event{Kind: EventNodeDone, Chain: chain, Round: round})
+					return
+				case txs, ok := <-inbox:
+					if !ok {
+						inbox = nil
+						continue
+					}
+					for _, tx := range txs {
+						if !committed[tx.TxID] {
+							pending = append(pending, tx)
+						}
+					}
+				case msgs := <-receiver:
+					for _, m := range msgs {
+						if m.Height < height {
+							continue
+						}
+						tally.add(m)
+						if corrupt && m.Step == bftPropose && m.Height == height {
+							equivocate(m)
+						}
+					}
+					advance()
+				case <-timer.C:
+					if halted {
+						continue
+					}
+					switch step {
+					case bftPropose:
+						vote(bftPrevote, Block{})
+						enter(bftPrevote)
+					case bftPrevote:
+						vote(bftPrecommit, Block{})
+						enter(bftPrecommit)
+					case bftPrecommit:
+						startRound(round + 1)
+					}
+					advance()
+				}
+			}
+		}()
+	}
+
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	consensus := trackBFT(bus, C)
+	txSent := SendTransactions(ctx, N, C, R, inboxes, newWorkload(cfg, N, C, nil), bus, nil)
+
+	consensus.watch.wait(ctx, txSent, 50*timeout)
+	stopNodes()
+	wg.Wait()
+	for i := range N {
+		queues[i].close()
+	}
+
+	corruptPercentage := getPercentage(C, N)
+	txConfirmed := countConfirmedTransactions(winner)
+	confirmed := make(map[TxID]struct{})
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			if _, seen := confirmed[tx.TxID]; !seen {
+				confirmed[tx.TxID] = struct{}{}
+				bus.Publish(Event{Kind: EventConfirmed, Sim: "BFT", Tx: tx})
+			}
+		}
+	}
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.chainLatencies(winner))
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	throughput := timing.chainThroughput(winner, txConfirmed, duration)
+	bftStats := consensus.result()
+	bftStats.Equivocations = int(equivocations.Load())
+	var leaderStats LeaderStats
+	if election != nil {
+		leaderStats = election.result()
+	}
+	var scheduleStats ScheduleStats
+	if sched != nil {
+		scheduleStats = sched.result()
+	}
+
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Corrupt nodes      =", C)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("Quorum             =", q)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Println("Winner             =", winnerType)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(nodes.stats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printBFTStats(bftStats)
+		if election != nil {
+			printLeaderStats(leaderStats)
+		}
+		if sched != nil {
+			printScheduleStats(scheduleStats)
+		}
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "BFT",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 nodes.stats,
+		BFT:                   bftStats,
+		Leaders:               leaderStats,
+		Schedule:              scheduleStats,
+		TimedOut:              timedOut,
+	}
+}
+
+// bftTracker collects the commits of a BFT simulation
+type bftTracker struct {
+	mu         sync.Mutex
+	C          int
+	commits    map[int]map[string]bool // height -> blocks honest nodes committed
+	refused    map[int]bool            // heights at which an honest node refused the decided block
+	rounds     map[int]int             // height -> rounds the slowest honest node needed
+	unfinished int                     // rounds honest nodes spent on the height they never committed
+	watch      *commitWatch
+}
+
+func trackBFT(bus *EventBus, C int) *bftTracker {
+	t := &bftTracker{
+		C:       C,
+		commits: make(map[int]map[string]bool),
+		refused: make(map[int]bool),
+		rounds:  make(map[int]int),
+		watch:   newCommitWatch(),
+	}
+	bus.Subscribe(func(e Event) {
+		if e.Kind == EventCommit {
+			t.watch.add(e.Block)
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if getLabel(nodeIndex(e.Node, t.C), t.C) == "corrupt" {
+			return
+		}
+		if e.Kind == EventNodeDone {
+			t.unfinished = max(t.unfinished, e.Round)
+			return
+		}
+		if e.Kind == EventRejected {
+			t.refused[e.Height] = true
+			return
+		}
+		if t.commits[e.Height] == nil {
+			t.commits[e.Height] = make(map[string]bool)
+		}
+		t.commits[e.Height][e.Block.Hash] = true
+		t.rounds[e.Height] = max(t.rounds[e.Height], e.Round+1)
+	}, EventCommit, EventNodeDone, EventRejected)
+	return t
+}
+
+func (t *bftTracker) result() BFTStats {
+	stats := BFTStats{Heights: len(t.commits), Stalls: t.unfinished}
+	for h, r := range t.rounds {
+		stats.Rounds += r
+		stats.Stalls += r - 1
+		stats.MaxRounds = max(stats.MaxRounds, r)
+		if len(t.commits[h]) > 1 || t.refused[h] {
+			stats.SafetyViolations++
+		}
+	}
+	for h := range t.refused {
+		if t.rounds[h] == 0 { // no honest node committed the height
+			stats.SafetyViolations++
+		}
+	}
+	return stats
+}
+
+func printBFTStats(stats BFTStats) {
+	fmt.Println("Heights            =", stats.Heights)
+	fmt.Println("Consensus rounds   =", stats.Rounds)
+	fmt.Println("Liveness stalls    =", stats.Stalls)
+	fmt.Println("Max rounds/height  =", stats.MaxRounds)
+	fmt.Println("Equivocations      =", stats.Equivocations)
+	fmt.Println("Safety violations  =", stats.SafetyViolations)
+}
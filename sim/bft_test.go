@@ -0,0 +1,42 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// TestBFTChainsLink runs BFT with more than f corrupt nodes, which split the honest nodes with
+// conflicting proposals. Honest nodes used to commit whatever a quorum decided, extending blocks they
+// had never committed.
+func TestBFTChainsLink(t *testing.T) {
+	for _, nc := range [][2]int{{15, 10}, {20, 10}, {20, 15}} {
+		t.Run(fmt.Sprintf("N=%d,C=%d", nc[0], nc[1]), func(t *testing.T) {
+			t.Parallel()
+			cfg := SimConfig{N: nc[0], C: nc[1], R: 2, D: 1, P: 0.4, Events: NewEventBus()}
+			checker := checkInvariants(cfg.Events, cfg)
+			res := SimulateBFTCtx(context.Background(), cfg)
+			for _, v := range checker.violations(res) {
+				t.Error(v)
+			}
+		})
+	}
+}
+
+func TestBFTTrackerCountsRefusedBlocks(t *testing.T) {
+	bus := NewEventBus()
+	tracker := trackBFT(bus, 1) // corrupt1, then honest2 and honest3
+	a := Block{Hash: "a", PrevHash: "g"}
+	b := Block{Hash: "b", PrevHash: "g"}
+	c := Block{Hash: "c", PrevHash: "a"}
+	bus.Publish(Event{Kind: EventCommit, Node: "honest2", Block: a, Height: 1})
+	bus.Publish(Event{Kind: EventCommit, Node: "honest3", Block: a, Height: 1})
+	bus.Publish(Event{Kind: EventCommit, Node: "corrupt1", Block: b, Height: 1}) // corrupt nodes do not count
+	bus.Publish(Event{Kind: EventCommit, Node: "honest2", Block: c, Height: 2})
+	bus.Publish(Event{Kind: EventRejected, Node: "honest3", Block: c, Height: 2})
+	bus.Publish(Event{Kind: EventRejected, Node: "honest3", Block: c, Height: 3}) // nobody committed height 3
+
+	if got := tracker.result().SafetyViolations; got != 2 {
+		t.Errorf("SafetyViolations = %d, want 2 (heights 2 and 3)", got)
+	}
+}
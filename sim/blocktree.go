@@ -0,0 +1,206 @@
+package sim
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// --- Block Tree Export ---
+
+/*
+	With -block-tree <dir> the tester writes the whole block tree of every simulation to
+	dir/test<n>-<mode>.dot, every block a node mined and not only the winning chain, so the forks and
+	orphans show. -block-tree-format mermaid writes Mermaid (.mmd) instead of GraphViz DOT. Every block
+	points to its parent (a DAG vertex to the transactions it approves, an uncle reference is dashed),
+	blocks of the winning chain (the DAG's confident set) are filled green and the corrupt nodes' blocks
+	have a red border. The modes that run several chains at once (swap, shard, sidechain) draw all of
+	them, marking the longest winning one.
+*/
+
+// blockTree collects the blocks of a simulation from its bus. It is only read once the simulation is
+// over, or by control.go, which updates it under its own lock.
+type blockTree struct {
+	mu        sync.Mutex
+	blocks    []Block // in the order they were first seen
+	seen      map[string]bool
+	chains    map[string][]Block // node -> its final chain
+	confirmed map[TxID]bool
+}
+
+func trackBlockTree(bus *EventBus) *blockTree {
+	t := newBlockTree()
+	bus.Subscribe(t.handle, EventMined, EventAccepted, EventNodeDone, EventConfirmed)
+	return t
+}
+
+func newBlockTree() *blockTree {
+	return &blockTree{seen: make(map[string]bool), chains: make(map[string][]Block), confirmed: make(map[TxID]bool)}
+}
+
+func (t *blockTree) handle(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e.Kind {
+	case EventMined, EventAccepted:
+		if !t.seen[e.Block.Hash] {
+			t.seen[e.Block.Hash] = true
+			t.blocks = append(t.blocks, e.Block)
+		}
+	case EventNodeDone:
+		if len(e.Chain) > 0 {
+			t.chains[e.Node] = e.Chain
+		}
+	case EventConfirmed:
+		t.confirmed[e.Tx.TxID] = true
+	}
+}
+
+// winningChain returns the longest of the final chains of the nodes on the winner's side, the longest
+// of all if none is
+func winningChain(chains map[string][]Block, winner string) []Block {
+	best := []Block{}
+	for node, chain := range chains {
+		if strings.HasPrefix(node, winner) && len(chain) > len(best) {
+			best = chain
+		}
+	}
+	for _, chain := range chains {
+		if len(best) == 0 && len(chain) > len(best) {
+			best = chain
+		}
+	}
+	return best
+}
+
+// treeNode is a block of the tree to draw
+type treeNode struct {
+	id      string
+	label   string
+	winning bool
+	corrupt bool
+}
+
+// treeEdge points from a block to a parent, dashed for an uncle
+type treeEdge struct {
+	from, to string
+	dashed   bool
+}
+
+// layout turns the blocks into nodes and edges, adding the parents nobody mined (genesis)
+func (t *blockTree) layout(winner string) ([]treeNode, []treeEdge) {
+	winning := make(map[string]bool)
+	for _, b := range winningChain(t.chains, winner) {
+		winning[b.Hash] = true
+	}
+	id := func(hash string) string { return "b" + hash[max(len(hash)-12, 0):] } // the start is the difficulty's zeros
+	nodes, edges := []treeNode{}, []treeEdge{}
+	parents := make(map[string]bool)
+	for _, b := range t.blocks {
+		n := treeNode{id: id(b.Hash), winning: winning[b.Hash]}
+		if len(b.Transactions) == 1 && b.PrevHash == "" { // a DAG vertex
+			tx := b.Transactions[0]
+			n.label = fmt.Sprintf("%s→%s %s", tx.Sender, tx.Receiver, tx.TxID)
+			n.winning = n.winning || t.confirmed[tx.TxID]
+			n.corrupt = strings.HasPrefix(tx.Sender, "corrupt")
+			for _, p := range tx.Parents {
+				edges = append(edges, treeEdge{from: n.id, to: id(p)})
+				parents[p] = true
+			}
+		} else {
+			n.label = fmt.Sprintf("%s %d txs", b.Miner, len(b.Transactions))
+			n.corrupt = strings.HasPrefix(b.Miner, "corrupt")
+			if b.PrevHash != "" {
+				edges = append(edges, treeEdge{from: n.id, to: id(b.PrevHash)})
+				parents[b.PrevHash] = true
+			}
+			for _, u := range b.Uncles {
+				edges = append(edges, treeEdge{from: n.id, to: id(u), dashed: true})
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	for _, b := range t.blocks {
+		delete(parents, b.Hash)
+	}
+	for p := range parents {
+		nodes = append(nodes, treeNode{id: id(p), label: "genesis", winning: true})
+	}
+	return nodes, edges
+}
+
+// dot writes the tree as a GraphViz digraph
+func (t *blockTree) dot(name, winner string) string {
+	nodes, edges := t.layout(winner)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n\trankdir=RL;\n\tnode [shape=box, style=filled, fillcolor=white];\n", name)
+	for _, n := range nodes {
+		attrs := fmt.Sprintf("label=%q", n.label)
+		if n.winning {
+			attrs += ", fillcolor=palegreen"
+		}
+		if n.corrupt {
+			attrs += ", color=red, penwidth=2"
+		}
+		fmt.Fprintf(&sb, "\t%s [%s];\n", n.id, attrs)
+	}
+	for _, e := range edges {
+		if e.dashed {
+			fmt.Fprintf(&sb, "\t%s -> %s [style=dashed];\n", e.from, e.to)
+		} else {
+			fmt.Fprintf(&sb, "\t%s -> %s;\n", e.from, e.to)
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// mermaid writes the tree as a Mermaid flowchart
+func (t *blockTree) mermaid(name, winner string) string {
+	nodes, edges := t.layout(winner)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "---\ntitle: %s\n---\nflowchart RL\n", name)
+	winning, corrupt := []string{}, []string{}
+	for _, n := range nodes {
+		fmt.Fprintf(&sb, "\t%s[\"%s\"]\n", n.id, strings.ReplaceAll(n.label, `"`, "#quot;"))
+		if n.winning {
+			winning = append(winning, n.id)
+		}
+		if n.corrupt {
+			corrupt = append(corrupt, n.id)
+		}
+	}
+	for _, e := range edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "\t%s %s %s\n", e.from, arrow, e.to)
+	}
+	sb.WriteString("\tclassDef winning fill:#98fb98\n\tclassDef corrupt stroke:#f00,stroke-width:2px\n")
+	if len(winning) > 0 {
+		fmt.Fprintf(&sb, "\tclass %s winning\n", strings.Join(winning, ","))
+	}
+	if len(corrupt) > 0 {
+		fmt.Fprintf(&sb, "\tclass %s corrupt\n", strings.Join(corrupt, ","))
+	}
+	return sb.String()
+}
+
+// writeBlockTree writes the tree of test's simulation of mode to dir in format, nothing if it has no blocks
+func writeBlockTree(dir, format string, test int, mode string, res SimResult, t *blockTree) error {
+	if len(t.blocks) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("test%d-%s", test, mode)
+	content, ext := t.dot(name, res.Winner), ".dot"
+	if format == "mermaid" {
+		content, ext = t.mermaid(name, res.Winner), ".mmd"
+	}
+	return os.WriteFile(filepath.Join(dir, name+ext), []byte(content), 0644)
+}
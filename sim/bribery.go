@@ -0,0 +1,77 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// --- Bribery ---
+
+/*
+	With SimConfig.BribeRate = b the corrupt nodes bribe the honest PoW miners (not in the hybrid mode) to build on the
+	corrupt fork: they promise a fee of BribeFee block rewards (default 1, doubling the miner's income)
+	for every block mined on the fork. Each honest miner takes the bribe with probability b at the
+	start of the simulation and from then on acts as a corrupt node: it receives the corrupt blocks,
+	mines on the corrupt fork and only broadcasts to the other defectors. It still includes its own
+	(honest) transactions.
+
+	The fees are paid in-band, by the transactions of the corrupt fork, so they are only paid out if
+	the fork wins: Budget is what the corrupt nodes paid to the bribed miners of a winning corrupt fork.
+	A run Flipped the winner if the corrupt fork won without the corrupt nodes holding half of the
+	hash power themselves.
+*/
+
+// BribeStats reports the bribes of a PoW simulation
+type BribeStats struct {
+	Rate         float64 // probability that an honest miner takes the bribe
+	Bribed       int     // honest miners that took the bribe
+	BribedBlocks int     // blocks of the winning chain mined by bribed miners
+	Budget       float64 // fees paid to bribed miners, in block rewards
+	Flipped      bool    // the corrupt fork won although the corrupt nodes hold less than half of the hash power
+}
+
+// bribeFee resolves cfg.BribeFee against the default
+func bribeFee(cfg SimConfig) float64 {
+	if cfg.BribeFee <= 0 {
+		return 1
+	}
+	return cfg.BribeFee
+}
+
+// bribeMiners decides which of the N-C honest miners take the bribe
+func bribeMiners(N, C int, rate float64) []bool {
+	bribed := make([]bool, N)
+	for i := C; i < N && C > 0; i++ {
+		bribed[i] = rand.Float64() < rate
+	}
+	return bribed
+}
+
+// bribeResult pays the bribed miners of the winning chain if the corrupt fork won
+func bribeResult(cfg SimConfig, winner []Block, winnerType string, bribed []bool, N, C int) BribeStats {
+	stats := BribeStats{Rate: cfg.BribeRate}
+	for _, b := range bribed {
+		if b {
+			stats.Bribed++
+		}
+	}
+	if winnerType != "corrupt" {
+		return stats
+	}
+	for _, b := range winner {
+		if b.Miner != "" && bribed[nodeIndex(b.Miner, C)] {
+			stats.BribedBlocks++
+		}
+	}
+	stats.Budget = float64(stats.BribedBlocks) * bribeFee(cfg)
+	stats.Flipped = 2*C < N
+	return stats
+}
+
+func printBribeStats(stats BribeStats) {
+	fmt.Println("Bribe rate         =", stats.Rate)
+	fmt.Println("Bribed miners      =", stats.Bribed)
+	fmt.Println("Bribed blocks      =", stats.BribedBlocks)
+	fmt.Println("Bribe budget       =", stats.Budget)
+	fmt.Println("Winner flipped     =", stats.Flipped)
+}
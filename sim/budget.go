@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"context"
+	"time"
+)
+
+// --- Round Budget ---
+
+/*
+	-round-budget <duration> gives every test a wall-clock budget instead of the suite's R: each mode
+	gets an equal share, and before it runs the runner times pilot simulations (the same config, without
+	events or console) of 1, 2, 4, ... rounds until one takes a sixteenth of the share, then gives the
+	mode as many rounds as fit the rest of its share at the pace of that last pilot, at least 1 and at
+	most maxBudgetRounds. A sweep then finishes on schedule whatever the difficulty or the machine,
+	slower tests simply run fewer rounds. The pilots take about a quarter of the share and their results
+	are not recorded.
+
+	The pace of the last pilot includes its setup and teardown, so the rounds chosen err on the short
+	side; a mode whose rounds get slower as its chain or DAG grows can still overrun. The Rounds column
+	records the R chosen, which differs between modes and between runs; -test and the repro line of
+	-check rerun the pilots rather than that R.
+*/
+
+// maxBudgetRounds bounds the rounds of -round-budget, a mode taking microseconds a round would
+// otherwise get millions
+const maxBudgetRounds = 1000
+
+// budgetRounds times pilot simulations of mode on cfg and returns the rounds that fill the rest of
+// share at the pace of the last, and the time the pilots took
+func budgetRounds(ctx context.Context, mode string, cfg SimConfig, share time.Duration) (int, time.Duration) {
+	pilot := cfg
+	pilot.Events, pilot.console = nil, nil
+	var spent, took time.Duration
+	for pilot.R = 1; ; pilot.R *= 2 {
+		start := time.Now()
+		simulators[mode](ctx, pilot)
+		took = max(time.Since(start), time.Microsecond)
+		spent += took
+		if took >= share/16 || pilot.R >= maxBudgetRounds || ctx.Err() != nil {
+			break
+		}
+	}
+	rounds := float64(pilot.R) * float64(share-spent) / float64(took)
+	return int(min(max(rounds, 1), maxBudgetRounds)), spent
+}
@@ -0,0 +1,70 @@
+package sim
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"time"
+)
+
+// --- Difficulty Calibration ---
+
+/*
+	The suite's D values are hash counts, 16^D per block, so the same suite gives 5 ms blocks on one
+	machine and 50 ms blocks on another. -calibrate sets the D of every test from a block time instead:
+	before the suite it mines blocks of ten transactions for calibrationTime to measure this host's hash
+	rate per miner (with -hash and -midstate as set), and gives each test the difficulty whose expected
+	block time is closest to -target-block-time. A test's network hashes with min(N, GOMAXPROCS) miners
+	at once (the mining slots of SimConfig.Miners), so its block time at difficulty d is
+	16^d / (rate * miners). With -fast-mining nothing is measured: every node hashes at -hashrate.
+
+	Difficulty is a count of hex digits, so it moves in steps of 16 and the block time lands within 4x
+	of the target (rounded in log scale). D is at least 1. The DAG takes the same D for the proof of
+	work of its transactions. The D column of the results is the calibrated one, -test and the repro
+	line of -check reproduce it only on a machine as fast, or with -calibrate again.
+*/
+
+// calibrationTime is how long the hash rate is measured
+const calibrationTime = 300 * time.Millisecond
+
+// benchBlock is a block of 10 transactions, as the default workload's
+func benchBlock() Block {
+	txs := []Transaction{}
+	for k := range 10 {
+		txs = append(txs, Transaction{Sender: nodeName(k%5, 1), Receiver: nodeName((k+1)%5, 1), TxID: TxID(k + 1)})
+	}
+	return newBlock("honest1", "00a1b2c3d4e5f60718293a4b5c6d7e8f00a1b2c3d4e5f60718293a4b5c6d7e8f", nil, txs, 2, 1700000000000000000)
+}
+
+// measureHashRate mines blocks like the default workload's for about d and returns the hashes per
+// second of one miner
+func measureHashRate(h hashFunc, d time.Duration) float64 {
+	block := benchBlock()
+	hashes := 0
+	start := time.Now()
+	for time.Since(start) < d {
+		block.Timestamp++
+		block.Nonce = 0
+		mined, _ := mineBlock(context.Background(), h, block, 3) // 4,096 hashes on average
+		hashes += mined.Nonce + 1
+	}
+	return float64(hashes) / time.Since(start).Seconds()
+}
+
+// calibratedDifficulty is the difficulty whose expected block time is closest to target for n nodes
+// hashing at rate each
+func calibratedDifficulty(rate float64, n int, target time.Duration, fast bool) int {
+	if !fast {
+		n = min(n, runtime.GOMAXPROCS(0))
+	}
+	hashes := rate * float64(n) * target.Seconds()
+	return min(maxDifficulty, max(1, int(math.Round(math.Log(hashes)/math.Log(16)))))
+}
+
+// expectedBlockTime is the mean time n nodes hashing at rate each take to find a block at difficulty d
+func expectedBlockTime(rate float64, n, d int, fast bool) time.Duration {
+	if !fast {
+		n = min(n, runtime.GOMAXPROCS(0))
+	}
+	return time.Duration(blockWork(d) / (rate * float64(n)) * float64(time.Second))
+}
@@ -0,0 +1,262 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Payment Channels ---
+
+/*
+	With SimConfig.Channels = "n[:updates,window]" n pairs of PoW nodes, drawn from both sides, open a
+	payment channel on-chain with the first round of transactions, exchange updates (default 10) off-chain
+	payments every round, and settle on-chain with the last round. Every update moves part of one party's
+	balance to the other and bumps the state number. Without channels each update would have been a
+	transaction, so the load reduction is the share of them that never touched the chain.
+
+	A channel with a corrupt and an honest party ends in a dispute: the corrupt party settles with the
+	stale state in which its balance was highest, and the honest party contests with the latest one, like
+	a Lightning watchtower. The two go to the miners of their own side, and the winning chain decides:
+	- Honest: the latest state settled first, the stale close came too late.
+	- Penalized: the stale close settled, but the contest followed within window blocks (default 6), so
+	  the cheater forfeits its balance.
+	- Cheated: the stale close settled and no contest followed in time, the cheater keeps what it took.
+	The other channels close cooperatively, with a single transaction of the first party.
+
+	Channel transactions are real transactions, announced and counted like the workload's, with IDs below
+	0 so they never collide with the workload's.
+*/
+
+// channelCapacity is what each party puts into a channel
+const channelCapacity = 100.0
+
+// ChannelStats reports the payment channels of a PoW simulation
+type ChannelStats struct {
+	Channels  int
+	Updates   int     // off-chain payments
+	OnChain   int     // channel transactions sent: opens, closes and contests
+	Reduction float64 // % of the updates that did not need a transaction
+	Settled   int     // cooperative closes in the winning chain
+	Disputes  int     // channels with one corrupt party
+	Honest    int     // disputes the latest state settled
+	Penalized int     // disputes the cheater lost to a contest in time
+	Cheated   int     // disputes the stale state settled
+	Unsettled int     // channels the winning chain never closed
+	Stolen    float64 // what the successful cheats took from the honest parties
+}
+
+// channel is a pair of nodes with their balances
+type channel struct {
+	a, b     int // nodes, a is the first party
+	balances [2]float64
+	state    int
+	bestBal  [2]float64 // the balances of the state in which the corrupt party's was highest
+	open     TxID       // IDs of the channel's transactions
+	settle   TxID
+	contest  TxID // 0 without a dispute
+	corrupt  int  // 0 or 1: which party cheats, -1 if none
+}
+
+// channelNet opens, updates and closes the channels of a simulation, nil without SimConfig.Channels.
+// Only the SendTransactions goroutine touches it until the result.
+type channelNet struct {
+	N, C     int
+	R        int
+	updates  int
+	window   int
+	channels []*channel
+	nextID   TxID
+	onChain  int
+}
+
+// parseChannels returns the channels, updates per round and dispute window of spec, 0 channels for ""
+func parseChannels(spec string) (n, updates, window int, err error) {
+	if spec == "" {
+		return 0, 0, 0, nil
+	}
+	count, params, _ := strings.Cut(spec, ":")
+	updates, window = 10, 6
+	values := []*int{&n, &updates, &window}
+	fields := []string{count}
+	if params != "" {
+		fields = append(fields, strings.Split(params, ",")...)
+	}
+	if len(fields) > len(values) {
+		return 0, 0, 0, fmt.Errorf("channels %q: too many parameters", spec)
+	}
+	for k, field := range fields {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || v < 1 {
+			return 0, 0, 0, fmt.Errorf("channels %q: %q is not a positive count", spec, field)
+		}
+		*values[k] = v
+	}
+	return n, updates, window, nil
+}
+
+func newChannelNet(cfg SimConfig, N, C int) *channelNet {
+	n, updates, window, err := parseChannels(cfg.Channels)
+	if err != nil || n == 0 || N < 2 {
+		return nil
+	}
+	net := &channelNet{N: N, C: C, R: cfg.R, updates: updates, window: window}
+	for range n {
+		a := rand.IntN(N)
+		b := rand.IntN(N - 1)
+		if b >= a {
+			b++
+		}
+		ch := &channel{a: a, b: b, balances: [2]float64{channelCapacity, channelCapacity}, corrupt: -1}
+		switch la, lb := getLabel(a, C), getLabel(b, C); {
+		case la == "corrupt" && lb == "honest":
+			ch.corrupt = 0
+		case la == "honest" && lb == "corrupt":
+			ch.corrupt = 1
+		}
+		ch.bestBal = ch.balances
+		net.channels = append(net.channels, ch)
+	}
+	return net
+}
+
+// id hands out the next channel transaction ID
+func (net *channelNet) id() TxID {
+	net.nextID--
+	return net.nextID
+}
+
+// tx is a channel transaction from party from to the other one
+func (net *channelNet) tx(ch *channel, from int) Transaction {
+	parties := [2]int{ch.a, ch.b}
+	net.onChain++
+	return Transaction{Sender: nodeName(parties[from], net.C), Receiver: nodeName(parties[1-from], net.C), TxID: net.id()}
+}
+
+// next adds the channel transactions of round to the workload's, and runs the round's updates
+func (net *channelNet) next(round int, txs []Transaction) []Transaction {
+	out := []Transaction{}
+	for _, ch := range net.channels {
+		if round == 0 {
+			open := net.tx(ch, 0)
+			ch.open = open.TxID
+			out = append(out, open)
+		}
+		for range net.updates { // move up to half of a party's balance to the other
+			from := rand.IntN(2)
+			amount := ch.balances[from] * rand.Float64() / 2
+			ch.balances[from] -= amount
+			ch.balances[1-from] += amount
+			ch.state++
+			if ch.corrupt >= 0 && ch.balances[ch.corrupt] > ch.bestBal[ch.corrupt] {
+				ch.bestBal = ch.balances
+			}
+		}
+		if round == net.R-1 {
+			if ch.corrupt < 0 {
+				settle := net.tx(ch, 0)
+				ch.settle = settle.TxID
+				out = append(out, settle)
+				continue
+			}
+			stale, contest := net.tx(ch, ch.corrupt), net.tx(ch, 1-ch.corrupt)
+			ch.settle, ch.contest = stale.TxID, contest.TxID
+			out = append(out, stale, contest)
+		}
+	}
+	return append(out, txs...)
+}
+
+// channelWorkload adds the channel transactions to the batches of a workload
+type channelWorkload struct {
+	Workload
+	net *channelNet
+}
+
+func (w *channelWorkload) Next(round int) []Transaction {
+	return w.net.next(round, w.Workload.Next(round))
+}
+
+// channelArrivals keeps an arrivalWorkload one, the channel transactions arrive right away
+type channelArrivals struct {
+	channelWorkload
+}
+
+func (w *channelArrivals) arrival(tx Transaction) time.Duration {
+	if tx.TxID < 0 {
+		return 0
+	}
+	return w.Workload.(arrivalWorkload).arrival(tx)
+}
+
+// wrap adds the channel transactions to w
+func (net *channelNet) wrap(w Workload) Workload {
+	if net == nil {
+		return w
+	}
+	if _, ok := w.(arrivalWorkload); ok {
+		return &channelArrivals{channelWorkload{w, net}}
+	}
+	return &channelWorkload{w, net}
+}
+
+// result settles the channels on the winning chain
+func (net *channelNet) result(winner []Block) ChannelStats {
+	if net == nil {
+		return ChannelStats{}
+	}
+	height := make(map[TxID]int) // ID -> height of its first block in the winner
+	for h, b := range winner {
+		for _, tx := range b.Transactions {
+			if _, seen := height[tx.TxID]; !seen && tx.TxID < 0 {
+				height[tx.TxID] = h
+			}
+		}
+	}
+	stats := ChannelStats{Channels: len(net.channels), OnChain: net.onChain}
+	for _, ch := range net.channels {
+		stats.Updates += ch.state
+		closed, okClose := height[ch.settle]
+		if ch.contest == 0 {
+			if okClose {
+				stats.Settled++
+			} else {
+				stats.Unsettled++
+			}
+			continue
+		}
+		stats.Disputes++
+		contested, okContest := height[ch.contest]
+		switch {
+		case !okClose && !okContest:
+			stats.Unsettled++
+		case !okClose || okContest && contested <= closed:
+			stats.Honest++
+		case okContest && contested <= closed+net.window:
+			stats.Penalized++
+		default:
+			stats.Cheated++
+			stats.Stolen += ch.bestBal[ch.corrupt] - ch.balances[ch.corrupt]
+		}
+	}
+	if stats.Updates > 0 {
+		stats.Reduction = 100 * float64(stats.Updates-stats.OnChain) / float64(stats.Updates)
+	}
+	return stats
+}
+
+func printChannelStats(stats ChannelStats) {
+	fmt.Println("Channels           =", stats.Channels)
+	fmt.Println("Channel updates    =", stats.Updates)
+	fmt.Println("Channel txs        =", stats.OnChain)
+	fmt.Printf("Load reduction %%   = %.2f\n", stats.Reduction)
+	fmt.Println("Channels settled   =", stats.Settled)
+	fmt.Println("Channel disputes   =", stats.Disputes)
+	fmt.Println("Disputes honest    =", stats.Honest)
+	fmt.Println("Disputes penalized =", stats.Penalized)
+	fmt.Println("Disputes cheated   =", stats.Cheated)
+	fmt.Println("Channels unsettled =", stats.Unsettled)
+	fmt.Printf("Channel stolen     = %.2f\n", stats.Stolen)
+}
@@ -0,0 +1,172 @@
+package sim
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// --- Invariant Checks ---
+
+/*
+	With -check the tester asserts structural properties of every simulation once it is over, from its
+	events, and fails loudly if one does not hold:
+	- every block a node mined or accepted extends a known block: its PrevHash is a block seen in the
+	  simulation or on a node's final chain, genesis included;
+	- every node's final chain starts at a genesis block and each block links to the one before (the
+	  first may build on "" instead of the genesis, as PoW's do), with
+	  the hash its contents give (calculateHash) and, on a PoW chain, the leading zeros of its
+	  difficulty;
+	- the DAG has no cycle, and every node's confident set only holds vertices that were mined;
+	- the balances of the winning chain never go negative: with SimConfig.UTXO each of its
+	  transactions spends outputs that are unspent and its sender's, worth at least what it pays (see
+	  utxoSet.spend; only the single-chain PoW simulators keep one UTXO ledger), and otherwise no confirmed transaction transfers a negative or NaN value.
+	Each violation is logged with the test and mode that broke it and how to rerun them (-test, and
+	-seed for the des simulator, the only one whose run a seed fixes), and the tester exits with status
+	1 once the suite stops. The winning chain is the one blocktree.go draws, winningChain.
+*/
+
+// checkMaxViolations is how many violations of a simulation are reported, the rest are counted
+const checkMaxViolations = 10
+
+// invariantChecker collects the blocks and chains of a simulation from its bus. The bus calls it one
+// event at a time, and it is only read once the simulation is over.
+type invariantChecker struct {
+	mu        sync.Mutex
+	cfg       SimConfig
+	seen      map[string]bool
+	blocks    []Block // mined or accepted, in the order they were first seen
+	vertices  map[string]Transaction
+	chains    map[string][]Block
+	confident map[string][]Transaction
+	confirmed []Transaction
+}
+
+func checkInvariants(bus *EventBus, cfg SimConfig) *invariantChecker {
+	c := &invariantChecker{cfg: cfg, seen: make(map[string]bool), vertices: make(map[string]Transaction),
+		chains: make(map[string][]Block), confident: make(map[string][]Transaction)}
+	bus.Subscribe(func(e Event) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		switch e.Kind {
+		case EventMined, EventAccepted:
+			if c.seen[e.Block.Hash] {
+				return
+			}
+			c.seen[e.Block.Hash] = true
+			if b := e.Block; len(b.Transactions) == 1 && b.PrevHash == "" && b.Miner == "" { // a DAG vertex
+				c.vertices[b.Hash] = b.Transactions[0]
+			} else {
+				c.blocks = append(c.blocks, b)
+			}
+		case EventNodeDone:
+			if len(e.Chain) > 0 {
+				c.chains[e.Node] = e.Chain
+			}
+			if len(e.Txs) > 0 {
+				c.confident[e.Node] = e.Txs
+			}
+		case EventConfirmed:
+			c.confirmed = append(c.confirmed, e.Tx)
+		}
+	}, EventMined, EventAccepted, EventNodeDone, EventConfirmed)
+	return c
+}
+
+// powChain tells the simulation types whose blocks are mined by grinding nonces
+func powChain(typ string) bool {
+	return slices.Contains([]string{"PoW", "Hybrid", "Rollup", "Swap", "Shard", "Sidechain"}, typ)
+}
+
+// violations checks the invariants of the simulation that ended in res
+func (c *invariantChecker) violations(res SimResult) []string {
+	found := []string{}
+	report := func(format string, args ...any) {
+		found = append(found, fmt.Sprintf(format, args...))
+	}
+
+	known := maps.Clone(c.seen) // and the blocks of the chains, e.g. a BFT proposer's twin is never published
+	for _, chain := range c.chains {
+		for _, b := range chain {
+			known[b.Hash] = true
+		}
+	}
+	for _, b := range c.blocks {
+		if b.PrevHash != "" && !known[b.PrevHash] {
+			report("block %s of %s extends unknown block %s", shortHash(b.Hash), b.Miner, shortHash(b.PrevHash))
+		}
+	}
+
+	nodes := slices.Sorted(maps.Keys(c.chains))
+	for _, node := range nodes {
+		chain := c.chains[node]
+		if chain[0].PrevHash != "" {
+			report("chain of %s starts at %s, which has a parent", node, shortHash(chain[0].Hash))
+		}
+		for k, b := range chain {
+			if k > 0 && b.PrevHash != chain[k-1].Hash && !(k == 1 && b.PrevHash == "") { // first blocks build on "" or genesis
+				report("chain of %s: block %d (%s) does not link to block %d (%s)", node, k, shortHash(b.Hash), k-1, shortHash(chain[k-1].Hash))
+			}
+			if calculateHash(c.cfg.hashing(), b) != b.Hash {
+				report("chain of %s: block %d (%s) does not hash to its hash", node, k, shortHash(b.Hash))
+			} else if powChain(res.Type) && !c.cfg.FastMining && !strings.HasPrefix(b.Hash, strings.Repeat("0", b.Difficulty)) {
+				report("chain of %s: block %d (%s) misses the %d leading zeros of its difficulty", node, k, shortHash(b.Hash), b.Difficulty)
+			}
+		}
+	}
+
+	// DAG: depth-first search for a vertex that is its own ancestor
+	state := make(map[string]int) // 1 = on the path, 2 = done
+	var visit func(hash string) bool
+	visit = func(hash string) bool {
+		switch state[hash] {
+		case 1:
+			return true
+		case 2:
+			return false
+		}
+		state[hash] = 1
+		for _, p := range c.vertices[hash].Parents {
+			if _, ok := c.vertices[p]; ok && visit(p) {
+				return true
+			}
+		}
+		state[hash] = 2
+		return false
+	}
+	for _, hash := range slices.Sorted(maps.Keys(c.vertices)) {
+		if state[hash] == 0 && visit(hash) {
+			report("DAG has a cycle through vertex %s", shortHash(hash))
+			break
+		}
+	}
+	for _, node := range slices.Sorted(maps.Keys(c.confident)) {
+		for _, tx := range c.confident[node] {
+			if _, ok := c.vertices[tx.Hash]; !ok && tx.Sender != "genesis" {
+				report("confident set of %s holds %s, which nobody mined", node, shortHash(tx.Hash))
+				break
+			}
+		}
+	}
+
+	// the other simulators ignore UTXO, and each shard has a ledger of its own
+	if c.cfg.UTXO && powChain(res.Type) && res.Type != "Shard" && len(c.chains) > 0 {
+		set := maps.Clone(newUTXOLedger(c.cfg, c.cfg.N, c.cfg.C).genesis) // the Sybils hold no outputs
+		for k, b := range winningChain(c.chains, res.Winner) {
+			for _, tx := range b.Transactions {
+				if !set.spend(tx) {
+					report("winning chain: block %d spends outputs %s does not have in tx %s", k, tx.Sender, tx.TxID)
+				}
+			}
+		}
+	} else {
+		for _, tx := range c.confirmed {
+			if tx.Amount < 0 {
+				report("confirmed tx %s of %s transfers %s", tx.TxID, tx.Sender, tx.Amount)
+			}
+		}
+	}
+	return found
+}
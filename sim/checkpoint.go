@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Checkpointing ---
+
+// checkpointPath is where the tests finished writing to resultsFile are recorded, which -resume skips:
+// benchmark_results.checkpoint.txt next to benchmark_results.csv. Each results file has its own, so
+// resuming one never skips the tests another run finished.
+func checkpointPath(resultsFile string) string {
+	return strings.TrimSuffix(resultsFile, filepath.Ext(resultsFile)) + ".checkpoint.txt"
+}
+
+// loadCheckpoint returns the number of tests finished by a previous run writing to resultsFile (0 if
+// there is no usable checkpoint)
+func loadCheckpoint(resultsFile string) int {
+	data, err := os.ReadFile(checkpointPath(resultsFile))
+	if err != nil {
+		return 0
+	}
+	completed, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || completed < 0 {
+		return 0
+	}
+	if _, err := os.Stat(resultsFile); err != nil { // nothing to append to
+		return 0
+	}
+	return completed
+}
+
+func saveCheckpoint(resultsFile string, completed int) error {
+	// Write then rename so a crash mid-write never leaves a truncated checkpoint
+	path := checkpointPath(resultsFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(completed)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func clearCheckpoint(resultsFile string) {
+	os.Remove(checkpointPath(resultsFile))
+}
+
+// --- Results File ---
+
+/*
+	The rows go to -out (benchmark_results.csv by default). A run replaces the file, warning first if it
+	has rows, unless -append adds them to the end; so does -resume. Appending checks that the file's
+	header is that of this version, rows of two layouts under one header would be misread, and writes
+	the header only into a new or empty file. -out-timestamp writes to a new file named after the start
+	time, e.g. benchmark_results-20260102-150405.csv, so reruns never touch earlier results. Missing
+	directories of -out are created.
+*/
+
+// timestampedPath puts now before the extension of path
+func timestampedPath(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + now.Format("20060102-150405") + ext
+}
+
+// openResults opens resultsFile for the rows of the suite, at its end if appending, and returns the rows
+// it already has
+func openResults(resultsFile string, appending bool) (*os.File, int, error) {
+	if err := os.MkdirAll(filepath.Dir(resultsFile), 0755); err != nil {
+		return nil, 0, err
+	}
+	if !appending {
+		file, err := os.Create(resultsFile)
+		return file, 0, err
+	}
+	file, err := os.OpenFile(resultsFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	records, err := csv.NewReader(file).ReadAll()
+	if err == nil && len(records) > 0 && !slices.Equal(records[0], resultHeaders()) {
+		err = fmt.Errorf("%s has other columns than this version writes, pick another -out", resultsFile)
+	}
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, max(len(records)-1, 0), nil
+}
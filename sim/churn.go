@@ -0,0 +1,140 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+)
+
+// --- Node Churn ---
+
+/*
+	With SimConfig.ChurnRate the honest PoW nodes leave and come back while the simulation runs: every
+	time a block is mined, each online honest node goes offline with probability ChurnRate and each
+	offline one comes back with the same probability. An offline node neither mines nor receives: the
+	broadcasts skip it, and it throws away the blocks still in its channel and the transactions it is
+	sent, while keeping the ones it already had.
+
+	SimConfig.Joiners honest nodes (the last ones) start offline, with nothing but genesis, and join
+	through the churn like a node that comes back. A node that comes back downloads the blocks it missed
+	from its online honest peers before it mines again (see ibd.go).
+
+	Once every transaction is sent the churn stops and every node comes back to finish its work, like
+	the Sybils join at the latest then. The corrupt nodes never churn.
+*/
+
+// ChurnStats reports the churn of a PoW simulation
+type ChurnStats struct {
+	Rate    float64
+	Joiners int // honest nodes that started offline
+	Left    int // times a node went offline
+	Joined  int // times a node came (back) online
+}
+
+// churn flips the honest nodes between online and offline, joined holds who is online
+type churn struct {
+	mu      sync.Mutex
+	rate    float64
+	nodes   []int // the nodes that churn
+	joiners int
+	joined  []atomic.Bool
+	over    bool
+	wake    []chan struct{} // node i checks whether it is still online
+}
+
+// newChurn returns nil without churn or joiners
+func newChurn(cfg SimConfig, N, C int, joined []atomic.Bool) *churn {
+	if cfg.ChurnRate <= 0 && cfg.Joiners <= 0 {
+		return nil
+	}
+	c := &churn{rate: max(0, cfg.ChurnRate), joined: joined, joiners: min(max(0, cfg.Joiners), N-C)}
+	for j := C; j < N; j++ {
+		c.nodes = append(c.nodes, j)
+	}
+	c.wake = make([]chan struct{}, N)
+	for j := range N {
+		c.wake[j] = make(chan struct{}, 1)
+	}
+	return c
+}
+
+// joiner tells whether node i starts offline
+func (c *churn) joiner(i int) bool {
+	return c != nil && i >= len(c.joined)-c.joiners
+}
+
+// step churns the nodes once, after a block was mined
+func (c *churn) step() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.over {
+		return
+	}
+	for _, j := range c.nodes {
+		if rand.Float64() < c.rate {
+			c.joined[j].Store(!c.joined[j].Load())
+			c.notify(j)
+		}
+	}
+}
+
+// end stops the churn and brings every node back online
+func (c *churn) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.over = true
+	for _, j := range c.nodes {
+		if !c.joined[j].Load() {
+			c.joined[j].Store(true)
+			c.notify(j)
+		}
+	}
+}
+
+func (c *churn) notify(j int) {
+	select {
+	case c.wake[j] <- struct{}{}:
+	default: // j has not checked the last flip yet
+	}
+}
+
+// woke is node i's wake-up channel, nil without churn
+func (c *churn) woke(i int) <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.wake[i]
+}
+
+// churnTracker counts the leaves and joins of the honest nodes
+type churnTracker struct {
+	mu    sync.Mutex
+	stats ChurnStats
+}
+
+func trackChurn(bus *EventBus, c *churn, C int) *churnTracker {
+	t := &churnTracker{}
+	if c == nil {
+		return t
+	}
+	t.stats.Rate, t.stats.Joiners = c.rate, c.joiners
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch {
+		case e.Kind == EventLeft:
+			t.stats.Left++
+		case getLabel(nodeIndex(e.Node, C), C) == "honest": // Sybils join too
+			t.stats.Joined++
+		}
+	}, EventLeft, EventJoined)
+	return t
+}
+
+func printChurnStats(stats ChurnStats) {
+	fmt.Println("Churn rate         =", stats.Rate)
+	fmt.Println("Late joiners       =", stats.Joiners)
+	fmt.Println("Nodes left         =", stats.Left)
+	fmt.Println("Nodes joined       =", stats.Joined)
+}
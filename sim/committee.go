@@ -0,0 +1,373 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Committee Sortition ---
+
+/*
+	SimulateCommitteeCtx agrees on a single chain height by height, but instead of every node only
+	a committee of CommitteeSize nodes (default N/3) votes, drawn anew for every round by VRF
+	sortition (see vrf.go), and the leader of the round proposes. Every member casts one vote per
+	round, for the proposal or against it. A proposal is committed once more than half of the
+	committee voted for it, and the round is over without a block once so many voted against that it
+	can no longer get there; the next round draws a new leader and committee. A member that has not
+	voted four SlotTimes into the round votes against, so rounds end on the committee's votes, which
+	every node sees, rather than on each node's own clock, and all nodes commit the same block at
+	every height.
+
+	Honest members vote for every valid proposal that extends their chain, and nodes never commit a
+	block that does not. Corrupt members vote for the proposals of corrupt leaders and against all
+	others, and corrupt leaders slip a forged transaction (an unsigned spend, negative TxID) into
+	their block. So a committee with a corrupt majority censors honest blocks and commits forged ones.
+
+	For a committee of k drawn from N nodes with C corrupt ones the number of corrupt members is
+	hypergeometric, which predicts how often a committee has a corrupt majority. The simulation
+	reports the prediction next to the share of corrupt-majority committees it actually drew. With
+	unequal stake (see -corrupt-stake) the prediction no longer applies, and neither does it while
+	corrupt leaders grind the seed (GrindAttempts): the leader is the lowest ticket and so always a
+	member of its own committee.
+*/
+
+// CommitteeStats reports the committees drawn in a committee simulation
+type CommitteeStats struct {
+	Size            int     // members per committee
+	Committees      int     // (height, round) committees drawn
+	CorruptMajority int     // committees with more than half corrupt members
+	Observed        float64 // % of committees with a corrupt majority
+	Predicted       float64 // % predicted by the hypergeometric distribution
+	Forged          int     // blocks with a forged transaction in the winning chain
+}
+
+type committeeMsg struct {
+	Height int
+	Round  int
+	From   int
+	Vote   bool  // false: a proposal
+	Yes    bool  // vote for Block, or against the round's proposal
+	Block  Block // the proposal, or the Hash the vote is about
+}
+
+// committeeSize resolves cfg.CommitteeSize against the default
+func committeeSize(cfg SimConfig) int {
+	if cfg.CommitteeSize <= 0 {
+		return max(1, cfg.N/3)
+	}
+	return min(cfg.CommitteeSize, cfg.N)
+}
+
+// forged reports whether tx was made up by a corrupt leader
+func forged(tx Transaction) bool {
+	return tx.TxID < 0
+}
+
+// corruptMajorityOdds is the probability that a committee of k drawn without replacement from
+// N nodes, C of them corrupt, has more than k/2 corrupt members
+func corruptMajorityOdds(N, C, k int) float64 {
+	logChoose := func(n, r int) float64 {
+		a, _ := math.Lgamma(float64(n + 1))
+		b, _ := math.Lgamma(float64(r + 1))
+		c, _ := math.Lgamma(float64(n - r + 1))
+		return a - b - c
+	}
+	p := 0.0
+	for x := k/2 + 1; x <= min(k, C); x++ {
+		if k-x <= N-C {
+			p += math.Exp(logChoose(C, x) + logChoose(N-C, k-x) - logChoose(N, k))
+		}
+	}
+	return p
+}
+
+func SimulateCommitteeCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "committee")
+	defer closeBus()
+
+	start := time.Now()
+	nodeCtx, stopNodes := context.WithCancel(ctx)
+	defer stopNodes()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan []committeeMsg, N)
+	queues := make([]*mailbox[committeeMsg], N)
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)
+		receivers[i] = make(chan []committeeMsg)
+		queues[i] = newMailbox(receivers[i])
+	}
+	G := createGenesisBlock(cfg.hashing(), 0)
+	names := nodeNames(N, C)
+	timeout := 4 * slotTime(cfg)
+	k := committeeSize(cfg)
+	election := newVRFElection(nodeStakes(N, C, cfg), C, cfg.GrindAttempts)
+
+	var winnerMu sync.Mutex
+	var winner = []Block{G}
+	var winnerType = ""
+	bus.Subscribe(func(e Event) {
+		winnerMu.Lock()
+		defer winnerMu.Unlock()
+		if len(e.Chain) > len(winner) {
+			winner = e.Chain
+			winnerType = getLabel(nodeIndex(e.Node, C), C)
+		}
+	}, EventNodeDone)
+
+	for i := range N {
+		go func() {
+			defer wg.Done()
+			publish := func(e Event) {
+				e.Sim, e.Node = "Committee", names[i]
+				bus.Publish(e)
+			}
+			corrupt := getLabel(i, C) == "corrupt"
+			inbox, receiver := inboxes[i], receivers[i]
+			chain := []Block{G}
+			pending := []Transaction{}
+			committed := make(map[TxID]bool)
+			height, round := 1, 0
+			members := []int{}
+			proposals := make(map[vrfKey]Block)
+			votes := make(map[vrfKey]map[string]map[int]bool) // Hash -> members that voted for it
+			against := make(map[vrfKey]map[int]bool)          // members that voted against the proposal
+			voted := make(map[vrfKey]bool)
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+
+			broadcast := func(m committeeMsg) {
+				m.From = i
+				for j := range N {
+					queues[j].send(m)
+				}
+			}
+			startRound := func(r int) {
+				round = r
+				members = election.committee(height, round, k)
+				timer.Reset(timeout)
+				if election.leader(height, round) != i {
+					return
+				}
+				txs := slices.Clone(pending)
+				if corrupt {
+					txs = append(txs, Transaction{TxID: -TxID(height*1000 + round), Sender: nodeName(N-1, C), Receiver: names[i]})
+				}
+				b := Block{Transactions: txs, PrevHash: chain[len(chain)-1].Hash, Nonce: height, Timestamp: time.Now().UnixNano(), Miner: names[i]}
+				b.Hash = calculateHash(cfg.hashing(), b)
+				publish(Event{Kind: EventMined, Block: b, Height: height})
+				broadcast(committeeMsg{Height: height, Round: round, Block: b})
+			}
+			vote := func(yes bool, hash string) {
+				key := vrfKey{height, round}
+				if voted[key] || !slices.Contains(members, i) {
+					return
+				}
+				voted[key] = true
+				broadcast(committeeMsg{Height: height, Round: round, Vote: true, Yes: yes, Block: Block{Hash: hash}})
+			}
+			extends := func(b Block) bool {
+				return b.PrevHash == chain[len(chain)-1].Hash
+			}
+			advance := func() { // commit the proposal of the current round once a committee majority voted for it
+				for {
+					key := vrfKey{height, round}
+					if len(against[key]) >= k-k/2 { // the proposal can no longer get a majority
+						startRound(round + 1)
+						continue
+					}
+					b, ok := proposals[key]
+					if !ok {
+						return
+					}
+					if corrupt {
+						vote(getLabel(nodeIndex(b.Miner, C), C) == "corrupt", b.Hash)
+					} else {
+						vote(extends(b) && !slices.ContainsFunc(b.Transactions, forged), b.Hash)
+					}
+					if len(votes[key][b.Hash]) <= k/2 || !extends(b) {
+						return
+					}
+					chain = append(chain, b)
+					for _, tx := range b.Transactions {
+						committed[tx.TxID] = true
+					}
+					pending = slices.DeleteFunc(pending, func(tx Transaction) bool { return committed[tx.TxID] })
+					publish(Event{Kind: EventCommit, Block: b, Height: height, Round: round})
+					height++
+					startRound(0)
+				}
+			}
+
+			startRound(0)
+			for {
+				select {
+				case <-nodeCtx.Done():
+					publish(Event{Kind: EventNodeDone, Chain: chain, Round: round})
+					return
+				case txs, ok := <-inbox:
+					if !ok {
+						inbox = nil
+						continue
+					}
+					for _, tx := range txs {
+						if !committed[tx.TxID] {
+							pending = append(pending, tx)
+						}
+					}
+				case msgs := <-receiver:
+					for _, m := range msgs {
+						key := vrfKey{m.Height, m.Round}
+						if m.Height < height {
+							continue
+						}
+						if !m.Vote {
+							if m.From == election.leader(m.Height, m.Round) {
+								proposals[key] = m.Block
+							}
+							continue
+						}
+						if !slices.Contains(election.committee(m.Height, m.Round, k), m.From) {
+							continue
+						}
+						if !m.Yes {
+							if against[key] == nil {
+								against[key] = make(map[int]bool)
+							}
+							against[key][m.From] = true
+							continue
+						}
+						if votes[key] == nil {
+							votes[key] = make(map[string]map[int]bool)
+						}
+						if votes[key][m.Block.Hash] == nil {
+							votes[key][m.Block.Hash] = make(map[int]bool)
+						}
+						votes[key][m.Block.Hash][m.From] = true
+					}
+					advance()
+				case <-timer.C:
+					vote(false, "")
+					advance()
+				}
+			}
+		}()
+	}
+
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	watch := newCommitWatch()
+	bus.Subscribe(func(e Event) {
+		b := e.Block
+		b.Transactions = slices.DeleteFunc(slices.Clone(b.Transactions), forged)
+		watch.add(b)
+	}, EventCommit)
+	txSent := SendTransactions(ctx, N, C, R, inboxes, newWorkload(cfg, N, C, nil), bus, nil)
+
+	watch.wait(ctx, txSent, 50*timeout)
+	stopNodes()
+	wg.Wait()
+	for i := range N {
+		queues[i].close()
+	}
+
+	stats := CommitteeStats{Size: k, Predicted: math.Round(10000*corruptMajorityOdds(N, C, k)) / 100}
+	for _, key := range election.rounds() {
+		stats.Committees++
+		corruptMembers := 0
+		for _, m := range election.committee(key.Height, key.Round, k) {
+			if m < C {
+				corruptMembers++
+			}
+		}
+		if corruptMembers > k/2 {
+			stats.CorruptMajority++
+		}
+	}
+	if stats.Committees > 0 {
+		stats.Observed = getPercentage(stats.CorruptMajority, stats.Committees)
+	}
+	confirmed := []Block{}
+	for _, b := range winner {
+		if slices.ContainsFunc(b.Transactions, forged) {
+			stats.Forged++
+		}
+		b.Transactions = slices.DeleteFunc(slices.Clone(b.Transactions), forged)
+		confirmed = append(confirmed, b)
+	}
+	corruptPercentage := getPercentage(C, N)
+	txConfirmed := countConfirmedTransactions(confirmed)
+	for _, b := range confirmed {
+		for _, tx := range b.Transactions {
+			bus.Publish(Event{Kind: EventConfirmed, Sim: "Committee", Tx: tx}) // the chain never includes a transaction twice
+		}
+	}
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.chainLatencies(confirmed))
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	throughput := timing.chainThroughput(confirmed, txConfirmed, duration)
+	leaderStats := election.result()
+
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Corrupt nodes      =", C)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Println("Winner             =", winnerType)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(nodes.stats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printLeaderStats(leaderStats)
+		printCommitteeStats(stats)
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "Committee",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 nodes.stats,
+		Leaders:               leaderStats,
+		Committee:             stats,
+		TimedOut:              timedOut,
+	}
+}
+
+func printCommitteeStats(stats CommitteeStats) {
+	fmt.Println("Committee size     =", stats.Size)
+	fmt.Println("Committees drawn   =", stats.Committees)
+	fmt.Println("Corrupt majorities =", stats.CorruptMajority)
+	fmt.Println("Observed %         =", stats.Observed)
+	fmt.Println("Predicted %        =", stats.Predicted)
+	fmt.Println("Forged blocks      =", stats.Forged)
+}
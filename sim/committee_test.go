@@ -0,0 +1,25 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestCommitteeChainsLink runs the minority-corrupt committee of the suite's first test several times at
+// once, with a slot time short enough that rounds time out while votes are in flight. Nodes used to move
+// rounds on their own timers and commit blocks that did not extend their chain.
+func TestCommitteeChainsLink(t *testing.T) {
+	for run := range 32 {
+		t.Run(fmt.Sprint(run), func(t *testing.T) {
+			t.Parallel()
+			cfg := SimConfig{N: 10, C: 2, R: 3, D: 1, P: 0.8, SlotTime: 2 * time.Millisecond, Events: NewEventBus()}
+			checker := checkInvariants(cfg.Events, cfg)
+			res := SimulateCommitteeCtx(context.Background(), cfg)
+			for _, v := range checker.violations(res) {
+				t.Error(v)
+			}
+		})
+	}
+}
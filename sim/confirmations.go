@@ -0,0 +1,144 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// --- Confirmation Depth ---
+
+/*
+	A transaction has k confirmations once k blocks are built on top of the block that includes it.
+	Every mined block is recorded, so at the end of a PoW simulation we know how deep each block got
+	in the tree of all mined blocks, whether or not it made it into the winning chain. A transaction
+	included in a block that was orphaned, and not confirmed by the winning chain anyway, is reversed.
+	Counting how many inclusions reached k confirmations and how many of those were reversed gives
+	the observed reversal probability per confirmation depth.
+*/
+
+// ConfirmationStats reports how deep the transactions of a PoW simulation were buried
+type ConfirmationStats struct {
+	Confirmations    map[TxID]int // confirmed tx -> blocks built on top of its first block in the winning chain
+	AvgConfirmations float64
+	MaxReversedDepth int          // most confirmations a reversed transaction had
+	ByDepth          []DepthCount // index k: inclusions that reached k confirmations
+}
+
+// DepthCount counts the transaction inclusions that reached a confirmation depth and how many were reversed
+type DepthCount struct {
+	Observed int
+	Reversed int
+}
+
+// confirmationTracker records the tree of all mined blocks
+type confirmationTracker struct {
+	mu     sync.Mutex
+	order  []Block // in the order they were mined, parents before children
+	parent map[string]string
+}
+
+func trackConfirmations(bus *EventBus) *confirmationTracker {
+	t := &confirmationTracker{parent: make(map[string]string)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.order = append(t.order, e.Block)
+		t.parent[e.Block.Hash] = e.Block.PrevHash
+	}, EventMined)
+	return t
+}
+
+func (t *confirmationTracker) result(chain []Block) ConfirmationStats {
+	// Blocks built on top of each block: its longest path of descendants
+	depth := make(map[string]int)
+	for k := len(t.order) - 1; k >= 0; k-- {
+		b := t.order[k]
+		if p, ok := t.parent[b.Hash]; ok {
+			depth[p] = max(depth[p], depth[b.Hash]+1)
+		}
+	}
+
+	stats := ConfirmationStats{Confirmations: make(map[TxID]int)}
+	inChain := make(map[string]bool)
+	for h, b := range chain {
+		inChain[b.Hash] = true
+		for _, tx := range b.Transactions {
+			if _, seen := stats.Confirmations[tx.TxID]; !seen {
+				stats.Confirmations[tx.TxID] = len(chain) - 1 - h
+			}
+		}
+	}
+
+	for _, b := range t.order {
+		d := depth[b.Hash]
+		for len(stats.ByDepth) <= d {
+			stats.ByDepth = append(stats.ByDepth, DepthCount{})
+		}
+		for _, tx := range b.Transactions {
+			_, confirmed := stats.Confirmations[tx.TxID]
+			stats.ByDepth[d].Observed++
+			if !inChain[b.Hash] && !confirmed { // reversed
+				stats.ByDepth[d].Reversed++
+				stats.MaxReversedDepth = max(stats.MaxReversedDepth, d)
+			}
+		}
+	}
+	for k := len(stats.ByDepth) - 2; k >= 0; k-- { // an inclusion that reached depth d also reached every k < d
+		stats.ByDepth[k].Observed += stats.ByDepth[k+1].Observed
+		stats.ByDepth[k].Reversed += stats.ByDepth[k+1].Reversed
+	}
+
+	total := 0
+	for _, c := range stats.Confirmations {
+		total += c
+	}
+	if len(stats.Confirmations) > 0 {
+		stats.AvgConfirmations = float64(total) / float64(len(stats.Confirmations))
+	}
+	return stats
+}
+
+// mergeDepths adds the per-depth counts of another simulation to total
+func mergeDepths(total, counts []DepthCount) []DepthCount {
+	for k, c := range counts {
+		if k == len(total) {
+			total = append(total, DepthCount{})
+		}
+		total[k].Observed += c.Observed
+		total[k].Reversed += c.Reversed
+	}
+	return total
+}
+
+// writeConfirmationReport writes the reversal probability per confirmation depth to a CSV file
+func writeConfirmationReport(path string, depths []DepthCount) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Confirmations", "Observed", "Reversed", "Reversal Probability"})
+	for k, c := range depths {
+		writer.Write([]string{
+			strconv.Itoa(k),
+			strconv.Itoa(c.Observed),
+			strconv.Itoa(c.Reversed),
+			fmt.Sprintf("%.4f", float64(c.Reversed)/float64(max(c.Observed, 1))),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func printConfirmationStats(stats ConfirmationStats) {
+	fmt.Printf("Avg confirmations  = %.2f\n", stats.AvgConfirmations)
+	fmt.Println("Max reversed depth =", stats.MaxReversedDepth)
+	for k, c := range stats.ByDepth {
+		fmt.Printf("  %2d confirmations: %d reversed of %d\n", k, c.Reversed, c.Observed)
+	}
+}
@@ -0,0 +1,244 @@
+package sim
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+// --- Double Spends ---
+
+/*
+	A double spend is a transaction whose sender also issues a conflicting twin: the same TxID
+	and funds, but a different receiver. Every other node gets the twin instead of the
+	original, so both sides end up in the DAG and each node has to pick one.
+
+	Nodes detect a conflict set when their view holds vertices with the same TxID but different
+	payloads, and keep the side with the larger cumulative weight (the side's vertices plus everything
+	approving them, directly or indirectly). The other sides are left out of the node's confident set,
+	and so is every vertex approving one of them, directly or indirectly: its past holds a spend the
+	node rejected.
+*/
+
+// ConflictStats reports the double spends of a DAG simulation and how nodes resolved them
+type ConflictStats struct {
+	Injected  int               // transactions issued twice
+	Detected  int               // conflict sets found by at least one node
+	Agreement float64           // % of node decisions that kept the side most nodes kept
+	Outcomes  []ConflictOutcome // one per detected conflict set, by TxID
+}
+
+// ConflictOutcome is how the nodes resolved one conflict set
+type ConflictOutcome struct {
+	TxID   TxID
+	Winner string         // receiver of the side most nodes kept
+	Votes  map[string]int // receiver -> nodes that kept that side
+}
+
+// doubleSpender returns a SendTransactions hook that turns a share rate of the transactions into
+// double spends, delivering the conflicting twin to every other node, and a counter of the double spends
+func doubleSpender(rate float64, N, C int) (func(node int, txs []Transaction) []Transaction, func() int) {
+	twins := make(map[TxID]Transaction) // TxID -> twin, only called from the SendTransactions goroutine
+	skip := make(map[TxID]bool)
+	split := func(node int, txs []Transaction) []Transaction {
+		for _, tx := range txs { // decide every transaction once
+			if _, twin := twins[tx.TxID]; !twin && !skip[tx.TxID] {
+				if rand.Float64() < rate {
+					twins[tx.TxID] = conflictingTwin(tx, N, C)
+				} else {
+					skip[tx.TxID] = true
+				}
+			}
+		}
+		if node%2 == 0 {
+			return txs
+		}
+		out := make([]Transaction, len(txs))
+		for k, tx := range txs {
+			if twin, ok := twins[tx.TxID]; ok {
+				out[k] = twin
+			} else {
+				out[k] = tx
+			}
+		}
+		return out
+	}
+	return split, func() int { return len(twins) }
+}
+
+// conflictingTwin spends the funds of tx again, to the next node with the same label as the receiver
+func conflictingTwin(tx Transaction, N, C int) Transaction {
+	twin := tx
+	for j := range N {
+		name := nodeName(j, C)
+		if name != tx.Sender && name != tx.Receiver && getLabel(j, C) == getLabel(nodeIndex(tx.Receiver, C), C) {
+			twin.Receiver = name
+			break
+		}
+	}
+	if twin.Receiver == tx.Receiver { // nobody else to pay, spend it back to the sender
+		twin.Receiver = tx.Sender
+	}
+	if len(tx.Outputs) > 0 { // a UTXO spend pays the new receiver instead, see utxo.go
+		twin.Outputs = slices.Clone(tx.Outputs)
+		twin.Outputs[0].Owner = twin.Receiver
+	}
+	return twin
+}
+
+// resolveConflicts finds the conflict sets of a node's view and returns the Hashes of the losing vertices.
+// Each resolved set is published as an EventConflict carrying the winning transaction and the losing ones.
+func resolveConflicts(HashMap map[string]Transaction, publish func(Event)) map[string]bool {
+	sides := conflictSets(HashMap)
+	return dropLosers(HashMap, sides, heaviestSides(HashMap, sides), publish)
+}
+
+// conflictSets groups the vertices of a view by TxID and side (receiver), keeping only the TxIDs with more than one side
+func conflictSets(HashMap map[string]Transaction) map[TxID]map[string][]string {
+	sides := spendSides(HashMap)
+	maps.DeleteFunc(sides, func(_ TxID, s map[string][]string) bool { return len(s) < 2 })
+	return sides
+}
+
+// spendSides groups the vertices of a view by TxID and side (receiver)
+func spendSides(HashMap map[string]Transaction) map[TxID]map[string][]string {
+	sides := make(map[TxID]map[string][]string)
+	for hash, tx := range HashMap {
+		if len(tx.Parents) == 0 { // genesis
+			continue
+		}
+		if sides[tx.TxID] == nil {
+			sides[tx.TxID] = make(map[string][]string)
+		}
+		sides[tx.TxID][tx.Receiver] = append(sides[tx.TxID][tx.Receiver], hash)
+	}
+	return sides
+}
+
+// heaviestSides picks the side of every conflict set with the largest cumulative weight
+func heaviestSides(HashMap map[string]Transaction, sides map[TxID]map[string][]string) map[TxID]string {
+	winners := make(map[TxID]string)
+	if len(sides) == 0 {
+		return winners
+	}
+	children := approvals(HashMap)
+	for id, set := range sides {
+		best := -1
+		for _, receiver := range slices.Sorted(maps.Keys(set)) { // ties go to the first receiver by name
+			if w := cumulativeWeight(set[receiver], children); w > best {
+				winners[id], best = receiver, w
+			}
+		}
+	}
+	return winners
+}
+
+// dropLosers returns the Hashes of the vertices on every side but the winner of each conflict set,
+// publishing each decision as an EventConflict. The winner may be a side the view does not hold.
+func dropLosers(HashMap map[string]Transaction, sides map[TxID]map[string][]string, winners map[TxID]string, publish func(Event)) map[string]bool {
+	losers := make(map[string]bool)
+	for _, id := range slices.Sorted(maps.Keys(sides)) {
+		lost := []Transaction{}
+		for receiver, hashes := range sides[id] {
+			if receiver == winners[id] {
+				continue
+			}
+			for _, hash := range hashes {
+				losers[hash] = true
+			}
+			lost = append(lost, HashMap[hashes[0]])
+		}
+		var won Transaction
+		if hashes, ok := sides[id][winners[id]]; ok {
+			won = HashMap[hashes[0]]
+		} else { // a side this view does not hold: the same spend to the winning receiver
+			won = lost[0]
+			won.Receiver = winners[id]
+		}
+		publish(Event{Kind: EventConflict, Tx: won, Txs: lost})
+	}
+	return losers
+}
+
+// approvals maps the Hash of every vertex of a view to the vertices approving it directly
+func approvals(HashMap map[string]Transaction) map[string][]string {
+	children := make(map[string][]string)
+	for hash, tx := range HashMap {
+		for _, p := range tx.Parents {
+			children[p] = append(children[p], hash)
+		}
+	}
+	return children
+}
+
+// cumulativeWeight counts the vertices in hashes and every vertex approving one of them
+func cumulativeWeight(hashes []string, children map[string][]string) int {
+	return len(approvedBy(hashes, children))
+}
+
+// approvedBy is the set of the vertices in hashes and every vertex approving one of them, directly or indirectly
+func approvedBy(hashes []string, children map[string][]string) map[string]bool {
+	visited := make(map[string]bool)
+	stack := slices.Clone(hashes)
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[h] {
+			continue
+		}
+		visited[h] = true
+		stack = append(stack, children[h]...)
+	}
+	return visited
+}
+
+// conflictTracker collects the EventConflict decisions of all nodes
+type conflictTracker struct {
+	mu    sync.Mutex
+	votes map[TxID]map[string]int // TxID -> winning receiver -> nodes
+}
+
+func trackConflicts(bus *EventBus) *conflictTracker {
+	t := &conflictTracker{votes: make(map[TxID]map[string]int)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.votes[e.Tx.TxID] == nil {
+			t.votes[e.Tx.TxID] = make(map[string]int)
+		}
+		t.votes[e.Tx.TxID][e.Tx.Receiver]++
+	}, EventConflict)
+	return t
+}
+
+func (t *conflictTracker) result(injected int) ConflictStats {
+	stats := ConflictStats{Injected: injected, Detected: len(t.votes)}
+	agree, total := 0, 0
+	for _, id := range slices.Sorted(maps.Keys(t.votes)) {
+		outcome := ConflictOutcome{TxID: id, Votes: t.votes[id]}
+		most := 0
+		for _, receiver := range slices.Sorted(maps.Keys(t.votes[id])) {
+			if n := t.votes[id][receiver]; n > most {
+				outcome.Winner, most = receiver, n
+			}
+			total += t.votes[id][receiver]
+		}
+		agree += most
+		stats.Outcomes = append(stats.Outcomes, outcome)
+	}
+	if total > 0 {
+		stats.Agreement = getPercentage(agree, total)
+	}
+	return stats
+}
+
+func printConflictStats(stats ConflictStats) {
+	fmt.Println("Double spends      =", stats.Injected)
+	fmt.Println("Conflicts detected =", stats.Detected)
+	fmt.Println("Agreement %        =", stats.Agreement)
+	for _, o := range stats.Outcomes {
+		fmt.Printf("  tx %s won by %-12s votes = %v\n", o.TxID, o.Winner, o.Votes)
+	}
+}
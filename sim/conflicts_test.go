@@ -0,0 +1,38 @@
+package sim
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveConflictsDropsApprovers(t *testing.T) {
+	// TxID 7 is spent twice: to honest1 by a (approved by b and c), to honest2 by x (approved by y)
+	// d approves both the winner's b and the loser's y
+	HashMap := map[string]Transaction{
+		"g1": {Hash: "g1"},
+		"g2": {Hash: "g2"},
+		"a":  {Hash: "a", TxID: 7, Receiver: "honest1", Parents: []string{"g1", "g2"}},
+		"x":  {Hash: "x", TxID: 7, Receiver: "honest2", Parents: []string{"g1", "g2"}},
+		"b":  {Hash: "b", TxID: 1, Parents: []string{"a", "g1"}},
+		"c":  {Hash: "c", TxID: 2, Parents: []string{"a", "b"}},
+		"y":  {Hash: "y", TxID: 3, Parents: []string{"x", "g2"}},
+		"d":  {Hash: "d", TxID: 4, Parents: []string{"b", "y"}},
+	}
+	var events []Event
+	losers := resolveConflicts(HashMap, func(e Event) { events = append(events, e) })
+	if !losers["x"] || len(losers) != 1 {
+		t.Fatalf("losers = %v, want x", losers)
+	}
+	if len(events) != 1 || events[0].Kind != EventConflict || events[0].Tx.Receiver != "honest1" {
+		t.Errorf("events = %v, want one conflict won by honest1", events)
+	}
+
+	confident := []string{}
+	for _, tx := range confidentTransactions(HashMap, confidenceScores(HashMap), losers) {
+		confident = append(confident, tx.Hash)
+	}
+	slices.Sort(confident)
+	if want := []string{"a", "b", "c", "g1", "g2"}; !slices.Equal(confident, want) {
+		t.Errorf("confident = %v, want %v: x and everything approving it (y, d) are out", confident, want)
+	}
+}
@@ -0,0 +1,263 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --- Pause, Step and Resume ---
+
+/*
+	With -control <addr> the tester serves HTTP endpoints to freeze the running simulation, step it and
+	let it go again, and to look at its state while it is frozen:
+	- POST /pause freezes it, POST /resume lets it run;
+	- POST /step?by=event|block|round&n=1 lets n more events, mined blocks or workload rounds through
+	  (a round: until the transactions of the round after are about to be sent), then freezes it again,
+	  answering with the state once it is frozen;
+	- GET /state is the state as JSON: the simulation, the events, blocks and rounds let through, the
+	  events held at the gate, and per node its height, tip, blocks mined and accepted and pending
+	  transactions, as -tui estimates them;
+	- GET /tree is the block tree so far in GraphViz DOT, as -block-tree writes it.
+	-paused starts the suite frozen, and a pause lasts over the end of a simulation into the next.
+
+	Freezing works through the bus: while paused, the event a node publishes is held, before any
+	subscriber sees it, until a step or resume lets it through, and every other node blocks as soon as
+	it publishes too, so nothing that shows in the events happens meanwhile. Nodes freeze at their next event, not at once: a miner keeps
+	hashing until it finds its block. The simulators' own timers (BFT round timeouts, Raft elections,
+	PoA and DPoS slots) and -timeout keep running while frozen, so resuming a timer-driven simulator
+	may cost it rounds.
+*/
+
+const controlStepWait = 10 * time.Second
+
+// simControl gates the events of the running simulation. The bus calls handle before its subscribers
+// in every publishing goroutine, so each publisher blocks in it while the simulation is frozen.
+type simControl struct {
+	ctx  context.Context
+	dash *dashboard // the node state, see tui.go
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	paused  bool
+	step    string // unit of the current step: event, block or round
+	to      int    // the step lets events through up to this count of its unit
+	running bool
+	test    int
+	mode    string
+	events  int
+	blocks  int
+	round   int      // latest workload round sent, -1 before the first
+	held    []*Event // the events the gate holds, one per blocked publisher
+	waits   int      // times the gate held an event
+	tree    *blockTree
+}
+
+func newSimControl(ctx context.Context, paused bool) *simControl {
+	c := &simControl{ctx: ctx, dash: newDashboard(io.Discard, 0), paused: paused, round: -1, tree: newBlockTree()}
+	c.cond = sync.NewCond(&c.mu)
+	go func() {
+		<-ctx.Done() // let a held event go, the simulation is cancelled
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}()
+	return c
+}
+
+// watch gates the events of bus, the simulation of mode in test, until the returned function ends it
+func (c *simControl) watch(bus *EventBus, test int, mode string, t BenchmarkConfig) func(SimResult) {
+	c.mu.Lock()
+	c.running, c.test, c.mode = true, test, mode
+	c.events, c.blocks, c.round, c.step, c.tree = 0, 0, -1, "", newBlockTree()
+	c.mu.Unlock()
+	c.dash.reset(test, mode, t)
+	bus.setGate(c.handle)
+	return func(SimResult) {
+		c.mu.Lock()
+		c.running = false // lets a held event go, its publisher may outlive the simulation
+		c.cond.Broadcast()
+		c.mu.Unlock()
+		bus.setGate(nil)
+	}
+}
+
+// holds reports whether the gate holds e back
+func (c *simControl) holds(e Event) bool {
+	return c.paused && c.running && c.ctx.Err() == nil && !c.allows(e)
+}
+
+// allows reports whether the current step lets e through
+func (c *simControl) allows(e Event) bool {
+	switch c.step {
+	case "event":
+		return c.events < c.to
+	case "block":
+		return c.blocks < c.to
+	case "round":
+		return e.Kind != EventSent || e.Round <= c.to
+	}
+	return false
+}
+
+func (c *simControl) handle(e Event) {
+	c.mu.Lock()
+	if c.holds(e) {
+		c.held = append(c.held, &e)
+		c.waits++
+		for c.holds(e) {
+			c.cond.Wait()
+		}
+		c.held = slices.DeleteFunc(c.held, func(h *Event) bool { return h == &e })
+	}
+	c.events++
+	switch e.Kind {
+	case EventMined:
+		c.blocks++
+	case EventSent:
+		c.round = max(c.round, e.Round)
+	}
+	c.tree.handle(e)
+	c.mu.Unlock()
+	if slices.Contains(tuiKinds, e.Kind) {
+		c.dash.handle(e)
+	}
+}
+
+// controlNode is a node in the state
+type controlNode struct {
+	Node     string
+	Height   int
+	Tip      string `json:",omitempty"`
+	Mined    int
+	Accepted int
+	Pending  int
+}
+
+// controlState is the answer of /state
+type controlState struct {
+	Paused  bool
+	Running bool
+	Test    int
+	Mode    string
+	Events  int
+	Blocks  int
+	Round   int
+	Held    []string `json:",omitempty"` // the events the gate holds
+	Nodes   []controlNode
+}
+
+func (c *simControl) state() controlState {
+	c.mu.Lock()
+	s := controlState{Paused: c.paused, Running: c.running, Test: c.test, Mode: c.mode, Events: c.events, Blocks: c.blocks, Round: c.round}
+	for _, e := range c.held {
+		s.Held = append(s.Held, describeEvent(*e))
+	}
+	c.mu.Unlock()
+	c.dash.mu.Lock()
+	defer c.dash.mu.Unlock()
+	for name, n := range c.dash.nodes {
+		s.Nodes = append(s.Nodes, controlNode{Node: name, Height: n.height, Tip: n.tip, Mined: n.mined, Accepted: n.accepted, Pending: c.dash.pending(name, n)})
+	}
+	slices.SortFunc(s.Nodes, func(a, b controlNode) int { return cmpNodeNames(a.Node, b.Node) })
+	return s
+}
+
+// stepBy starts a step of n units, returning how many times the gate held an event before it
+func (c *simControl) stepBy(unit string, n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused, c.step = true, unit
+	switch unit {
+	case "event":
+		c.to = c.events + n
+	case "block":
+		c.to = c.blocks + n
+	case "round":
+		c.to = c.round + n
+	}
+	c.cond.Broadcast()
+	return c.waits
+}
+
+// frozen waits until the gate holds an event again after waits, the simulation ends or timeout passes
+func (c *simControl) frozen(waits int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && c.ctx.Err() == nil {
+		c.mu.Lock()
+		done := c.waits > waits || !c.running
+		c.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (c *simControl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	answer := func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c.state())
+	}
+	switch r.Method + " " + r.URL.Path {
+	case "POST /pause":
+		c.mu.Lock()
+		c.paused, c.step = true, ""
+		c.mu.Unlock()
+		answer()
+	case "POST /resume":
+		c.mu.Lock()
+		c.paused, c.step = false, ""
+		c.cond.Broadcast()
+		c.mu.Unlock()
+		answer()
+	case "POST /step":
+		unit := r.URL.Query().Get("by")
+		if unit == "" {
+			unit = "event"
+		}
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if r.URL.Query().Get("n") == "" {
+			n, err = 1, nil
+		}
+		if err != nil || n < 1 || !slices.Contains([]string{"event", "block", "round"}, unit) {
+			http.Error(w, fmt.Sprintf("step by event, block or round, n >= 1 (got by=%q n=%q)", unit, r.URL.Query().Get("n")), http.StatusBadRequest)
+			return
+		}
+		c.frozen(c.stepBy(unit, n), controlStepWait)
+		answer()
+	case "GET /state":
+		answer()
+	case "GET /tree":
+		c.mu.Lock()
+		dot := c.tree.dot(fmt.Sprintf("test%d-%s", c.test, c.mode), "")
+		c.mu.Unlock()
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		io.WriteString(w, dot)
+	default:
+		http.Error(w, "POST /pause, /resume or /step?by=event|block|round&n=1, GET /state or /tree", http.StatusNotFound)
+	}
+}
+
+// serveControl serves c at addr until ctx is done
+func serveControl(ctx context.Context, log *slog.Logger, addr string, c *simControl) {
+	server := &http.Server{Addr: addr, Handler: c}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	log.Info("serving controls", "url", "http://"+addr+"/state", "paused", c.paused)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error("control server failed", "addr", addr, "err", err)
+		}
+	}()
+}
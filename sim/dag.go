@@ -0,0 +1,640 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/bits"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// computeHash is the hash (h, SHA-256 by default) of the canonical encoding of tx, see canonical.go
+func computeHash(h hashFunc, tx Transaction) string {
+	buf := hashBuffers.Get().(*[]byte)
+	defer putHashBuffer(buf)
+	*buf = canonicalTransaction((*buf)[:0], tx)
+	return hashHex(h.Sum(*buf))
+}
+
+// mineTransaction searches for a valid nonce, returning false if ctx is cancelled first
+func mineTransaction(ctx context.Context, h hashFunc, tx Transaction, difficulty int) (Transaction, bool) {
+	buf := hashBuffers.Get().(*[]byte)
+	defer putHashBuffer(buf)
+	*buf = canonicalTransaction((*buf)[:0], tx)
+	nonce, sum, ok := mineNonce(ctx, h, *buf, tx.Nonce, difficulty)
+	tx.Nonce, tx.Hash = nonce, hashHex(sum)
+	return tx, ok
+}
+
+func createGenesis(h hashFunc, difficulty int) []Transaction {
+	gen := []Transaction{}
+	for i := range 2 {
+		tx := Transaction{
+			TxID:     genesisTxIDs + TxID(i),
+			Sender:   "genesis",
+			Receiver: "network",
+			Parents:  []string{},
+		}
+		mineTransaction(context.Background(), h, tx, difficulty)
+		gen = append(gen, tx)
+	}
+	return gen
+}
+
+func pickParents(Nodes []Transaction) []string {
+	n := len(Nodes)
+	i := rand.Intn(n)
+	j := rand.Intn(n)
+	for i == j {
+		j = rand.Intn(n)
+	}
+	par := []string{Nodes[i].Hash, Nodes[j].Hash}
+	return par
+}
+
+/*
+confidenceScores maps the Hash of every transaction reachable from a tip (an unreferenced transaction)
+to the number of tips that reference it, directly or indirectly.
+
+Instead of walking the DAG once per tip, the reachable tips are computed once per transaction
+as a bitset, in a single pass from the tips towards genesis (a reverse topological order):
+a transaction is reached by its own bit if it is a tip, plus every tip that reaches one of its children.
+*/
+func confidenceScores(HashMap map[string]Transaction) map[string]int {
+	index := make(map[string]int, len(HashMap)) // Hash -> position in txs
+	txs := make([]Transaction, 0, len(HashMap))
+	for hash, tx := range HashMap {
+		index[hash] = len(txs)
+		txs = append(txs, tx)
+	}
+
+	// parents[v] are the (distinct, known) parents of txs[v], children[v] counts the transactions referencing it
+	parents := make([][]int, len(txs))
+	children := make([]int, len(txs))
+	for v, tx := range txs {
+		for _, hash := range tx.Parents {
+			u, ok := index[hash]
+			if !ok || slices.Contains(parents[v], u) {
+				continue
+			}
+			parents[v] = append(parents[v], u)
+			children[u]++
+		}
+	}
+
+	// Tips get one bit each
+	stack := []int{}
+	tipBit := make(map[int]int)
+	for v := range txs {
+		if children[v] == 0 {
+			tipBit[v] = len(tipBit)
+			stack = append(stack, v)
+		}
+	}
+	words := (len(tipBit) + 63) / 64
+	reach := make([][]uint64, len(txs))
+
+	Confidence := make(map[string]int, len(txs))
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reach[v] == nil {
+			reach[v] = make([]uint64, words)
+		}
+		if bit, isTip := tipBit[v]; isTip {
+			reach[v][bit/64] |= 1 << (bit % 64)
+		}
+
+		count := 0
+		for _, w := range reach[v] {
+			count += bits.OnesCount64(w)
+		}
+		Confidence[txs[v].Hash] = count
+
+		for _, u := range parents[v] {
+			if reach[u] == nil {
+				reach[u] = make([]uint64, words)
+			}
+			for k, w := range reach[v] {
+				reach[u][k] |= w
+			}
+			children[u]--
+			if children[u] == 0 { // every child is done, u is final
+				stack = append(stack, u)
+			}
+		}
+		reach[v] = nil // no longer needed once merged into the parents
+	}
+	return Confidence
+}
+
+// confidentTransactions lists the transactions of HashMap that have a confidence score, leaving out the
+// losers of conflicts and every transaction approving one of them, directly or indirectly
+func confidentTransactions(HashMap map[string]Transaction, Confidence map[string]int, losers map[string]bool) []Transaction {
+	excluded := losers
+	if len(losers) > 0 {
+		excluded = approvedBy(slices.Collect(maps.Keys(losers)), approvals(HashMap))
+	}
+	confident := []Transaction{}
+	for k := range Confidence {
+		if excluded[k] {
+			continue
+		}
+		confident = append(confident, HashMap[k])
+	}
+	return confident
+}
+
+func isHonest(name string) bool {
+	h := "honest"
+	return len(name) > len(h) && name[0:len(h)] == h
+}
+
+func isCorrupt(name string) bool {
+	c := "corrupt"
+	return len(name) > len(c) && name[0:len(c)] == c
+}
+
+/*
+	N = total number of nodes
+	C = number of corrupt nodes
+	R = number of rounds of transactions
+	D = difficulty (NOTE: run time scales exponentially with difficulty)
+	p = transaction reach {0 <= p <= 1} (i.e. p = 0.5 means each transaction reaches ~50% of nodes)
+*/
+
+func SimulateDAG(N, C, R, D int, p float64, verbose bool) (int, int, float64, int, int, int, int, float64, string, time.Duration, float64, float64) {
+	res := SimulateDAGCtx(context.Background(), SimConfig{N: N, C: C, R: R, D: D, P: p, Verbose: verbose})
+	return res.N, res.C, res.CorruptPercentage, res.R, res.D, res.TxSent, res.TxConfirmed, res.TxConfirmedPercentage, res.Winner, res.Duration, res.AvgConfHonest, res.AvgConfCorrupt
+}
+
+// SimulateDAGCtx runs the DAG simulation until it finishes, ctx is cancelled or cfg.Timeout passes.
+// A cut short simulation still returns the confidence metrics of the transactions mined so far.
+func SimulateDAGCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "dag")
+	defer closeBus()
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+	var minedWG sync.WaitGroup // nodes still mining (and broadcasting)
+	minedWG.Add(N)
+
+	// Note: DAG doesn't have Blocks, Transactions are the only object
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan []Transaction, N)
+	buffer := receiverBuffer(cfg, 0)
+	var G = createGenesis(cfg.hashing(), D)
+	G1, G2 := G[0], G[1]
+	G1.Hash = "gen1"
+	G2.Hash = "gen2"
+
+	/*
+		NOTE:
+		The current implementation allows for duplicate transactions in the final blockchain result
+		The check for duplicate transactions is omitted in order to speed up the simulation
+		However, the corrupt nodes have not been configured to take advantage of this
+	*/
+	var trackerMu sync.Mutex
+	transactionTracker := make(map[TxID]int)
+	transactionMap := make(map[TxID]Transaction)
+
+	// Aggregate Results
+	bus.Subscribe(func(e Event) {
+		trackerMu.Lock()
+		defer trackerMu.Unlock()
+		for _, tx := range e.Txs {
+			_, exists := transactionTracker[tx.TxID]
+			if !exists {
+				transactionTracker[tx.TxID] = 0
+				transactionMap[tx.TxID] = tx
+			}
+			transactionTracker[tx.TxID] = transactionTracker[tx.TxID] + 1
+		}
+	}, EventNodeDone)
+
+	// Broadcasts go through a per-node delivery queue unless DirectDelivery asks for the lossy non-blocking send
+	queues := make([]*mailbox[Transaction], N)
+	var snapshotMismatches atomic.Int64       // SnapshotCheck: nodes whose pruned view disagreed with the full one
+	controls := make([]*mailbox[solidMsg], N) // Solidify: missing-parent requests and responses
+	controlOut := make([]chan []solidMsg, N)
+	names := nodeNames(N, C)
+	miners := newMinerPool(cfg)
+	tips := tipSelection(cfg)
+	var aval *avalanche // only set if double spends are settled by subsampled voting
+	if cfg.Avalanche {
+		aval = newAvalanche(cfg)
+	}
+
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)           // initialize each inbox
+		receivers[i] = make(chan []Transaction, buffer) // initialize each receiver
+		if !cfg.DirectDelivery {
+			queues[i] = newMailbox(receivers[i])
+		}
+		if cfg.Solidify {
+			controlOut[i] = make(chan []solidMsg)
+			controls[i] = newMailbox(controlOut[i])
+		}
+	}
+
+	for i := range N {
+		go func() {
+			defer wg.Done()
+			publish := func(e Event) {
+				e.Sim, e.Node = "DAG", names[i]
+				bus.Publish(e)
+			}
+
+			HashMap := make(map[string]Transaction) // maps Hash to Transaction
+			HashMap[G1.Hash] = G1
+			HashMap[G2.Hash] = G2
+
+			Nodes := []Transaction{}
+			Nodes = append(Nodes, G1)
+			Nodes = append(Nodes, G2)
+			var walk *tangleWalk // only kept up to date for MCMC tip selection
+			if tips == TipsMCMC {
+				walk = newTangleWalk(cfg.TipAlpha, G1, G2)
+			}
+			snaps := newSnapshotter(cfg.SnapshotInterval)
+			var full map[string]Transaction // SnapshotCheck: the view without pruning
+			if cfg.SnapshotCheck && cfg.SnapshotInterval > 0 {
+				full = maps.Clone(HashMap)
+			}
+			known := func(hash string) bool {
+				_, exists := HashMap[hash]
+				return exists || snaps.known(hash)
+			}
+			insert := func(t Transaction) {
+				if snaps.known(t.Hash) { // already part of the snapshot root
+					return
+				}
+				HashMap[t.Hash] = t
+				Nodes = append(Nodes, t)
+			}
+			added := func(t Transaction) { // bookkeeping after t joined the view
+				if walk != nil {
+					walk.add(t)
+				}
+				if full != nil {
+					full[t.Hash] = t
+				}
+				if snaps.due() {
+					var pruned []Transaction
+					Nodes, pruned = snaps.snapshot(HashMap, Nodes)
+					publish(Event{Kind: EventSnapshot, Txs: pruned})
+				}
+			}
+			var solid *solidifier // only set if unsolid transactions are held instead of rejected
+			if cfg.Solidify {
+				solid = newSolidifier()
+			}
+			// receive adds t to the view, followed by every held transaction that was only waiting for it.
+			// recovered is set if t itself was fetched from a peer.
+			receive := func(t Transaction, recovered bool) {
+				pending := []Transaction{t}
+				for len(pending) > 0 {
+					t := pending[0]
+					pending = pending[1:]
+					missing := []string{}
+					for _, p := range t.Parents {
+						if !known(p) {
+							missing = append(missing, p)
+						}
+					}
+					if len(missing) > 0 {
+						if solid == nil {
+							publish(Event{Kind: EventRejected, Block: dagVertex(t)})
+							continue
+						}
+						for _, p := range solid.hold(t, missing) {
+							publish(Event{Kind: EventRequested, Block: Block{Hash: p}})
+							for _, j := range solidPeers(i, N, C) {
+								controls[j].send(solidMsg{Want: p, From: i})
+							}
+						}
+						continue
+					}
+					insert(t)
+					publish(Event{Kind: EventAccepted, Block: dagVertex(t)})
+					if recovered {
+						publish(Event{Kind: EventSolidified, Block: dagVertex(t)})
+					}
+					added(t)
+					if solid != nil {
+						pending = append(pending, solid.arrived(t.Hash)...)
+					}
+					recovered = true // anything released from here on waited for a parent
+				}
+			}
+			var control chan []solidMsg // nil (never ready) without solidification
+			if solid != nil {
+				control = controlOut[i]
+			}
+			var exit = false
+			var trigger MineTrigger
+			inbox := inboxes[i]
+			mining := true
+			stopMining := func() { // keep accepting peers' transactions until the receivers are closed
+				if mining {
+					mining = false
+					minedWG.Done()
+				}
+			}
+			transactions := []Transaction{} // unprocessed transactions
+
+			for !exit {
+				select {
+				case mined, ok := <-receivers[i]: // listen for mined transactions
+					if !ok { // every node finished mining
+						exit = true
+					}
+					for _, t := range mined {
+						receive(t, false)
+					}
+				case msgs := <-control: // missing-parent requests and responses
+					for _, m := range msgs {
+						if m.Want != "" {
+							if t, exists := HashMap[m.Want]; exists {
+								controls[m.From].send(solidMsg{Tx: t})
+							}
+						} else if !known(m.Tx.Hash) {
+							receive(m.Tx, true)
+						}
+					}
+				case txs, ok := <-inbox: // read a round of unmined transactions
+					if !ok { // no more transactions, stop once the pending ones are mined
+						inbox = nil
+						if len(transactions) == 0 {
+							stopMining()
+						}
+					} else {
+						transactions = append(transactions, txs...)
+					}
+				case <-ctx.Done(): // simulation cancelled
+					stopMining()
+					exit = true
+				case <-trigger.Ready(len(transactions) > 0 && len(receivers[i]) == 0): // mine transaction (only when there is work and nothing waiting)
+					if !trigger.Fire() {
+						continue
+					}
+					t := transactions[len(transactions)-1] // popped once mined, cancelling keeps it pending
+					if walk != nil {
+						t.Parents = walk.pickParents()
+					} else {
+						t.Parents = pickParents(Nodes)
+					}
+					if !miners.acquire(ctx) { // cancelled while waiting to mine
+						continue
+					}
+					t, ok := mineTransaction(ctx, cfg.hashing(), t, D)
+					miners.release()
+					if !ok { // cancelled while mining
+						continue
+					}
+					transactions = transactions[:len(transactions)-1]
+					insert(t)
+					publish(Event{Kind: EventMined, Block: dagVertex(t)})
+					added(t)
+					if solid != nil {
+						for _, child := range solid.arrived(t.Hash) {
+							receive(child, true)
+						}
+					}
+
+					l1 := getLabel(i, C)
+					delivered := []string{}
+					for j := range N { // broadcast transaction
+						l2 := getLabel(j, C)
+						if i == j || (l1 == "corrupt" && l2 == "honest") { // corrupt nodes only broadcast to other corrupt nodes
+							continue
+						}
+						if queues[j] != nil { // queued for delivery, never dropped
+							queues[j].send(t)
+							delivered = append(delivered, names[j])
+							continue
+						}
+						select {
+						case receivers[j] <- []Transaction{t}: // successfully sent
+							delivered = append(delivered, names[j])
+						default: // channel full or busy -- unable to send block
+							publish(Event{Kind: EventDropped, Block: dagVertex(t), Peer: names[j]})
+						}
+					}
+					publish(Event{Kind: EventDelivered, Block: dagVertex(t), Peers: delivered})
+					if inbox == nil && len(transactions) == 0 { // that was the last of the work
+						stopMining()
+					}
+				}
+			}
+
+			if solid != nil { // still missing a parent
+				for _, t := range solid.unsolid {
+					publish(Event{Kind: EventRejected, Block: dagVertex(t)})
+				}
+			}
+
+			// Calculate Confidence Scores
+			Confidence := confidenceScores(HashMap)
+			var losers map[string]bool
+			if aval != nil { // double spends: vote with the other nodes
+				losers = aval.resolve(ctx, i, HashMap, publish)
+			} else { // double spends: keep the heavier side
+				losers = resolveConflicts(HashMap, publish)
+			}
+			confident := confidentTransactions(HashMap, Confidence, losers)
+			confident = append(confident, snaps.history...) // pruned history was confirmed
+
+			if full != nil {
+				fullConfidence := confidenceScores(full)
+				fullLosers := resolveConflicts(full, func(Event) {})
+				if !sameConfidence(fullConfidence, Confidence, confidentTransactions(full, fullConfidence, fullLosers), confident) {
+					snapshotMismatches.Add(1)
+				}
+			}
+			publish(Event{Kind: EventNodeDone, Txs: confident})
+		}()
+	}
+
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	drops := trackDrops(bus)
+	conflicts := trackConflicts(bus)
+	snapshots := trackSnapshots(bus)
+	solidification := trackSolidification(bus)
+	decisions := trackAvalanche(bus)
+	var split func(int, []Transaction) []Transaction
+	doubleSpends := func() int { return 0 }
+	if cfg.DoubleSpend > 0 {
+		split, doubleSpends = doubleSpender(cfg.DoubleSpend, N, C)
+	}
+	txSent := SendTransactions(ctx, N, C, R, inboxes, newWorkload(cfg, N, C, nil), bus, split) // same function from pow.go
+
+	// Once nothing is broadcast anymore, close the receivers after the queued transactions are delivered
+	minedWG.Wait()
+	for i := range N {
+		if queues[i] != nil {
+			queues[i].flush()
+		} else {
+			close(receivers[i])
+		}
+	}
+
+	wg.Wait()
+	for i := range N {
+		if queues[i] != nil {
+			queues[i].close()
+		}
+		if controls[i] != nil {
+			controls[i].close()
+		}
+	}
+
+	corruptPercentage := getPercentage(C, N)
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	dropStats := drops.result()
+	conflictStats := conflicts.result(doubleSpends())
+	snapshotStats := snapshots.stats
+	snapshotStats.Mismatches = int(snapshotMismatches.Load())
+	var avalancheStats AvalancheStats
+	if aval != nil {
+		avalancheStats = decisions.result(aval)
+	}
+
+	// Output Results
+	type kv struct {
+		Key   Transaction
+		Value int
+	}
+
+	var sortedConfidence []kv
+	for k, v := range transactionTracker {
+		sortedConfidence = append(sortedConfidence, kv{transactionMap[k], v})
+	}
+
+	sort.Slice(sortedConfidence, func(i, j int) bool {
+		return sortedConfidence[i].Value > sortedConfidence[j].Value
+	})
+
+	totalConfidence := 0
+	honestCount := 0
+	corruptCount := 0
+	honestConfidence := 0
+	corruptConfidence := 0
+	for _, kv := range sortedConfidence {
+		totalConfidence += kv.Value
+		if isHonest(kv.Key.Sender) {
+			honestCount += 1
+			honestConfidence += kv.Value
+		} else if isCorrupt(kv.Key.Sender) {
+			corruptCount += 1
+			corruptConfidence += kv.Value
+		}
+	}
+
+	avgConf_Honest := 0.0
+	if honestCount > 0 {
+		avgConf_Honest = float64(honestConfidence) / float64(honestCount)
+	}
+	avgConf_Corrupt := 0.0
+	if corruptCount > 0 {
+		avgConf_Corrupt = float64(corruptConfidence) / float64(corruptCount)
+	}
+
+	txConfirmed := 0
+	confirmedTxs := []Transaction{}
+	avgConfidence := float64(totalConfidence) / float64(len(sortedConfidence))
+
+	for _, kv := range sortedConfidence {
+		if float64(kv.Value) >= avgConfidence {
+			txConfirmed += 1
+			confirmedTxs = append(confirmedTxs, kv.Key)
+			bus.Publish(Event{Kind: EventConfirmed, Sim: "DAG", Tx: kv.Key})
+		}
+	}
+
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.txLatencies(confirmedTxs))
+	throughput := timing.tangleThroughput(txConfirmed, duration)
+	winnerType := "honest"
+	if avgConf_Corrupt > avgConf_Honest {
+		winnerType = "corrupt"
+	}
+
+	// Print Result
+	if verbose {
+		fmt.Println("Transaction Confidence Levels:\n===============================")
+		for _, kv := range sortedConfidence {
+			fmt.Printf("Transaction: %s, Confidence: %d\n", formatTransaction(kv.Key), kv.Value)
+		}
+
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Corrupt nodes      =", C)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("Difficulty         =", D)
+		fmt.Println("Receiver buffer    =", buffer)
+		fmt.Println("Tip selection      =", tips, "alpha =", cfg.TipAlpha)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Println("Winner             =", winnerType)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		fmt.Printf("avgConf_Honest      = %.2f\n", avgConf_Honest)
+		fmt.Printf("avgConf_Corrupt     = %.2f\n", avgConf_Corrupt)
+		printNodeStats(nodes.stats)
+		printDropStats(dropStats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printConflictStats(conflictStats)
+		printSnapshotStats(snapshotStats)
+		printSolidStats(solidification.stats)
+		if aval != nil {
+			printAvalancheStats(avalancheStats)
+		}
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "DAG",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		ReceiverBuffer:        buffer,
+		TipSelection:          tips,
+		TipAlpha:              cfg.TipAlpha,
+		Conflicts:             conflictStats,
+		Snapshots:             snapshotStats,
+		Solidification:        solidification.stats,
+		Avalanche:             avalancheStats,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		AvgConfHonest:         avgConf_Honest,
+		AvgConfCorrupt:        avgConf_Corrupt,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 nodes.stats,
+		Drops:                 dropStats,
+		TimedOut:              timedOut,
+	}
+}
@@ -0,0 +1,82 @@
+package sim
+
+import "sync"
+
+// mailbox is an unbounded delivery queue in front of a node's receiver channel.
+// Senders never block and nothing is dropped: a dedicated goroutine (run) hands
+// everything queued so far to the receiver channel as one batch whenever the node
+// is ready to read, so a busy node catches up in a single receive.
+type mailbox[T any] struct {
+	mu       sync.Mutex
+	queue    []T
+	wake     chan struct{} // signals run that queue is non-empty
+	out      chan []T
+	done     chan struct{}
+	flushing bool // close out once queue is empty
+}
+
+func newMailbox[T any](out chan []T) *mailbox[T] {
+	m := &mailbox[T]{
+		wake: make(chan struct{}, 1),
+		out:  out,
+		done: make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+func (m *mailbox[T]) send(v T) {
+	m.mu.Lock()
+	m.queue = append(m.queue, v)
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default: // run is already signalled
+	}
+}
+
+func (m *mailbox[T]) run() {
+	for {
+		m.mu.Lock()
+		if len(m.queue) == 0 {
+			flushed := m.flushing
+			m.mu.Unlock()
+			if flushed {
+				close(m.out)
+				return
+			}
+			select {
+			case <-m.wake:
+				continue
+			case <-m.done:
+				return
+			}
+		}
+		batch := m.queue
+		m.queue = nil
+		m.mu.Unlock()
+
+		select {
+		case m.out <- batch:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// flush closes the receiver channel once every queued message has been delivered.
+// Nothing may be sent after flush.
+func (m *mailbox[T]) flush() {
+	m.mu.Lock()
+	m.flushing = true
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close stops the delivery goroutine, undelivered messages are discarded
+func (m *mailbox[T]) close() {
+	close(m.done)
+}
@@ -0,0 +1,360 @@
+package sim
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// --- Discrete-Event PoW ---
+
+/*
+	SimulateDESCtx runs the PoW simulation on a discrete-event engine instead of goroutines: a single
+	loop pops events off a queue ordered by virtual time, so nothing is hashed or waited for and a run
+	is as fast as its events allow. Everything random is drawn from one generator seeded with
+	SimConfig.Seed, so the same seed gives the same run, event for event.
+
+	The rules follow the goroutine simulation: round k of transactions (generated by the workload, see
+	workload.go) reaches the nodes at virtual time k * BlockTime, or every transaction at its own arrival
+	time with poisson:<rate>, and a node mines while it holds transactions, taking them all (up to
+	SimConfig.MaxBlockTxs) into its next block. Every node has the same hash power, so the network
+	finds a block every BlockTime on average: each node's next block is exponentially distributed with
+	mean N * BlockTime. Mining is memoryless, so a node that switches heads keeps its scheduled time and
+	the block goes on whatever its head is by then. Every delivery takes an exponentially distributed
+	delay with mean Latency (or the delay of its link, with a latency matrix or bandwidth limit, see
+	bandwidth.go), so blocks overtake each other and nodes park blocks until their parent arrives. Nodes
+	follow the longest chain, the first one seen on a tie. Corrupt nodes only deliver their blocks to
+	each other, and the longest final chain (the first node's on a tie) wins.
+
+	Latency and throughput are measured in virtual time. Duration stays the wall time of the run.
+*/
+
+// DESStats reports the discrete-event engine's run
+type DESStats struct {
+	Seed        uint64
+	VirtualTime time.Duration // virtual time of the last event
+	Events      int           // events processed
+	Orphans     int           // blocks mined off the winning chain
+}
+
+// desBlockTime and desLatency resolve cfg.BlockTime and cfg.Latency against the defaults
+func desBlockTime(cfg SimConfig) time.Duration {
+	if cfg.BlockTime <= 0 {
+		return 10 * time.Second
+	}
+	return cfg.BlockTime
+}
+
+func desLatency(cfg SimConfig) time.Duration {
+	if cfg.Latency <= 0 {
+		return time.Second
+	}
+	return cfg.Latency
+}
+
+type desKind int
+
+const (
+	desTxs     desKind = iota // a batch of transactions reaches Node
+	desMine                   // Node finds a block
+	desDeliver                // Block reaches Node
+)
+
+type desEvent struct {
+	at    time.Duration
+	seq   int // ties go to the event scheduled first
+	kind  desKind
+	node  int
+	txs   []Transaction
+	block Block
+}
+
+// desQueue is a min-heap of events by virtual time
+type desQueue []desEvent
+
+func (q desQueue) Len() int { return len(q) }
+func (q desQueue) Less(a, b int) bool {
+	if q[a].at != q[b].at {
+		return q[a].at < q[b].at
+	}
+	return q[a].seq < q[b].seq
+}
+func (q desQueue) Swap(a, b int) { q[a], q[b] = q[b], q[a] }
+func (q *desQueue) Push(x any)   { *q = append(*q, x.(desEvent)) }
+func (q *desQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// desArrival is a batch of transactions that reaches the nodes of the senders' sides at the same time
+type desArrival struct {
+	at              time.Duration
+	honest, corrupt []Transaction
+}
+
+// desTransactions generates the transactions of w: round k at k * blockTime, or every transaction of an
+// arrivalWorkload at its arrival time
+func desTransactions(w Workload, C, R int, blockTime time.Duration) []desArrival {
+	arrivals, _ := w.(arrivalWorkload)
+	batches := []desArrival{}
+	for round := range R {
+		batch := desArrival{at: time.Duration(round) * blockTime}
+		for _, tx := range w.Next(round) {
+			if arrivals != nil {
+				batch = desArrival{at: arrivals.arrival(tx)}
+			}
+			if getLabel(nodeIndex(tx.Sender, C), C) == "honest" {
+				batch.honest = append(batch.honest, tx)
+			} else {
+				batch.corrupt = append(batch.corrupt, tx)
+			}
+			if arrivals != nil {
+				batches = append(batches, batch)
+			}
+		}
+		if arrivals == nil {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+// desNode is a node's view of the chain
+type desNode struct {
+	head    string
+	height  map[string]int
+	orphans orphanPool
+	pending []Transaction
+	mining  bool // a desMine event is scheduled
+}
+
+func SimulateDESCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "des")
+	defer closeBus()
+
+	start := time.Now()
+	rng := rand.New(rand.NewPCG(cfg.Seed, 0))
+	value := trackValue(bus)
+	blockTime, latency := desBlockTime(cfg), desLatency(cfg)
+	links := newDelayedLinks(cfg) // replace the exponential delays, see bandwidth.go
+	names := nodeNames(N, C)
+	G := createGenesisBlock(cfg.hashing(), 0)
+	nodes := make([]desNode, N)
+	for i := range nodes {
+		nodes[i] = desNode{head: G.Hash, height: map[string]int{G.Hash: 0}, orphans: orphanPool{}}
+	}
+	blocks := map[string]Block{G.Hash: G}
+	mined := map[string]time.Duration{} // Hash -> when it was mined
+	sentAt := map[TxID]time.Duration{}
+	stats := DESStats{Seed: cfg.Seed}
+	tracker := trackNodes(bus, N, C)
+	regions := trackRegions(bus, links.matrix, C)
+
+	queue := &desQueue{}
+	seq := 0
+	now := time.Duration(0)
+	schedule := func(after time.Duration, e desEvent) {
+		e.at, e.seq = now+after, seq
+		seq++
+		heap.Push(queue, e)
+	}
+	exp := func(mean time.Duration) time.Duration {
+		return time.Duration(rng.ExpFloat64() * float64(mean))
+	}
+	publish := func(i int, e Event) {
+		e.Sim, e.Node = "DES", names[i]
+		bus.Publish(e)
+	}
+
+	txSent := 0
+	for _, a := range desTransactions(newWorkload(cfg, N, C, rng), C, R, blockTime) {
+		for _, tx := range slices.Concat(a.honest, a.corrupt) {
+			txSent++
+			sentAt[tx.TxID] = a.at
+			bus.Publish(Event{Kind: EventSent, Sim: "DES", Tx: tx})
+		}
+		for i := range N {
+			txs := a.honest
+			if i < C {
+				txs = a.corrupt
+			}
+			schedule(a.at, desEvent{kind: desTxs, node: i, txs: txs})
+		}
+	}
+
+	// accept adds b to node i's view, and anything parked behind it
+	var accept func(i int, b Block)
+	accept = func(i int, b Block) {
+		n := &nodes[i]
+		if _, known := n.height[b.Hash]; known {
+			return
+		}
+		parent, ok := n.height[b.PrevHash]
+		if !ok {
+			n.orphans.park(b)
+			return
+		}
+		n.height[b.Hash] = parent + 1
+		publish(i, Event{Kind: EventAccepted, Block: b, Height: parent + 1})
+		if parent+1 > n.height[n.head] {
+			n.head = b.Hash
+		}
+		for _, child := range n.orphans.release(b.Hash) {
+			accept(i, child)
+		}
+	}
+
+	for queue.Len() > 0 {
+		if stats.Events%1024 == 0 && ctx.Err() != nil {
+			break
+		}
+		e := heap.Pop(queue).(desEvent)
+		now = e.at
+		stats.Events++
+		n := &nodes[e.node]
+		switch e.kind {
+		case desTxs:
+			n.pending = append(n.pending, e.txs...)
+		case desMine:
+			n.mining = false
+			included := n.pending
+			if cfg.MaxBlockTxs > 0 { // the rest wait for the next block
+				included = n.pending[:min(len(n.pending), cfg.MaxBlockTxs)]
+			}
+			b := Block{Transactions: included, PrevHash: n.head, Nonce: seq, Timestamp: int64(now), Difficulty: D, Miner: names[e.node]}
+			b.Hash = calculateHash(cfg.hashing(), b)
+			n.pending = n.pending[len(included):]
+			blocks[b.Hash] = b
+			mined[b.Hash] = now
+			n.height[b.Hash] = n.height[b.PrevHash] + 1
+			n.head = b.Hash
+			publish(e.node, Event{Kind: EventMined, Block: b, Height: n.height[b.Hash]})
+			slowest := time.Duration(0)
+			for j := range N {
+				if j != e.node && (e.node >= C || j < C) { // corrupt nodes only deliver to other corrupt nodes
+					delay := exp(latency)
+					if links.matrix != nil || links.bandwidth > 0 {
+						delay = links.delayed(e.node, j, b)
+					}
+					slowest = max(slowest, delay)
+					schedule(delay, desEvent{kind: desDeliver, node: j, block: b})
+				}
+			}
+			links.record(b, slowest)
+		case desDeliver:
+			accept(e.node, e.block)
+		}
+		if len(n.pending) > 0 && !n.mining {
+			n.mining = true
+			schedule(exp(time.Duration(N)*blockTime), desEvent{kind: desMine, node: e.node})
+		}
+	}
+	stats.VirtualTime = now
+
+	var winner []Block
+	var winnerType = ""
+	for i, n := range nodes {
+		chain := []Block{}
+		for h := n.head; h != ""; h = blocks[h].PrevHash {
+			chain = append(chain, blocks[h])
+		}
+		slices.Reverse(chain)
+		publish(i, Event{Kind: EventNodeDone, Chain: chain})
+		if len(chain) > len(winner) {
+			winner = chain
+			winnerType = getLabel(i, C)
+		}
+	}
+	stats.Orphans = len(mined) - (len(winner) - 1)
+	regionStats := regions.result(winner)
+
+	corruptPercentage := getPercentage(C, N)
+	txConfirmed := countConfirmedTransactions(winner)
+	latencies := []time.Duration{}
+	var times []time.Time
+	confirmed := make(map[TxID]struct{})
+	for _, b := range winner[1:] {
+		times = append(times, time.Unix(0, int64(mined[b.Hash])))
+		for _, tx := range b.Transactions {
+			if _, seen := confirmed[tx.TxID]; !seen {
+				confirmed[tx.TxID] = struct{}{}
+				latencies = append(latencies, mined[b.Hash]-sentAt[tx.TxID])
+				bus.Publish(Event{Kind: EventConfirmed, Sim: "DES", Tx: tx})
+			}
+		}
+	}
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(latencies)
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	throughput := Throughput{
+		ConfirmedTPS:     perSecond(txConfirmed, stats.VirtualTime),
+		BlocksMined:      len(mined),
+		BlocksPerSecond:  perSecond(len(mined), stats.VirtualTime),
+		AvgBlockInterval: averageInterval(times),
+	}
+
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Corrupt nodes      =", C)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("Chain length       =", len(winner)-1)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Println("Winner             =", winnerType)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(tracker.stats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printDESStats(stats)
+		if links.matrix != nil {
+			printRegionStats(cfg.LatencyMatrix, regionStats)
+		}
+		printPropagationStats(links.result())
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "DES",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 tracker.stats,
+		ChainLength:           max(0, len(winner)-1),
+		DES:                   stats,
+		LatencyMatrix:         cfg.LatencyMatrix,
+		Regions:               regionStats,
+		Propagation:           links.result(),
+		TimedOut:              timedOut,
+	}
+}
+
+func printDESStats(stats DESStats) {
+	fmt.Println("DES seed           =", stats.Seed)
+	fmt.Println("Virtual time       =", stats.VirtualTime)
+	fmt.Println("Events             =", stats.Events)
+	fmt.Println("Orphaned blocks    =", stats.Orphans)
+}
@@ -0,0 +1,71 @@
+package sim
+
+import (
+	"math"
+	"time"
+)
+
+// --- Difficulty and Chain Work ---
+
+/*
+	By default every PoW block is mined at difficulty D. With SimConfig.RetargetInterval the
+	difficulty of a chain is retargeted every RetargetInterval blocks from the timestamps of the
+	last RetargetInterval blocks: one step up if they came in more than 4x faster than
+	TargetBlockTime, one step down (never below 1) if they came in more than 4x slower.
+	One step of difficulty is one more leading hex zero, 16x the expected work.
+
+	Once blocks differ in difficulty the longest chain is no longer the one with the most work,
+	so nodes track the total work of every chain tip and ForkHeaviest follows the largest.
+*/
+
+// blockWork is the expected number of hashes needed to mine a block at difficulty d
+func blockWork(d int) float64 {
+	return math.Pow(16, float64(d))
+}
+
+// chainWork sums the work of the blocks in chain (genesis included)
+func chainWork(chain []Block) float64 {
+	work := 0.0
+	for _, b := range chain {
+		work += blockWork(b.Difficulty)
+	}
+	return work
+}
+
+// targetBlockTime resolves cfg.TargetBlockTime against the default
+func targetBlockTime(cfg SimConfig) time.Duration {
+	if cfg.TargetBlockTime <= 0 {
+		return time.Millisecond
+	}
+	return cfg.TargetBlockTime
+}
+
+// nextDifficulty returns the difficulty of a block mined on prev, at height Counts[prev] + 1
+func nextDifficulty(HashMap BlockStore, Counts map[string]int, prev string, cfg SimConfig) int {
+	last, ok := HashMap.Get(prev)
+	if !ok { // mining on genesis
+		return cfg.D
+	}
+	interval := cfg.RetargetInterval
+	if interval < 2 || Counts[prev]%interval != 0 {
+		return last.Difficulty
+	}
+
+	first := last
+	for range interval - 1 {
+		parent, ok := HashMap.Get(first.PrevHash)
+		if !ok {
+			return last.Difficulty
+		}
+		first = parent
+	}
+	elapsed := time.Duration(last.Timestamp - first.Timestamp)
+	expected := time.Duration(interval-1) * targetBlockTime(cfg)
+	switch {
+	case elapsed < expected/4:
+		return min(maxDifficulty, last.Difficulty+1)
+	case elapsed > expected*4:
+		return max(1, last.Difficulty-1)
+	}
+	return last.Difficulty
+}
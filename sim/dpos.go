@@ -0,0 +1,303 @@
+package sim
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Delegated Proof-of-Stake ---
+
+/*
+	SimulateDPoSCtx elects a small set of delegates (SimConfig.Delegates, default N/3) that produce the
+	blocks of a single chain. Every node approves as many candidates as there are delegate seats,
+	weighted by its stake (see stake.go), and the candidates with the most approval are elected.
+	Honest nodes cannot tell corrupt candidates apart and approve random ones, while the corrupt
+	nodes all approve the corrupt candidates. The delegates then take turns, one block per SlotTime
+	(default 5ms) in the order of their votes, empty or not.
+	A node accepts a block only from the delegate scheduled for its slot and on top of its own head.
+
+	Corrupt delegates only ever include transactions from corrupt nodes, so honest transactions
+	have to wait for an honest delegate's slot and are never confirmed once the corrupt nodes hold
+	every seat. Capture Stake is the share of all stake the corrupt nodes would need to win a
+	majority of the seats against the honest votes of the run: spread over random candidates, the
+	honest approval of each candidate is a fraction of the honest stake, so far less than half of
+	all stake is enough.
+*/
+
+// DPoSStats reports the delegate election and block production of a DPoS simulation
+type DPoSStats struct {
+	Delegates        int     // seats
+	CorruptDelegates int     // seats won by corrupt nodes
+	CorruptStake     float64 // % of all stake held by corrupt nodes
+	CaptureStake     float64 // % of all stake that wins a majority of the seats against this run's honest votes
+	CorruptBlocks    float64 // % of the winning chain's blocks produced by corrupt delegates
+}
+
+// delegateSeats resolves cfg.Delegates against the default
+func delegateSeats(cfg SimConfig) int {
+	if cfg.Delegates <= 0 {
+		return max(1, cfg.N/3)
+	}
+	return min(cfg.Delegates, cfg.N)
+}
+
+// slotTime resolves cfg.SlotTime against the default
+func slotTime(cfg SimConfig) time.Duration {
+	if cfg.SlotTime <= 0 {
+		return 5 * time.Millisecond
+	}
+	return cfg.SlotTime
+}
+
+// electDelegates runs the approval vote and returns the K elected node indexes, most votes first
+func electDelegates(stakes []float64, C, K int) (delegates []int, stats DPoSStats) {
+	N := len(stakes)
+	honestVotes := make([]float64, N) // approval from honest nodes, as a share of all honest stake
+	votes := make([]float64, N)
+	honestStake := 1 - corruptShare(stakes, C)
+	for i := range N {
+		approved := rand.Perm(N)[:K]
+		if i < C { // the corrupt candidates first, random honest ones if there are fewer than K
+			approved = rand.Perm(C)
+			for _, j := range rand.Perm(N - C) {
+				approved = append(approved, C+j)
+			}
+			approved = approved[:K]
+		}
+		for _, j := range approved {
+			votes[j] += stakes[i]
+			if i >= C && honestStake > 0 {
+				honestVotes[j] += stakes[i] / honestStake
+			}
+		}
+	}
+
+	delegates = rand.Perm(N) // ties broken at random
+	slices.SortStableFunc(delegates, func(a, b int) int { return cmp.Compare(votes[b], votes[a]) })
+	delegates = delegates[:K]
+
+	stats = DPoSStats{Delegates: K, CorruptStake: math.Round(10000*corruptShare(stakes, C)) / 100}
+	for _, d := range delegates {
+		if d < C {
+			stats.CorruptDelegates++
+		}
+	}
+
+	// A majority m of corrupt delegates needs the m most approved corrupt candidates, the weakest of
+	// them with honest approval c, to beat the (K-m+1)th most approved honest candidate with honest
+	// approval t: s + (1-s) c > (1-s) t, so s > d / (1+d) with d = t - c
+	m := K/2 + 1
+	byApproval := func(a, b float64) int { return cmp.Compare(b, a) }
+	honest, corrupt := slices.Clone(honestVotes[C:]), slices.Clone(honestVotes[:C])
+	slices.SortFunc(honest, byApproval)
+	slices.SortFunc(corrupt, byApproval)
+	t, c := 0.0, 0.0
+	if K-m < len(honest) {
+		t = honest[K-m]
+	}
+	if m <= len(corrupt) { // with fewer corrupt nodes, corrupt candidates without honest approval
+		c = corrupt[m-1]
+	}
+	d := max(0, t-c)
+	stats.CaptureStake = math.Round(10000*d/(1+d)) / 100
+	return delegates, stats
+}
+
+func SimulateDPoSCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "dpos")
+	defer closeBus()
+
+	start := time.Now()
+	nodeCtx, stopNodes := context.WithCancel(ctx)
+	defer stopNodes()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan []Block, N)
+	queues := make([]*mailbox[Block], N)
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)
+		receivers[i] = make(chan []Block)
+		queues[i] = newMailbox(receivers[i])
+	}
+	G := createGenesisBlock(cfg.hashing(), 0)
+	names := nodeNames(N, C)
+	slot := slotTime(cfg)
+	K := delegateSeats(cfg)
+	delegates, dposStats := electDelegates(nodeStakes(N, C, cfg), C, K)
+	for _, d := range delegates {
+		bus.Publish(Event{Kind: EventElected, Sim: "DPoS", Node: names[d]})
+	}
+	scheduled := func(s int) string { return names[delegates[s%K]] }
+
+	var winnerMu sync.Mutex
+	var winner = []Block{G}
+	var winnerType = ""
+	bus.Subscribe(func(e Event) {
+		winnerMu.Lock()
+		defer winnerMu.Unlock()
+		if len(e.Chain) > len(winner) {
+			winner = e.Chain
+			winnerType = getLabel(nodeIndex(e.Node, C), C)
+		}
+	}, EventNodeDone)
+
+	for i := range N {
+		go func() {
+			defer wg.Done()
+			publish := func(e Event) {
+				e.Sim, e.Node = "DPoS", names[i]
+				bus.Publish(e)
+			}
+			inbox, receiver := inboxes[i], receivers[i]
+			chain := []Block{G}
+			pending := []Transaction{}
+			included := make(map[TxID]bool)
+			ticker := time.NewTicker(slot)
+			defer ticker.Stop()
+
+			extend := func(b Block) {
+				chain = append(chain, b)
+				for _, tx := range b.Transactions {
+					included[tx.TxID] = true
+				}
+				pending = slices.DeleteFunc(pending, func(tx Transaction) bool { return included[tx.TxID] })
+			}
+
+			for {
+				select {
+				case <-nodeCtx.Done():
+					publish(Event{Kind: EventNodeDone, Chain: chain})
+					return
+				case txs, ok := <-inbox:
+					if !ok {
+						inbox = nil
+						continue
+					}
+					for _, tx := range txs {
+						if !included[tx.TxID] {
+							pending = append(pending, tx)
+						}
+					}
+				case blocks := <-receiver:
+					for _, b := range blocks {
+						if b.Miner != scheduled(b.Nonce) || b.PrevHash != chain[len(chain)-1].Hash {
+							publish(Event{Kind: EventRejected, Block: b, Height: len(chain)})
+							continue
+						}
+						extend(b)
+						publish(Event{Kind: EventAccepted, Block: b, Height: len(chain) - 1})
+					}
+				case now := <-ticker.C:
+					s := int(now.Sub(start) / slot)
+					if scheduled(s) != names[i] {
+						continue
+					}
+					b := Block{Transactions: slices.Clone(pending), PrevHash: chain[len(chain)-1].Hash, Nonce: s, Timestamp: now.UnixNano(), Miner: names[i]}
+					b.Hash = calculateHash(cfg.hashing(), b)
+					extend(b)
+					publish(Event{Kind: EventMined, Block: b, Height: len(chain) - 1})
+					for j := range N {
+						if j != i {
+							queues[j].send(b)
+						}
+					}
+				}
+			}
+		}()
+	}
+
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	watch := newCommitWatch()
+	bus.Subscribe(func(e Event) { watch.add(e.Block) }, EventMined)
+	txSent := SendTransactions(ctx, N, C, R, inboxes, newWorkload(cfg, N, C, nil), bus, nil)
+
+	watch.wait(ctx, txSent, time.Duration(4*K)*slot)
+	stopNodes()
+	wg.Wait()
+	for i := range N {
+		queues[i].close()
+	}
+
+	corruptBlocks := 0
+	for _, b := range winner[1:] {
+		if getLabel(nodeIndex(b.Miner, C), C) == "corrupt" {
+			corruptBlocks++
+		}
+	}
+	if len(winner) > 1 {
+		dposStats.CorruptBlocks = getPercentage(corruptBlocks, len(winner)-1)
+	}
+	corruptPercentage := getPercentage(C, N)
+	txConfirmed := countConfirmedTransactions(winner)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			bus.Publish(Event{Kind: EventConfirmed, Sim: "DPoS", Tx: tx}) // delegates never include a transaction twice
+		}
+	}
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.chainLatencies(winner))
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	throughput := timing.chainThroughput(winner, txConfirmed, duration)
+
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Corrupt nodes      =", C)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(nodes.stats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printDPoSStats(dposStats)
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "DPoS",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 nodes.stats,
+		DPoS:                  dposStats,
+		TimedOut:              timedOut,
+	}
+}
+
+func printDPoSStats(stats DPoSStats) {
+	fmt.Println("Delegates          =", stats.Delegates)
+	fmt.Println("Corrupt delegates  =", stats.CorruptDelegates)
+	fmt.Println("Corrupt stake %    =", stats.CorruptStake)
+	fmt.Println("Capture stake %    =", stats.CaptureStake)
+	fmt.Println("Corrupt blocks %   =", stats.CorruptBlocks)
+}
@@ -0,0 +1,62 @@
+package sim
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// DropStats accounts for broadcasts lost on the non-blocking send path and how far blocks propagated
+type DropStats struct {
+	Broadcasts int            // block / vertex sends attempted
+	Dropped    int            // sends lost because the receiver's channel was full or busy
+	DropRate   float64        // Dropped as a percentage of Broadcasts
+	ByPair     map[string]int // "sender->receiver" -> dropped sends
+	Accepted   int            // broadcasts the receiver actually added to its view
+	Coverage   float64        // Accepted as a percentage of Broadcasts
+}
+
+// dropTracker tallies delivery and drop events of a simulation
+type dropTracker struct {
+	mu    sync.Mutex
+	stats DropStats
+}
+
+func trackDrops(bus *EventBus) *dropTracker {
+	d := &dropTracker{stats: DropStats{ByPair: make(map[string]int)}}
+	bus.Subscribe(func(e Event) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		switch e.Kind {
+		case EventAccepted:
+			d.stats.Accepted++
+		case EventDropped:
+			d.stats.Dropped++
+			d.stats.ByPair[e.Node+"->"+e.Peer]++
+			d.stats.Broadcasts++
+		case EventDelivered:
+			d.stats.Broadcasts += len(e.Peers)
+		}
+	}, EventDelivered, EventDropped, EventAccepted)
+	return d
+}
+
+func (d *dropTracker) result() DropStats {
+	stats := d.stats
+	if stats.Broadcasts > 0 {
+		stats.DropRate = getPercentage(stats.Dropped, stats.Broadcasts)
+		stats.Coverage = getPercentage(stats.Accepted, stats.Broadcasts)
+	}
+	return stats
+}
+
+func printDropStats(stats DropStats) {
+	fmt.Println("Broadcasts         =", stats.Broadcasts)
+	fmt.Println("Dropped            =", stats.Dropped)
+	fmt.Println("Drop %             =", stats.DropRate)
+	fmt.Println("Coverage %         =", stats.Coverage)
+	for _, pair := range slices.Sorted(maps.Keys(stats.ByPair)) {
+		fmt.Printf("  %-32s dropped = %d\n", pair, stats.ByPair[pair])
+	}
+}
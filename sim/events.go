@@ -0,0 +1,259 @@
+package sim
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventKind says what happened in an Event
+type EventKind int
+
+const (
+	EventMined      EventKind = iota // Node mined Block
+	EventAccepted                    // Node added Block received from a peer to its view
+	EventRejected                    // Node discarded Block received from a peer (BFT: refused decided Block at Height, see bft.go)
+	EventDropped                     // Node could not deliver Block to Peer (channel full or busy)
+	EventFork                        // PoW: Node attached Block at Height without extending its current tip
+	EventNodeDone                    // Node stopped, its final view is in Chain (PoW) or Txs (DAG)
+	EventConfirmed                   // Tx made it into the final result (winning chain / confident DAG set)
+	EventSent                        // SendTransactions created Tx and is about to deliver it
+	EventReorg                       // PoW: Node switched its tip to Block, rolling back Depth blocks of its old chain
+	EventDelivered                   // Node handed Block to the channels of Peers (one event per broadcast)
+	EventConflict                    // DAG: Node resolved a double spend, keeping Tx over the conflicting Txs
+	EventSnapshot                    // DAG: Node pruned the confirmed Txs into its snapshot root
+	EventRequested                   // DAG: Node asked its peers for the missing parent Block (only Hash is set)
+	EventSolidified                  // DAG: Node added Block after fetching missing parents (also published as EventAccepted)
+	EventOrphaned                    // PoW: Node parked Block in its orphan pool because the parent is unknown
+	EventAdopted                     // PoW: Node attached Block from its orphan pool (also published as EventAccepted)
+	EventVote                        // PoW: Node voted for checkpoint Block at Height, linking it to Source (see finality.go)
+	EventCommit                      // BFT, Committee: Node committed Block at Height, decided in Round (Raft: appended in term Round)
+	EventElected                     // Raft: Node became leader of term Round; DPoS: Node was elected delegate
+	EventDecided                     // DAG (Avalanche): Node decided side Tx of its conflict set after Round query rounds
+	EventAttested                    // PoW (hybrid): Node attested Block at Height with its stake (see hybrid.go)
+	EventJoined                      // PoW, PoA: Sybil identity Node joined mid-simulation (see sybil.go); PoW: Node came online (see churn.go)
+	EventLeft                        // PoW: Node went offline (see churn.go)
+	EventSynced                      // PoW: Node caught up to Height after coming online, downloading Depth blocks (see ibd.go)
+	EventForged                      // PoW: corrupt Node sent the honest nodes forged Block (see peerscore.go)
+	EventBanned                      // PoW: Node banned Peer for the invalid blocks it sent (see peerscore.go)
+)
+
+var eventKindNames = []string{"mined", "accepted", "rejected", "dropped", "fork", "node_done", "confirmed", "sent", "reorg", "delivered", "conflict", "snapshot", "requested", "solidified", "orphaned", "adopted", "vote", "commit", "elected", "decided", "attested", "joined", "left", "synced", "forged", "banned"}
+
+// RejectReason says why a PoW node discarded a block (EventRejected)
+type RejectReason int
+
+const (
+	RejectOther     RejectReason = iota // no reason recorded: discarded orphans, and the other simulators' refusals
+	RejectInvalid                       // the hash is not the block's or misses its difficulty (see peerscore.go)
+	RejectTimestamp                     // stamped too far ahead or not after the median time past (see timewarp.go)
+	RejectLedger                        // spends an output twice, or one its chain lacks (see utxo.go)
+	RejectScript                        // a transaction's script failed (see script.go)
+)
+
+// misbehavior tells whether a block rejected for r was sent by a misbehaving peer. Ledger and script
+// conflicts are not: honest miners produce them whenever they mine on different branches.
+func (r RejectReason) misbehavior() bool {
+	return r == RejectInvalid || r == RejectTimestamp
+}
+
+func (k EventKind) String() string {
+	if int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return "unknown"
+}
+
+// Event is published by the node goroutines (and the simulator itself) while a simulation runs.
+// In the DAG each mined transaction is its own vertex and is carried as a Block holding just that transaction.
+type Event struct {
+	Kind   EventKind
+	Sim    string // "PoW" or "DAG"
+	Time   time.Time
+	Node   string        // node that published the event ("" for simulator events)
+	Peer   string        // EventDropped: receiver; EventRejected (invalid blocks), EventBanned: peer that sent the block
+	Peers  []string      // EventDelivered: receivers
+	Block  Block         // block / DAG vertex the event is about
+	Height int           // PoW: height of Block in Node's view
+	Depth  int           // EventReorg: blocks rolled back; EventSynced: blocks downloaded
+	Reason RejectReason  // EventRejected (PoW): why Node discarded Block
+	Source string        // EventVote: checkpoint of the previous epoch
+	Round  int           // EventCommit: consensus round the block was decided in; EventNodeDone (BFT): round of the open height; EventElected: term; EventSent: workload round
+	Chain  []Block       // EventNodeDone (PoW): Node's final chain
+	Txs    []Transaction // EventNodeDone (DAG): transactions Node has confidence in; EventConflict: losing sides; EventSnapshot: pruned
+	Tx     Transaction   // EventConfirmed, EventSent; EventConflict: winning side
+}
+
+// EventBus fans events out to subscribers. Delivery is synchronous, subscribers are called from the
+// publishing goroutine and must return quickly, but not serialized: the nodes publish concurrently,
+// so a subscriber keeping state guards it with its own lock. The bus holds no lock while the gate
+// or the subscribers run, they may publish or block.
+type EventBus struct {
+	mu     sync.Mutex
+	nextID int
+	subs   []subscription // in the order they subscribed, replaced rather than changed
+	gate   func(Event)    // called before the subscribers, may block to hold the event back, see control.go
+}
+
+type subscription struct {
+	id    int
+	kinds map[EventKind]bool // nil = every kind
+	fn    func(Event)
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+// Subscribe calls fn for every event of the given kinds (every kind if none are given).
+// The returned function removes the subscription; an event being delivered meanwhile may still reach fn.
+func (bus *EventBus) Subscribe(fn func(Event), kinds ...EventKind) (unsubscribe func()) {
+	sub := subscription{fn: fn}
+	if len(kinds) > 0 {
+		sub.kinds = make(map[EventKind]bool)
+		for _, k := range kinds {
+			sub.kinds[k] = true
+		}
+	}
+
+	bus.mu.Lock()
+	sub.id = bus.nextID
+	bus.nextID++
+	bus.subs = append(slices.Clip(bus.subs), sub)
+	bus.mu.Unlock()
+
+	return func() {
+		bus.mu.Lock()
+		bus.subs = slices.DeleteFunc(slices.Clone(bus.subs), func(s subscription) bool { return s.id == sub.id })
+		bus.mu.Unlock()
+	}
+}
+
+// Publish stamps e with the current time and delivers it to the matching subscribers
+func (bus *EventBus) Publish(e Event) {
+	e.Time = time.Now()
+	bus.publishAt(e)
+}
+
+// setGate makes gate hold back every event before the subscribers see it, nil removes it
+func (bus *EventBus) setGate(gate func(Event)) {
+	bus.mu.Lock()
+	bus.gate = gate
+	bus.mu.Unlock()
+}
+
+// publishAt delivers e keeping its time, e.g. an event replayed from a trace
+func (bus *EventBus) publishAt(e Event) {
+	bus.mu.Lock()
+	gate, subs := bus.gate, bus.subs
+	bus.mu.Unlock()
+	if gate != nil {
+		gate(e)
+	}
+	for _, sub := range subs {
+		if sub.kinds == nil || sub.kinds[e.Kind] {
+			sub.fn(e)
+		}
+	}
+}
+
+// --- Built-in Subscribers ---
+
+// newSimBus creates the bus of a single simulation, wiring up the optional user bus,
+// Observer and debug logging from cfg. Call the returned function once the simulation ends.
+func newSimBus(cfg SimConfig, component string) (*EventBus, func()) {
+	bus := NewEventBus()
+	unsubs := []func(){}
+
+	if cfg.Events != nil { // forward everything to the caller's bus
+		unsubs = append(unsubs, bus.Subscribe(cfg.Events.Publish))
+	}
+	if cfg.Observer != nil {
+		unsubs = append(unsubs, subscribeObserver(bus, cfg.Observer))
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		unsubs = append(unsubs, subscribeLogger(bus, component))
+	}
+
+	return bus, func() {
+		for _, unsub := range unsubs {
+			unsub()
+		}
+	}
+}
+
+// subscribeObserver adapts an Observer to the bus
+func subscribeObserver(bus *EventBus, obs Observer) func() {
+	return bus.Subscribe(func(e Event) {
+		switch e.Kind {
+		case EventMined:
+			obs.OnBlockMined(e.Node, e.Block)
+		case EventAccepted:
+			obs.OnBlockAccepted(e.Node, e.Block)
+		case EventFork:
+			obs.OnForkDetected(e.Node, e.Block, e.Height)
+		case EventConfirmed:
+			obs.OnTxConfirmed(e.Tx)
+		}
+	}, EventMined, EventAccepted, EventFork, EventConfirmed)
+}
+
+// subscribeLogger writes node events to the debug log, one logger per node
+func subscribeLogger(bus *EventBus, component string) func() {
+	var mu sync.Mutex
+	loggers := make(map[string]*slog.Logger)
+	return bus.Subscribe(func(e Event) {
+		mu.Lock()
+		log, ok := loggers[e.Node]
+		if !ok {
+			log = nodeLogger(component, e.Node)
+			loggers[e.Node] = log
+		}
+		mu.Unlock()
+		noun := "block"
+		if e.Sim == "DAG" {
+			noun = "transaction"
+		}
+		switch e.Kind {
+		case EventMined, EventAccepted, EventRejected:
+			log.Debug(noun+" "+e.Kind.String(), "hash", shortHash(e.Block.Hash), "height", e.Height, "txs", len(e.Block.Transactions))
+		case EventFork:
+			log.Debug("fork detected", "hash", shortHash(e.Block.Hash), "height", e.Height)
+		case EventReorg:
+			log.Debug("chain reorganized", "tip", shortHash(e.Block.Hash), "height", e.Height, "depth", e.Depth)
+		case EventDropped:
+			log.Debug(noun+" dropped", "hash", shortHash(e.Block.Hash), "to", e.Peer)
+		case EventConflict:
+			log.Debug("double spend resolved", "tx", e.Tx.TxID, "kept", e.Tx.Receiver, "sides", len(e.Txs)+1)
+		case EventSnapshot:
+			log.Debug("snapshot taken", "pruned", len(e.Txs))
+		case EventRequested:
+			log.Debug("missing parent requested", "hash", shortHash(e.Block.Hash))
+		case EventOrphaned:
+			log.Debug("block parked in orphan pool", "hash", shortHash(e.Block.Hash), "parent", shortHash(e.Block.PrevHash))
+		case EventVote:
+			log.Debug("checkpoint vote", "target", shortHash(e.Block.Hash), "source", shortHash(e.Source), "height", e.Height)
+		case EventCommit:
+			log.Debug("block committed", "hash", shortHash(e.Block.Hash), "height", e.Height, "round", e.Round, "txs", len(e.Block.Transactions))
+		case EventElected:
+			log.Debug("leader elected", "sim", e.Sim, "term", e.Round)
+		case EventDecided:
+			log.Debug("conflict decided", "tx", e.Tx.TxID, "kept", e.Tx.Receiver, "rounds", e.Round)
+		case EventAttested:
+			log.Debug("block attested", "hash", shortHash(e.Block.Hash), "height", e.Height)
+		case EventJoined:
+			log.Debug("node joined")
+		case EventLeft:
+			log.Debug("node left")
+		case EventSynced:
+			log.Debug("node synced", "height", e.Height, "downloaded", e.Depth)
+		case EventForged:
+			log.Debug("forged block sent", "hash", shortHash(e.Block.Hash))
+		case EventBanned:
+			log.Debug("peer banned", "peer", e.Peer)
+		}
+	}, EventMined, EventAccepted, EventRejected, EventFork, EventReorg, EventDropped, EventConflict, EventSnapshot, EventRequested, EventOrphaned, EventVote, EventCommit, EventElected, EventDecided, EventAttested, EventJoined,
+		EventLeft, EventSynced, EventForged, EventBanned)
+}
@@ -0,0 +1,280 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// --- Chain Explorer ---
+
+/*
+	-chains <dir> persists the winning chain of every simulation that builds chains (the PoW family,
+	PoA, DPoS, BFT, Raft, Committee and des) to dir, one JSON file per simulation, test<N>-<mode>.json,
+	with its blocks from the genesis on.
+
+	-explorer <addr> serves the chains in -chains instead of running the suite, so the output can be
+	browsed like a chain explorer. A chain is named by its file, e.g. test1-pow, and picked with
+	?chain=<name>, which may be left out when the directory holds only one:
+	- GET /chains lists the chains, their winner, height and transactions;
+	- GET /blocks lists blocks from the tip down, ?from=<height> and ?limit=<n> (20 by default) page
+	  through them;
+	- GET /block/{hash} is a block, its height and confirmations (searching every chain without ?chain);
+	- GET /address/{name}/balance is what a node received and sent on the chain, and the balance of it;
+	- GET /tx/{id} is a transaction, by the ID the simulator gives it (its TxID, e.g. 102), and the
+	  block that holds it.
+	Balances count the amounts -amounts or a trace give the transactions, and the fees they pay, from 0:
+	without amounts every balance is 0, and the UTXO mode's genesis outputs are not counted. The files are
+	read once, restart the explorer to see new ones.
+*/
+
+// explorerChain is a persisted winning chain, as its file holds it
+type explorerChain struct {
+	Test   int
+	Mode   string
+	Type   string
+	Winner string
+	Blocks []Block // genesis first
+
+	name   string
+	height map[string]int // block hash -> height
+}
+
+// finalChains collects the final chain of every node of a simulation from its bus
+type finalChains map[string][]Block
+
+func collectChains(bus *EventBus) finalChains {
+	var mu sync.Mutex
+	chains := finalChains{}
+	bus.Subscribe(func(e Event) {
+		if len(e.Chain) > 0 {
+			mu.Lock()
+			chains[e.Node] = e.Chain
+			mu.Unlock()
+		}
+	}, EventNodeDone)
+	return chains
+}
+
+// writeChain writes the winning chain of the simulation of mode in test that ended in res to dir
+func writeChain(dir string, test int, mode string, res SimResult, chains finalChains) error {
+	blocks := winningChain(chains, res.Winner)
+	if len(blocks) == 0 {
+		return nil // a DAG, or a simulation cut short
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(explorerChain{Test: test, Mode: mode, Type: res.Type, Winner: res.Winner, Blocks: blocks})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, fmt.Sprintf("test%d-%s.json", test, mode)), data, 0644)
+}
+
+// readChains reads the chains in dir, by name
+func readChains(dir string) (map[string]*explorerChain, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	chains := make(map[string]*explorerChain)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		c := &explorerChain{name: strings.TrimSuffix(filepath.Base(path), ".json"), height: make(map[string]int)}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for k, b := range c.Blocks {
+			c.height[b.Hash] = k
+		}
+		chains[c.name] = c
+	}
+	if len(chains) == 0 {
+		return nil, fmt.Errorf("no chains in %s (write them with -chains)", dir)
+	}
+	return chains, nil
+}
+
+// explorerBlock is a block in a list
+type explorerBlock struct {
+	Height    int
+	Hash      string
+	PrevHash  string
+	Miner     string
+	Txs       int
+	Timestamp int64
+}
+
+// explorerBalance is the answer of /address/{name}/balance
+type explorerBalance struct {
+	Address  string
+	Chain    string
+	Balance  Amount
+	Received Amount
+	Sent     Amount
+	Fees     Amount
+	TxsIn    int
+	TxsOut   int
+}
+
+// ServeExplorer serves the chains in dir at addr until ctx is done
+func ServeExplorer(ctx context.Context, addr, dir string) error {
+	log := componentLogger("explorer")
+	chains, err := readChains(dir)
+	if err != nil {
+		return err
+	}
+	answer := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	// pick returns the chain of ?chain=, or the only one
+	pick := func(w http.ResponseWriter, r *http.Request) *explorerChain {
+		name := r.URL.Query().Get("chain")
+		if name == "" && len(chains) == 1 {
+			for _, c := range chains {
+				return c
+			}
+		}
+		if c := chains[name]; c != nil {
+			return c
+		}
+		http.Error(w, fmt.Sprintf("pick a chain with ?chain=, one of %s", strings.Join(slices.Sorted(maps.Keys(chains)), ", ")), http.StatusNotFound)
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /chains", func(w http.ResponseWriter, r *http.Request) {
+		type summary struct {
+			Name   string
+			Type   string
+			Winner string
+			Height int
+			Txs    int
+		}
+		list := []summary{}
+		for _, name := range slices.Sorted(maps.Keys(chains)) {
+			c := chains[name]
+			list = append(list, summary{Name: name, Type: c.Type, Winner: c.Winner, Height: len(c.Blocks) - 1, Txs: countConfirmedTransactions(c.Blocks)})
+		}
+		answer(w, list)
+	})
+	mux.HandleFunc("GET /blocks", func(w http.ResponseWriter, r *http.Request) {
+		c := pick(w, r)
+		if c == nil {
+			return
+		}
+		from, limit := len(c.Blocks)-1, 20
+		var err error
+		if s := r.URL.Query().Get("from"); s != "" {
+			from, err = strconv.Atoi(s)
+		}
+		if s := r.URL.Query().Get("limit"); s != "" && err == nil {
+			limit, err = strconv.Atoi(s)
+		}
+		if err != nil || from < 0 || limit < 1 {
+			http.Error(w, "from is a height, limit at least 1", http.StatusBadRequest)
+			return
+		}
+		list := []explorerBlock{}
+		for k := min(from, len(c.Blocks)-1); k >= 0 && len(list) < limit; k-- {
+			b := c.Blocks[k]
+			list = append(list, explorerBlock{Height: k, Hash: b.Hash, PrevHash: b.PrevHash, Miner: b.Miner, Txs: len(b.Transactions), Timestamp: b.Timestamp})
+		}
+		answer(w, list)
+	})
+	mux.HandleFunc("GET /block/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		hash := r.PathValue("hash")
+		search := []*explorerChain{}
+		for _, name := range slices.Sorted(maps.Keys(chains)) { // the genesis is in every chain of a difficulty
+			search = append(search, chains[name])
+		}
+		if r.URL.Query().Get("chain") != "" {
+			c := pick(w, r)
+			if c == nil {
+				return
+			}
+			search = []*explorerChain{c}
+		}
+		for _, c := range search {
+			if k, ok := c.height[hash]; ok {
+				answer(w, struct {
+					Chain         string
+					Height        int
+					Confirmations int
+					Block         Block
+				}{c.name, k, len(c.Blocks) - k, c.Blocks[k]})
+				return
+			}
+		}
+		http.Error(w, "no block "+hash, http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /address/{name}/balance", func(w http.ResponseWriter, r *http.Request) {
+		c := pick(w, r)
+		if c == nil {
+			return
+		}
+		bal := explorerBalance{Address: r.PathValue("name"), Chain: c.name}
+		for _, b := range c.Blocks {
+			for _, tx := range b.Transactions {
+				if tx.Sender == bal.Address {
+					bal.Sent += tx.Amount
+					bal.Fees += tx.Fee
+					bal.TxsOut++
+				}
+				if tx.Receiver == bal.Address {
+					bal.Received += tx.Amount
+					bal.TxsIn++
+				}
+			}
+		}
+		bal.Balance = bal.Received - bal.Sent - bal.Fees
+		answer(w, bal)
+	})
+	mux.HandleFunc("GET /tx/{id}", func(w http.ResponseWriter, r *http.Request) {
+		c := pick(w, r)
+		if c == nil {
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		for k, b := range c.Blocks {
+			for _, tx := range b.Transactions {
+				if err == nil && tx.TxID == TxID(id) {
+					answer(w, struct {
+						Chain         string
+						Block         string
+						Height        int
+						Confirmations int
+						Tx            Transaction
+					}{c.name, b.Hash, k, len(c.Blocks) - k, tx})
+					return
+				}
+			}
+		}
+		http.Error(w, "no transaction "+r.PathValue("id"), http.StatusNotFound)
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	log.Info("serving the chain explorer", "url", "http://"+addr+"/chains", "chains", len(chains))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
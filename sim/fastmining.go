@@ -0,0 +1,43 @@
+package sim
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// --- Fast Mining ---
+
+/*
+	Grinding nonces takes 16^d hashes on average at difficulty d, so high difficulties take real CPU
+	time. With SimConfig.FastMining a PoW node does not hash at all: finding a block is a Poisson
+	process, so the time to the next block is exponentially distributed with mean 16^d / HashRate,
+	and the node just waits that long. The dynamics are the same as hashing at HashRate, without the
+	CPU: nodes no longer share the SimConfig.Miners slots, and difficulty only scales the waiting time.
+
+	The block gets a random nonce and its hash is not a valid proof of work, nothing checks it.
+*/
+
+// hashRate resolves cfg.HashRate against the default
+func hashRate(cfg SimConfig) float64 {
+	if cfg.HashRate <= 0 {
+		return 1e6
+	}
+	return cfg.HashRate
+}
+
+// sampleBlock waits for the exponentially distributed time it takes to find block at rate hashes per
+// second, returning false if ctx is cancelled first
+func sampleBlock(ctx context.Context, h hashFunc, block Block, rate float64) (Block, bool) {
+	wait := time.Duration(rand.ExpFloat64() * blockWork(block.Difficulty) / rate * float64(time.Second))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return block, false
+	}
+	block.Nonce = rand.Int()
+	block.Hash = calculateHash(h, block)
+	return block, true
+}
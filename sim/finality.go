@@ -0,0 +1,163 @@
+package sim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// --- Finality Gadget ---
+
+/*
+	With SimConfig.FinalityInterval = K every PoW node is also a validator of a Casper-FFG style
+	overlay. The blocks at heights K, 2K, ... of a chain are its checkpoints. Once a node's head
+	reaches a new epoch it votes (EventVote) for the checkpoint of that epoch on its chain,
+	linking it to the checkpoint of the previous epoch (the source). A node never votes twice
+	for the same epoch, except corrupt nodes, which equivocate by also voting for every other
+	checkpoint of the epoch they know of.
+
+	The gadget tallies the votes: a checkpoint is justified once more than 2/3 of all nodes voted
+	for it from a justified source, and a justified checkpoint is finalized once the checkpoint
+	of the next epoch on top of it is justified. Genesis is justified and final. Two finalized
+	checkpoints that are not on one chain are a safety failure. Honest nodes never see the corrupt
+	nodes' blocks, so that takes the corrupt nodes alone to hold more than 2/3 of the votes while
+	they equivocate for the honest chain too.
+*/
+
+// FinalityStats reports the checkpoints finalized by the finality gadget
+type FinalityStats struct {
+	Interval          int           // blocks per epoch
+	Justified         int           // checkpoints justified, genesis excluded
+	Finalized         int           // checkpoints finalized, genesis excluded
+	AvgTimeToFinality time.Duration // from mining a checkpoint to finalizing it
+	MaxTimeToFinality time.Duration
+	Equivocations     int // extra votes cast by corrupt nodes for an epoch they already voted in
+	Conflicting       int // pairs of finalized checkpoints that are not on one chain
+}
+
+// checkpointAt returns the block at height of the chain ending at tip ("" for genesis)
+func checkpointAt(HashMap BlockStore, Counts map[string]int, tip string, height int) Block {
+	for tip != "" && Counts[tip] > height {
+		b, ok := HashMap.Get(tip)
+		if !ok {
+			break
+		}
+		tip = b.PrevHash
+	}
+	b, _ := HashMap.Get(tip)
+	return b
+}
+
+// finalityGadget tallies the EventVote votes of a simulation
+type finalityGadget struct {
+	mu            sync.Mutex
+	validators    int
+	votes         map[string]map[string]bool // target -> nodes that voted for it
+	source        map[string]string          // target -> checkpoint of the previous epoch
+	mined         map[string]time.Time       // block hash -> when it was mined
+	justified     map[string]bool
+	finalized     map[string]time.Time // checkpoint -> when it was finalized
+	equivocations int
+	voted         map[string]map[int]bool // node -> epochs it voted in
+}
+
+func trackFinality(bus *EventBus, N int) *finalityGadget {
+	g := &finalityGadget{
+		validators: N,
+		votes:      make(map[string]map[string]bool),
+		source:     make(map[string]string),
+		mined:      make(map[string]time.Time),
+		justified:  map[string]bool{"": true},
+		finalized:  make(map[string]time.Time),
+		voted:      make(map[string]map[int]bool),
+	}
+	bus.Subscribe(func(e Event) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if e.Kind == EventMined {
+			g.mined[e.Block.Hash] = e.Time
+			return
+		}
+		target := e.Block.Hash
+		if g.votes[target] == nil {
+			g.votes[target] = make(map[string]bool)
+		}
+		if g.votes[target][e.Node] { // same vote again
+			return
+		}
+		if g.voted[e.Node] == nil {
+			g.voted[e.Node] = make(map[int]bool)
+		}
+		if g.voted[e.Node][e.Height] {
+			g.equivocations++
+		}
+		g.voted[e.Node][e.Height] = true
+		g.votes[target][e.Node] = true
+		g.source[target] = e.Source
+		g.justify(e.Time)
+	}, EventVote, EventMined)
+	return g
+}
+
+// justify justifies every checkpoint with a supermajority from a justified source until nothing changes,
+// finalizing the sources of checkpoints justified in the following epoch
+func (g *finalityGadget) justify(now time.Time) {
+	for changed := true; changed; {
+		changed = false
+		for target, voters := range g.votes {
+			source := g.source[target]
+			if g.justified[target] || !g.justified[source] || 3*len(voters) <= 2*g.validators {
+				continue
+			}
+			g.justified[target] = true
+			changed = true
+			if _, done := g.finalized[source]; !done && source != "" {
+				g.finalized[source] = now
+			}
+		}
+	}
+}
+
+// onChain reports whether checkpoint a is b or one of its ancestors
+func (g *finalityGadget) onChain(a, b string) bool {
+	for ; b != ""; b = g.source[b] {
+		if a == b {
+			return true
+		}
+	}
+	return a == ""
+}
+
+func (g *finalityGadget) result(interval int) FinalityStats {
+	stats := FinalityStats{Interval: interval, Justified: len(g.justified) - 1, Equivocations: g.equivocations}
+	final := []string{}
+	var total time.Duration
+	for hash, at := range g.finalized {
+		final = append(final, hash)
+		ttf := at.Sub(g.mined[hash])
+		total += ttf
+		stats.MaxTimeToFinality = max(stats.MaxTimeToFinality, ttf)
+	}
+	stats.Finalized = len(final)
+	if stats.Finalized > 0 {
+		stats.AvgTimeToFinality = total / time.Duration(stats.Finalized)
+	}
+	for x := range final {
+		for y := x + 1; y < len(final); y++ {
+			if !g.onChain(final[x], final[y]) && !g.onChain(final[y], final[x]) {
+				stats.Conflicting++
+			}
+		}
+	}
+	return stats
+}
+
+func printFinalityStats(stats FinalityStats) {
+	fmt.Println("Epoch length       =", stats.Interval)
+	fmt.Println("Justified          =", stats.Justified)
+	fmt.Println("Finalized          =", stats.Finalized)
+	fmt.Println("Avg time to final  =", stats.AvgTimeToFinality)
+	fmt.Println("Max time to final  =", stats.MaxTimeToFinality)
+	fmt.Println("Equivocations      =", stats.Equivocations)
+	fmt.Println("Conflicting final  =", stats.Conflicting)
+}
@@ -0,0 +1,62 @@
+package sim
+
+// Fork-choice rules of the PoW simulation (SimConfig.ForkChoice)
+const (
+	ForkLongest  = "longest"  // follow the tip of the longest chain, the first one seen wins a tie
+	ForkHeaviest = "heaviest" // follow the tip with the most total work, see difficulty.go
+	ForkGHOST    = "ghost"    // follow the heaviest subtree, see ghostTree
+)
+
+// ghostTree picks the head of a PoW node's block tree with the GHOST rule (Greedy Heaviest Observed SubTree).
+//
+// Starting at genesis the rule repeatedly steps to the child whose subtree holds the most work, until it
+// reaches a leaf. Blocks on losing branches still count for the subtree they were mined in, so under high
+// latency, when many blocks are mined on stale tips, the honest majority's work is not wasted.
+//
+// The first blocks of a node are mined on "" (the empty MaxChain), so "" stands in for genesis.
+type ghostTree struct {
+	children map[string][]string // Hash -> blocks mined on it, in the order they were seen
+	weight   map[string]float64  // Hash -> work of its subtree, including itself
+	parent   map[string]string
+}
+
+func newGhostTree() *ghostTree {
+	return &ghostTree{
+		children: make(map[string][]string),
+		weight:   make(map[string]float64),
+		parent:   make(map[string]string),
+	}
+}
+
+// add records b, whose parent must already be known, and raises the weight of its ancestors
+func (g *ghostTree) add(b Block, genesis string) {
+	if _, known := g.weight[b.Hash]; known {
+		return
+	}
+	parent := b.PrevHash
+	if parent == genesis {
+		parent = ""
+	}
+	g.parent[b.Hash] = parent
+	g.children[parent] = append(g.children[parent], b.Hash)
+	work := blockWork(b.Difficulty)
+	g.weight[b.Hash] = work
+	for h := parent; h != ""; h = g.parent[h] {
+		g.weight[h] += work
+	}
+}
+
+// head walks from genesis to the leaf of the heaviest subtree, ties go to the child seen first
+func (g *ghostTree) head() string {
+	x := ""
+	for len(g.children[x]) > 0 {
+		next := ""
+		for _, c := range g.children[x] {
+			if next == "" || g.weight[c] > g.weight[next] {
+				next = c
+			}
+		}
+		x = next
+	}
+	return x
+}
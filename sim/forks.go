@@ -0,0 +1,125 @@
+package sim
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ForkStats describes the blocks that lost out to the winning PoW chain
+type ForkStats struct {
+	Orphans       int            // mined blocks that are not in the winning chain
+	OrphanRate    float64        // Orphans as a percentage of all mined blocks
+	MaxForkDepth  int            // longest run of orphaned blocks branching off the winning chain
+	OrphansByNode map[string]int // node -> blocks it mined that were orphaned
+}
+
+// forkTracker records the block tree from the mining events of a simulation
+type forkTracker struct {
+	mu     sync.Mutex
+	parent map[string]string // block hash -> PrevHash it was mined on
+	miner  map[string]string // block hash -> node that mined it
+}
+
+func trackForks(bus *EventBus) *forkTracker {
+	f := &forkTracker{parent: make(map[string]string), miner: make(map[string]string)}
+	bus.Subscribe(func(e Event) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.parent[e.Block.Hash] = e.Block.PrevHash
+		f.miner[e.Block.Hash] = e.Node
+	}, EventMined)
+	return f
+}
+
+func (f *forkTracker) stats(chain []Block) ForkStats {
+	inChain := make(map[string]bool)
+	for _, b := range chain {
+		inChain[b.Hash] = true
+	}
+
+	stats := ForkStats{OrphansByNode: make(map[string]int)}
+	depth := make(map[string]int) // memoized distance from an orphan back to the winning chain
+	var forkDepth func(hash string) int
+	forkDepth = func(hash string) int {
+		if d, ok := depth[hash]; ok {
+			return d
+		}
+		prev, mined := f.parent[hash]
+		if inChain[hash] || !mined {
+			return 0
+		}
+		d := forkDepth(prev) + 1
+		depth[hash] = d
+		return d
+	}
+
+	for hash, node := range f.miner {
+		if inChain[hash] {
+			continue
+		}
+		stats.Orphans++
+		stats.OrphansByNode[node]++
+		stats.MaxForkDepth = max(stats.MaxForkDepth, forkDepth(hash))
+	}
+	if len(f.miner) > 0 {
+		stats.OrphanRate = getPercentage(stats.Orphans, len(f.miner))
+	}
+	return stats
+}
+
+func printForkStats(stats ForkStats) {
+	fmt.Println("Orphaned blocks    =", stats.Orphans)
+	fmt.Println("Orphan %           =", stats.OrphanRate)
+	fmt.Println("Max fork depth     =", stats.MaxForkDepth)
+}
+
+// ReorgStats counts how often nodes abandoned their tip for a block that did not extend it
+type ReorgStats struct {
+	Count    int
+	MaxDepth int                       // most blocks rolled back by a single reorg
+	ByNode   map[string]NodeReorgStats // node -> its own reorgs
+}
+
+type NodeReorgStats struct {
+	Count    int
+	MaxDepth int
+}
+
+// reorgTracker tallies the reorg events of a simulation
+type reorgTracker struct {
+	mu     sync.Mutex
+	byNode map[string]NodeReorgStats
+}
+
+func trackReorgs(bus *EventBus) *reorgTracker {
+	r := &reorgTracker{byNode: make(map[string]NodeReorgStats)}
+	bus.Subscribe(func(e Event) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		node := r.byNode[e.Node]
+		node.Count++
+		node.MaxDepth = max(node.MaxDepth, e.Depth)
+		r.byNode[e.Node] = node
+	}, EventReorg)
+	return r
+}
+
+func (r *reorgTracker) stats() ReorgStats {
+	stats := ReorgStats{ByNode: r.byNode}
+	for _, node := range r.byNode {
+		stats.Count += node.Count
+		stats.MaxDepth = max(stats.MaxDepth, node.MaxDepth)
+	}
+	return stats
+}
+
+func printReorgStats(stats ReorgStats) {
+	fmt.Println("Reorgs             =", stats.Count)
+	fmt.Println("Max reorg depth    =", stats.MaxDepth)
+	for _, node := range slices.Sorted(maps.Keys(stats.ByNode)) {
+		ns := stats.ByNode[node]
+		fmt.Printf("  %-16s count = %d, max depth = %d\n", node, ns.Count, ns.MaxDepth)
+	}
+}
@@ -0,0 +1,170 @@
+package sim
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets of the hashing and (de)serialization paths, so malformed input (extreme amounts,
+// huge parent lists, invalid UTF-8, truncated protobuf, broken JSON or CSV) is found before it
+// panics a node goroutine. go test runs the seeds, go test -fuzz FuzzCalculateHash ./sim fuzzes one.
+
+var hexHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// fuzzBlock is a block of one transaction with the given fields, amount also its ID, and up to 10,000
+// copies of prev as uncles
+func fuzzBlock(prev, miner, sender, receiver string, amount, fee int64, nonce int, uncles uint16) Block {
+	tx := Transaction{TxID: TxID(amount), Sender: sender, Receiver: receiver, Amount: Amount(amount), Fee: Amount(fee),
+		Inputs: []Outpoint{{Tx: TxID(amount), Index: nonce}}, Outputs: []TxOut{{Owner: receiver, Value: Amount(amount - fee)}}}
+	return Block{Transactions: []Transaction{tx}, PrevHash: prev, Miner: miner, Nonce: nonce, Difficulty: nonce,
+		Timestamp: int64(nonce), Uncles: slices.Repeat([]string{prev}, int(uncles%10001))}
+}
+
+// addBlockSeeds adds the edge cases of the block targets to f
+func addBlockSeeds(f *testing.F) {
+	hash := "00a1b2c3d4e5f60718293a4b5c6d7e8f00a1b2c3d4e5f60718293a4b5c6d7e8f"
+	f.Add(hash, "honest1", "honest1", "honest2", int64(amountScale), int64(amountScale/100), 0, uint16(0))
+	f.Add("", "", "", "", int64(0), int64(0), -1, uint16(0))
+	f.Add(hash, "corrupt1", "\xff\xfe", "日本", int64(math.MinInt64), int64(math.MaxInt64), math.MaxInt, uint16(1))
+	f.Add("\x00", `"\`, "\n", strings.Repeat("a", 4096), int64(math.MaxInt64), int64(math.MinInt64), math.MinInt, uint16(10000))
+	f.Add(hash, "honest1", "honest2", "honest3", int64(-1), int64(1), 64, uint16(3))
+}
+
+func FuzzCalculateHash(f *testing.F) {
+	addBlockSeeds(f)
+	h := SimConfig{}.hashing()
+	f.Fuzz(func(t *testing.T, prev, miner, sender, receiver string, amount, fee int64, nonce int, uncles uint16) {
+		b := fuzzBlock(prev, miner, sender, receiver, amount, fee, nonce, uncles)
+		hash := calculateHash(h, b)
+		if !hexHash.MatchString(hash) || calculateHash(h, b) != hash {
+			t.Fatalf("hash %q is not 64 hex digits or not the same twice", hash)
+		}
+		other := b
+		other.Transactions = slices.Clone(b.Transactions)
+		other.Transactions[0].Sender += "x"
+		if calculateHash(h, other) == hash {
+			t.Errorf("hash %s does not cover the transactions", shortHash(hash))
+		}
+	})
+}
+
+func FuzzComputeHash(f *testing.F) {
+	hash := "00a1b2c3d4e5f60718293a4b5c6d7e8f00a1b2c3d4e5f60718293a4b5c6d7e8f"
+	f.Add("honest1", "honest2", int64(amountScale), int64(amountScale/100), 0, hash, uint32(2))
+	f.Add("", "", int64(0), int64(0), -1, "", uint32(0))
+	f.Add("\xff", "日本", int64(math.MinInt64), int64(math.MaxInt64), math.MaxInt, hash, uint32(100000)) // a huge parent list
+	h := SimConfig{}.hashing()
+	f.Fuzz(func(t *testing.T, sender, receiver string, amount, fee int64, nonce int, parent string, parents uint32) {
+		tx := Transaction{TxID: TxID(amount), Sender: sender, Receiver: receiver, Amount: Amount(amount), Fee: Amount(fee), Nonce: nonce,
+			Parents: slices.Repeat([]string{parent}, int(parents%100001))}
+		hash := computeHash(h, tx)
+		if !hexHash.MatchString(hash) || computeHash(h, tx) != hash {
+			t.Fatalf("hash %q is not 64 hex digits or not the same twice", hash)
+		}
+	})
+}
+
+func FuzzDiskBlockStore(f *testing.F) {
+	fuzzBlockStore(f, SimConfig{StoreDir: f.TempDir(), Store: StoreFile})
+}
+
+func FuzzBoltBlockStore(f *testing.F) {
+	fuzzBlockStore(f, SimConfig{StoreDir: f.TempDir(), Store: StoreBolt})
+}
+
+// fuzzBlockStore checks that every block comes back from the store of cfg as it went in
+func fuzzBlockStore(f *testing.F, cfg SimConfig) {
+	addBlockSeeds(f)
+	h := cfg.hashing()
+	store, err := newBlockStore(cfg)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() {
+		if err := store.Close(); err != nil {
+			f.Error(err)
+		}
+	})
+	f.Fuzz(func(t *testing.T, prev, miner, sender, receiver string, amount, fee int64, nonce int, uncles uint16) {
+		b := fuzzBlock(prev, miner, sender, receiver, amount, fee, nonce, uncles)
+		b.Hash = calculateHash(h, b)
+		store.Put(b)
+		got, ok := store.Get(b.Hash)
+		if !ok || calculateHash(h, got) != b.Hash {
+			t.Errorf("block came back changed (found %v)", ok)
+		}
+	})
+}
+
+func FuzzUnmarshalBlock(f *testing.F) {
+	data := appendBlock(nil, fuzzBlock("00a1", "honest1", "honest1", "honest2", amountScale, amountScale/4, 7, 2))
+	f.Add(data)
+	f.Add(data[:len(data)/2]) // truncated
+	f.Add(data[:len(data)-1])
+	f.Add([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f})                                     // a length beyond the end
+	f.Add([]byte{0x0a, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}) // an overlong varint
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b, err := unmarshalBlock(data)
+		if err != nil {
+			return // rejected, as it should be if malformed
+		}
+		encoded := appendBlock(nil, b)
+		again, err := unmarshalBlock(encoded)
+		if err != nil || !bytes.Equal(appendBlock(nil, again), encoded) {
+			t.Errorf("a decoded block does not round trip (%v)", err)
+		}
+	})
+}
+
+// checkPayments fails t if a trace reader accepted a payment with a time or amount it should not have
+func checkPayments(t *testing.T, payments []tracePayment) {
+	for _, p := range payments {
+		if math.IsNaN(p.at) || math.IsInf(p.at, 0) || p.value < 0 {
+			t.Errorf("accepted a payment at %v of amount %s", p.at, p.value)
+		}
+	}
+}
+
+func FuzzReadJSONTrace(f *testing.F) {
+	for _, seed := range []string{
+		`[{"timestamp": 1700000000, "sender": "alice", "receiver": "bob", "amount": 5},
+{"timestamp": 1700000001.5, "sender": "bob", "receiver": "carol", "amount": "2.5", "asset": "USD"}]`,
+		`{"round": 1, "from": "honest1", "to": "honest2", "value": 3} {"round": 0, "from": "honest2", "to": "corrupt1"}`,
+		`[{"time": "2024-01-02T15:04:05Z", "from": "0xabc", "to": "0xdef", "value": 1e3, "token": "ETH"}]`,
+		`[{"timestamp": 1, "sender": "a", "receiver": "b", "amount": "NaN"}]`,
+		`[{"timestamp": "Inf", "sender": "a", "receiver": "b", "amount": -1}]`,
+		`[{"round": 0, "from": "a", "to": "b", "value": 1e309`, // cut off
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		payments, _, err := readJSONTrace(strings.NewReader(input))
+		if err == nil {
+			checkPayments(t, payments)
+		}
+	})
+}
+
+func FuzzReadCSVTrace(f *testing.F) {
+	for _, seed := range []string{
+		"round,sender,receiver,amount\n0,honest1,honest2,1\n1,honest2,corrupt1,2.5\n",
+		"1700000000,alice,bob,3\n1700000002.25,bob,alice,4\n",
+		"time,from,to,value,token\n2024-01-02T15:04:05Z,0xabc,0xdef,7,ETH\n",
+		"0,honest1,honest2\n2,corrupt1,honest1\n",
+		"0,honest1,honest2,NaN\n1,honest2,honest1,-Inf\n",
+		"round,sender\n0,\"honest1\n",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		payments, _, err := readCSVTrace(bytes.NewReader([]byte(input)))
+		if err == nil {
+			checkPayments(t, payments)
+		}
+	})
+}
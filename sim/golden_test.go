@@ -0,0 +1,233 @@
+package sim
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+/*
+	TestGolden runs small simulations with fixed seeds and compares their outcome with the golden files
+	in testdata/golden, one JSON file per case: the block hashes of the winning chain, the winner, chain
+	length, transactions sent and confirmed, blocks mined, orphans, events, virtual time and latency
+	percentiles. A change to the consensus logic that alters an outcome fails the test with a diff; if
+	the change is intended, go test ./sim -run TestGolden -update rewrites the files, to be committed
+	with it so the review sees what changed. The test vectors of the hash encoding (see canonical.go)
+	are checked and rewritten with them.
+
+	Only the discrete-event simulator (des) is deterministic, a seed fixes its whole run; the goroutine
+	simulators race their nodes against each other and the clock, so they have no golden files.
+*/
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden with the current outcomes")
+
+const goldenDir = "testdata/golden"
+
+// goldenCase is a simulation with a fixed seed
+type goldenCase struct {
+	name     string
+	cfg      SimConfig
+	workload string
+}
+
+var goldenCases = []goldenCase{
+	{"des-small", SimConfig{N: 10, C: 2, R: 3, D: 1, P: 0.8, Seed: 1}, ""},
+	{"des-third-corrupt", SimConfig{N: 15, C: 5, R: 2, D: 1, P: 0.6, Seed: 2}, ""},
+	{"des-half-corrupt", SimConfig{N: 20, C: 10, R: 2, D: 2, P: 0.4, Seed: 3}, ""},
+	{"des-corrupt-majority", SimConfig{N: 25, C: 15, R: 1, D: 2, P: 0.2, Seed: 4}, ""},
+	{"des-poisson", SimConfig{N: 10, C: 3, R: 5, D: 1, P: 0.8, Seed: 5}, "poisson:2"},
+}
+
+// goldenRecord is the outcome of a case, as its golden file holds it
+type goldenRecord struct {
+	N           int
+	C           int
+	R           int
+	D           int
+	P           float64
+	Seed        uint64
+	Workload    string `json:",omitempty"`
+	Winner      string
+	ChainLength int
+	TxSent      int
+	TxConfirmed int
+	BlocksMined int
+	Orphans     int
+	Events      int
+	VirtualTime time.Duration
+	LatencyP50  time.Duration
+	LatencyP95  time.Duration
+	LatencyMax  time.Duration
+	Chain       []string // hashes of the winning chain, genesis first
+}
+
+// runGolden runs c and records its outcome
+func runGolden(c goldenCase) goldenRecord {
+	cfg := c.cfg
+	cfg.Workload = c.workload
+	cfg.Events = NewEventBus()
+	var winner []Block
+	cfg.Events.Subscribe(func(e Event) { // the first longest chain, as the simulator picks it
+		if len(e.Chain) > len(winner) {
+			winner = e.Chain
+		}
+	}, EventNodeDone)
+	res := SimulateDESCtx(context.Background(), cfg)
+	rec := goldenRecord{N: cfg.N, C: cfg.C, R: cfg.R, D: cfg.D, P: cfg.P, Seed: cfg.Seed, Workload: c.workload,
+		Winner: res.Winner, ChainLength: res.ChainLength, TxSent: res.TxSent, TxConfirmed: res.TxConfirmed,
+		BlocksMined: res.Throughput.BlocksMined, Orphans: res.DES.Orphans, Events: res.DES.Events, VirtualTime: res.DES.VirtualTime,
+		LatencyP50: res.Latency.P50, LatencyP95: res.Latency.P95, LatencyMax: res.Latency.Max}
+	for _, b := range winner {
+		rec.Chain = append(rec.Chain, b.Hash)
+	}
+	return rec
+}
+
+// goldenDiff lists how got differs from want
+func goldenDiff(want, got goldenRecord) []string {
+	diff := []string{}
+	wantChain, gotChain := want.Chain, got.Chain
+	want.Chain, got.Chain = nil, nil
+	fields := func(rec goldenRecord) map[string]json.RawMessage {
+		data, _ := json.Marshal(rec)
+		m := map[string]json.RawMessage{}
+		json.Unmarshal(data, &m)
+		return m
+	}
+	wantFields, gotFields := fields(want), fields(got)
+	names := maps.Clone(wantFields)
+	maps.Copy(names, gotFields)
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		if string(wantFields[name]) != string(gotFields[name]) {
+			diff = append(diff, fmt.Sprintf("%s: golden %s, now %s", name, wantFields[name], gotFields[name]))
+		}
+	}
+	if !slices.Equal(wantChain, gotChain) {
+		k := 0
+		for k < min(len(wantChain), len(gotChain)) && wantChain[k] == gotChain[k] {
+			k++
+		}
+		diff = append(diff, fmt.Sprintf("Chain: forks at height %d of %d golden and %d now blocks", k, len(wantChain), len(gotChain)))
+	}
+	return diff
+}
+
+// hashVector is a test vector of the canonical encoding, as hash-vectors.json holds it
+type hashVector struct {
+	Name     string
+	Encoding string // hex
+	Hash     string
+}
+
+// hashVectorInputs are the blocks and transactions of the test vectors, by name
+var hashVectorInputs = []struct {
+	name  string
+	input any
+}{
+	{"empty-block", Block{}},
+	{"empty-transaction", Transaction{}},
+	{"block", Block{PrevHash: "00a1b2c3d4e5f60718293a4b5c6d7e8f00a1b2c3d4e5f60718293a4b5c6d7e8f", Timestamp: 1700000000000000000,
+		Difficulty: 2, Miner: "honest1", Nonce: 42, Uncles: []string{"00f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f"},
+		Transactions: []Transaction{{TxID: 101, Sender: "honest1", Receiver: "honest2"}, {TxID: 218, Sender: "corrupt1", Receiver: "honest3", Amount: amountOf(2.18), Fee: amountOf(0.01)}}}},
+	{"block-negative-nonce", Block{Miner: "corrupt2", Difficulty: 5, Nonce: -1, Timestamp: -1}},
+	{"utxo-transaction", Transaction{TxID: 105, Sender: "honest1", Receiver: "honest2", Amount: amountOf(12.5), Fee: amountOf(0.0001), Asset: "gold",
+		Inputs:  []Outpoint{{Tx: -1, Index: 0}, {Tx: 102, Index: 1}},
+		Outputs: []TxOut{{Owner: "honest2", Value: amountOf(12.5), Asset: "gold"}, {Owner: "honest1", Value: amountOf(7.4999)}},
+		Script:  []string{"SENDER", "SIGNED", "VERIFY"}, Witness: []string{"honest1"}}},
+	{"rollup-transaction", Transaction{TxID: rollupTxIDs, Sender: "honest1", Receiver: "rollup", Batch: []Transaction{
+		{TxID: 301, Sender: "honest1", Receiver: "honest2"}, {TxID: 302, Sender: "honest2", Receiver: "honest1", Amount: amountOf(0.5)}}}},
+	{"dag-transaction", Transaction{TxID: 101, Sender: "honest1", Receiver: "honest2", Nonce: 7, Parents: []string{
+		"00a1b2c3d4e5f60718293a4b5c6d7e8f00a1b2c3d4e5f60718293a4b5c6d7e8f", "00f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f"}}},
+	{"extreme-values", Transaction{TxID: math.MinInt64, Sender: "\xff\xfe", Receiver: "日本", Amount: math.MinInt64, Fee: math.MaxInt64,
+		Outputs: []TxOut{{Owner: "honest1", Value: -1}, {Owner: "honest1", Value: math.MaxInt64}}}},
+}
+
+// hashVectors computes the test vectors with SHA-256
+func hashVectors() []hashVector {
+	h := SimConfig{}.hashing()
+	vectors := []hashVector{}
+	for _, v := range hashVectorInputs {
+		var enc []byte
+		var hash string
+		switch input := v.input.(type) {
+		case Block:
+			enc, hash = canonicalBlock(nil, input), calculateHash(h, input)
+		case Transaction:
+			enc, hash = canonicalTransaction(nil, input), computeHash(h, input)
+		}
+		vectors = append(vectors, hashVector{Name: v.name, Encoding: hex.EncodeToString(enc), Hash: hash})
+	}
+	return vectors
+}
+
+// checkGoldenFile compares got with the golden file name, or rewrites it with -update; diff lists how
+// the file's record differs from got
+func checkGoldenFile[T any](t *testing.T, name string, got T, diff func(want, got T) []string) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".json")
+	if *update {
+		data, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	var want T
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	for _, d := range diff(want, got) {
+		t.Error(d)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			checkGoldenFile(t, c.name, runGolden(c), goldenDiff)
+		})
+	}
+	t.Run("hash-vectors", func(t *testing.T) {
+		checkGoldenFile(t, "hash-vectors", hashVectors(), hashVectorDiff)
+	})
+}
+
+// hashVectorDiff lists how the vectors got differ from want
+func hashVectorDiff(want, got []hashVector) []string {
+	wanted := make(map[string]hashVector)
+	for _, v := range want {
+		wanted[v.Name] = v
+	}
+	diff := []string{}
+	for _, v := range got {
+		w, ok := wanted[v.Name]
+		switch {
+		case !ok:
+			diff = append(diff, v.Name+": not in the file")
+		case w.Encoding != v.Encoding:
+			diff = append(diff, fmt.Sprintf("%s: encoding differs, hash golden %s, now %s", v.Name, shortHash(w.Hash), shortHash(v.Hash)))
+		case w.Hash != v.Hash:
+			diff = append(diff, fmt.Sprintf("%s: hash golden %s, now %s", v.Name, shortHash(w.Hash), shortHash(v.Hash)))
+		}
+	}
+	if len(want) != len(got) {
+		diff = append(diff, fmt.Sprintf("%d golden and %d now vectors", len(want), len(got)))
+	}
+	return diff
+}
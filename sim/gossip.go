@@ -0,0 +1,240 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Transaction Gossip ---
+
+/*
+	By default SendTransactions hands every round of transactions to every node of the sender's side, so
+	all mempools hold the same transactions. With SimConfig.TxGossip = k a PoW transaction only starts
+	at its sender's node, and the nodes relay it: a node that hears of transactions it did not know
+	passes them on to k random online peers of its side (every peer if k is at least their number).
+	Relaying takes the one-way delay of the link with SimConfig.LatencyMatrix.
+
+	Nodes mine whatever they heard of so far, so the mempools diverge: with a small fanout some
+	transactions reach only part of the nodes, and a node mines a block without the transactions still
+	on their way to it. Offline nodes (see churn.go) neither hear nor relay. The spam flood and the
+	Sybils' transactions are still handed out directly. A node only stops once the relaying is over.
+
+	Coverage is how many of the honest transactions the average honest node heard of, Full Coverage
+	how many of them reached every honest node.
+*/
+
+// GossipStats reports the transaction relay of a PoW simulation
+type GossipStats struct {
+	Fanout   int
+	Relays   int     // batches of transactions relayed to a peer
+	Coverage float64 // % of the honest transactions the average honest node heard of
+	Full     float64 // % of the honest transactions that reached every honest node
+}
+
+// txGossip relays the transactions between the nodes of a side, nil without gossip
+type txGossip struct {
+	fanout  int
+	C       int   // corrupt nodes, Sybils included
+	peers   []int // the nodes that relay, Sybils excluded
+	matrix  *latencyMatrix
+	boxes   []*mailbox[[]Transaction]
+	out     []chan [][]Transaction
+	pending atomic.Int64 // batches handed to a node that it has not relayed yet
+	quiet   chan struct{}
+	over    chan struct{} // closed once every transaction is sent and relayed
+
+	mu      sync.Mutex
+	relays  int
+	sent    int          // honest transactions sent
+	reached map[TxID]int // honest transaction -> honest nodes that heard of it
+	heard   []int        // honest transactions every honest node heard of
+}
+
+func newTxGossip(cfg SimConfig, N, C, S int, matrix *latencyMatrix, bus *EventBus) *txGossip {
+	if cfg.TxGossip <= 0 {
+		return nil
+	}
+	g := &txGossip{
+		fanout:  cfg.TxGossip,
+		C:       C,
+		matrix:  matrix,
+		boxes:   make([]*mailbox[[]Transaction], N),
+		out:     make([]chan [][]Transaction, N),
+		quiet:   make(chan struct{}, 1),
+		over:    make(chan struct{}),
+		reached: make(map[TxID]int),
+		heard:   make([]int, N),
+	}
+	for j := range N {
+		if j < C-S || j >= C {
+			g.peers = append(g.peers, j)
+		}
+		g.out[j] = make(chan [][]Transaction)
+		g.boxes[j] = newMailbox(g.out[j])
+	}
+	bus.Subscribe(func(e Event) {
+		if g.honest(e.Tx) {
+			g.mu.Lock()
+			g.sent++
+			g.mu.Unlock()
+		}
+	}, EventSent)
+	return g
+}
+
+// origin is the split hook of SendTransactions: node (counted without the Sybils, like the senders)
+// only gets the transactions it sends
+func (g *txGossip) origin(node, C int, txs []Transaction) []Transaction {
+	if g == nil {
+		return txs
+	}
+	g.pending.Add(1) // the node relays them once it reads the batch
+	sender := nodeName(node, C)
+	return slices.DeleteFunc(slices.Clone(txs), func(tx Transaction) bool { return tx.Sender != sender })
+}
+
+// relayed is node i's channel of relayed transactions, nil without gossip
+func (g *txGossip) relayed(i int) <-chan [][]Transaction {
+	if g == nil {
+		return nil
+	}
+	return g.out[i]
+}
+
+// done is closed once the relaying is over, and is nil (never ready) without gossip
+func (g *txGossip) done() <-chan struct{} {
+	if g == nil {
+		return nil
+	}
+	return g.over
+}
+
+// hear adds the transactions node i did not know, or replacements with a higher fee (see rbf.go), to
+// seen and relays them, returning them. The batch counts as handled either way, online tells whether
+// the node was there to hear it.
+func (g *txGossip) hear(i int, txs []Transaction, seen map[TxID]Amount, online bool, joined []atomic.Bool) []Transaction {
+	defer g.handled()
+	if !online {
+		return nil
+	}
+	fresh, first := []Transaction{}, []Transaction{}
+	for _, tx := range txs {
+		if !fresher(seen, tx) {
+			continue
+		}
+		if _, known := seen[tx.TxID]; !known {
+			first = append(first, tx)
+		}
+		seen[tx.TxID] = tx.Fee
+		fresh = append(fresh, tx)
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	if getLabel(i, g.C) == "honest" {
+		g.mu.Lock()
+		for _, tx := range first {
+			if g.honest(tx) { // not the spam
+				g.heard[i]++
+				g.reached[tx.TxID]++
+			}
+		}
+		g.mu.Unlock()
+	}
+
+	peers := slices.DeleteFunc(slices.Clone(g.peers), func(j int) bool {
+		return j == i || getLabel(j, g.C) != getLabel(i, g.C) || !joined[j].Load()
+	})
+	rand.Shuffle(len(peers), func(a, b int) { peers[a], peers[b] = peers[b], peers[a] })
+	for _, j := range peers[:min(g.fanout, len(peers))] {
+		g.pending.Add(1)
+		g.mu.Lock()
+		g.relays++
+		g.mu.Unlock()
+		if d := g.matrix.between(i, j); d > 0 {
+			time.AfterFunc(d, func() { g.boxes[j].send(fresh) })
+		} else {
+			g.boxes[j].send(fresh)
+		}
+	}
+	return fresh
+}
+
+// honest tells whether tx was sent by an honest node
+func (g *txGossip) honest(tx Transaction) bool {
+	return strings.HasPrefix(tx.Sender, "honest")
+}
+
+// handled marks one batch as relayed
+func (g *txGossip) handled() {
+	if g.pending.Add(-1) == 0 {
+		select {
+		case g.quiet <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// settle waits, once SendTransactions returned, until no batch is left to relay and closes done
+func (g *txGossip) settle(ctx context.Context) {
+	if g == nil {
+		return
+	}
+	for g.pending.Load() > 0 {
+		select {
+		case <-g.quiet:
+		case <-ctx.Done():
+			close(g.over)
+			return
+		}
+	}
+	close(g.over)
+}
+
+// close stops the relay queues once the nodes are done
+func (g *txGossip) close() {
+	if g == nil {
+		return
+	}
+	for _, box := range g.boxes {
+		box.close()
+	}
+}
+
+// result compares what the honest nodes heard with the honest transactions sent
+func (g *txGossip) result(N, C int) GossipStats {
+	if g == nil {
+		return GossipStats{}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := GossipStats{Fanout: g.fanout, Relays: g.relays}
+	if g.sent == 0 || N == C {
+		return stats
+	}
+	heard, full := 0, 0
+	for j := C; j < N; j++ {
+		heard += g.heard[j]
+	}
+	for _, n := range g.reached {
+		if n == N-C {
+			full++
+		}
+	}
+	stats.Coverage = getPercentage(heard, g.sent*(N-C))
+	stats.Full = getPercentage(full, g.sent)
+	return stats
+}
+
+func printGossipStats(stats GossipStats) {
+	fmt.Println("Gossip fanout      =", stats.Fanout)
+	fmt.Println("Tx relays          =", stats.Relays)
+	fmt.Println("Tx coverage %      =", stats.Coverage)
+	fmt.Println("Full coverage %    =", stats.Full)
+}
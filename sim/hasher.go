@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"crypto/sha256"
+	"crypto/sha3"
+	"fmt"
+	"slices"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// --- Hash Functions ---
+
+/*
+	-hash picks the function every block and DAG transaction is hashed with, over its canonical encoding
+	(see canonical.go): sha256 (the default, Bitcoin's), sha3 (SHA3-256, the standardized Keccak) or
+	blake2b (BLAKE2b-256). Every one gives 32 bytes, so hashes stay 64 hex digits and a difficulty is the
+	same number of leading zeros whatever the function. The function is a simulation's, SimConfig.Hasher:
+	its nodes mine, validate (peer scores, -check, the headers of a sync) and compare hashes with it
+	alike. TestGolden uses sha256, its files hold those hashes.
+
+	SHA3-256 comes from crypto/sha3 and BLAKE2b, unkeyed with a 32-byte digest, from
+	golang.org/x/crypto/blake2b. Ethereum's Keccak-256, which pads differently from SHA3-256, is not
+	offered.
+*/
+
+// Hasher is a hash function of the proofs of work
+type Hasher interface {
+	Name() string
+	Sum(data []byte) [32]byte
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Name() string             { return "sha256" }
+func (sha256Hasher) Sum(data []byte) [32]byte { return sha256.Sum256(data) }
+
+type sha3Hasher struct{}
+
+func (sha3Hasher) Name() string             { return "sha3" }
+func (sha3Hasher) Sum(data []byte) [32]byte { return sha3.Sum256(data) }
+
+type blake2bHasher struct{}
+
+func (blake2bHasher) Name() string             { return "blake2b" }
+func (blake2bHasher) Sum(data []byte) [32]byte { return blake2b.Sum256(data) }
+
+// hashers are the functions of -hash, by name
+var hashers = []Hasher{sha256Hasher{}, sha3Hasher{}, blake2bHasher{}}
+
+// hashFunc is the hash function of a simulation and how it mines, see SimConfig.Hasher
+type hashFunc struct {
+	Hasher
+	whole bool // SHA-256 mining hashes the whole encoding per nonce, see SimConfig.NoMidstate
+}
+
+// hashing returns the hash function of cfg
+func (cfg SimConfig) hashing() hashFunc {
+	h := hashFunc{Hasher: cfg.Hasher, whole: cfg.NoMidstate}
+	if h.Hasher == nil {
+		h.Hasher = sha256Hasher{}
+	}
+	return h
+}
+
+// ParseHasher returns the hash function of name, see -hash
+func ParseHasher(name string) (Hasher, error) {
+	k := slices.IndexFunc(hashers, func(h Hasher) bool { return h.Name() == name })
+	if k < 0 {
+		return nil, fmt.Errorf("unknown hash function %q (sha256, sha3 or blake2b)", name)
+	}
+	return hashers[k], nil
+}
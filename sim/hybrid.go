@@ -0,0 +1,187 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+)
+
+// --- Hybrid PoW/PoS ---
+
+/*
+	With SimConfig.Hybrid PoW miners still propose the blocks, but every node is also a
+	validator holding stake (see stake.go) that attests the blocks it mines or accepts (EventAttested).
+	A block only counts toward the winning chain once validators holding more than q of all stake
+	(AttestQuorum, default 2/3) attested it: the winning chain is the one with the longest attested
+	prefix (ties go to the most work), cut off before its first unattested block.
+
+	Honest validators attest every block they see. Corrupt validators attest only blocks mined by
+	corrupt nodes, and honest nodes never see the corrupt nodes' private blocks. So the corrupt chain
+	needs more than q of the stake to win however much of the hash power the corrupt nodes hold,
+	where pure PoW only asks for more hash power than the honest nodes. Holding more than 1-q of the
+	stake is enough to stall the honest chain instead: none of its blocks is attested and nothing is
+	confirmed. PoWWinner is the winner the most-work rule picks from the same run, for comparison.
+
+	With NothingAtStake the corrupt validators play the nothing-at-stake attack: attesting costs
+	nothing, so they attest every block on every fork they see, the honest chain included, while
+	still mining on their own withheld fork, to be on the winning side whichever fork wins. A
+	validator that attested two different blocks at the same height equivocated; honest validators
+	equivocate too whenever they accept competing blocks.
+
+	With SlashFraction = f every equivocation is caught: the first time a validator attests a second
+	block at a height it loses f of its stake, which is burned. Evidence can be submitted at any time,
+	so the slashed stake no longer counts for any block the validator attested, and the quorum is
+	taken over the stake left. Honest validators stay safe by attesting only the first block they
+	accept at each height, so competing honest blocks split the honest stake between them.
+*/
+
+// HybridStats reports the attestations of a hybrid PoW/PoS simulation
+type HybridStats struct {
+	Quorum       float64 // % of all stake that has to attest a block
+	CorruptStake float64 // % of all stake held by corrupt nodes
+	Attested     int     // blocks of the winning chain attested by a quorum
+	Unattested   int     // blocks mined on top of the winning chain's last attested block, not counted
+	PoWWinner    string  // the winner by most work alone
+	Equivocators int     // validators that attested two different blocks at the same height
+	CorruptEquiv int     // equivocators among the corrupt validators
+	Slashed      int     // validators slashed
+	SlashedStake float64 // % of all stake burned by slashing
+	CorruptLeft  float64 // % of the stake left that the corrupt nodes hold
+}
+
+// attestQuorum resolves cfg.AttestQuorum against the default
+func attestQuorum(cfg SimConfig) float64 {
+	if cfg.AttestQuorum <= 0 {
+		return 2.0 / 3
+	}
+	return min(cfg.AttestQuorum, 1)
+}
+
+// SimulateHybridCtx runs the PoW simulation with attesting validators
+func SimulateHybridCtx(ctx context.Context, cfg SimConfig) SimResult {
+	cfg.Hybrid = true
+	return SimulateBlockchainCtx(ctx, cfg)
+}
+
+// attestations tallies the stake behind every block from the EventAttested events
+type attestations struct {
+	mu      sync.Mutex
+	initial []float64
+	stakes  []float64 // stake left after slashing
+	C       int
+	slash   float64
+	burned  float64
+	voters  map[string]map[int]bool // Hash -> validators that attested it
+	heights []map[int]string        // validator -> Height -> the first block it attested there
+	equiv   map[int]bool            // validators that attested two blocks at one height
+}
+
+func trackAttestations(bus *EventBus, stakes []float64, C int, slash float64) *attestations {
+	a := &attestations{
+		initial: stakes,
+		stakes:  slices.Clone(stakes),
+		C:       C,
+		slash:   min(slash, 1),
+		voters:  make(map[string]map[int]bool),
+		heights: make([]map[int]string, len(stakes)),
+		equiv:   make(map[int]bool),
+	}
+	bus.Subscribe(func(e Event) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		i := nodeIndex(e.Node, C)
+		if a.heights[i] == nil {
+			a.heights[i] = make(map[int]string)
+		}
+		if first, ok := a.heights[i][e.Height]; !ok {
+			a.heights[i][e.Height] = e.Block.Hash
+		} else if first != e.Block.Hash && !a.equiv[i] {
+			a.equiv[i] = true
+			if a.slash > 0 {
+				a.burned += a.stakes[i] * a.slash
+				a.stakes[i] -= a.stakes[i] * a.slash
+			}
+		}
+		if a.voters[e.Block.Hash] == nil {
+			a.voters[e.Block.Hash] = make(map[int]bool)
+		}
+		a.voters[e.Block.Hash][i] = true
+	}, EventAttested)
+	return a
+}
+
+// share is validator i's % of the stake left
+func (a *attestations) share(i int) float64 {
+	if a.burned >= 1 {
+		return 0
+	}
+	return math.Round(10000*a.stakes[i]/(1-a.burned)) / 100
+}
+
+// prefix returns chain up to (not including) its first block without a quorum of attestations
+func (a *attestations) prefix(chain []Block, quorum float64) []Block {
+	for n, b := range chain[1:] { // genesis needs no attestations
+		stake := 0.0
+		for i := range a.voters[b.Hash] {
+			stake += a.stakes[i]
+		}
+		if stake <= quorum*(1-a.burned) {
+			return chain[:n+1]
+		}
+	}
+	return chain
+}
+
+// result picks the winning chain from the nodes' final EventNodeDone chains
+func (a *attestations) result(finals []Event, quorum float64) (winner []Block, winnerType string, stats HybridStats) {
+	stats = HybridStats{
+		Quorum:       math.Round(10000*quorum) / 100,
+		CorruptStake: math.Round(10000*(corruptShare(a.initial, a.C))) / 100,
+		SlashedStake: math.Round(10000*a.burned) / 100,
+	}
+	if a.burned < 1 {
+		stats.CorruptLeft = math.Round(10000*corruptShare(a.stakes, a.C)/(1-a.burned)) / 100
+	}
+	best, bestWork := -1, 0.0
+	for _, e := range finals {
+		if len(e.Chain) == 0 {
+			continue
+		}
+		n, work := len(a.prefix(e.Chain, quorum)), chainWork(e.Chain)
+		if n > best || (n == best && work > bestWork) {
+			best, bestWork = n, work
+			winner = a.prefix(e.Chain, quorum)
+			stats.Unattested = len(e.Chain) - n
+			winnerType = getLabel(nodeIndex(e.Node, a.C), a.C)
+		}
+	}
+	stats.Attested = max(0, len(winner)-1)
+	stats.Equivocators = len(a.equiv)
+	for i := range a.equiv {
+		if i < a.C {
+			stats.CorruptEquiv++
+		}
+		if a.slash > 0 {
+			stats.Slashed++
+		}
+	}
+	if stats.Attested == 0 { // stalled, no chain won
+		winnerType = ""
+	}
+	return winner, winnerType, stats
+}
+
+func printHybridStats(stats HybridStats) {
+	fmt.Println("Attest quorum %    =", stats.Quorum)
+	fmt.Println("Corrupt stake %    =", stats.CorruptStake)
+	fmt.Println("Attested blocks    =", stats.Attested)
+	fmt.Println("Unattested blocks  =", stats.Unattested)
+	fmt.Println("PoW winner         =", stats.PoWWinner)
+	fmt.Println("Equivocators       =", stats.Equivocators)
+	fmt.Println("Corrupt equivocs   =", stats.CorruptEquiv)
+	fmt.Println("Slashed validators =", stats.Slashed)
+	fmt.Println("Slashed stake %    =", stats.SlashedStake)
+	fmt.Println("Corrupt stake left =", stats.CorruptLeft)
+}
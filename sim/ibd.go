@@ -0,0 +1,308 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Initial Block Download ---
+
+/*
+	A PoW node that comes online (see churn.go) downloads the blocks it missed headers first, like
+	Bitcoin's initial block download, and does not mine until it is done:
+
+	1. It asks a random online honest peer for the headers of its chain after the node's own head (the
+	   locator). A header is a block without its transactions. Peers that are syncing themselves are
+	   only asked if every online peer is, e.g. when they all came back at once, and answer with what
+	   they have so far.
+	2. It checks that the headers link up, starting from a block it knows, and that every header's hash
+	   meets its difficulty (except with SimConfig.FastMining, whose hashes are no proof of work). Bad
+	   headers send it to another peer.
+	3. It asks for the bodies of the headers it does not know, ibdBatch at a time, every batch from a
+	   random online honest peer, and checks every body against the hash of its header.
+	4. Once every body arrived the node is synced and mines again.
+
+	A request or reply that gets lost, e.g. to a peer that just went offline, makes the node start over
+	with the headers after ibdTimeout. Peers answer between the blocks they mine, so the higher the
+	difficulty the slower the replies. The sync time runs from coming online to being synced.
+*/
+
+// ibdBatch is the number of bodies asked of a peer at once, ibdTimeout how long a download waits for
+// a reply before it starts over
+const (
+	ibdBatch   = 16
+	ibdTimeout = 250 * time.Millisecond
+)
+
+// IBDStats reports the initial block downloads of a PoW simulation
+type IBDStats struct {
+	Syncs     int           // downloads that finished
+	Blocks    int           // blocks downloaded
+	AvgHeight float64       // average height the nodes synced to
+	AvgTime   time.Duration // average time from coming online to being synced
+	MaxTime   time.Duration
+}
+
+// syncRequest asks a peer for the headers after locator, or for the bodies of hashes
+type syncRequest struct {
+	from    int
+	locator string
+	hashes  []string
+}
+
+// syncReply carries headers (blocks without transactions) or bodies (whole blocks)
+type syncReply struct {
+	headers bool
+	blocks  []Block
+}
+
+// syncNet carries the downloads between the honest nodes
+type syncNet struct {
+	peers    []int
+	joined   []atomic.Bool
+	busy     []atomic.Bool // nodes that are syncing
+	requests []chan syncRequest
+	replies  []chan syncReply
+}
+
+// newSyncNet returns nil without churn, nodes then never need to sync
+func newSyncNet(c *churn) *syncNet {
+	if c == nil {
+		return nil
+	}
+	N := len(c.joined)
+	s := &syncNet{peers: c.nodes, joined: c.joined, busy: make([]atomic.Bool, N), requests: make([]chan syncRequest, N), replies: make([]chan syncReply, N)}
+	for j := range N {
+		s.requests[j] = make(chan syncRequest, N)
+		s.replies[j] = make(chan syncReply, N)
+	}
+	return s
+}
+
+// asked is node i's channel of requests, nil without churn
+func (s *syncNet) asked(i int) <-chan syncRequest {
+	if s == nil {
+		return nil
+	}
+	return s.requests[i]
+}
+
+// ask sends req to a random online honest peer, one that is not syncing if there is any, reporting
+// false if there is none
+func (s *syncNet) ask(req syncRequest) bool {
+	peers := slices.DeleteFunc(slices.Clone(s.peers), func(j int) bool { return j == req.from || !s.joined[j].Load() })
+	if len(peers) == 0 {
+		return false
+	}
+	if synced := slices.DeleteFunc(slices.Clone(peers), func(j int) bool { return s.busy[j].Load() }); len(synced) > 0 {
+		peers = synced
+	}
+	select {
+	case s.requests[peers[rand.IntN(len(peers))]] <- req:
+	default: // the peer is swamped, the request is lost
+	}
+	return true
+}
+
+// answer replies to req from the view of a node whose chain ends at head
+func (s *syncNet) answer(req syncRequest, HashMap BlockStore, genesis Block, head string) {
+	rep := syncReply{headers: req.hashes == nil}
+	if rep.headers {
+		chain := buildBlockChain(HashMap, genesis, head)[1:]
+		from := 0 // everything if the locator is not on the chain
+		for k, b := range chain {
+			if b.Hash == req.locator {
+				from = k + 1
+			}
+		}
+		for _, b := range chain[from:] {
+			b.Transactions = nil
+			rep.blocks = append(rep.blocks, b)
+		}
+	} else {
+		for _, h := range req.hashes {
+			if b, ok := HashMap.Get(h); ok {
+				rep.blocks = append(rep.blocks, b)
+			}
+		}
+	}
+	select {
+	case s.replies[req.from] <- rep:
+	default: // the node is swamped, the reply is lost
+	}
+}
+
+// download is a node's initial block download
+type download struct {
+	net     *syncNet
+	node    int
+	fast    bool            // SimConfig.FastMining, headers carry no proof of work
+	active  bool            // the node is syncing
+	want    map[string]bool // bodies still missing, nil while waiting for headers
+	got     int             // bodies downloaded since the node came online
+	expires <-chan time.Time
+}
+
+// download returns node i's download, nil without churn
+func (s *syncNet) download(i int, cfg SimConfig) *download {
+	if s == nil {
+		return nil
+	}
+	return &download{net: s, node: i, fast: cfg.FastMining}
+}
+
+// syncing tells whether the node waits for its download before it mines
+func (d *download) syncing() bool {
+	return d != nil && d.active
+}
+
+// replied is the node's channel of replies and expired fires once the download should start over,
+// both nil while the node is not syncing
+func (d *download) replied() <-chan syncReply {
+	if !d.syncing() {
+		return nil
+	}
+	return d.net.replies[d.node]
+}
+
+func (d *download) expired() <-chan time.Time {
+	if !d.syncing() {
+		return nil
+	}
+	return d.expires
+}
+
+// begin starts the download of a node that came online with head, abort stops it when it goes offline
+func (d *download) begin(head string) {
+	d.got = 0
+	d.request(head)
+}
+
+func (d *download) abort() {
+	d.setActive(false)
+}
+
+// setActive also tells the peers whether the node is syncing
+func (d *download) setActive(active bool) {
+	d.active = active
+	d.net.busy[d.node].Store(active)
+}
+
+// request (re)starts with the headers after head, a node without online peers has nothing to download
+func (d *download) request(head string) {
+	d.want = nil
+	d.expires = time.After(ibdTimeout)
+	d.setActive(d.net.ask(syncRequest{from: d.node, locator: head}))
+}
+
+// receive takes a reply and returns the bodies to add to the node's view, and whether the download is done
+func (d *download) receive(h hashFunc, rep syncReply, HashMap BlockStore, genesis Block, head string) ([]Block, bool) {
+	known := func(h string) bool {
+		_, ok := HashMap.Get(h)
+		return ok || h == "" || h == genesis.Hash
+	}
+	if rep.headers {
+		if d.want != nil { // a late reply to a request made before starting over
+			return nil, false
+		}
+		if !validHeaders(rep.blocks, known, d.fast) {
+			d.request(head)
+			return nil, false
+		}
+		d.want = make(map[string]bool)
+		missing := []string{}
+		for _, h := range rep.blocks {
+			if !known(h.Hash) {
+				d.want[h.Hash] = true
+				missing = append(missing, h.Hash)
+			}
+		}
+		for batch := range slices.Chunk(missing, ibdBatch) {
+			d.net.ask(syncRequest{from: d.node, hashes: batch})
+		}
+		d.expires = time.After(ibdTimeout)
+		d.setActive(len(d.want) > 0)
+		return nil, !d.active
+	}
+	if d.want == nil { // bodies asked for before starting over
+		return nil, false
+	}
+	bodies := []Block{}
+	for _, b := range rep.blocks {
+		if d.want[b.Hash] && calculateHash(h, b) == b.Hash { // the body matches its header
+			delete(d.want, b.Hash)
+			d.got++
+			bodies = append(bodies, b)
+		}
+	}
+	if len(bodies) > 0 {
+		d.expires = time.After(ibdTimeout)
+	}
+	d.setActive(len(d.want) > 0)
+	return bodies, !d.active
+}
+
+// validHeaders checks that headers link up from a known block and carry their proof of work
+func validHeaders(headers []Block, known func(string) bool, fast bool) bool {
+	for k, h := range headers {
+		if k == 0 && !known(h.PrevHash) || k > 0 && h.PrevHash != headers[k-1].Hash {
+			return false
+		}
+		if !fast && !strings.HasPrefix(h.Hash, strings.Repeat("0", h.Difficulty)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ibdTracker times the downloads from the EventJoined to the EventSynced of every honest node
+type ibdTracker struct {
+	mu      sync.Mutex
+	online  map[string]time.Time
+	stats   IBDStats
+	total   time.Duration
+	heights int
+}
+
+func trackIBD(bus *EventBus, C int) *ibdTracker {
+	t := &ibdTracker{online: make(map[string]time.Time)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if e.Kind == EventJoined {
+			if getLabel(nodeIndex(e.Node, C), C) == "honest" { // Sybils join too
+				t.online[e.Node] = e.Time
+			}
+			return
+		}
+		took := e.Time.Sub(t.online[e.Node])
+		t.stats.Syncs++
+		t.stats.Blocks += e.Depth
+		t.heights += e.Height
+		t.total += took
+		t.stats.MaxTime = max(t.stats.MaxTime, took)
+	}, EventJoined, EventSynced)
+	return t
+}
+
+func (t *ibdTracker) result() IBDStats {
+	stats := t.stats
+	if stats.Syncs > 0 {
+		stats.AvgHeight = float64(t.heights) / float64(stats.Syncs)
+		stats.AvgTime = t.total / time.Duration(stats.Syncs)
+	}
+	return stats
+}
+
+func printIBDStats(stats IBDStats) {
+	fmt.Println("Syncs              =", stats.Syncs)
+	fmt.Println("Synced blocks      =", stats.Blocks)
+	fmt.Println("Avg sync height    =", stats.AvgHeight)
+	fmt.Println("Avg sync time      =", stats.AvgTime)
+	fmt.Println("Max sync time      =", stats.MaxTime)
+}
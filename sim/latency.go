@@ -0,0 +1,113 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// LatencyStats summarizes how long confirmed transactions took from being sent to being mined
+// into the block (PoW) or vertex (DAG) they were confirmed in.
+type LatencyStats struct {
+	Count int // confirmed transactions with a known latency
+	P50   time.Duration
+	P95   time.Duration
+	Max   time.Duration
+}
+
+// timingTracker records send and mining times from a simulation's bus for the latency and throughput metrics.
+// Transactions are identified by TxID, like everywhere else in the simulators.
+type timingTracker struct {
+	mu         sync.Mutex
+	sent       map[TxID]time.Time   // tx -> when SendTransactions created it
+	minedBlock map[string]time.Time // block / vertex hash -> when it was mined
+	firstMined map[TxID]time.Time   // tx -> first time any node mined it
+}
+
+func trackTiming(bus *EventBus) *timingTracker {
+	t := &timingTracker{
+		sent:       make(map[TxID]time.Time),
+		minedBlock: make(map[string]time.Time),
+		firstMined: make(map[TxID]time.Time),
+	}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch e.Kind {
+		case EventSent:
+			t.sent[e.Tx.TxID] = e.Time
+		case EventMined:
+			t.minedBlock[e.Block.Hash] = e.Time
+			for _, tx := range e.Block.Transactions {
+				if _, ok := t.firstMined[tx.TxID]; !ok {
+					t.firstMined[tx.TxID] = e.Time
+				}
+			}
+		}
+	}, EventSent, EventMined)
+	return t
+}
+
+// chainLatencies measures each transaction of chain up to the first block of chain that includes it
+func (t *timingTracker) chainLatencies(chain []Block) []time.Duration {
+	latencies := []time.Duration{}
+	seen := make(map[TxID]struct{})
+	for _, b := range chain {
+		mined, ok := t.minedBlock[b.Hash]
+		if !ok { // genesis
+			continue
+		}
+		for _, tx := range b.Transactions {
+			if _, dup := seen[tx.TxID]; dup {
+				continue
+			}
+			seen[tx.TxID] = struct{}{}
+			if sent, ok := t.sent[tx.TxID]; ok {
+				latencies = append(latencies, mined.Sub(sent))
+			}
+		}
+	}
+	return latencies
+}
+
+// txLatencies measures each transaction up to the first time any node mined it
+func (t *timingTracker) txLatencies(txs []Transaction) []time.Duration {
+	latencies := []time.Duration{}
+	for _, tx := range txs {
+		sent, ok1 := t.sent[tx.TxID]
+		mined, ok2 := t.firstMined[tx.TxID]
+		if ok1 && ok2 {
+			latencies = append(latencies, mined.Sub(sent))
+		}
+	}
+	return latencies
+}
+
+func summarizeLatencies(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	slices.Sort(latencies)
+	return LatencyStats{
+		Count: len(latencies),
+		P50:   percentile(latencies, 50),
+		P95:   percentile(latencies, 95),
+		Max:   latencies[len(latencies)-1],
+	}
+}
+
+// percentile uses the nearest-rank method on sorted values
+func percentile(sorted []time.Duration, pct int) time.Duration {
+	rank := (pct*len(sorted) + 99) / 100 // ceil(pct/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func printLatencyStats(stats LatencyStats) {
+	fmt.Printf("Latency p50 (s)     = %.3f\n", stats.P50.Seconds())
+	fmt.Printf("Latency p95 (s)     = %.3f\n", stats.P95.Seconds())
+	fmt.Printf("Latency max (s)     = %.3f\n", stats.Max.Seconds())
+}
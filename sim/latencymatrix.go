@@ -0,0 +1,175 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Latency Matrix ---
+
+/*
+	SimConfig.LatencyMatrix puts the nodes on a map: every block a PoW node (or the discrete-event
+	engine's node) sends to a peer takes the one-way delay between their regions. The matrix is either
+	a preset or a CSV file of M rows of M delays in milliseconds (row = sender, column = receiver, so
+	links can be asymmetric). Node i sits in region i mod M, so every region gets its share of the
+	nodes, honest and corrupt alike, whatever N is; an N by N file gives every node its own row.
+
+	The presets are
+	- continents: North America, Europe, Asia, South America and Oceania, with rough one-way delays
+	  between them (tens of ms within a continent, up to 160ms across the world).
+
+	A miner far from the others hears about their blocks late and keeps mining on a stale head, and its
+	own blocks reach the others late, so it loses more races. Region Blocks compares the blocks every
+	region mined with the ones that made it into the winning chain. Only blocks (see bandwidth.go) and
+	relayed transactions (see gossip.go) are delayed, the churn and sync messages are not.
+*/
+
+// latencyPresets are the built-in matrices of SimConfig.LatencyMatrix
+var latencyPresets = map[string]latencyMatrix{
+	"continents": {
+		regions: []string{"NA", "EU", "AS", "SA", "OC"},
+		delays: msMatrix([][]float64{
+			{20, 45, 90, 70, 80},
+			{45, 15, 110, 100, 140},
+			{90, 110, 30, 160, 60},
+			{70, 100, 160, 25, 150},
+			{80, 140, 60, 150, 15},
+		}),
+	},
+}
+
+// latencyMatrix is the one-way delay between every pair of regions
+type latencyMatrix struct {
+	regions []string
+	delays  [][]time.Duration
+}
+
+func msMatrix(ms [][]float64) [][]time.Duration {
+	delays := make([][]time.Duration, len(ms))
+	for r, row := range ms {
+		for _, d := range row {
+			delays[r] = append(delays[r], time.Duration(d*float64(time.Millisecond)))
+		}
+	}
+	return delays
+}
+
+// parseLatencyMatrix reads a preset name or a CSV file, "" is no matrix
+func parseLatencyMatrix(spec string) (*latencyMatrix, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if m, ok := latencyPresets[spec]; ok {
+		return &m, nil
+	}
+	f, err := os.Open(spec)
+	if err != nil {
+		return nil, fmt.Errorf("latency matrix %q is neither a preset nor a readable file: %w", spec, err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("latency matrix %s: %w", spec, err)
+	}
+	ms := make([][]float64, len(rows))
+	for r, row := range rows {
+		if len(row) != len(rows) {
+			return nil, fmt.Errorf("latency matrix %s: row %d has %d delays, not %d", spec, r+1, len(row), len(rows))
+		}
+		for _, cell := range row {
+			d, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("latency matrix %s: row %d: %q is not a delay in ms", spec, r+1, cell)
+			}
+			ms[r] = append(ms[r], d)
+		}
+	}
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("latency matrix %s is empty", spec)
+	}
+	m := &latencyMatrix{delays: msMatrix(ms)}
+	for r := range ms {
+		m.regions = append(m.regions, fmt.Sprintf("r%d", r+1))
+	}
+	return m, nil
+}
+
+// newLatencyMatrix returns nil without a matrix, the tester already checked the spec
+func newLatencyMatrix(cfg SimConfig) *latencyMatrix {
+	m, _ := parseLatencyMatrix(cfg.LatencyMatrix)
+	return m
+}
+
+// region is the region of node i
+func (m *latencyMatrix) region(i int) int {
+	return i % len(m.regions)
+}
+
+// between is the delay from node i to node j, 0 without a matrix
+func (m *latencyMatrix) between(i, j int) time.Duration {
+	if m == nil || i == j {
+		return 0
+	}
+	return m.delays[m.region(i)][m.region(j)]
+}
+
+// RegionStats compares the blocks a region mined with the ones in the winning chain
+type RegionStats struct {
+	Region   string
+	Mined    int
+	InWinner int
+}
+
+// regionTracker counts the blocks mined in every region
+type regionTracker struct {
+	mu    sync.Mutex
+	mined map[string]int // block hash -> region
+	stats []RegionStats
+}
+
+func trackRegions(bus *EventBus, m *latencyMatrix, C int) *regionTracker {
+	t := &regionTracker{mined: make(map[string]int)}
+	if m == nil {
+		return t
+	}
+	for _, r := range m.regions {
+		t.stats = append(t.stats, RegionStats{Region: r})
+	}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		r := m.region(nodeIndex(e.Node, C))
+		t.mined[e.Block.Hash] = r
+		t.stats[r].Mined++
+	}, EventMined)
+	return t
+}
+
+func (t *regionTracker) result(winner []Block) []RegionStats {
+	stats := append([]RegionStats(nil), t.stats...)
+	for _, b := range winner {
+		if r, ok := t.mined[b.Hash]; ok {
+			stats[r].InWinner++
+		}
+	}
+	return stats
+}
+
+// formatRegions writes every region's winning and mined blocks, e.g. "NA=8/10 EU=5/9"
+func formatRegions(stats []RegionStats) string {
+	parts := []string{}
+	for _, r := range stats {
+		parts = append(parts, fmt.Sprintf("%s=%d/%d", r.Region, r.InWinner, r.Mined))
+	}
+	return strings.Join(parts, " ")
+}
+
+func printRegionStats(matrix string, stats []RegionStats) {
+	fmt.Println("Latency matrix     =", matrix)
+	fmt.Println("Region blocks      =", formatRegions(stats))
+}
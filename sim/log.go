@@ -0,0 +1,34 @@
+package sim
+
+import (
+	"log/slog"
+	"os"
+)
+
+// logLevel is set by SetLogLevel, see -log-level
+var logLevel = new(slog.LevelVar)
+
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
+// SetLogLevel sets the level of the logs of the tester and every simulator
+func SetLogLevel(level slog.Level) {
+	logLevel.Set(level)
+}
+
+// componentLogger tags every record with the part of the program that wrote it (e.g. "tester", "pow", "dag")
+func componentLogger(component string) *slog.Logger {
+	return logger.With("component", component)
+}
+
+// nodeLogger tags every record with the component and the node's name (e.g. "honest3")
+func nodeLogger(component string, node string) *slog.Logger {
+	return componentLogger(component).With("node", node)
+}
+
+// shortHash keeps log lines readable
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// --- Long-Range Attack ---
+
+/*
+	With SimConfig.LongRange = r a hybrid simulation ends with a long-range attack on its winning
+	chain. The corrupt nodes fork the chain r blocks below its tip and mine a longer one on top,
+	attested by every key they hold from the fork point: their own, plus the keys of honest validators
+	holding ExitedStake of all stake (by default all of them) that have since exited, withdrawing their
+	stake, and sold their old keys as they have nothing left to lose. Exited keys cannot be slashed.
+
+	A new node that syncs both chains only knows the validator set of their common history, so if
+	those keys held more than the quorum the attack chain looks attested, and being longer it wins by
+	the naive longest-chain rule. With a weak-subjectivity checkpoint the new node also gets the block
+	WSPeriod blocks below the tip (default r/2) from a source it trusts and ignores every chain without
+	it, which defeats the attack unless the checkpoint is older than the fork point.
+*/
+
+// LongRangeStats reports a long-range attack on the winning chain of a hybrid simulation
+type LongRangeStats struct {
+	ForkHeight   int     // height of the last block the attack chain shares with the winning chain
+	AttackBlocks int     // blocks the corrupt nodes mined on top of the fork point
+	KeyStake     float64 // % of the stake at the fork point whose keys the corrupt nodes held
+	Fooled       bool    // a new node following the longest attested chain picked the attack chain
+	Checkpoint   int     // height of the weak-subjectivity checkpoint
+	FooledWS     bool    // ... even with the weak-subjectivity checkpoint
+}
+
+// exitedStake resolves cfg.ExitedStake against the default
+func exitedStake(cfg SimConfig) float64 {
+	if cfg.ExitedStake <= 0 {
+		return 1
+	}
+	return min(cfg.ExitedStake, 1)
+}
+
+// newNodeChoice is the chain a new node picks from chains: the longest attested prefix among the chains
+// holding checkpoint ("" = no checkpoint), the first one on a tie
+func newNodeChoice(chains [][]Block, attested func([]Block) []Block, checkpoint string) []Block {
+	var best []Block
+	for _, chain := range chains {
+		if checkpoint != "" && !slices.ContainsFunc(chain, func(b Block) bool { return b.Hash == checkpoint }) {
+			continue
+		}
+		if prefix := attested(chain); len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return best
+}
+
+// longRangeAttack mines the attack chain against winner and shows both chains to a new node
+func longRangeAttack(ctx context.Context, cfg SimConfig, winner []Block, a *attestations, quorum float64) LongRangeStats {
+	C := cfg.C
+	tip := len(winner) - 1
+	stats := LongRangeStats{ForkHeight: max(0, tip-cfg.LongRange)}
+	ws := cfg.WSPeriod
+	if ws <= 0 {
+		ws = cfg.LongRange / 2
+	}
+	stats.Checkpoint = max(0, tip-ws)
+	if C == 0 || tip < 0 {
+		return stats
+	}
+	corrupt := corruptShare(a.initial, C)
+	keys := corrupt + min(exitedStake(cfg), 1-corrupt)
+	stats.KeyStake = math.Round(10000*keys) / 100
+
+	chain := slices.Clone(winner[:stats.ForkHeight+1])
+	for len(chain) <= len(winner) { // one block longer than the winning chain
+		prev := chain[len(chain)-1]
+		b, ok := generateBlock(ctx, cfg, newBlock(nodeName(len(chain)%C, C), prev.Hash, nil, []Transaction{}, prev.Difficulty, time.Now().UnixNano()))
+		if !ok { // cancelled
+			return stats
+		}
+		chain = append(chain, b)
+		stats.AttackBlocks++
+	}
+
+	// The new node checks the honest chain against the attestations it saw and the attack chain against
+	// the stake of the keys that signed it
+	forged := make(map[string]bool)
+	for _, b := range chain[stats.ForkHeight+1:] {
+		forged[b.Hash] = true
+	}
+	attested := func(c []Block) []Block {
+		if len(c) == 0 || !forged[c[len(c)-1].Hash] {
+			return a.prefix(c, quorum)
+		}
+		if keys <= quorum {
+			return c[:stats.ForkHeight+1]
+		}
+		return c
+	}
+	attack := func(c []Block) bool { return len(c) > 0 && forged[c[len(c)-1].Hash] }
+	chains := [][]Block{winner, chain}
+	stats.Fooled = attack(newNodeChoice(chains, attested, ""))
+	stats.FooledWS = attack(newNodeChoice(chains, attested, winner[stats.Checkpoint].Hash))
+	return stats
+}
+
+func printLongRangeStats(stats LongRangeStats) {
+	fmt.Println("Fork height        =", stats.ForkHeight)
+	fmt.Println("Attack blocks      =", stats.AttackBlocks)
+	fmt.Println("Key stake %        =", stats.KeyStake)
+	fmt.Println("New node fooled    =", stats.Fooled)
+	fmt.Println("WS checkpoint      =", stats.Checkpoint)
+	fmt.Println("Fooled with WS     =", stats.FooledWS)
+}
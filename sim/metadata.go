@@ -0,0 +1,160 @@
+package sim
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- Run Metadata ---
+
+/*
+	Every run also writes the metadata of the run next to its results, in <out>.meta.json for -out
+	<out>.csv (benchmark_results.meta.json by default), so an archived CSV can still be told apart from
+	another: the schema version of the columns, the git commit of the simulator (with "-dirty" for
+	uncommitted changes), the Go version, OS and architecture, the hostname, the CPU count and
+	GOMAXPROCS, the seeds, the value of every flag, the tests run (with the D of -calibrate, and without R
+	under -round-budget, where it is per mode) and the config hash, the SHA-256 of the flags and tests that
+	shape the results: two runs with the same config hash ran the same tests the same way, whatever
+	file they wrote to or how verbosely. FirstRow and Rows are the rows of the CSV the run wrote
+	(FirstRow 1 is the row after the header), so the file holds a list of runs: a run that replaces the
+	CSV replaces the list, -append and -resume add to it.
+
+	The schema version is resultsSchemaVersion, raised whenever columns are added, removed or change
+	meaning; with the column count it says which layout a CSV has. The commit comes from the build
+	info of a binary built from a checkout, else from git in the working directory, and is empty if
+	neither knows it.
+*/
+
+// resultsSchemaVersion is the version of the results columns, raised whenever they change
+const resultsSchemaVersion = 1
+
+// runMetadata is a run of the suite as <out>.meta.json lists it
+type runMetadata struct {
+	SchemaVersion int
+	Columns       int
+	Started       time.Time
+	Finished      time.Time
+	Interrupted   bool `json:",omitempty"`
+	Commit        string
+	GoVersion     string
+	OS            string
+	Arch          string
+	Hostname      string
+	CPUs          int
+	GOMAXPROCS    int
+	Seed          uint64 // of des
+	ScheduleSeed  uint64 // of bft's -schedule
+	ConfigHash    string
+	Tests         []string
+	Flags         map[string]string
+	FirstRow      int
+	Rows          int
+}
+
+// unhashedFlags only change where and how the results are written, not the results
+var unhashedFlags = []string{"out", "append", "out-timestamp", "long", "parquet", "resume", "log-level", "tui"}
+
+// metadataPath is the metadata file of the results file path
+func metadataPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".meta.json"
+}
+
+// newRunMetadata describes a run of o started at start that runs tests
+func newRunMetadata(start time.Time, o Options, tests []BenchmarkConfig) runMetadata {
+	host, _ := os.Hostname()
+	meta := runMetadata{
+		SchemaVersion: resultsSchemaVersion,
+		Columns:       len(resultHeaders()),
+		Started:       start,
+		Commit:        buildCommit(),
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		Hostname:      host,
+		CPUs:          runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Seed:          o.Config.Seed,
+		ScheduleSeed:  o.Config.ScheduleSeed,
+		Flags:         o.Flags,
+	}
+	for _, t := range tests {
+		test := fmt.Sprintf("N=%d C=%d R=%d D=%d p=%g", t.N, t.C, t.R, t.D, t.p)
+		if o.RoundBudget > 0 {
+			test = fmt.Sprintf("N=%d C=%d D=%d p=%g", t.N, t.C, t.D, t.p)
+		}
+		meta.Tests = append(meta.Tests, test)
+	}
+	hash := sha256.New()
+	for _, name := range slices.Sorted(maps.Keys(meta.Flags)) {
+		if !slices.Contains(unhashedFlags, name) {
+			fmt.Fprintf(hash, "%s=%s\n", name, meta.Flags[name])
+		}
+	}
+	for _, test := range meta.Tests {
+		fmt.Fprintln(hash, test)
+	}
+	meta.ConfigHash = fmt.Sprintf("%x", hash.Sum(nil))
+	return meta
+}
+
+// buildCommit is the git commit the simulator was built from, "" if unknown
+func buildCommit() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		commit, dirty := "", ""
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision":
+				commit = s.Value
+			case s.Key == "vcs.modified" && s.Value == "true":
+				dirty = "-dirty"
+			}
+		}
+		if commit != "" {
+			return commit + dirty
+		}
+	}
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(out))
+	if status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output(); err == nil && len(status) > 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// writeMetadata writes run to the metadata file of the results file path, after the runs already
+// in it if appending
+func writeMetadata(path string, run runMetadata, appending bool) error {
+	path = metadataPath(path)
+	runs := []runMetadata{}
+	if appending {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &runs); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	data, err := json.MarshalIndent(append(runs, run), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
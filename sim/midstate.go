@@ -0,0 +1,54 @@
+package sim
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+)
+
+// --- Midstate Mining ---
+
+/*
+	SHA-256 hashes its input 64 bytes at a time, carrying a 32-byte state from one block to the next,
+	and a miner only changes the nonce, the last 8 bytes of the canonical encoding. So with -hash sha256
+	mineNonce hashes the 64-byte blocks before the nonce once, keeps the state they leave (the midstate,
+	as Bitcoin miners call it), and per nonce restores it and hashes only the rest: the last 8 to 71
+	bytes, one or two blocks with the padding. For the 10-transaction block of BenchmarkMineBlock (1,016 bytes) that
+	is 2 blocks instead of 17 per attempt. The hashes are the same, only the work to find them is
+	less, and the more nonces a difficulty needs the more is saved.
+
+	The state is saved and restored with the MarshalBinary and UnmarshalBinary of crypto/sha256, which
+	keeps its assembly (SHA extensions where the CPU has them). Go has no SIMD intrinsics to hash several
+	nonces at once in one set of registers; nonces are tried one after another in a loop that allocates
+	nothing. -midstate=false hashes the whole encoding per nonce again, to compare; SHA-3 and BLAKE2b
+	always do.
+*/
+
+// mineMidstate is mineNonce with SHA-256, hashing the fixed prefix of enc once
+func mineMidstate(ctx context.Context, enc []byte, nonce, difficulty int) (int, [32]byte, bool) {
+	split := (len(enc) - 8) / sha256.BlockSize * sha256.BlockSize
+	digest := sha256.New()
+	digest.Write(enc[:split])
+	midstate, err := digest.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return mineWhole(ctx, hashFunc{Hasher: sha256Hasher{}}, enc, nonce, difficulty)
+	}
+	restore := digest.(encoding.BinaryUnmarshaler)
+	rest := enc[split:]
+	at := len(rest) - 8
+	var sum [32]byte
+	for {
+		binary.BigEndian.PutUint64(rest[at:], uint64(nonce))
+		restore.UnmarshalBinary(midstate)
+		digest.Write(rest)
+		digest.Sum(sum[:0])
+		if leadingZeros(sum, difficulty) {
+			return nonce, sum, true
+		}
+		nonce++
+		if nonce%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return nonce, sum, false
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package sim
+
+// Observer receives callbacks while a simulation runs, set it with SimConfig.Observer.
+// It is a convenience over subscribing to the simulation's EventBus (see SimConfig.Events).
+// Callbacks are made from the node goroutines, so implementations must be safe for concurrent use
+// and should return quickly since the calling node is blocked until they do.
+//
+// The DAG has no blocks: each mined transaction is its own vertex and is reported to
+// OnBlockMined / OnBlockAccepted as a Block holding just that transaction, with the same Hash.
+type Observer interface {
+	OnBlockMined(node string, b Block)               // node mined b
+	OnBlockAccepted(node string, b Block)            // node received b from a peer and added it to its view
+	OnTxConfirmed(tx Transaction)                    // tx made it into the final result (winning chain / confident DAG set)
+	OnForkDetected(node string, b Block, height int) // PoW only: b was attached at height without extending node's current tip
+}
+
+// NopObserver ignores every callback, embed it to implement only some of them.
+type NopObserver struct{}
+
+func (NopObserver) OnBlockMined(node string, b Block)               {}
+func (NopObserver) OnBlockAccepted(node string, b Block)            {}
+func (NopObserver) OnTxConfirmed(tx Transaction)                    {}
+func (NopObserver) OnForkDetected(node string, b Block, height int) {}
+
+// dagVertex wraps a DAG transaction as a Block for Observer callbacks
+func dagVertex(tx Transaction) Block {
+	return Block{Transactions: []Transaction{tx}, Hash: tx.Hash}
+}
@@ -0,0 +1,80 @@
+package sim
+
+import (
+	"fmt"
+	"sync"
+)
+
+// --- PoW Orphan Pool ---
+
+/*
+	A PoW node that receives a block whose parent it doesn't know yet parks the block in its orphan pool,
+	keyed by the missing PrevHash, and attaches it (and anything parked behind it) once the parent arrives.
+	Blocks still in the pool when the node stops are discarded (EventRejected).
+	Not to be confused with ForkStats.Orphans, the mined blocks that ended up off the winning chain.
+*/
+
+// OrphanPoolStats reports how the orphan pools of a PoW simulation were used
+type OrphanPoolStats struct {
+	Held      int     // blocks parked because their parent was unknown
+	Adopted   int     // parked blocks attached once their parent arrived
+	Discarded int     // parked blocks whose parent never arrived
+	HitRate   float64 // Adopted as a percentage of Held
+}
+
+// orphanPool holds blocks by the hash of their missing parent
+type orphanPool map[string][]Block
+
+// park holds b until its parent arrives
+func (pool orphanPool) park(b Block) {
+	pool[b.PrevHash] = append(pool[b.PrevHash], b)
+}
+
+// release removes and returns the blocks waiting for parent
+func (pool orphanPool) release(parent string) []Block {
+	children := pool[parent]
+	delete(pool, parent)
+	return children
+}
+
+// orphanTracker tallies the orphan pool events of a simulation
+type orphanTracker struct {
+	mu     sync.Mutex
+	stats  OrphanPoolStats
+	parked map[string]bool // Node + Hash of the blocks held, nodes also reject blocks they never parked
+}
+
+func trackOrphanPool(bus *EventBus) *orphanTracker {
+	t := &orphanTracker{parked: make(map[string]bool)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch e.Kind {
+		case EventOrphaned:
+			t.stats.Held++
+			t.parked[e.Node+e.Block.Hash] = true
+		case EventAdopted:
+			t.stats.Adopted++
+		case EventRejected:
+			if t.parked[e.Node+e.Block.Hash] {
+				t.stats.Discarded++
+			}
+		}
+	}, EventOrphaned, EventAdopted, EventRejected)
+	return t
+}
+
+func (t *orphanTracker) result() OrphanPoolStats {
+	stats := t.stats
+	if stats.Held > 0 {
+		stats.HitRate = getPercentage(stats.Adopted, stats.Held)
+	}
+	return stats
+}
+
+func printOrphanPoolStats(stats OrphanPoolStats) {
+	fmt.Println("Orphan pool held   =", stats.Held)
+	fmt.Println("Orphan pool hits   =", stats.Adopted)
+	fmt.Println("Orphans discarded  =", stats.Discarded)
+	fmt.Println("Orphan pool hit %  =", stats.HitRate)
+}
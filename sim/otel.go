@@ -0,0 +1,198 @@
+package sim
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- OpenTelemetry Tracing ---
+
+/*
+	With -otlp <url> the tester exports the lifecycle of every block as OpenTelemetry spans to an OTLP
+	collector, e.g. -otlp http://localhost:4318 for Jaeger, so a simulation can be inspected block by
+	block. Each block (DAG vertex) is a trace of its own:
+	- "mine" on the miner, from the start of mining (Block.Timestamp, the DAG has none) until it was mined,
+	  the root span;
+	- "propagate" on every node that accepted it, from when the node's peer handed it over (EventDelivered)
+	  until the node accepted it, a child of the span of that peer, so the trace shows the path the block
+	  took and where it was slow;
+	- "drop" and "reject" spans with an error status where a delivery was dropped (channel full or busy) or
+	  a node discarded the block.
+	The resource attributes name the test and mode, so the simulations of a suite tell apart in Jaeger.
+
+	The spans are sent once the simulation ends, otlpBatch per request, with the OTLP/HTTP JSON encoding
+	to <url>/v1/traces; it needs no OpenTelemetry SDK, and collectors that only speak gRPC are not
+	supported.
+*/
+
+const otlpBatch = 2000
+
+// otlpSpan is a span in OTLP/JSON
+type otlpSpan struct {
+	TraceID      string          `json:"traceId"`
+	SpanID       string          `json:"spanId"`
+	ParentSpanID string          `json:"parentSpanId,omitempty"`
+	Name         string          `json:"name"`
+	Kind         int             `json:"kind"` // 1 = internal
+	Start        string          `json:"startTimeUnixNano"`
+	End          string          `json:"endTimeUnixNano"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	Status       *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string `json:"stringValue,omitempty"`
+	Int    *string `json:"intValue,omitempty"` // int64 is a string in OTLP/JSON
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 = error
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttr(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{String: &value}}
+}
+
+func intAttr(key string, value int) otlpAttribute {
+	s := strconv.Itoa(value)
+	return otlpAttribute{Key: key, Value: otlpValue{Int: &s}}
+}
+
+func unixNano(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+// handover is a delivery of a block to a peer, waiting for the peer to accept it
+type handover struct {
+	at     time.Time
+	parent string // span of the node that delivered it
+}
+
+// blockTracer turns the events of a simulation into spans, exported once it is over
+type blockTracer struct {
+	run      string // tells the traces of the simulations apart
+	resource []otlpAttribute
+
+	mu        sync.Mutex
+	spans     []otlpSpan
+	mined     map[string]string              // block hash -> span of its miner
+	accepted  map[string]map[string]string   // block hash -> node -> its propagate span
+	delivered map[string]map[string]handover // block hash -> peer -> its latest handover
+	drops     int
+}
+
+// traceBlocks traces the blocks of bus, the simulation of mode in test
+func traceBlocks(bus *EventBus, test int, mode string, t BenchmarkConfig) *blockTracer {
+	tr := &blockTracer{run: fmt.Sprintf("%d/%d/%s", time.Now().UnixNano(), test, mode), mined: make(map[string]string),
+		accepted: make(map[string]map[string]string), delivered: make(map[string]map[string]handover)}
+	tr.resource = []otlpAttribute{stringAttr("service.name", "blockchain-sim"), intAttr("sim.test", test), stringAttr("sim.mode", mode),
+		intAttr("sim.N", t.N), intAttr("sim.C", t.C), intAttr("sim.R", t.R), intAttr("sim.D", t.D), stringAttr("sim.p", strconv.FormatFloat(t.p, 'f', -1, 64))}
+	bus.Subscribe(tr.handle, EventMined, EventDelivered, EventAccepted, EventDropped, EventRejected)
+	return tr
+}
+
+// ids returns the trace of the block hash and a span of it named by parts
+func (tr *blockTracer) ids(hash string, parts ...string) (string, string) {
+	trace := sha256.Sum256([]byte(tr.run + "/" + hash))
+	span := sha256.Sum256([]byte(tr.run + "/" + hash + "/" + strings.Join(parts, "/")))
+	return hex.EncodeToString(trace[:16]), hex.EncodeToString(span[:8])
+}
+
+// spanOf returns the span of node for the block hash, the miner's if node has none
+func (tr *blockTracer) spanOf(hash, node string) string {
+	if span, ok := tr.accepted[hash][node]; ok {
+		return span
+	}
+	return tr.mined[hash]
+}
+
+func (tr *blockTracer) handle(e Event) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	hash := e.Block.Hash
+	attrs := []otlpAttribute{stringAttr("node", e.Node), stringAttr("block.hash", hash)}
+	if e.Height > 0 {
+		attrs = append(attrs, intAttr("block.height", e.Height))
+	}
+	switch e.Kind {
+	case EventMined:
+		trace, span := tr.ids(hash, "mine")
+		start := e.Time
+		if e.Block.Timestamp > 0 {
+			start = time.Unix(0, e.Block.Timestamp)
+		}
+		tr.mined[hash] = span
+		attrs = append(attrs, intAttr("block.txs", len(e.Block.Transactions)), intAttr("block.difficulty", e.Block.Difficulty))
+		tr.spans = append(tr.spans, otlpSpan{TraceID: trace, SpanID: span, Name: "mine", Kind: 1, Start: unixNano(start), End: unixNano(e.Time), Attributes: attrs})
+	case EventDelivered:
+		if tr.delivered[hash] == nil {
+			tr.delivered[hash] = make(map[string]handover)
+		}
+		for _, peer := range e.Peers {
+			tr.delivered[hash][peer] = handover{at: e.Time, parent: tr.spanOf(hash, e.Node)}
+		}
+	case EventAccepted:
+		trace, span := tr.ids(hash, "propagate", e.Node)
+		h, ok := tr.delivered[hash][e.Node]
+		if !ok { // e.g. fetched as a missing parent
+			h = handover{at: e.Time, parent: tr.mined[hash]}
+		}
+		if tr.accepted[hash] == nil {
+			tr.accepted[hash] = make(map[string]string)
+		}
+		tr.accepted[hash][e.Node] = span
+		tr.spans = append(tr.spans, otlpSpan{TraceID: trace, SpanID: span, ParentSpanID: h.parent, Name: "propagate", Kind: 1,
+			Start: unixNano(h.at), End: unixNano(e.Time), Attributes: attrs})
+	case EventDropped, EventRejected:
+		tr.drops++
+		name, message := "drop", "delivery to "+e.Peer+" dropped"
+		if e.Kind == EventRejected {
+			name, message = "reject", "block discarded"
+		}
+		trace, span := tr.ids(hash, name, e.Node, e.Peer, strconv.Itoa(tr.drops))
+		if e.Peer != "" {
+			attrs = append(attrs, stringAttr("peer", e.Peer))
+		}
+		tr.spans = append(tr.spans, otlpSpan{TraceID: trace, SpanID: span, ParentSpanID: tr.spanOf(hash, e.Node), Name: name, Kind: 1,
+			Start: unixNano(e.Time), End: unixNano(e.Time), Attributes: attrs, Status: &otlpStatus{Code: 2, Message: message}})
+	}
+}
+
+// export sends the spans to the OTLP/HTTP collector at endpoint, returning how many it sent
+func (tr *blockTracer) export(endpoint string) (int, error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	url := strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	client := &http.Client{Timeout: 30 * time.Second}
+	for start := 0; start < len(tr.spans); start += otlpBatch {
+		batch := tr.spans[start:min(start+otlpBatch, len(tr.spans))]
+		body, err := json.Marshal(map[string]any{"resourceSpans": []any{map[string]any{
+			"resource":   map[string]any{"attributes": tr.resource},
+			"scopeSpans": []any{map[string]any{"scope": map[string]string{"name": "tester"}, "spans": batch}},
+		}}})
+		if err != nil {
+			return start, err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return start, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return start, fmt.Errorf("%s answered %s", url, resp.Status)
+		}
+	}
+	return len(tr.spans), nil
+}
@@ -0,0 +1,353 @@
+package sim
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// --- Parquet Output ---
+
+/*
+	-parquet <file> also writes the results as an Apache Parquet file when the run ends, for DuckDB,
+	Spark, pandas or polars to query column by column instead of parsing CSV text. Like -report, it is
+	made from the whole results file, so rows added by -append and -resume are in it too. Every column of
+	the CSV becomes a column of the same name, typed by its values: INT64 if every value is an integer,
+	DOUBLE if every value is a number (NaN and ±Inf included), a UTF-8 string otherwise. Blank cells
+	(the metrics of other modes) are nulls.
+
+	The format is written here with the standard library: the file metadata and page headers in
+	Thrift's compact protocol, one uncompressed PLAIN data page per column of a row group, the definition
+	levels bit-packed. That is the subset every reader supports. The results file is read twice, once
+	for the types of the columns and once for the rows, which are written a row group of at most
+	parquetGroupRows rows at a time, so a sweep of millions of rows takes the memory of one group.
+	Dictionary encoding and compression are left out.
+*/
+
+// parquetGroupRows is the most rows of a row group
+const parquetGroupRows = 10000
+
+// Parquet physical types, repetition, converted types, encodings and page types of parquet.thrift
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+	parquetOptional  = 1
+	parquetUTF8      = 0
+	parquetPlain     = 0
+	parquetRLE       = 3
+	parquetDataPage  = 0
+)
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thrift writes a Thrift struct in the compact protocol, keeping the last field ID of each open struct
+type thrift struct {
+	buf  []byte
+	last []int
+}
+
+func (t *thrift) field(id int, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta<<4)|typ)
+	} else {
+		t.buf = binary.AppendVarint(append(t.buf, typ), int64(id))
+	}
+	*last = id
+}
+
+func (t *thrift) i32(id int, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thrift) i64(id int, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thrift) binary(s string) {
+	t.buf = append(binary.AppendUvarint(t.buf, uint64(len(s))), s...)
+}
+
+func (t *thrift) string(id int, s string) {
+	t.field(id, thriftBinary)
+	t.binary(s)
+}
+
+// list starts a list field of n elements of type elem
+func (t *thrift) list(id int, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n<<4)|elem)
+	} else {
+		t.buf = binary.AppendUvarint(append(t.buf, 0xf0|elem), uint64(n))
+	}
+}
+
+// structure writes a struct, fields writing its fields; id 0 writes a list element
+func (t *thrift) structure(id int, fields func()) {
+	if id > 0 {
+		t.field(id, thriftStruct)
+	}
+	t.last = append(t.last, 0)
+	fields()
+	t.buf = append(t.buf, 0) // stop
+	t.last = t.last[:len(t.last)-1]
+}
+
+// parquetWiden is the physical type of a column of type typ that also holds v
+func parquetWiden(typ int32, v string) int32 {
+	if v == "" || typ == parquetByteArray {
+		return typ
+	}
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return typ
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return parquetByteArray
+	}
+	return parquetDouble
+}
+
+// parquetPage is the data page of column k of rows as typ: the definition levels, then the values
+func parquetPage(rows [][]string, k int, typ int32) []byte {
+	levels := binary.AppendUvarint(nil, uint64((len(rows)+7)/8)<<1|1) // one bit-packed run, bit width 1
+	levels = append(levels, make([]byte, (len(rows)+7)/8)...)
+	values := []byte{}
+	for r, row := range rows {
+		v := row[k]
+		if v == "" {
+			continue // null, level 0
+		}
+		levels[len(levels)-(len(rows)+7)/8+r/8] |= 1 << (r % 8)
+		switch typ {
+		case parquetInt64:
+			n, _ := strconv.ParseInt(v, 10, 64)
+			values = binary.LittleEndian.AppendUint64(values, uint64(n))
+		case parquetDouble:
+			f, _ := strconv.ParseFloat(v, 64)
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(f))
+		default:
+			values = append(binary.LittleEndian.AppendUint32(values, uint32(len(v))), v...)
+		}
+	}
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	return append(append(page, levels...), values...)
+}
+
+// parquetChunk is where a column chunk of a row group is in the file
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// parquetGroup is a row group written to the file
+type parquetGroup struct {
+	rows   int
+	chunks []parquetChunk
+}
+
+// parquetWriter writes the Parquet file of a table whose column types are known a row group at a time
+type parquetWriter struct {
+	w      *bufio.Writer
+	at     int64 // bytes written so far
+	header []string
+	types  []int32
+	groups []parquetGroup
+	err    error // the first error writing
+}
+
+func newParquetWriter(w io.Writer, header []string, types []int32) *parquetWriter {
+	p := &parquetWriter{w: bufio.NewWriter(w), header: header, types: types}
+	p.write([]byte("PAR1"))
+	return p
+}
+
+func (p *parquetWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.at += int64(n)
+	p.err = err
+}
+
+// writeGroup writes rows as a row group
+func (p *parquetWriter) writeGroup(rows [][]string) {
+	if len(rows) == 0 {
+		return
+	}
+	group := parquetGroup{rows: len(rows)}
+	for k := range p.header {
+		data := parquetPage(rows, k, p.types[k])
+		page := thrift{last: []int{0}}
+		page.i32(1, parquetDataPage)
+		page.i32(2, int32(len(data)))
+		page.i32(3, int32(len(data)))
+		page.structure(5, func() {
+			page.i32(1, int32(len(rows)))
+			page.i32(2, parquetPlain)
+			page.i32(3, parquetRLE)
+			page.i32(4, parquetRLE)
+		})
+		page.buf = append(page.buf, 0)
+		group.chunks = append(group.chunks, parquetChunk{offset: p.at, size: int64(len(page.buf) + len(data))})
+		p.write(page.buf)
+		p.write(data)
+	}
+	p.groups = append(p.groups, group)
+}
+
+// close writes the file metadata after the row groups and flushes the file
+func (p *parquetWriter) close() error {
+	rows := 0
+	for _, g := range p.groups {
+		rows += g.rows
+	}
+	meta := thrift{last: []int{0}}
+	meta.i32(1, 1) // version
+	meta.list(2, thriftStruct, len(p.header)+1)
+	meta.structure(0, func() {
+		meta.string(4, "schema")
+		meta.i32(5, int32(len(p.header)))
+	})
+	for k, name := range p.header {
+		meta.structure(0, func() {
+			meta.i32(1, p.types[k])
+			meta.i32(3, parquetOptional)
+			meta.string(4, name)
+			if p.types[k] == parquetByteArray {
+				meta.i32(6, parquetUTF8)
+			}
+		})
+	}
+	meta.i64(3, int64(rows))
+	meta.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		meta.structure(0, func() {
+			meta.list(1, thriftStruct, len(p.header))
+			total := int64(0)
+			for k, name := range p.header {
+				c := g.chunks[k]
+				total += c.size
+				meta.structure(0, func() {
+					meta.i64(2, c.offset)
+					meta.structure(3, func() {
+						meta.i32(1, p.types[k])
+						meta.list(2, thriftI32, 2)
+						meta.buf = binary.AppendVarint(binary.AppendVarint(meta.buf, parquetPlain), parquetRLE)
+						meta.list(3, thriftBinary, 1)
+						meta.binary(name)
+						meta.i32(4, 0) // uncompressed
+						meta.i64(5, int64(g.rows))
+						meta.i64(6, c.size)
+						meta.i64(7, c.size)
+						meta.i64(9, c.offset)
+					})
+				})
+			}
+			meta.i64(2, total)
+			meta.i64(3, int64(g.rows))
+		})
+	}
+	meta.string(6, "blockchain-simulator")
+	meta.buf = append(meta.buf, 0)
+
+	p.write(meta.buf)
+	p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta.buf))))
+	p.write([]byte("PAR1"))
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+// parquetSchema reads the header of the results CSV in and the type of each column from its rows
+func parquetSchema(in io.Reader) ([]string, []int32, error) {
+	r := csv.NewReader(in)
+	header, err := r.Read()
+	if err == io.EOF {
+		header = resultHeaders()
+	} else if err != nil {
+		return nil, nil, err
+	}
+	types := make([]int32, len(header))
+	for k := range types {
+		types[k] = parquetInt64
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return header, types, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range row {
+			types[k] = parquetWiden(types[k], v)
+		}
+	}
+}
+
+// writeParquet writes the results CSV at resultsPath to path as Parquet, in row groups of at most
+// groupRows rows
+func writeParquet(path, resultsPath string, groupRows int) error {
+	in, err := os.Open(resultsPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	header, types, err := parquetSchema(in)
+	if err != nil {
+		return err
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := csv.NewReader(in)
+	r.Read() // the header, read above
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	p := newParquetWriter(out, header, types)
+	rows := make([][]string, 0, groupRows)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+		if len(rows) == groupRows {
+			p.writeGroup(rows)
+			rows = rows[:0]
+		}
+	}
+	p.writeGroup(rows)
+	if err := p.close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,186 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// thriftReader decodes the Thrift compact protocol that thrift writes: structs become a map from field
+// ID to value, lists []any, integers int64 and binaries string
+type thriftReader struct {
+	data []byte
+	pos  int
+	t    *testing.T
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("bad uvarint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		head := r.data[r.pos]
+		r.pos++
+		n, elem := int(head>>4), head&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := []any{}
+		for range n {
+			list = append(list, r.value(elem))
+		}
+		return list
+	case thriftStruct:
+		fields := map[int]any{}
+		id := 0
+		for {
+			head := r.data[r.pos]
+			r.pos++
+			if head == 0 {
+				return fields
+			}
+			if delta := int(head >> 4); delta > 0 {
+				id += delta
+			} else {
+				id = int(r.varint())
+			}
+			fields[id] = r.value(head & 0x0f)
+		}
+	}
+	r.t.Fatalf("unknown thrift type %d at %d", typ, r.pos)
+	return nil
+}
+
+// TestParquetRoundTrip writes a small results file in row groups of two rows and reads the schema, the
+// row groups and every value back
+func TestParquetRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	results, out := filepath.Join(dir, "results.csv"), filepath.Join(dir, "results.parquet")
+	header := []string{"Simulation Type", "Total Nodes", "Latency", "Winner"}
+	rows := [][]string{
+		{"PoW", "10", "0.25", "honest"},
+		{"DAG", "15", "", ""},
+		{"PoW", "20", "NaN", "corrupt"},
+		{"BFT", "25", "1e3", ""},
+		{"Raft", "", "2", "日本"},
+	}
+	var csvData bytes.Buffer
+	w := csv.NewWriter(&csvData)
+	w.WriteAll(append([][]string{header}, rows...))
+	if err := os.WriteFile(results, csvData.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeParquet(out, results, 2); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing the PAR1 magic")
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-size : len(data)-8], t: t}
+	meta := footer.value(thriftStruct).(map[int]any)
+	if footer.pos != size {
+		t.Fatalf("footer is %d bytes, its metadata %d", size, footer.pos)
+	}
+
+	wantTypes := []int64{parquetByteArray, parquetInt64, parquetDouble, parquetByteArray}
+	schema := meta[2].([]any)
+	if root := schema[0].(map[int]any); root[4] != "schema" || root[5] != int64(len(header)) {
+		t.Errorf("schema root = %v, want %d children", root, len(header))
+	}
+	for k, name := range header {
+		col := schema[k+1].(map[int]any)
+		if col[4] != name || col[1] != wantTypes[k] || col[3] != int64(parquetOptional) {
+			t.Errorf("column %d = %v, want %q of type %d", k, col, name, wantTypes[k])
+		}
+	}
+	if meta[3] != int64(len(rows)) {
+		t.Errorf("num_rows = %v, want %d", meta[3], len(rows))
+	}
+
+	got := [][]string{}
+	groups := meta[4].([]any)
+	if len(groups) != 3 {
+		t.Fatalf("%d row groups, want 3 of at most 2 rows", len(groups))
+	}
+	for _, g := range groups {
+		group := g.(map[int]any)
+		n := int(group[3].(int64))
+		values := make([][]string, n)
+		for k, c := range group[1].([]any) {
+			chunk := c.(map[int]any)[3].(map[int]any)
+			page := &thriftReader{data: data, pos: int(chunk[9].(int64)), t: t}
+			head := page.value(thriftStruct).(map[int]any)
+			if head[5].(map[int]any)[1] != int64(n) {
+				t.Fatalf("page of %v values in a group of %d rows", head[5].(map[int]any)[1], n)
+			}
+			body := data[page.pos : page.pos+int(head[3].(int64))]
+			levels := body[4 : 4+binary.LittleEndian.Uint32(body)]
+			_, skip := binary.Uvarint(levels)
+			bits, vals := levels[skip:], body[4+len(levels):]
+			for r := range n {
+				if bits[r/8]&(1<<(r%8)) == 0 {
+					values[r] = append(values[r], "")
+					continue
+				}
+				switch wantTypes[k] {
+				case parquetInt64:
+					values[r] = append(values[r], strconv.FormatInt(int64(binary.LittleEndian.Uint64(vals)), 10))
+					vals = vals[8:]
+				case parquetDouble:
+					f := math.Float64frombits(binary.LittleEndian.Uint64(vals))
+					values[r] = append(values[r], strconv.FormatFloat(f, 'g', -1, 64))
+					vals = vals[8:]
+				default:
+					l := binary.LittleEndian.Uint32(vals)
+					values[r] = append(values[r], string(vals[4:4+l]))
+					vals = vals[4+l:]
+				}
+			}
+		}
+		got = append(got, values...)
+	}
+	for r, row := range rows {
+		want := slices.Clone(row)
+		if f, err := strconv.ParseFloat(want[2], 64); err == nil {
+			want[2] = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		if !slices.Equal(got[r], want) {
+			t.Errorf("row %d = %q, want %q", r, got[r], want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package sim
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Peer Scoring ---
+
+/*
+	With peer scoring or forged blocks configured, every PoW node checks the hash of the blocks it
+	receives: it has to be the block's own and meet its difficulty (only the former with
+	SimConfig.FastMining, whose hashes are no proof of work). Otherwise every block was mined by a node
+	of the simulation and rehashing it in every receiver is pure overhead. An honest node also checks
+	the timestamp against SimConfig.MaxTimeDrift (see timewarp.go). Invalid blocks are rejected.
+
+	With SimConfig.BanScore every honest node also scores its peers, like Bitcoin's misbehavior score:
+	each invalid block adds banPenalty to the score of the peer that sent it, and a peer whose score
+	reaches BanScore is banned. The node drops whatever a banned peer sends and no longer broadcasts to
+	it. Nodes only send the blocks they mine, except the withholding pool operator's releases, so the
+	peer of a block is its miner. Blocks rejected for a ledger or script conflict (RejectLedger,
+	RejectScript) do not count, honest miners on another branch send them too.
+
+	With SimConfig.InvalidBlocks = r the corrupt nodes give the honest nodes something to ban: every
+	time a corrupt node mines a block it also sends the honest nodes a forged one with probability r,
+	a block with a hash that looks like a proof of work but is not the block's. Forgeries cost nothing,
+	so without banning an attacker can make every honest node check as many as it likes.
+*/
+
+// banPenalty is the score an invalid block adds to its peer
+const banPenalty = 10
+
+// BanStats reports how the honest nodes scored their peers
+type BanStats struct {
+	Threshold     int
+	Forged        int           // forged blocks the corrupt nodes sent
+	Invalid       int           // invalid blocks the honest nodes rejected, for their hash or timestamp
+	Bans          int           // peers banned by honest nodes
+	HonestBans    int           // honest peers banned by honest nodes
+	Isolated      float64       // % of the pairs of honest node and corrupt peer that ended banned
+	IsolationTime time.Duration // from the first forged block until the last ban of a corrupt peer
+}
+
+// checkHashes tells whether the PoW nodes rehash the blocks they receive, see validBlock
+func checkHashes(cfg SimConfig) bool {
+	return cfg.BanScore > 0 || cfg.InvalidBlocks > 0
+}
+
+// validBlock checks that b's hash is its own and meets its difficulty
+func validBlock(b Block, cfg SimConfig) bool {
+	if calculateHash(cfg.hashing(), b) != b.Hash {
+		return false
+	}
+	return cfg.FastMining || strings.HasPrefix(b.Hash, strings.Repeat("0", b.Difficulty))
+}
+
+// forgeBlock turns b into a block whose hash claims a proof of work it does not have
+func forgeBlock(h hashFunc, b Block) Block {
+	b.Nonce = -1 - b.Nonce
+	b.Hash = strings.Repeat("0", b.Difficulty) + calculateHash(h, b)[b.Difficulty:]
+	return b
+}
+
+// peerScores is an honest node's score of its peers, nil without scoring
+type peerScores struct {
+	threshold int
+	score     map[string]int
+	banned    map[string]bool
+}
+
+func newPeerScores(cfg SimConfig) *peerScores {
+	if cfg.BanScore <= 0 {
+		return nil
+	}
+	return &peerScores{threshold: cfg.BanScore, score: make(map[string]int), banned: make(map[string]bool)}
+}
+
+// misbehaved adds banPenalty to peer's score, returning true if that got it banned
+func (s *peerScores) misbehaved(peer string) bool {
+	if s == nil || s.banned[peer] {
+		return false
+	}
+	s.score[peer] += banPenalty
+	s.banned[peer] = s.score[peer] >= s.threshold
+	return s.banned[peer]
+}
+
+// isBanned tells whether the node ignores peer
+func (s *peerScores) isBanned(peer string) bool {
+	return s != nil && s.banned[peer]
+}
+
+// banTracker counts the forgeries, rejections and bans
+type banTracker struct {
+	mu         sync.Mutex
+	stats      BanStats
+	firstForge time.Time
+	lastBan    time.Time
+	pairs      int // honest node, corrupt peer pairs banned
+}
+
+func trackBans(bus *EventBus, cfg SimConfig, C int) *banTracker {
+	t := &banTracker{stats: BanStats{Threshold: max(0, cfg.BanScore)}}
+	corrupt := func(name string) bool { return getLabel(nodeIndex(name, C), C) == "corrupt" }
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch e.Kind {
+		case EventForged:
+			t.stats.Forged++
+			if t.firstForge.IsZero() {
+				t.firstForge = e.Time
+			}
+		case EventRejected:
+			if e.Reason.misbehavior() && !corrupt(e.Node) { // not an orphan or a ledger conflict
+				t.stats.Invalid++
+			}
+		case EventBanned:
+			t.stats.Bans++
+			if !corrupt(e.Node) && corrupt(e.Peer) {
+				t.pairs++
+				t.lastBan = e.Time
+			} else if !corrupt(e.Peer) {
+				t.stats.HonestBans++
+			}
+		}
+	}, EventForged, EventRejected, EventBanned)
+	return t
+}
+
+func (t *banTracker) result(N, C int) BanStats {
+	stats := t.stats
+	if pairs := (N - C) * C; pairs > 0 {
+		stats.Isolated = getPercentage(t.pairs, pairs)
+	}
+	if !t.firstForge.IsZero() && !t.lastBan.IsZero() {
+		stats.IsolationTime = t.lastBan.Sub(t.firstForge)
+	}
+	return stats
+}
+
+func printBanStats(stats BanStats) {
+	fmt.Println("Ban score          =", stats.Threshold)
+	fmt.Println("Forged blocks      =", stats.Forged)
+	fmt.Println("Invalid rejected   =", stats.Invalid)
+	fmt.Println("Bans               =", stats.Bans)
+	fmt.Println("Honest bans        =", stats.HonestBans)
+	fmt.Println("Isolated %         =", stats.Isolated)
+	fmt.Println("Isolation time     =", stats.IsolationTime)
+}
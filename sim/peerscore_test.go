@@ -0,0 +1,21 @@
+package sim
+
+import "testing"
+
+// TestRejectReasons publishes a rejection of every reason. Ledger and script conflicts used to count as
+// invalid blocks and timestamp rejections, and got honest miners banned.
+func TestRejectReasons(t *testing.T) {
+	bus := NewEventBus()
+	bans := trackBans(bus, SimConfig{BanScore: 20}, 1) // corrupt1, then honest2
+	warps := trackTimeWarp(bus, 1, 0)
+	for _, reason := range []RejectReason{RejectOther, RejectInvalid, RejectTimestamp, RejectLedger, RejectScript} {
+		bus.Publish(Event{Kind: EventRejected, Node: "honest2", Peer: "corrupt1", Height: 1, Reason: reason})
+	}
+
+	if got := bans.stats.Invalid; got != 2 {
+		t.Errorf("Invalid = %d, want 2 (hash and timestamp)", got)
+	}
+	if got := warps.stats.Rejected; got != 1 {
+		t.Errorf("timestamp Rejected = %d, want 1", got)
+	}
+}
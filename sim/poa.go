@@ -0,0 +1,357 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Proof-of-Authority ---
+
+/*
+	SimulatePoACtx runs the nodes as the fixed signer set of a permissioned chain with the rules of
+	Clique (Ethereum's PoA engine). Block n is in turn for signer n mod N, which signs it one period
+	(SlotTime, default 5ms) after its parent arrived, with difficulty 2. Every other signer may sign
+	the block out of turn with difficulty 1, after the period plus a random wiggle of up to
+	(N/2+1) half periods, so an offline in-turn signer does not halt the chain. A signer may sign
+	only one of any N/2+1 consecutive blocks, and nodes follow the chain with the most total difficulty,
+	so in-turn blocks win forks.
+
+	Corrupt signers sign out of turn without the wiggle, racing the in-turn signer for every block
+	they are allowed to sign, and only include transactions from corrupt nodes. Their blocks lose to
+	the in-turn block of the same height, which shows up as forks and reorgs, and win whenever the
+	in-turn signer is a corrupt node or barred by the signer limit.
+
+	A signer that receives a block breaking the rules (wrong difficulty, or a signer that signed too
+	recently) rejects it. Once every sent transaction is in an in-turn block (or no new one was for 4N
+	periods) the nodes keep signing for N/2+1 more periods to settle forks, then stop.
+*/
+
+// PoAStats reports who signed the winning chain of a PoA simulation
+type PoAStats struct {
+	InTurn        int     // blocks of the winning chain signed in turn
+	OutOfTurn     int     // blocks of the winning chain signed out of turn
+	CorruptBlocks float64 // % of the winning chain's blocks signed by corrupt nodes
+	Invalid       int     // blocks rejected for breaking the signing rules
+}
+
+// poaDifficulty is the difficulty of a block at height signed by node i of N
+func poaDifficulty(height, i, N int) int {
+	if height%N == i {
+		return 2
+	}
+	return 1
+}
+
+// recentlySigned reports whether signer signed one of the last N/2 blocks up to tip
+func recentlySigned(HashMap BlockStore, tip, signer string, N int) bool {
+	for range N / 2 {
+		b, ok := HashMap.Get(tip)
+		if !ok {
+			return false
+		}
+		if b.Miner == signer {
+			return true
+		}
+		tip = b.PrevHash
+	}
+	return false
+}
+
+func SimulatePoACtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "poa")
+	defer closeBus()
+
+	start := time.Now()
+	nodeCtx, stopNodes := context.WithCancel(ctx)
+	defer stopNodes()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan []Block, N)
+	queues := make([]*mailbox[Block], N)
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)
+		receivers[i] = make(chan []Block)
+		queues[i] = newMailbox(receivers[i])
+	}
+	G := createGenesisBlock(cfg.hashing(), 0)
+	names := nodeNames(N, C)
+	period := slotTime(cfg)
+	stats := PoAStats{}
+	S := sybilCount(cfg)
+	spawn := newSybilSpawn()
+
+	var mu sync.Mutex // guards the winner and stats, the subscribers run in the nodes' goroutines
+	var winner = []Block{G}
+	var winnerType = ""
+	var winnerDifficulty = 0
+	bus.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		total := 0
+		for _, b := range e.Chain[1:] {
+			total += b.Difficulty
+		}
+		if total > winnerDifficulty {
+			winner, winnerDifficulty = e.Chain, total
+			winnerType = getLabel(nodeIndex(e.Node, C), C)
+		}
+	}, EventNodeDone)
+
+	for i := range N {
+		go func() {
+			defer wg.Done()
+			publish := func(e Event) {
+				e.Sim, e.Node = "PoA", names[i]
+				bus.Publish(e)
+			}
+			corrupt := getLabel(i, C) == "corrupt"
+			inbox, receiver := inboxes[i], receivers[i]
+			HashMap := memBlockStore{} // genesis is not stored, its children build on G.Hash
+			Counts := map[string]int{G.Hash: 0}
+			Total := map[string]int{G.Hash: 0} // total difficulty of the chain ending at a block
+			head := G.Hash
+			pending := []Transaction{}
+			timer := time.NewTimer(period)
+			defer timer.Stop()
+
+			// schedule (re)starts the timer for signing on top of head, if this signer may sign
+			schedule := func() {
+				timer.Stop()
+				if recentlySigned(HashMap, head, names[i], N) {
+					return
+				}
+				delay := period
+				if poaDifficulty(Counts[head]+1, i, N) == 1 && !corrupt {
+					delay += rand.N(time.Duration(N/2+1) * period / 2)
+				}
+				timer.Reset(delay)
+			}
+			add := func(b Block) {
+				HashMap.Put(b)
+				Counts[b.Hash] = Counts[b.PrevHash] + 1
+				Total[b.Hash] = Total[b.PrevHash] + b.Difficulty
+				if Total[b.Hash] <= Total[head] {
+					publish(Event{Kind: EventFork, Block: b, Height: Counts[b.Hash]})
+					return
+				}
+				if b.PrevHash != head {
+					if depth := reorgDepth(HashMap, Counts, head, b.Hash); depth > 0 {
+						publish(Event{Kind: EventReorg, Block: b, Height: Counts[b.Hash], Depth: depth})
+					}
+				}
+				head = b.Hash
+				schedule()
+			}
+			sign := func() {
+				included := make(map[TxID]bool)
+				for _, b := range buildBlockChain(HashMap, G, head) {
+					for _, tx := range b.Transactions {
+						included[tx.TxID] = true
+					}
+				}
+				txs := []Transaction{}
+				for _, tx := range pending {
+					if !included[tx.TxID] {
+						txs = append(txs, tx)
+					}
+				}
+				b := Block{Transactions: txs, PrevHash: head, Nonce: Counts[head] + 1, Timestamp: time.Now().UnixNano(), Difficulty: poaDifficulty(Counts[head]+1, i, N), Miner: names[i]}
+				b.Hash = calculateHash(cfg.hashing(), b)
+				publish(Event{Kind: EventMined, Block: b, Height: b.Nonce})
+				for j := range N {
+					if j != i {
+						queues[j].send(b)
+					}
+				}
+				add(b)
+			}
+
+			for {
+				select {
+				case <-nodeCtx.Done():
+					publish(Event{Kind: EventNodeDone, Chain: buildBlockChain(HashMap, G, head)})
+					return
+				case txs, ok := <-inbox:
+					if !ok {
+						inbox = nil
+						continue
+					}
+					pending = append(pending, txs...)
+				case blocks := <-receiver:
+					for _, b := range blocks {
+						_, known := Total[b.PrevHash]
+						signer := slices.Index(names, b.Miner) // -1 for a signer outside the set, e.g. a Sybil
+						if !known || signer < 0 || b.Difficulty != poaDifficulty(Counts[b.PrevHash]+1, signer, N) || recentlySigned(HashMap, b.PrevHash, b.Miner, N) {
+							publish(Event{Kind: EventRejected, Block: b})
+							continue
+						}
+						publish(Event{Kind: EventAccepted, Block: b, Height: Counts[b.PrevHash] + 1})
+						add(b)
+					}
+				case <-timer.C:
+					sign()
+				}
+			}
+		}()
+	}
+
+	wg.Add(S)
+	for _, name := range sybilNames(C, S) { // a Sybil signs its own chain, which no node accepts
+		go func() {
+			defer wg.Done()
+			select {
+			case <-spawn.joined:
+			case <-nodeCtx.Done():
+				return
+			}
+			bus.Publish(Event{Kind: EventJoined, Sim: "PoA", Node: name})
+			ticker := time.NewTicker(period)
+			defer ticker.Stop()
+			prev := G
+			for {
+				select {
+				case <-nodeCtx.Done():
+					return
+				case <-ticker.C:
+				}
+				b := Block{PrevHash: prev.Hash, Nonce: prev.Nonce + 1, Timestamp: time.Now().UnixNano(), Difficulty: 2, Miner: name}
+				b.Hash = calculateHash(cfg.hashing(), b)
+				for j := range N {
+					queues[j].send(b)
+				}
+				bus.Publish(Event{Kind: EventDelivered, Sim: "PoA", Node: name, Block: b, Peers: names})
+				prev = b
+			}
+		}()
+	}
+
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	forks := trackForks(bus)
+	reorgs := trackReorgs(bus)
+	sybils := trackSybils(bus, sybilNames(C, S))
+	if S > 0 {
+		bus.Subscribe(func(Event) { spawn.spawn() }, EventMined)
+	}
+	watch := newCommitWatch()
+	bus.Subscribe(func(e Event) {
+		if e.Kind == EventRejected && e.Node != "" {
+			mu.Lock()
+			stats.Invalid++
+			mu.Unlock()
+			return
+		}
+		if e.Block.Difficulty == 2 { // out-of-turn blocks are likely to lose a fork
+			watch.add(e.Block)
+		}
+	}, EventMined, EventRejected)
+	txSent := SendTransactions(ctx, N, C, R, inboxes, newWorkload(cfg, N, C, nil), bus, nil)
+	spawn.spawn()
+
+	watch.wait(ctx, txSent, time.Duration(4*N)*period)
+	select { // let forks settle
+	case <-time.After(time.Duration(N/2+1) * period):
+	case <-ctx.Done():
+	}
+	stopNodes()
+	wg.Wait()
+	for i := range N {
+		queues[i].close()
+	}
+
+	corruptBlocks := 0
+	for _, b := range winner[1:] {
+		if b.Difficulty == 2 {
+			stats.InTurn++
+		} else {
+			stats.OutOfTurn++
+		}
+		if getLabel(nodeIndex(b.Miner, C), C) == "corrupt" {
+			corruptBlocks++
+		}
+	}
+	if len(winner) > 1 {
+		stats.CorruptBlocks = getPercentage(corruptBlocks, len(winner)-1)
+	}
+	corruptPercentage := getPercentage(C, N)
+	txConfirmed := countConfirmedTransactions(winner)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			bus.Publish(Event{Kind: EventConfirmed, Sim: "PoA", Tx: tx}) // signers never include a transaction twice on one chain
+		}
+	}
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.chainLatencies(winner))
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	throughput := timing.chainThroughput(winner, txConfirmed, duration)
+	forkStats := forks.stats(winner)
+	reorgStats := reorgs.stats()
+	sybilStats := sybils.result(winner)
+
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Corrupt nodes      =", C)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Println("Winner             =", winnerType)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(nodes.stats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printForkStats(forkStats)
+		printReorgStats(reorgStats)
+		printPoAStats(stats)
+		if S > 0 {
+			printSybilStats(sybilStats)
+		}
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "PoA",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Forks:                 forkStats,
+		Reorgs:                reorgStats,
+		Nodes:                 nodes.stats,
+		PoA:                   stats,
+		Sybils:                sybilStats,
+		TimedOut:              timedOut,
+	}
+}
+
+func printPoAStats(stats PoAStats) {
+	fmt.Println("In-turn blocks     =", stats.InTurn)
+	fmt.Println("Out-of-turn blocks =", stats.OutOfTurn)
+	fmt.Println("Corrupt blocks %   =", stats.CorruptBlocks)
+	fmt.Println("Invalid blocks     =", stats.Invalid)
+}
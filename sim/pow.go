@@ -0,0 +1,1070 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Block struct {
+	Transactions []Transaction
+	PrevHash     string
+	Hash         string
+	Nonce        int
+	Timestamp    int64    // UnixNano when mining started
+	Difficulty   int      // leading zeros of Hash, see nextDifficulty
+	Miner        string   // node that mined the block, paid by the winning chain
+	Uncles       []string // orphaned blocks referenced for a partial reward, see uncles.go
+}
+
+type Transaction struct {
+	TxID     TxID
+	Sender   string
+	Receiver string
+	Amount   Amount        `json:",omitempty"` // with SimConfig.Amounts or a trace with amounts, see amounts.go
+	Fee      Amount        `json:",omitempty"` // with SimConfig.RBFRate, see rbf.go
+	Inputs   []Outpoint    `json:",omitempty"` // with SimConfig.UTXO, see utxo.go
+	Outputs  []TxOut       `json:",omitempty"`
+	Asset    string        `json:",omitempty"` // with SimConfig.Assets, see assets.go
+	Script   []string      `json:",omitempty"` // with SimConfig.Script, see script.go
+	Witness  []string      `json:",omitempty"` // the keys that signed the transaction
+	Batch    []Transaction `json:",omitempty"` // of a rollup commitment, see rollup.go
+	// --  parameters below this are only used in DAG --
+	Parents []string
+	Hash    string
+	Nonce   int
+}
+
+// --- Hashing and Mining ---
+// calculateHash is the hash (h, SHA-256 by default) of the canonical encoding of block, see canonical.go
+func calculateHash(h hashFunc, block Block) string {
+	buf := hashBuffers.Get().(*[]byte)
+	defer putHashBuffer(buf)
+	*buf = canonicalBlock((*buf)[:0], block)
+	return hashHex(h.Sum(*buf))
+}
+
+// cancelCheckInterval is how many nonces are tried between checks for cancellation
+const cancelCheckInterval = 1024
+
+// mineBlock searches for a valid nonce, returning false if ctx is cancelled first
+func mineBlock(ctx context.Context, h hashFunc, block Block, difficulty int) (Block, bool) {
+	buf := hashBuffers.Get().(*[]byte)
+	defer putHashBuffer(buf)
+	*buf = canonicalBlock((*buf)[:0], block)
+	nonce, sum, ok := mineNonce(ctx, h, *buf, block.Nonce, difficulty)
+	block.Nonce, block.Hash = nonce, hashHex(sum)
+	return block, ok
+}
+
+// readyChan is closed, so receiving from it never blocks
+var readyChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// MineTrigger drives the mining case of a node's select loop.
+// Ready is nil (never selected) while the node has nothing to mine, so idle nodes block on
+// their channels instead of spinning. Once there is work, the first Fire yields the processor
+// and sends the node back to its channels, so blocks and transactions that are about to arrive
+// are handled before the node commits to mining.
+type MineTrigger struct {
+	yielded bool
+}
+
+func (m *MineTrigger) Ready(hasWork bool) <-chan struct{} {
+	if hasWork {
+		return readyChan
+	}
+	return nil
+}
+
+// Fire reports whether the node should mine now
+func (m *MineTrigger) Fire() bool {
+	if !m.yielded {
+		m.yielded = true
+		runtime.Gosched()
+		return false
+	}
+	m.yielded = false
+	return true
+}
+
+func createGenesisBlock(h hashFunc, difficulty int) Block {
+	block := Block{
+		Transactions: []Transaction{},
+		PrevHash:     "",
+		Nonce:        0,
+		Difficulty:   difficulty,
+	}
+	block, _ = mineBlock(context.Background(), h, block, difficulty)
+	return block
+}
+
+// newBlock is the block to mine, stamped with timestamp
+func newBlock(miner, prev string, uncles []string, txs []Transaction, difficulty int, timestamp int64) Block {
+	return Block{
+		Transactions: txs,
+		PrevHash:     prev,
+		Timestamp:    timestamp,
+		Difficulty:   difficulty,
+		Miner:        miner,
+		Uncles:       uncles,
+	}
+}
+
+// generateBlock mines block, by grinding nonces or with cfg.FastMining by sampling when it is found
+func generateBlock(ctx context.Context, cfg SimConfig, block Block) (Block, bool) {
+	if cfg.FastMining {
+		return sampleBlock(ctx, cfg.hashing(), block, hashRate(cfg))
+	}
+	return mineBlock(ctx, cfg.hashing(), block, block.Difficulty)
+}
+
+// --- Print Functions ---
+
+func formatTransaction(tx Transaction) string {
+	return fmt.Sprintf("%s → %s | Tx: %s", tx.Sender, tx.Receiver, tx.TxID)
+}
+
+func formatBlockHeader(b Block) string {
+	return fmt.Sprintf("\n--- Block ---\nPrevHash: %s\nHash: %s", b.PrevHash, b.Hash)
+}
+
+func printBlockchain(Blockchain []Block) {
+	fmt.Println("length =", len(Blockchain))
+	for _, b := range Blockchain {
+		fmt.Println(formatBlockHeader(b))
+		for _, tx := range b.Transactions {
+			fmt.Println(formatTransaction(tx))
+		}
+	}
+}
+
+// --- Blockchain Simulation ---
+
+func getLabel(index, C int) string {
+	if index < C {
+		return "corrupt"
+	}
+	return "honest"
+}
+func getNum(index, C int) int {
+	if index < C {
+		return index + 1
+	}
+	return index + 1 - C
+}
+
+// nodeName is the name used for node index in transactions, e.g. "honest3"
+func nodeName(index, C int) string {
+	return fmt.Sprintf("%s%d", getLabel(index, C), getNum(index, C))
+}
+
+// nodeIndex is the inverse of nodeName
+func nodeIndex(name string, C int) int {
+	if num, ok := strings.CutPrefix(name, "corrupt"); ok {
+		i, _ := strconv.Atoi(num)
+		return i - 1
+	}
+	num := strings.TrimPrefix(name, "honest")
+	i, _ := strconv.Atoi(num)
+	return i - 1 + C
+}
+
+// SendTransactions delivers R rounds of transactions generated by w to the node inboxes, stopping early if ctx
+// is cancelled. A transaction goes to every node of its sender's side (see workload.go), and one of an
+// arrivalWorkload not before its arrival time, together with the others that arrived by then.
+// Each node receives a round as a single batch, so channel traffic is O(N) per round rather than O(N * txs).
+// Each transaction is announced on bus (if not nil) as an EventSent before it is delivered.
+// split (if not nil) may rewrite the batch of a single node, e.g. to hand it conflicting transactions.
+func SendTransactions(ctx context.Context, N, C, R int, inboxes []chan []Transaction, w Workload, bus *EventBus, split func(node int, txs []Transaction) []Transaction) (txSent int) {
+	defer func() {
+		// Close inbox after sending transactions
+		for i := range N {
+			close(inboxes[i])
+		}
+	}()
+
+	start := time.Now()
+	arrivals, _ := w.(arrivalWorkload)
+	for round := range R {
+		batch := w.Next(round)
+		for len(batch) > 0 {
+			txs := batch
+			if arrivals != nil { // wait for the first one, then take every one that arrived meanwhile
+				select {
+				case <-time.After(arrivals.arrival(batch[0]) - time.Since(start)):
+				case <-ctx.Done():
+					return txSent
+				}
+				n := 1
+				for n < len(batch) && arrivals.arrival(batch[n]) <= time.Since(start) {
+					n++
+				}
+				txs = batch[:n]
+			}
+			batch = batch[len(txs):]
+			txSent += len(txs)
+			if !deliverTransactions(ctx, N, C, round, inboxes, txs, bus, split) {
+				return txSent
+			}
+		}
+	}
+
+	return txSent
+}
+
+// deliverTransactions hands txs of the workload's round to the nodes of their sender's side like
+// SendTransactions, returning false if ctx was cancelled
+func deliverTransactions(ctx context.Context, N, C, round int, inboxes []chan []Transaction, txs []Transaction, bus *EventBus, split func(node int, txs []Transaction) []Transaction) bool {
+	honestTxs := []Transaction{}
+	corruptTxs := []Transaction{}
+	for _, tx := range txs {
+		if getLabel(nodeIndex(tx.Sender, C), C) == "honest" {
+			honestTxs = append(honestTxs, tx)
+		} else {
+			corruptTxs = append(corruptTxs, tx)
+		}
+	}
+
+	if bus != nil {
+		for _, tx := range append(honestTxs, corruptTxs...) {
+			bus.Publish(Event{Kind: EventSent, Tx: tx, Round: round})
+		}
+	}
+
+	// Send Transactions
+	for i := range N {
+		txs := honestTxs
+		if i < C {
+			txs = corruptTxs
+		}
+		if len(txs) == 0 {
+			continue
+		}
+		if split != nil {
+			txs = split(i, txs)
+		}
+		select {
+		case inboxes[i] <- txs: // nodes only read the batch, so it can be shared
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+func buildBlockChain(HashMap BlockStore, genesis Block, tail string) []Block {
+	temp := []Block{}
+	for {
+		next, ok := HashMap.Get(tail)
+		if !ok {
+			break
+		}
+		if next.Hash != "" {
+			temp = append(temp, next)
+		}
+		tail = next.PrevHash
+	}
+
+	Blockchain := []Block{}
+	Blockchain = append(Blockchain, genesis)
+	for i := len(temp) - 1; i >= 0; i-- {
+		Blockchain = append(Blockchain, temp[i])
+	}
+	return Blockchain
+}
+
+// reorgDepth counts the blocks of the chain ending at oldTip that are not on the chain ending at newTip
+func reorgDepth(HashMap BlockStore, Counts map[string]int, oldTip, newTip string) int {
+	parent := func(hash string) string {
+		b, _ := HashMap.Get(hash)
+		return b.PrevHash
+	}
+	depth := 0
+	for oldTip != newTip && (Counts[oldTip] > 0 || Counts[newTip] > 0) {
+		if Counts[oldTip] >= Counts[newTip] {
+			oldTip = parent(oldTip)
+			depth++
+		} else {
+			newTip = parent(newTip)
+		}
+	}
+	return depth
+}
+
+func countConfirmedTransactions(Blockchain []Block) int {
+	txs := make(map[TxID]struct{})
+	for _, b := range Blockchain {
+		for _, t := range b.Transactions {
+			txs[t.TxID] = struct{}{}
+		}
+	}
+	return len(txs)
+}
+
+func getPercentage(a, b int) float64 {
+	percent := 100.0 * float64(a) / float64(b)
+	rounded := math.Round(percent*100) / 100
+	return rounded
+}
+
+/*
+	N = total number of nodes
+	C = number of corrupt nodes
+	R = number of rounds of transactions
+	D = difficulty (NOTE: run time scales exponentially with difficulty)
+	p = transaction reach {0 <= p <= 1} (i.e. p = 0.5 means each transaction reaches ~50% of nodes)
+*/
+
+func SimulateBlockchain(N, C, R, D int, p float64, verbose bool) (int, int, float64, int, int, int, int, float64, string, time.Duration) {
+	res := SimulateBlockchainCtx(context.Background(), SimConfig{N: N, C: C, R: R, D: D, P: p, Verbose: verbose})
+	return res.N, res.C, res.CorruptPercentage, res.R, res.D, res.TxSent, res.TxConfirmed, res.TxConfirmedPercentage, res.Winner, res.Duration
+}
+
+// SimulateBlockchainCtx runs the PoW simulation until it finishes, ctx is cancelled or cfg.Timeout passes.
+// A cut short simulation still returns the metrics of the transactions sent so far.
+func SimulateBlockchainCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	S := sybilCount(cfg)
+	N, C = N+S, C+S // the Sybils are the corrupt nodes C-S .. C-1, asleep until they join (see sybil.go)
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "pow")
+	defer closeBus()
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+
+	var blockWG sync.WaitGroup
+	blockWG.Add(N)
+
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan Block, N)
+	buffer := receiverBuffer(cfg, N)
+	var G = createGenesisBlock(cfg.hashing(), D)
+	names := nodeNames(N, C)
+	inject := cfg.console.attach(ctx, N, C, G, bus) // commands typed at runtime, see repl.go
+	miners := newMinerPool(cfg)
+	rule := forkChoice(cfg)
+	joined := make([]atomic.Bool, N) // nodes that take part, broadcasts skip the others
+	spawn := newSybilSpawn()
+	churn := newChurn(cfg, N, C, joined)
+	links := newDelayedLinks(cfg) // blocks take the delay of their link, see bandwidth.go
+	syncs := newSyncNet(churn)
+	gossip := newTxGossip(cfg, N, C, S, links.matrix, bus) // transactions start at their sender, see gossip.go
+	bumps := newRBF(cfg, C-S)                              // senders replace transactions with higher fees, see rbf.go
+	ledger := newUTXOLedger(cfg, N-S, C-S)                 // transactions spend outputs, see utxo.go
+	scripts := newScriptEngine(cfg, N-S, C-S)              // miners evaluate the transactions' scripts, see script.go
+	channels := newChannelNet(cfg, N-S, C-S)               // pairs of nodes pay off-chain, see channels.go
+	sequencer := newRollup(cfg, N-S, C-S)                  // batches the users' transactions in the rollup mode, see rollup.go
+	bribed := make([]bool, N)                              // honest miners that took the bribe mine for the corrupt nodes (see bribery.go)
+	if !cfg.Hybrid {
+		bribed = bribeMiners(N, C, cfg.BribeRate)
+	}
+	side := func(j int) string {
+		if bribed[j] || inject.corrupt(j) {
+			return "corrupt"
+		}
+		return getLabel(j, C)
+	}
+
+	// The chain with the most work wins, which is the longest chain unless difficulty is retargeted
+	var winnerMu sync.Mutex // the nodes publish their final chains concurrently
+	var winner = []Block{}
+	var winnerWork = 0.0
+	var winnerType = ""
+	var finals []Event // every node's final chain, for the hybrid winner
+	var withholding WithholdStats
+	bus.Subscribe(func(e Event) {
+		winnerMu.Lock()
+		defer winnerMu.Unlock()
+		finals = append(finals, e)
+		if work := chainWork(e.Chain); work > winnerWork {
+			winner, winnerWork = e.Chain, work
+			winnerType = side(nodeIndex(e.Node, C))
+		}
+	}, EventNodeDone)
+
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)   // initialize each inbox
+		receivers[i] = make(chan Block, buffer) // initialize each receiver
+		if i >= C-S && i < C {
+			inboxes[i] = make(chan []Transaction, R) // a Sybil's transactions wait for it to join
+		} else {
+			joined[i].Store(!churn.joiner(i))
+		}
+		go func(inbox chan []Transaction, receiver chan Block, genesis Block) {
+			defer wg.Done()
+			/*
+				NOTE:
+				The current implementation allows for duplicate transactions in the final blockchain result
+				The check for duplicate transactions is omitted in order to speed up the simulation
+				However, the corrupt nodes have not been configured to take advantage of this
+			*/
+			publish := func(e Event) {
+				e.Sim, e.Node = "PoW", names[i]
+				bus.Publish(e)
+			}
+			HashMap, err := newBlockStore(cfg) // maps Hash to Block
+			if err != nil {
+				nodeLogger("pow", names[i]).Error("creating disk block store failed, keeping blocks in memory", "dir", cfg.StoreDir, "err", err)
+			}
+			defer func() {
+				if err := HashMap.Close(); err != nil {
+					nodeLogger("pow", names[i]).Error("disk block store failed", "dir", cfg.StoreDir, "err", err)
+				}
+			}()
+			Counts := make(map[string]int)   // maps Hash to BlockChain length
+			Work := make(map[string]float64) // maps Hash to total work of the chain ending there
+			MaxLength := 0                   // track current max length
+			MaxChain := ""                   // track the tail hash of the max length chain
+			transactions := []Transaction{}  // unprocessed transactions
+			orphans := orphanPool{}          // blocks waiting for their parent
+			var ghost *ghostTree             // block tree for the GHOST rule, nil for longest chain
+			var family uncleIndex            // children of every known block, nil without uncles
+			if cfg.Uncles {
+				family = uncleIndex{}
+			}
+			lastEpoch := 0
+			vote := func() { // cast a finality vote once the head reaches a new epoch
+				K := cfg.FinalityInterval
+				if K <= 0 || MaxLength/K <= lastEpoch {
+					return
+				}
+				lastEpoch = MaxLength / K
+				target := checkpointAt(HashMap, Counts, MaxChain, lastEpoch*K)
+				source := checkpointAt(HashMap, Counts, target.Hash, (lastEpoch-1)*K)
+				publish(Event{Kind: EventVote, Block: target, Source: source.Hash, Height: lastEpoch * K})
+				if getLabel(i, C) != "corrupt" {
+					return
+				}
+				for hash, height := range Counts { // equivocate: vote for every other checkpoint of the epoch known so far
+					if height == lastEpoch*K && hash != target.Hash {
+						other, _ := HashMap.Get(hash)
+						source := checkpointAt(HashMap, Counts, hash, (lastEpoch-1)*K)
+						publish(Event{Kind: EventVote, Block: other, Source: source.Hash, Height: lastEpoch * K})
+					}
+				}
+			}
+			equivocate := func(b Block) { // corrupt nodes also vote for checkpoints of epochs they already voted in
+				K := cfg.FinalityInterval
+				if K <= 0 || getLabel(i, C) != "corrupt" || Counts[b.Hash]%K != 0 || Counts[b.Hash]/K > lastEpoch {
+					return
+				}
+				source := checkpointAt(HashMap, Counts, b.Hash, Counts[b.Hash]-K)
+				publish(Event{Kind: EventVote, Block: b, Source: source.Hash, Height: Counts[b.Hash]})
+			}
+			attested := make(map[int]bool) // heights this node attested
+			attest := func(b Block) {
+				if !cfg.Hybrid {
+					return
+				}
+				honest := getLabel(i, C) == "honest"
+				if honest && cfg.SlashFraction > 0 && attested[Counts[b.Hash]] { // one block per height, or be slashed
+					return
+				}
+				// corrupt validators only attest corrupt blocks, unless they attest every fork
+				if honest || getLabel(nodeIndex(b.Miner, C), C) == "corrupt" || cfg.NothingAtStake {
+					attested[Counts[b.Hash]] = true
+					publish(Event{Kind: EventAttested, Block: b, Height: Counts[b.Hash]})
+				}
+			}
+			var scores *peerScores // honest nodes ban the peers that send invalid blocks, see peerscore.go
+			if getLabel(i, C) == "honest" {
+				scores = newPeerScores(cfg)
+			}
+			reject := func(b Block, height int, reason RejectReason) { // b is invalid, blame its peer if it misbehaved
+				publish(Event{Kind: EventRejected, Block: b, Height: height, Peer: b.Miner, Reason: reason})
+				if reason.misbehavior() && scores.misbehaved(b.Miner) {
+					publish(Event{Kind: EventBanned, Peer: b.Miner})
+				}
+			}
+			broadcast := func(b Block, to func(j int) bool) { // hand b to the receivers of the nodes to picks
+				delivered := []string{}
+				slowest := time.Duration(0)
+				for j := range N {
+					if i == j || !joined[j].Load() || !to(j) || scores.isBanned(names[j]) || inject.cut(i, j) {
+						continue
+					}
+					if d := links.delayed(i, j, b); d > 0 {
+						links.send(ctx, d, receivers[j], b)
+						delivered = append(delivered, names[j])
+						slowest = max(slowest, d)
+						continue
+					}
+					select {
+					case receivers[j] <- b: // successfully sent
+						delivered = append(delivered, names[j])
+					default: // channel full or busy -- unable to send block
+						publish(Event{Kind: EventDropped, Block: b, Peer: names[j]})
+					}
+				}
+				publish(Event{Kind: EventDelivered, Block: b, Peers: delivered})
+				links.record(b, slowest)
+			}
+			var withhold *withholder // corrupt0 releases the corrupt chain, see withhold.go
+			if i == 0 && C > S {
+				withhold = newWithholder(cfg)
+			}
+			if rule == ForkGHOST {
+				ghost = newGhostTree()
+			}
+			var exit = false
+			var trigger MineTrigger
+			mining := true
+			stopMining := func() { // keep accepting peers' blocks until the receivers are closed
+				if mining {
+					mining = false
+					blockWG.Done()
+				}
+			}
+			release := func(upTo int) {
+				if !mining { // the receivers close once every node stopped mining
+					return
+				}
+				for _, b := range withhold.unreleased(HashMap, Counts, MaxChain, upTo) {
+					broadcast(b, func(j int) bool { return side(j) == "honest" })
+				}
+			}
+
+			receive := func(b Block) { // add b to the view, and the orphans it releases
+				if scores.isBanned(b.Miner) {
+					return
+				}
+				if checkHashes(cfg) && !validBlock(b, cfg) {
+					reject(b, 0, RejectInvalid)
+					return
+				}
+				pending := []Block{b}
+				for adopted := false; len(pending) > 0; adopted = true { // b, then the orphans it released
+					b := pending[0]
+					pending = pending[1:]
+					_, exists := HashMap.Get(b.PrevHash)
+					if !exists && b.PrevHash != "" && b.PrevHash != genesis.Hash { // parent unknown, wait for it
+						orphans.park(b)
+						publish(Event{Kind: EventOrphaned, Block: b})
+						continue
+					}
+					if getLabel(i, C) == "honest" && !validTimestamp(HashMap, b, cfg) { // corrupt nodes take their own lies
+						reject(b, Counts[b.PrevHash]+1, RejectTimestamp)
+						continue
+					}
+					if !ledger.validBlock(HashMap, genesis, b) { // spends an output twice, or one its chain lacks
+						reject(b, Counts[b.PrevHash]+1, RejectLedger)
+						continue
+					}
+					if !scripts.validBlock(b, Counts[b.PrevHash]+1) {
+						reject(b, Counts[b.PrevHash]+1, RejectScript)
+						continue
+					}
+					HashMap.Put(b)
+					if family != nil {
+						family.add(b)
+					}
+					Counts[b.Hash] = Counts[b.PrevHash] + 1 // first blocks build on "" (or genesis), with count 0
+					Work[b.Hash] = Work[b.PrevHash] + blockWork(b.Difficulty)
+					publish(Event{Kind: EventAccepted, Block: b, Height: Counts[b.Hash]})
+					if adopted {
+						publish(Event{Kind: EventAdopted, Block: b, Height: Counts[b.Hash]})
+					}
+					equivocate(b)
+					attest(b)
+					if b.PrevHash != MaxChain { // competing branch
+						publish(Event{Kind: EventFork, Block: b, Height: Counts[b.Hash]})
+					}
+					head := MaxChain
+					switch {
+					case ghost != nil:
+						ghost.add(b, genesis.Hash)
+						head = ghost.head()
+					case rule == ForkHeaviest && Work[b.Hash] > Work[MaxChain]:
+						head = b.Hash
+					case rule == ForkLongest && Counts[b.Hash] > MaxLength: // update max if needed
+						head = b.Hash
+					}
+					if head != MaxChain {
+						if MaxChain != "" { // switching branches unless head extends MaxChain
+							if depth := reorgDepth(HashMap, Counts, MaxChain, head); depth > 0 {
+								hb, _ := HashMap.Get(head)
+								publish(Event{Kind: EventReorg, Block: hb, Height: Counts[head], Depth: depth})
+								if mining { // a node done mining leaves them to the others
+									transactions = append(ledger.abandoned(HashMap, Counts, MaxChain, head), transactions...)
+								}
+							}
+						}
+						MaxChain = head
+						MaxLength = Counts[head]
+						vote()
+					}
+					if withhold != nil && side(nodeIndex(b.Miner, C)) == "honest" {
+						priv := MaxLength // height of the private chain, 0 if corrupt0 follows the public one
+						if withhold.public[MaxChain] {
+							priv = 0
+						}
+						release(withhold.honestBlock(b.Hash, Counts[b.Hash], priv))
+					} else if withhold != nil && b.Hash == MaxChain {
+						release(withhold.privateBlock(MaxLength))
+					}
+					pending = append(pending, orphans.release(b.Hash)...)
+				}
+			}
+
+			if i >= C-S && i < C { // Sybil
+				select {
+				case <-spawn.joined:
+				case <-ctx.Done():
+				}
+				publish(Event{Kind: EventJoined})
+				joined[i].Store(true)
+			}
+			online := !churn.joiner(i) // joined as this node last saw it
+			ibd := syncs.download(i, cfg)
+			relaying := gossip != nil && (i < C-S || i >= C) // the Sybils are still handed their transactions
+			relayDone := gossip.done()                       // relayed transactions may come until it is closed
+			holding := inject.holding()                      // the console may inject transactions until it is closed
+			seen := map[TxID]Amount{}                        // transactions the node heard of -> their fee
+			finished := func() bool { return inbox == nil && relayDone == nil && holding == nil && len(transactions) == 0 }
+			if !relaying {
+				relayDone = nil
+			}
+			for !exit {
+				select { // if a transaction and block are both available one is selected by Go (perhaps arbitrarily)
+				case b, ok := <-receiver: // listen for blocks
+					if !ok { // every node finished mining
+						exit = true
+						continue
+					}
+					if !online { // offline, the block is lost
+						continue
+					}
+					receive(b)
+				case txs, ok := <-inbox: // read a round of transactions
+					if !ok { // no more transactions, stop once the pending ones are mined
+						inbox = nil
+						if finished() {
+							stopMining()
+						}
+					} else if relaying { // the node's own transactions, and the spam
+						transactions = bumps.accept(transactions, gossip.hear(i, txs, seen, online, joined))
+					} else if online { // an offline node misses them
+						transactions = bumps.accept(transactions, txs)
+					}
+				case txs := <-inject.txs(i): // typed into the console
+					if mining && online { // a node done mining would leave them pending
+						transactions = bumps.accept(transactions, txs)
+					}
+				case batches := <-gossip.relayed(i): // transactions relayed by peers
+					for _, txs := range batches {
+						transactions = bumps.accept(transactions, gossip.hear(i, txs, seen, online, joined))
+					}
+				case <-relayDone: // every transaction was relayed
+					relayDone = nil
+					if finished() {
+						stopMining()
+					}
+				case <-holding: // no more injected transactions
+					holding = nil
+					if finished() {
+						stopMining()
+					}
+				case <-churn.woke(i): // went offline or came back
+					if joined[i].Load() == online {
+						continue
+					}
+					online = !online
+					if online {
+						publish(Event{Kind: EventJoined})
+						ibd.begin(MaxChain)
+					} else {
+						publish(Event{Kind: EventLeft})
+						ibd.abort()
+					}
+				case req := <-syncs.asked(i): // a peer that came online wants headers or bodies
+					if online {
+						syncs.answer(req, HashMap, G, MaxChain)
+					}
+				case rep := <-ibd.replied():
+					bodies, done := ibd.receive(cfg.hashing(), rep, HashMap, G, MaxChain)
+					for _, b := range bodies {
+						if _, known := HashMap.Get(b.Hash); !known { // a peer may have broadcast it meanwhile
+							receive(b)
+						}
+					}
+					if done {
+						publish(Event{Kind: EventSynced, Height: MaxLength, Depth: ibd.got})
+					}
+				case <-ibd.expired(): // a request or reply got lost
+					ibd.request(MaxChain)
+				case <-ctx.Done(): // simulation cancelled
+					stopMining()
+					exit = true
+				case <-trigger.Ready(online && !ibd.syncing() && len(transactions) > 0 && len(receiver) == 0): // mine block (only when there is work and no block waiting)
+					if !trigger.Fire() {
+						continue
+					}
+					bumps.order(transactions, cfg.MaxBlockTxs)
+					transactions = ledger.spendable(HashMap, genesis, MaxChain, transactions)
+					var final int // transactions that are final, the rest wait for a later block
+					transactions, final = scripts.ready(transactions, MaxLength+1)
+					if len(transactions) == 0 { // none of them valid on MaxChain
+						if finished() {
+							stopMining()
+						}
+						continue
+					}
+					if !miners.acquire(ctx) { // cancelled while waiting to mine
+						continue
+					}
+					var uncles []string
+					if family != nil {
+						uncles = family.pick(HashMap, MaxChain)
+					}
+					included := transactions[:final] // empty if none is final yet, the chain still grows towards their height
+					if cfg.MaxBlockTxs > 0 {         // the rest wait for the next block
+						included = included[:min(len(included), cfg.MaxBlockTxs)]
+					}
+					stamp := time.Now().UnixNano()
+					if getLabel(i, C) == "corrupt" {
+						stamp = warpedTimestamp(HashMap, Counts, MaxChain, cfg)
+					}
+					nextBlock, ok := generateBlock(ctx, cfg, newBlock(names[i], MaxChain, uncles, included, nextDifficulty(HashMap, Counts, MaxChain, cfg), stamp))
+					miners.release()
+					if !ok { // cancelled while mining
+						continue
+					}
+					HashMap.Put(nextBlock)
+					if family != nil {
+						family.add(nextBlock)
+					}
+					if ghost != nil {
+						ghost.add(nextBlock, genesis.Hash)
+					}
+					Counts[nextBlock.Hash] = Counts[MaxChain] + 1
+					Work[nextBlock.Hash] = Work[MaxChain] + blockWork(nextBlock.Difficulty)
+					MaxChain = nextBlock.Hash
+					MaxLength = Counts[nextBlock.Hash]
+					transactions = transactions[len(included):] // flush the included transactions
+					publish(Event{Kind: EventMined, Block: nextBlock, Height: MaxLength})
+					vote()
+					attest(nextBlock)
+
+					broadcast(nextBlock, func(j int) bool { // corrupt nodes only broadcast to other corrupt nodes
+						return side(i) == "honest" || side(j) == "corrupt"
+					})
+					if getLabel(i, C) == "corrupt" && rand.Float64() < cfg.InvalidBlocks {
+						forged := forgeBlock(cfg.hashing(), nextBlock)
+						publish(Event{Kind: EventForged, Block: forged})
+						broadcast(forged, func(j int) bool { return side(j) == "honest" })
+					}
+					if withhold != nil {
+						release(withhold.privateBlock(MaxLength))
+					}
+					if finished() { // that was the last of the work
+						stopMining()
+					}
+				}
+			}
+
+			for _, parked := range orphans { // their parent never arrived
+				for _, b := range parked {
+					publish(Event{Kind: EventRejected, Block: b})
+				}
+			}
+			if withhold != nil {
+				withholding = withhold.stats
+			}
+			publish(Event{Kind: EventNodeDone, Chain: buildBlockChain(HashMap, G, MaxChain)})
+		}(inboxes[i], receivers[i], G)
+	}
+
+	// Send transactions
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	drops := trackDrops(bus)
+	forks := trackForks(bus)
+	reorgs := trackReorgs(bus)
+	orphanPools := trackOrphanPool(bus)
+	uncles := trackUncles(bus)
+	finality := trackFinality(bus, N)
+	confirmations := trackConfirmations(bus)
+	attests := trackAttestations(bus, slices.Insert(nodeStakes(N-S, C-S, cfg), C-S, make([]float64, S)...), C, cfg.SlashFraction) // Sybils hold no stake
+	sybils := trackSybils(bus, sybilNames(C-S, S))
+	spam := newSpammer(cfg)
+	warp := trackTimeWarp(bus, C, cfg.TimeWarp)
+	churned := trackChurn(bus, churn, C)
+	downloads := trackIBD(bus, C)
+	bans := trackBans(bus, cfg, C)
+	regions := trackRegions(bus, links.matrix, C)
+	if churn != nil {
+		bus.Subscribe(func(Event) { churn.step() }, EventMined)
+	}
+	if S > 0 {
+		bus.Subscribe(func(Event) { spawn.spawn() }, EventMined)
+	}
+	feedSybils := func(node int, txs []Transaction) []Transaction { // hand the corrupt transactions to the Sybils too
+		if node == 0 {
+			for _, inbox := range inboxes[C-S : C] {
+				inbox <- txs
+			}
+		}
+		return txs
+	}
+	conflict, doubleSpends := func(node int, txs []Transaction) []Transaction { return txs }, func() int { return 0 }
+	if ledger != nil && cfg.DoubleSpend > 0 { // twins that spend the same outputs
+		conflict, doubleSpends = doubleSpender(cfg.DoubleSpend, N-S, C-S)
+	}
+	split := func(node int, txs []Transaction) []Transaction {
+		return spam.flood(node, gossip.origin(node, C-S, feedSybils(node, conflict(node, bumps.bump(node, ledger.fund(node, scripts.attach(node, txs)))))))
+	}
+	workload := channels.wrap(newWorkload(cfg, N-S, C-S, nil))
+	var txSent int
+	if sequencer != nil {
+		txSent = sequencer.send(ctx, N-S, R, slices.Concat(inboxes[:C-S], inboxes[C:]), workload, bus, split)
+	} else {
+		txSent = SendTransactions(ctx, N-S, C-S, R, slices.Concat(inboxes[:C-S], inboxes[C:]), workload, bus, split)
+	}
+	inject.hold(ctx) // with -hold, see txapi.go
+	for _, inbox := range inboxes[C-S : C] {
+		close(inbox)
+	}
+	gossip.settle(ctx)
+	spawn.spawn()
+	if churn != nil {
+		churn.end()
+	}
+
+	// Wait until all nodes are finished processing blocks before closing receivers
+	blockWG.Wait()
+	links.wait()
+
+	// Close receivers after processing all blocks
+	for i := range N {
+		close(receivers[i])
+	}
+
+	// Wait until all nodes are finished processing blocks before ending the simulation
+	wg.Wait()
+	gossip.close()
+
+	simType := "PoW"
+	var hybridStats HybridStats
+	var longRange LongRangeStats
+	if cfg.Hybrid { // only attested blocks count
+		powWinner := winnerType
+		winner, winnerType, hybridStats = attests.result(finals, attestQuorum(cfg))
+		winnerWork = chainWork(winner)
+		for n := range nodes.stats {
+			nodes.stats[n].Stake = attests.share(nodeIndex(nodes.stats[n].Node, C))
+		}
+		if cfg.LongRange > 0 {
+			longRange = longRangeAttack(ctx, cfg, winner, attests, attestQuorum(cfg))
+		}
+		hybridStats.PoWWinner = powWinner
+		simType = "Hybrid"
+	}
+	if cfg.Rollup {
+		simType = "Rollup"
+	}
+
+	corruptPercentage := getPercentage(C-S, N-S)
+	spamStats := spam.result(winner)
+	rbfStats := bumps.result(winner)
+	utxoStats := ledger.result(winner, doubleSpends())
+	scriptStats := scripts.result()
+	channelStats := channels.result(winner)
+	settled := sequencer.settled(winner) // the commitments' batches instead of the commitments
+	txConfirmed := countConfirmedTransactions(settled) - spamStats.InWinner
+	rollupStats := sequencer.result(winner, txConfirmed)
+	confirmed := make(map[TxID]struct{}) // report duplicated transactions once
+	for _, b := range settled {
+		for _, tx := range b.Transactions {
+			if _, seen := confirmed[tx.TxID]; !seen && !isSpam(tx) {
+				confirmed[tx.TxID] = struct{}{}
+				bus.Publish(Event{Kind: EventConfirmed, Sim: "PoW", Tx: tx})
+			}
+		}
+	}
+	cfg.console.detach(inject) // once the console saw which of its transactions were confirmed
+	txSent += inject.injected()
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.chainLatencies(settled))
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	dropStats := drops.result()
+	throughput := timing.chainThroughput(settled, txConfirmed, duration)
+	forkStats := forks.stats(winner)
+	reorgStats := reorgs.stats()
+	orphanPoolStats := orphanPools.result()
+	uncleStats := uncles.result(winner, C)
+	sybilStats := sybils.result(winner)
+	timeWarpStats := warp.result()
+	churnStats := churned.stats
+	ibdStats := downloads.result()
+	banStats := bans.result(N, C)
+	regionStats := regions.result(winner)
+	propagation := links.result()
+	gossipStats := gossip.result(N, C)
+	fastHashRate := 0.0
+	if cfg.FastMining {
+		fastHashRate = hashRate(cfg)
+	}
+	var bribeStats BribeStats
+	if !cfg.Hybrid {
+		bribeStats = bribeResult(cfg, winner, winnerType, bribed, N, C)
+	}
+	finalityStats := finality.result(cfg.FinalityInterval)
+	confirmationStats := confirmations.result(winner)
+
+	// Print Result
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N-S)
+		fmt.Println("Corrupt nodes      =", C-S)
+		fmt.Println("Corrupt %          =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("Difficulty         =", D)
+		fmt.Println("Receiver buffer    =", buffer)
+		fmt.Println("Fork choice        =", rule)
+		fmt.Println("Chain length       =", len(winner)-1)
+		fmt.Println("Chain work         =", winnerWork)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Println("Winner             =", winnerType)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(nodes.stats)
+		printDropStats(dropStats)
+		printLatencyStats(latencyStats)
+		printValueStats(value.result())
+		printThroughput(throughput)
+		printForkStats(forkStats)
+		printReorgStats(reorgStats)
+		printOrphanPoolStats(orphanPoolStats)
+		printUncleStats(uncleStats)
+		if cfg.FinalityInterval > 0 {
+			printFinalityStats(finalityStats)
+		}
+		printConfirmationStats(confirmationStats)
+		if cfg.Hybrid {
+			printHybridStats(hybridStats)
+		}
+		if cfg.LongRange > 0 {
+			printLongRangeStats(longRange)
+		}
+		if S > 0 {
+			printSybilStats(sybilStats)
+		}
+		if bribeStats.Rate > 0 {
+			printBribeStats(bribeStats)
+		}
+		if spamStats.Rate > 0 {
+			printSpamStats(spamStats)
+		}
+		if withholding.Strategy != "" {
+			printWithholdStats(withholding)
+		}
+		if cfg.TimeWarp > 0 {
+			printTimeWarpStats(timeWarpStats)
+		}
+		if churn != nil {
+			printChurnStats(churnStats)
+			printIBDStats(ibdStats)
+		}
+		if checkHashes(cfg) {
+			printBanStats(banStats)
+		}
+		if links.matrix != nil {
+			printRegionStats(cfg.LatencyMatrix, regionStats)
+		}
+		if propagation.Blocks > 0 {
+			printPropagationStats(propagation)
+		}
+		if gossip != nil {
+			printGossipStats(gossipStats)
+		}
+		if bumps != nil {
+			printRBFStats(rbfStats)
+		}
+		if ledger != nil {
+			printUTXOStats(utxoStats)
+		}
+		if scripts != nil {
+			printScriptStats(scriptStats)
+		}
+		if channels != nil {
+			printChannelStats(channelStats)
+		}
+		if sequencer != nil {
+			printRollupStats(rollupStats)
+		}
+		if cfg.FastMining {
+			fmt.Println("Fast mining rate   =", hashRate(cfg))
+		}
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  simType,
+		N:                     N - S,
+		C:                     C - S,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		ReceiverBuffer:        buffer,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 nodes.stats,
+		Drops:                 dropStats,
+		Forks:                 forkStats,
+		Reorgs:                reorgStats,
+		OrphanPool:            orphanPoolStats,
+		ForkChoice:            rule,
+		ChainLength:           max(0, len(winner)-1),
+		ChainWork:             winnerWork,
+		Uncles:                uncleStats,
+		Finality:              finalityStats,
+		Confirmations:         confirmationStats,
+		Hybrid:                hybridStats,
+		LongRange:             longRange,
+		Sybils:                sybilStats,
+		Bribes:                bribeStats,
+		Spam:                  spamStats,
+		RBF:                   rbfStats,
+		UTXO:                  utxoStats,
+		Script:                scriptStats,
+		Channels:              channelStats,
+		Rollup:                rollupStats,
+		Withholding:           withholding,
+		TimeWarp:              timeWarpStats,
+		Churn:                 churnStats,
+		IBD:                   ibdStats,
+		Bans:                  banStats,
+		LatencyMatrix:         cfg.LatencyMatrix,
+		Regions:               regionStats,
+		Propagation:           propagation,
+		Gossip:                gossipStats,
+		HashRate:              fastHashRate,
+		TimedOut:              timedOut,
+	}
+}
@@ -0,0 +1,290 @@
+package sim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// --- Protobuf Encoding ---
+
+/*
+	Blocks and transactions are encoded as the protobuf messages of proto/chain.proto for the disk block
+	store; hashes take the canonical encoding instead (see canonical.go). The encoding is written here
+	with the standard library, the tree has no module to pull in a protobuf runtime, and is the proto3
+	wire format a generated encoder writes for the same message: fields in field number order, zero
+	values left out, amounts as their int64 count of minor units.
+
+	Strings are written as they are: proto3 requires valid UTF-8 in string fields and the official
+	runtimes reject anything else, but a node name or hash here may be any bytes and must come back
+	unchanged. Unknown fields and fields of an unexpected wire type are skipped when decoding.
+*/
+
+// protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+func protoKey(buf []byte, field, wire int) []byte {
+	return binary.AppendUvarint(buf, uint64(field)<<3|uint64(wire))
+}
+
+func protoInt(buf []byte, field int, v int64) []byte {
+	if v == 0 {
+		return buf
+	}
+	return binary.AppendUvarint(protoKey(buf, field, protoVarint), uint64(v))
+}
+
+func protoString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = binary.AppendUvarint(protoKey(buf, field, protoBytes), uint64(len(s)))
+	return append(buf, s...)
+}
+
+func protoStrings(buf []byte, field int, list []string) []byte {
+	for _, s := range list { // repeated fields keep empty elements
+		buf = binary.AppendUvarint(protoKey(buf, field, protoBytes), uint64(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+// protoMessage appends the embedded message encode appends, prefixed by its length
+func protoMessage(buf []byte, field int, encode func([]byte) []byte) []byte {
+	buf = protoKey(buf, field, protoBytes)
+	start := len(buf)
+	buf = encode(buf)
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(buf)-start))
+	return slices.Insert(buf, start, size[:n]...)
+}
+
+// appendBlock appends the encoding of b to buf
+func appendBlock(buf []byte, b Block) []byte {
+	for _, tx := range b.Transactions {
+		buf = protoMessage(buf, 1, func(buf []byte) []byte { return appendTransaction(buf, tx) })
+	}
+	buf = protoString(buf, 2, b.PrevHash)
+	buf = protoString(buf, 3, b.Hash)
+	buf = protoInt(buf, 4, int64(b.Nonce))
+	buf = protoInt(buf, 5, b.Timestamp)
+	buf = protoInt(buf, 6, int64(b.Difficulty))
+	buf = protoString(buf, 7, b.Miner)
+	return protoStrings(buf, 8, b.Uncles)
+}
+
+// appendTransaction appends the encoding of tx to buf
+func appendTransaction(buf []byte, tx Transaction) []byte {
+	buf = protoString(buf, 1, tx.Sender)
+	buf = protoString(buf, 2, tx.Receiver)
+	buf = protoInt(buf, 3, int64(tx.Amount))
+	for _, in := range tx.Inputs {
+		buf = protoMessage(buf, 6, func(buf []byte) []byte {
+			return protoInt(protoInt(buf, 1, int64(in.Tx)), 2, int64(in.Index))
+		})
+	}
+	for _, out := range tx.Outputs {
+		buf = protoMessage(buf, 7, func(buf []byte) []byte {
+			return protoInt(protoString(protoString(buf, 1, out.Owner), 3, out.Asset), 4, int64(out.Value))
+		})
+	}
+	buf = protoString(buf, 8, tx.Asset)
+	buf = protoStrings(buf, 9, tx.Script)
+	buf = protoStrings(buf, 10, tx.Witness)
+	for _, inner := range tx.Batch {
+		buf = protoMessage(buf, 11, func(buf []byte) []byte { return appendTransaction(buf, inner) })
+	}
+	buf = protoStrings(buf, 12, tx.Parents)
+	buf = protoString(buf, 13, tx.Hash)
+	buf = protoInt(buf, 14, int64(tx.Nonce))
+	buf = protoInt(buf, 15, int64(tx.TxID))
+	return protoInt(buf, 16, int64(tx.Fee))
+}
+
+// protoReader reads the fields of a message, keeping the first error
+type protoReader struct {
+	data []byte
+	err  error
+}
+
+func (r *protoReader) more() bool { return r.err == nil && len(r.data) > 0 }
+
+func (r *protoReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail(errProtoTruncated)
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// key reads the field number and wire type of the next field
+func (r *protoReader) key() (int, int) {
+	key := r.varint()
+	if r.err == nil && key>>3 == 0 {
+		r.fail(errors.New("protobuf: field number 0"))
+	}
+	return int(key >> 3), int(key & 7)
+}
+
+// fixed reads past n bytes of a fixed-width field
+func (r *protoReader) fixed(n int) {
+	if len(r.data) < n {
+		r.fail(errProtoTruncated)
+		return
+	}
+	r.data = r.data[n:]
+}
+
+func (r *protoReader) bytes() []byte {
+	n := r.varint()
+	if r.err != nil || n > uint64(len(r.data)) {
+		r.fail(errProtoTruncated)
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+// skip reads past a field of wire type wire
+func (r *protoReader) skip(wire int) {
+	switch wire {
+	case protoVarint:
+		r.varint()
+	case protoFixed64:
+		r.fixed(8)
+	case protoBytes:
+		r.bytes()
+	case 5: // fixed32
+		r.fixed(4)
+	default:
+		r.fail(fmt.Errorf("protobuf: unsupported wire type %d", wire))
+	}
+}
+
+func (r *protoReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.data = nil
+}
+
+// unmarshalBlock decodes a block appendBlock encoded
+func unmarshalBlock(data []byte) (Block, error) {
+	var b Block
+	r := protoReader{data: data}
+	for r.more() {
+		switch field, wire := r.key(); {
+		case field == 1 && wire == protoBytes:
+			tx, err := unmarshalTransaction(r.bytes())
+			if err != nil {
+				r.fail(err)
+			}
+			b.Transactions = append(b.Transactions, tx)
+		case field == 2 && wire == protoBytes:
+			b.PrevHash = string(r.bytes())
+		case field == 3 && wire == protoBytes:
+			b.Hash = string(r.bytes())
+		case field == 4 && wire == protoVarint:
+			b.Nonce = int(r.varint())
+		case field == 5 && wire == protoVarint:
+			b.Timestamp = int64(r.varint())
+		case field == 6 && wire == protoVarint:
+			b.Difficulty = int(r.varint())
+		case field == 7 && wire == protoBytes:
+			b.Miner = string(r.bytes())
+		case field == 8 && wire == protoBytes:
+			b.Uncles = append(b.Uncles, string(r.bytes()))
+		default:
+			r.skip(wire)
+		}
+	}
+	return b, r.err
+}
+
+// unmarshalTransaction decodes a transaction appendTransaction encoded
+func unmarshalTransaction(data []byte) (Transaction, error) {
+	var tx Transaction
+	r := protoReader{data: data}
+	for r.more() {
+		switch field, wire := r.key(); {
+		case field == 1 && wire == protoBytes:
+			tx.Sender = string(r.bytes())
+		case field == 2 && wire == protoBytes:
+			tx.Receiver = string(r.bytes())
+		case field == 3 && wire == protoVarint:
+			tx.Amount = Amount(r.varint())
+		case field == 6 && wire == protoBytes:
+			var in Outpoint
+			m := protoReader{data: r.bytes()}
+			for m.more() {
+				switch field, wire := m.key(); {
+				case field == 1 && wire == protoVarint:
+					in.Tx = TxID(m.varint())
+				case field == 2 && wire == protoVarint:
+					in.Index = int(m.varint())
+				default:
+					m.skip(wire)
+				}
+			}
+			if m.err != nil {
+				r.fail(m.err)
+			}
+			tx.Inputs = append(tx.Inputs, in)
+		case field == 7 && wire == protoBytes:
+			var out TxOut
+			m := protoReader{data: r.bytes()}
+			for m.more() {
+				switch field, wire := m.key(); {
+				case field == 1 && wire == protoBytes:
+					out.Owner = string(m.bytes())
+				case field == 4 && wire == protoVarint:
+					out.Value = Amount(m.varint())
+				case field == 3 && wire == protoBytes:
+					out.Asset = string(m.bytes())
+				default:
+					m.skip(wire)
+				}
+			}
+			if m.err != nil {
+				r.fail(m.err)
+			}
+			tx.Outputs = append(tx.Outputs, out)
+		case field == 8 && wire == protoBytes:
+			tx.Asset = string(r.bytes())
+		case field == 9 && wire == protoBytes:
+			tx.Script = append(tx.Script, string(r.bytes()))
+		case field == 10 && wire == protoBytes:
+			tx.Witness = append(tx.Witness, string(r.bytes()))
+		case field == 11 && wire == protoBytes:
+			inner, err := unmarshalTransaction(r.bytes())
+			if err != nil {
+				r.fail(err)
+			}
+			tx.Batch = append(tx.Batch, inner)
+		case field == 12 && wire == protoBytes:
+			tx.Parents = append(tx.Parents, string(r.bytes()))
+		case field == 13 && wire == protoBytes:
+			tx.Hash = string(r.bytes())
+		case field == 14 && wire == protoVarint:
+			tx.Nonce = int(r.varint())
+		case field == 15 && wire == protoVarint:
+			tx.TxID = TxID(r.varint())
+		case field == 16 && wire == protoVarint:
+			tx.Fee = Amount(r.varint())
+		default:
+			r.skip(wire)
+		}
+	}
+	return tx, r.err
+}
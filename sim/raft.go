@@ -0,0 +1,473 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Raft ---
+
+/*
+	SimulateRaftCtx runs the nodes as a permissioned, crash-fault-tolerant ledger: a Raft cluster
+	replicating a log of blocks. A follower that hears nothing from a leader for a randomized election
+	timeout (RaftTimeout to 2x RaftTimeout) starts an election for the next term, and the first
+	candidate with votes from a majority of all N nodes leads it. The leader batches the
+	transactions it receives (or that followers forward to it) into blocks, appends them to its log
+	and replicates them with AppendEntries every RaftTimeout/4. A block is committed once a majority
+	stores it and it was appended in the leader's current term.
+
+	There are no Byzantine nodes: corrupt nodes are faulty instead. Even corrupt nodes crash at a
+	random time within the first two election timeouts, often around the first election, and stay
+	down, dropping the transactions still sent to them. Odd corrupt nodes deliver every message they
+	send one RaftTimeout late. Once a majority of the nodes crashed nothing commits anymore.
+
+	Followers keep every transaction they received until it is committed and forward it again to each
+	new leader, so a leader crash does not lose transactions. The nodes stop once every sent transaction
+	is committed, or nothing new was committed for 50 election timeouts.
+*/
+
+type raftKind int
+
+const (
+	raftVote raftKind = iota
+	raftVoteReply
+	raftAppend
+	raftAppendReply
+	raftForward
+)
+
+type raftEntry struct {
+	Term  int
+	Block Block
+}
+
+// raftMsg is a Raft RPC or its reply, only the fields of its Kind are set
+type raftMsg struct {
+	Kind      raftKind
+	Term      int
+	From      int
+	LastIndex int // raftVote: candidate's log
+	LastTerm  int
+	Granted   bool // raftVoteReply
+	PrevIndex int  // raftAppend
+	PrevTerm  int
+	Entries   []raftEntry
+	Commit    int
+	Success   bool          // raftAppendReply
+	Match     int           // raftAppendReply: last index the follower stores (on failure a hint)
+	Txs       []Transaction // raftForward
+}
+
+// RaftStats reports the elections and faults of a Raft simulation
+type RaftStats struct {
+	Terms        int // highest term a leader was elected in
+	Elections    int // leaders elected
+	Crashed      int // nodes that crashed
+	Delayed      int // nodes whose messages were delayed
+	LogConflicts int // committed blocks that differ from the longest committed log (should stay 0)
+}
+
+// raftTimeout resolves cfg.RaftTimeout against the default
+func raftTimeout(cfg SimConfig) time.Duration {
+	if cfg.RaftTimeout <= 0 {
+		return 20 * time.Millisecond
+	}
+	return cfg.RaftTimeout
+}
+
+func SimulateRaftCtx(ctx context.Context, cfg SimConfig) SimResult {
+	N, C, R, D, p, verbose := cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Verbose
+	ctx, cancel := withDeadline(ctx, cfg)
+	defer cancel()
+	bus, closeBus := newSimBus(cfg, "raft")
+	defer closeBus()
+
+	start := time.Now()
+	nodeCtx, stopNodes := context.WithCancel(ctx)
+	defer stopNodes()
+
+	var wg sync.WaitGroup
+	wg.Add(N)
+
+	inboxes := make([]chan []Transaction, N)
+	receivers := make([]chan []raftMsg, N)
+	queues := make([]*mailbox[raftMsg], N)
+	for i := range N {
+		inboxes[i] = make(chan []Transaction)
+		receivers[i] = make(chan []raftMsg)
+		queues[i] = newMailbox(receivers[i])
+	}
+	G := createGenesisBlock(cfg.hashing(), 0)
+	names := nodeNames(N, C)
+	timeout := raftTimeout(cfg)
+	majority := N/2 + 1
+	stats := RaftStats{}
+
+	var mu sync.Mutex // guards the winner, chains and stats against the nodes' concurrent events
+	var winner = []Block{G}
+	var winnerType = ""
+	chains := [][]Block{}
+	bus.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		chains = append(chains, e.Chain)
+		if len(e.Chain) > len(winner) {
+			winner = e.Chain
+			winnerType = getLabel(nodeIndex(e.Node, C), C)
+		}
+	}, EventNodeDone)
+
+	for i := range N {
+		faulty := getLabel(i, C) == "corrupt"
+		crashes, delayed := faulty && i%2 == 0, faulty && i%2 == 1
+		if crashes {
+			stats.Crashed++
+		}
+		if delayed {
+			stats.Delayed++
+		}
+		go func() {
+			defer wg.Done()
+			publish := func(e Event) {
+				e.Sim, e.Node = "Raft", names[i]
+				bus.Publish(e)
+			}
+			inbox, receiver := inboxes[i], receivers[i]
+			term, votedFor, leader := 0, -1, -1
+			leading := false
+			votes := make(map[int]bool)
+			log := []raftEntry{{Block: G}} // log[0] is genesis, committed by everyone
+			commitIndex := 0
+			nextIndex, matchIndex := make([]int, N), make([]int, N)
+			pending := []Transaction{} // received but not committed yet
+			inLog := make(map[TxID]bool)
+
+			election := time.NewTimer(timeout + rand.N(timeout))
+			defer election.Stop()
+			heartbeat := time.NewTicker(timeout / 4)
+			defer heartbeat.Stop()
+			var crash <-chan time.Time
+			if crashes {
+				crash = time.After(rand.N(2 * timeout))
+			}
+
+			send := func(j int, m raftMsg) {
+				m.From, m.Term = i, term
+				if delayed {
+					time.AfterFunc(timeout, func() { queues[j].send(m) })
+					return
+				}
+				queues[j].send(m)
+			}
+			resetElection := func() {
+				election.Reset(timeout + rand.N(timeout))
+			}
+			apply := func(upTo int) { // commit log entries up to index upTo
+				for ; commitIndex < upTo; commitIndex++ {
+					b := log[commitIndex+1].Block
+					for _, tx := range b.Transactions {
+						inLog[tx.TxID] = true
+					}
+					publish(Event{Kind: EventCommit, Block: b, Height: commitIndex + 1, Round: log[commitIndex+1].Term})
+				}
+				kept := pending[:0]
+				for _, tx := range pending {
+					if !inLog[tx.TxID] {
+						kept = append(kept, tx)
+					}
+				}
+				pending = kept
+			}
+			appendBlock := func(txs []Transaction) { // leader: put new transactions in a block at the end of the log
+				fresh := []Transaction{}
+				for _, tx := range txs {
+					if !inLog[tx.TxID] {
+						inLog[tx.TxID] = true
+						fresh = append(fresh, tx)
+					}
+				}
+				if len(fresh) == 0 {
+					return
+				}
+				b := Block{Transactions: fresh, PrevHash: log[len(log)-1].Block.Hash, Timestamp: time.Now().UnixNano(), Miner: names[i]}
+				b.Hash = calculateHash(cfg.hashing(), b)
+				log = append(log, raftEntry{Term: term, Block: b})
+				matchIndex[i] = len(log) - 1
+				publish(Event{Kind: EventMined, Block: b, Height: len(log) - 1})
+			}
+			replicate := func() {
+				for j := range N {
+					if j == i {
+						continue
+					}
+					prev := nextIndex[j] - 1
+					send(j, raftMsg{Kind: raftAppend, PrevIndex: prev, PrevTerm: log[prev].Term, Entries: log[prev+1:], Commit: commitIndex})
+				}
+			}
+			forward := func() { // hand pending transactions to the leader
+				switch {
+				case leading:
+					appendBlock(pending)
+				case leader >= 0 && len(pending) > 0:
+					send(leader, raftMsg{Kind: raftForward, Txs: slices.Clone(pending)}) // pending is compacted in place
+				}
+			}
+			follow := func(t, l int) {
+				if t > term {
+					term, votedFor = t, -1
+				}
+				leading = false
+				if l != leader && l >= 0 {
+					leader = l
+					forward()
+				}
+			}
+			advanceCommit := func() {
+				for n := len(log) - 1; n > commitIndex; n-- {
+					stored := 0
+					for j := range N {
+						if matchIndex[j] >= n {
+							stored++
+						}
+					}
+					if stored >= majority && log[n].Term == term {
+						apply(n)
+						return
+					}
+				}
+			}
+			handle := func(m raftMsg) {
+				if m.Term > term {
+					follow(m.Term, -1)
+				}
+				switch m.Kind {
+				case raftVote:
+					lastTerm := log[len(log)-1].Term
+					upToDate := m.LastTerm > lastTerm || (m.LastTerm == lastTerm && m.LastIndex >= len(log)-1)
+					granted := m.Term == term && (votedFor == -1 || votedFor == m.From) && upToDate
+					if granted {
+						votedFor = m.From
+						resetElection()
+					}
+					send(m.From, raftMsg{Kind: raftVoteReply, Granted: granted})
+				case raftVoteReply:
+					if leading || m.Term != term || !m.Granted || votedFor != i {
+						return
+					}
+					votes[m.From] = true
+					if len(votes) < majority {
+						return
+					}
+					leading, leader = true, i
+					publish(Event{Kind: EventElected, Round: term})
+					for j := range N {
+						nextIndex[j], matchIndex[j] = len(log), 0
+					}
+					matchIndex[i] = len(log) - 1
+					appendBlock(pending)
+					replicate()
+				case raftAppend:
+					if m.Term < term {
+						send(m.From, raftMsg{Kind: raftAppendReply, Match: len(log) - 1})
+						return
+					}
+					follow(m.Term, m.From)
+					resetElection()
+					if m.PrevIndex >= len(log) || log[m.PrevIndex].Term != m.PrevTerm {
+						send(m.From, raftMsg{Kind: raftAppendReply, Match: min(len(log)-1, m.PrevIndex-1)})
+						return
+					}
+					for k, e := range m.Entries {
+						idx := m.PrevIndex + 1 + k
+						if idx < len(log) && log[idx].Term != e.Term { // conflicting suffix, never committed
+							log = log[:idx]
+						}
+						if idx >= len(log) {
+							log = append(log, e)
+						}
+					}
+					match := m.PrevIndex + len(m.Entries)
+					apply(min(m.Commit, match))
+					send(m.From, raftMsg{Kind: raftAppendReply, Success: true, Match: match})
+				case raftAppendReply:
+					if !leading || m.Term != term {
+						return
+					}
+					if m.Success {
+						matchIndex[m.From] = max(matchIndex[m.From], m.Match)
+						nextIndex[m.From] = matchIndex[m.From] + 1
+						advanceCommit()
+					} else {
+						nextIndex[m.From] = max(1, min(nextIndex[m.From]-1, m.Match+1))
+					}
+				case raftForward:
+					if leading {
+						appendBlock(m.Txs)
+					} else if leader >= 0 && leader != m.From {
+						send(leader, m)
+					}
+				}
+			}
+
+			for {
+				select {
+				case <-nodeCtx.Done():
+					publish(Event{Kind: EventNodeDone, Chain: committedChain(log, commitIndex)})
+					return
+				case <-crash: // stay silent until the simulation ends
+					for inbox != nil { // lose the transactions still sent to it, rather than block the sender
+						select {
+						case _, ok := <-inbox:
+							if !ok {
+								inbox = nil
+							}
+						case <-nodeCtx.Done():
+							inbox = nil
+						}
+					}
+					<-nodeCtx.Done()
+					publish(Event{Kind: EventNodeDone, Chain: committedChain(log, commitIndex)})
+					return
+				case txs, ok := <-inbox:
+					if !ok {
+						inbox = nil
+						continue
+					}
+					pending = append(pending, txs...)
+					if leading {
+						appendBlock(txs)
+					} else if leader >= 0 {
+						send(leader, raftMsg{Kind: raftForward, Txs: txs})
+					}
+				case msgs := <-receiver:
+					for _, m := range msgs {
+						handle(m)
+					}
+				case <-election.C:
+					if leading {
+						resetElection()
+						continue
+					}
+					term++
+					votedFor, leader = i, -1
+					clear(votes)
+					votes[i] = true
+					for j := range N {
+						if j != i {
+							send(j, raftMsg{Kind: raftVote, LastIndex: len(log) - 1, LastTerm: log[len(log)-1].Term})
+						}
+					}
+					resetElection()
+				case <-heartbeat.C:
+					if leading {
+						replicate()
+					}
+				}
+			}
+		}()
+	}
+
+	timing := trackTiming(bus)
+	value := trackValue(bus)
+	nodes := trackNodes(bus, N, C)
+	watch := newCommitWatch()
+	bus.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch e.Kind {
+		case EventCommit:
+			watch.add(e.Block)
+			stats.Terms = max(stats.Terms, e.Round)
+		case EventElected:
+			stats.Elections++
+			stats.Terms = max(stats.Terms, e.Round)
+		}
+	}, EventCommit, EventElected)
+	txSent := SendTransactions(ctx, N, C, R, inboxes, newWorkload(cfg, N, C, nil), bus, nil)
+
+	watch.wait(ctx, txSent, 50*timeout)
+	stopNodes()
+	wg.Wait()
+	for i := range N {
+		queues[i].close()
+	}
+
+	for _, chain := range chains {
+		for k, b := range chain {
+			if b.Hash != winner[k].Hash {
+				stats.LogConflicts++
+			}
+		}
+	}
+	corruptPercentage := getPercentage(C, N)
+	txConfirmed := countConfirmedTransactions(winner)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			bus.Publish(Event{Kind: EventConfirmed, Sim: "Raft", Tx: tx}) // the leader never logs a transaction twice
+		}
+	}
+	txConfirmedPercentage := getPercentage(txConfirmed, txSent)
+	latencyStats := summarizeLatencies(timing.chainLatencies(winner))
+	duration := time.Since(start)
+	timedOut := timedOut(ctx)
+	throughput := timing.chainThroughput(winner, txConfirmed, duration)
+
+	if verbose {
+		printBlockchain(winner)
+		fmt.Println("\nTotal nodes        =", N)
+		fmt.Println("Faulty nodes       =", C)
+		fmt.Println("Faulty %           =", corruptPercentage)
+		fmt.Println("Rounds             =", R)
+		fmt.Println("txSent             =", txSent)
+		fmt.Println("txConfirmed        =", txConfirmed)
+		fmt.Println("txConfirmed %      =", txConfirmedPercentage)
+		fmt.Printf("Duration (s)        = %.2f\n", duration.Seconds())
+		printNodeStats(nodes.stats)
+		printLatencyStats(latencyStats)
+		printThroughput(throughput)
+		printRaftStats(stats)
+		fmt.Println("Timed out          =", timedOut)
+	}
+
+	return SimResult{
+		Type:                  "Raft",
+		N:                     N,
+		C:                     C,
+		CorruptPercentage:     corruptPercentage,
+		R:                     R,
+		D:                     D,
+		P:                     p,
+		TxSent:                txSent,
+		TxConfirmed:           txConfirmed,
+		TxConfirmedPercentage: txConfirmedPercentage,
+		Winner:                winnerType,
+		Duration:              duration,
+		Latency:               latencyStats,
+		Value:                 value.result(),
+		Throughput:            throughput,
+		Nodes:                 nodes.stats,
+		Raft:                  stats,
+		TimedOut:              timedOut,
+	}
+}
+
+// committedChain is the committed prefix of a Raft log as a chain of blocks, genesis included
+func committedChain(log []raftEntry, commitIndex int) []Block {
+	chain := make([]Block, commitIndex+1)
+	for k := range chain {
+		chain[k] = log[k].Block
+	}
+	return chain
+}
+
+func printRaftStats(stats RaftStats) {
+	fmt.Println("Terms              =", stats.Terms)
+	fmt.Println("Leaders elected    =", stats.Elections)
+	fmt.Println("Crashed nodes      =", stats.Crashed)
+	fmt.Println("Delayed nodes      =", stats.Delayed)
+	fmt.Println("Log conflicts      =", stats.LogConflicts)
+}
@@ -0,0 +1,28 @@
+package sim
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestRaftCrashesDoNotBlockSender runs Raft with crashing nodes under arrivals paced in real time. A
+// crashed node used to stop reading its inbox, so SendTransactions blocked on it and the simulation
+// never ended; followers also forwarded their pending slice while compacting it in place, which go test
+// -race reports here.
+func TestRaftCrashesDoNotBlockSender(t *testing.T) {
+	cfg := SimConfig{N: 7, C: 4, R: 2, D: 1, P: 0.8, Workload: "poisson:200", Events: NewEventBus()}
+	checker := checkInvariants(cfg.Events, cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res := SimulateRaftCtx(ctx, cfg)
+	if ctx.Err() != nil {
+		t.Fatal("the simulation ran into the timeout, a crashed node blocked the sender")
+	}
+	if res.Raft.Crashed == 0 {
+		t.Error("no node crashed")
+	}
+	for _, v := range checker.violations(res) {
+		t.Error(v)
+	}
+}
@@ -0,0 +1,173 @@
+package sim
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// --- Replace-by-Fee ---
+
+/*
+	With SimConfig.RBFRate = r every PoW transaction pays a base fee of 1, and its sender rebroadcasts
+	it with probability r along with the next round of transactions (the next batch of an arrival
+	workload), paying twice the fee. The replacement keeps the transaction's TxID, so only one of the
+	two can confirm: the trackers count the first one the winning chain includes.
+
+	A node that still has the original pending swaps the replacement in, a node that already mined the
+	original (or never heard of it) drops the replacement as a conflict. Miners also prefer higher fees
+	when a block size limit (SimConfig.MaxBlockTxs) leaves transactions out: they fill the block by
+	fee, first come, first served among equal fees, so a replacement jumps the queue. The spam pays no
+	fee and goes last. Replacements are not announced as EventSent, they only count in the RBF columns:
+	how many were sent, how many mempools took them, and which version of the replaced transactions the
+	winning chain confirmed.
+*/
+
+// rbfBaseFee is the fee of an original transaction, a replacement pays rbfBump times the fee it replaces
+const (
+	rbfBaseFee Amount = amountScale
+	rbfBump           = 2
+)
+
+// RBFStats reports the fee bumps of a PoW simulation
+type RBFStats struct {
+	Rate                 float64 // probability that a sender replaces a transaction
+	Replacements         int     // replacements sent
+	Swapped              int     // pending transactions nodes replaced
+	ConfirmedReplacement int     // replaced transactions the winning chain confirmed with the higher fee
+	ConfirmedOriginal    int     // replaced transactions the winning chain confirmed with the original fee
+}
+
+// rbf adds the fees and replacements to the batches SendTransactions hands out, nil without RBF
+type rbf struct {
+	rate    float64
+	C       int
+	last    int                      // node of the previous batch, a lower one starts a new batch
+	batch   map[TxID]Transaction     // the transactions of the current batch, of both sides
+	out     map[string][]Transaction // label -> the current batch of that side, with fees and replacements
+	bumps   []Transaction            // replacements to send with the current batch
+	replace map[TxID]Amount          // replaced transaction -> fee of the replacement
+
+	mu      sync.Mutex
+	swapped int
+}
+
+func newRBF(cfg SimConfig, C int) *rbf {
+	if cfg.RBFRate <= 0 {
+		return nil
+	}
+	return &rbf{rate: cfg.RBFRate, C: C, last: -1, batch: make(map[TxID]Transaction), replace: make(map[TxID]Amount)}
+}
+
+// bump is a split hook for SendTransactions: node (of an N, C simulation) gets its batch with fees,
+// followed by the replacements of its side for the previous batch
+func (r *rbf) bump(node int, txs []Transaction) []Transaction {
+	if r == nil {
+		return txs
+	}
+	if node <= r.last { // new batch, replace some of the previous one
+		r.bumps = nil
+		for _, tx := range r.batch {
+			if rand.Float64() < r.rate {
+				tx.Fee *= rbfBump
+				r.replace[tx.TxID] = tx.Fee
+				r.bumps = append(r.bumps, tx)
+			}
+		}
+		slices.SortFunc(r.bumps, func(a, b Transaction) int { return cmp.Compare(a.TxID, b.TxID) })
+		r.batch, r.out = make(map[TxID]Transaction), nil
+	}
+	r.last = node
+	label := getLabel(node, r.C)
+	if out, ok := r.out[label]; ok {
+		return out
+	}
+	out := []Transaction{}
+	for _, tx := range txs {
+		tx.Fee = rbfBaseFee
+		r.batch[tx.TxID] = tx
+		out = append(out, tx)
+	}
+	for _, tx := range r.bumps {
+		if strings.HasPrefix(tx.Sender, label) {
+			out = append(out, tx)
+		}
+	}
+	if r.out == nil {
+		r.out = make(map[string][]Transaction)
+	}
+	r.out[label] = out
+	return out
+}
+
+// accept adds txs to the pending transactions of a node: new transactions go to the end, and a
+// replacement takes the place of the transaction it outbids or is dropped
+func (r *rbf) accept(pending, txs []Transaction) []Transaction {
+	if r == nil {
+		return append(pending, txs...)
+	}
+	for _, tx := range txs {
+		if tx.Fee <= rbfBaseFee { // not a replacement
+			pending = append(pending, tx)
+			continue
+		}
+		k := slices.IndexFunc(pending, func(p Transaction) bool { return p.TxID == tx.TxID })
+		if k >= 0 && pending[k].Fee < tx.Fee {
+			pending[k] = tx
+			r.mu.Lock()
+			r.swapped++
+			r.mu.Unlock()
+		}
+	}
+	return pending
+}
+
+// order sorts pending transactions by fee before a miner fills a block of at most limit of them
+func (r *rbf) order(pending []Transaction, limit int) {
+	if r != nil && limit > 0 {
+		slices.SortStableFunc(pending, func(a, b Transaction) int { return cmp.Compare(b.Fee, a.Fee) })
+	}
+}
+
+// fresher tells whether a node that heard of the transactions in seen (ID -> fee) hears tx for the first
+// time, or a replacement of it with a higher fee
+func fresher(seen map[TxID]Amount, tx Transaction) bool {
+	fee, ok := seen[tx.TxID]
+	return !ok || tx.Fee > fee
+}
+
+func (r *rbf) result(winner []Block) RBFStats {
+	if r == nil {
+		return RBFStats{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := RBFStats{Rate: r.rate, Replacements: len(r.replace), Swapped: r.swapped}
+	counted := make(map[TxID]bool)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			fee, replaced := r.replace[tx.TxID]
+			if !replaced || counted[tx.TxID] {
+				continue
+			}
+			counted[tx.TxID] = true
+			if tx.Fee >= fee {
+				stats.ConfirmedReplacement++
+			} else {
+				stats.ConfirmedOriginal++
+			}
+		}
+	}
+	return stats
+}
+
+func printRBFStats(stats RBFStats) {
+	fmt.Println("RBF rate           =", stats.Rate)
+	fmt.Println("RBF replacements   =", stats.Replacements)
+	fmt.Println("RBF swapped        =", stats.Swapped)
+	fmt.Println("Confirmed replaced =", stats.ConfirmedReplacement)
+	fmt.Println("Confirmed original =", stats.ConfirmedOriginal)
+}
@@ -0,0 +1,402 @@
+package sim
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Console ---
+
+/*
+	With -repl (or in cmd/repl) the tester reads commands from stdin while the suite runs and applies them to the PoW
+	simulation running at the time (pow, hybrid and rollup, and the first PoW chain of swap, shard and
+	sidechain), to explore scenarios by hand:
+	- tx <from> <to> <value>: sends a transaction of value from node from to node to, handed to every
+	  node of the sender's side like the workload's;
+	- partition <nodes> | <nodes> [| ...]: cuts the network into groups, each a list of node names,
+	  indices or index ranges (e.g. 0-4 | 5-9, indices count the corrupt nodes first); blocks only reach
+	  the nodes of their miner's group, and the nodes left out form a group of their own;
+	- heal: ends the partition;
+	- corrupt <node>: an honest node joins the corrupt nodes, mining on their chain and only sending its
+	  blocks to them, like a bribed miner (see bribery.go);
+	- status: the running simulation, its partition, the nodes turned corrupt and transactions sent;
+	- help.
+	Everything ends with the simulation, the next one starts from scratch. The suite is fast, pause it
+	with -control (see control.go) to type commands into a given point of it.
+
+	Injected transactions carry no inputs, fee or script, so with -utxo or -script miners drop them, and
+	they reach the nodes directly even with -tx-gossip. A partition cuts the block broadcasts, the
+	initial block download of a node coming back online (-churn) still reaches across it.
+*/
+
+// consoleTxID is the first ID of an injected transaction, far from the workload's
+const consoleTxID TxID = 1_000_000_000
+
+// console applies the commands it reads to the PoW simulation attached to it
+type console struct {
+	mu     sync.Mutex
+	target *consoleTarget
+	out    io.Writer
+	nextID TxID                // of the next injected transaction, over the whole suite
+	txs    map[TxID]*consoleTx // every injected transaction, by ID
+	hold   time.Duration       // how long PoW simulations take injected transactions after their workload, see txapi.go
+
+	// the blocks of the last PoW simulation attached and the tip of the longest chain among them,
+	// kept until the next one attaches, see rpc.go
+	genesis Block
+	blocks  map[string]Block
+	heights map[string]int
+	tip     string
+}
+
+// consoleTx is an injected transaction and how far it got
+type consoleTx struct {
+	ID     int64
+	From   string
+	To     string
+	Value  Amount
+	Status string // sent, mined (in a block, not yet final), confirmed (on the winning chain) or dropped (not on it)
+	Block  string `json:",omitempty"` // the first block that included it
+	Height int    `json:",omitempty"`
+	Miner  string `json:",omitempty"`
+	Sent   time.Time
+	Mined  time.Time `json:",omitzero"`
+}
+
+// consoleTarget is a running PoW simulation of N nodes, C corrupt, as the console changes it. Its
+// methods are safe on a nil target, which changes nothing.
+type consoleTarget struct {
+	ctx     context.Context
+	bus     *EventBus
+	names   []string
+	C       int
+	inbox   []chan []Transaction // injected transactions, per node
+	group   []atomic.Int32       // partition group per node, all 0 without a partition
+	turned  []atomic.Bool        // honest nodes turned corrupt
+	sent    atomic.Int64
+	done    chan struct{} // closed once the nodes stopped reading their inbox
+	held    chan struct{} // closed once the simulation stops taking injected transactions
+	holdFor time.Duration
+	wg      sync.WaitGroup
+	untrack func()
+}
+
+func newConsole(out io.Writer) *console {
+	return &console{out: out, nextID: consoleTxID, txs: make(map[TxID]*consoleTx)}
+}
+
+// attach makes the console change the simulation of N nodes, C corrupt, publishing to bus until
+// detach; it returns nil (and the simulation runs untouched) without a console or if another
+// simulation is attached, e.g. a chain of the shard mode
+func (c *console) attach(ctx context.Context, N, C int, genesis Block, bus *EventBus) *consoleTarget {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.target != nil {
+		return nil
+	}
+	t := &consoleTarget{ctx: ctx, bus: bus, names: nodeNames(N, C), C: C, inbox: make([]chan []Transaction, N),
+		group: make([]atomic.Int32, N), turned: make([]atomic.Bool, N), done: make(chan struct{}), held: make(chan struct{}), holdFor: c.hold}
+	for i := range t.inbox {
+		t.inbox[i] = make(chan []Transaction)
+	}
+	t.untrack = bus.Subscribe(c.track, EventMined, EventAccepted, EventConfirmed)
+	c.target = t
+	c.genesis, c.blocks, c.heights, c.tip = genesis, map[string]Block{genesis.Hash: genesis}, map[string]int{genesis.Hash: 0}, genesis.Hash
+	return t
+}
+
+// track follows the blocks and the injected transactions in them
+func (c *console) track(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.Kind == EventConfirmed {
+		if tx := c.txs[e.Tx.TxID]; tx != nil {
+			tx.Status = "confirmed"
+		}
+		return
+	}
+	if _, known := c.blocks[e.Block.Hash]; known {
+		return
+	}
+	c.blocks[e.Block.Hash], c.heights[e.Block.Hash] = e.Block, e.Height
+	if e.Height > c.heights[c.tip] {
+		c.tip = e.Block.Hash
+	}
+	for _, included := range e.Block.Transactions {
+		if tx := c.txs[included.TxID]; tx != nil && tx.Status == "sent" {
+			tx.Status, tx.Block, tx.Height, tx.Miner, tx.Mined = "mined", e.Block.Hash, e.Height, e.Node, e.Time
+		}
+	}
+}
+
+// detach ends the console's changes to t, once its nodes are done
+func (c *console) detach(t *consoleTarget) {
+	if t == nil {
+		return
+	}
+	c.mu.Lock()
+	c.target = nil // no more sends
+	c.mu.Unlock()
+	close(t.done)
+	t.wg.Wait()
+	t.untrack()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tx := range c.txs {
+		if tx.Status == "sent" || tx.Status == "mined" {
+			tx.Status = "dropped"
+		}
+	}
+}
+
+// hold keeps the simulation taking injected transactions for the console's hold after its workload
+// was sent, or until ctx is done
+func (t *consoleTarget) hold(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	select {
+	case <-time.After(t.holdFor):
+	case <-ctx.Done():
+	}
+	close(t.held)
+}
+
+// holding is closed once the simulation stops taking injected transactions, nil (never ready)
+// without a console
+func (t *consoleTarget) holding() <-chan struct{} {
+	if t == nil {
+		return nil
+	}
+	return t.held
+}
+
+// txs is where node i receives injected transactions, nil (never ready) without a console
+func (t *consoleTarget) txs(i int) <-chan []Transaction {
+	if t == nil {
+		return nil
+	}
+	return t.inbox[i]
+}
+
+// cut reports whether a partition separates nodes i and j
+func (t *consoleTarget) cut(i, j int) bool {
+	return t != nil && t.group[i].Load() != t.group[j].Load()
+}
+
+// corrupt reports whether honest node i was turned corrupt
+func (t *consoleTarget) corrupt(i int) bool {
+	return t != nil && t.turned[i].Load()
+}
+
+// injected is the number of transactions sent from the console
+func (t *consoleTarget) injected() int {
+	if t == nil {
+		return 0
+	}
+	return int(t.sent.Load())
+}
+
+// node returns the index of a node name or index, -1 if there is none
+func (t *consoleTarget) node(s string) int {
+	if i, err := strconv.Atoi(s); err == nil && i >= 0 && i < len(t.names) {
+		return i
+	}
+	return slices.Index(t.names, s)
+}
+
+// run executes the commands read from in until it ends or ctx is done
+func (c *console) run(ctx context.Context, in io.Reader) {
+	fmt.Fprintln(c.out, "console ready, type help for the commands")
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return
+			}
+			if reply := c.exec(line); reply != "" {
+				fmt.Fprintln(c.out, reply)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// exec executes a command line, returning the reply
+func (c *console) exec(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	if fields[0] == "help" {
+		return "tx <from> <to> <value> | partition <nodes> | <nodes> ... | heal | corrupt <node> | status | help"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.target
+	if t == nil {
+		return errNoSimulation.Error()
+	}
+	switch fields[0] {
+	case "tx":
+		if len(fields) != 4 {
+			return "usage: tx <from> <to> <value>"
+		}
+		value, err := parseAmount(fields[3])
+		if err != nil {
+			return fmt.Sprintf("tx: bad value %q", fields[3])
+		}
+		tx, err := c.inject(fields[1], fields[2], value)
+		if err != nil {
+			return "tx: " + err.Error()
+		}
+		return fmt.Sprintf("tx %d: %s -> %s %s, to the %s nodes", tx.ID, tx.From, tx.To, value, getLabel(t.node(tx.From), t.C))
+	case "partition":
+		groups := strings.Split(strings.Join(fields[1:], " "), "|")
+		if len(groups) < 2 {
+			return "usage: partition <nodes> | <nodes> ..."
+		}
+		group := make([]int32, len(t.names)) // the nodes left out stay in group 0
+		for g, spec := range groups {
+			for _, token := range strings.FieldsFunc(spec, func(r rune) bool { return r == ' ' || r == ',' }) {
+				lo, hi, isRange := strings.Cut(token, "-")
+				first, last := t.node(lo), t.node(lo)
+				if isRange {
+					last = t.node(hi)
+				}
+				if first < 0 || last < first {
+					return fmt.Sprintf("partition: unknown node %q", token)
+				}
+				for i := first; i <= last; i++ {
+					group[i] = int32(g + 1)
+				}
+			}
+		}
+		for i := range group {
+			t.group[i].Store(group[i])
+		}
+		return "partitioned: " + t.partition()
+	case "heal":
+		for i := range t.group {
+			t.group[i].Store(0)
+		}
+		return "healed"
+	case "corrupt":
+		if len(fields) != 2 {
+			return "usage: corrupt <node>"
+		}
+		i := t.node(fields[1])
+		if i < 0 || getLabel(i, t.C) == "corrupt" {
+			return fmt.Sprintf("corrupt: %q is not an honest node", fields[1])
+		}
+		t.turned[i].Store(true)
+		return t.names[i] + " is corrupt"
+	case "status":
+		turned := []string{}
+		for i := range t.turned {
+			if t.turned[i].Load() {
+				turned = append(turned, t.names[i])
+			}
+		}
+		return fmt.Sprintf("PoW simulation of %d nodes, %d corrupt; partition: %s; turned corrupt: %v; transactions sent: %d",
+			len(t.names), t.C, t.partition(), turned, t.injected())
+	}
+	return fmt.Sprintf("unknown command %q, type help", fields[0])
+}
+
+// errNoSimulation is the error injecting into no simulation, or one that takes no more transactions
+var errNoSimulation = errors.New("no PoW simulation taking transactions")
+
+// inject sends a transaction of value from node from to node to into the attached simulation; the
+// caller holds c.mu
+func (c *console) inject(from, to string, value Amount) (consoleTx, error) {
+	t := c.target
+	if t == nil {
+		return consoleTx{}, errNoSimulation
+	}
+	i, j := t.node(from), t.node(to)
+	switch {
+	case i < 0 || j < 0:
+		return consoleTx{}, fmt.Errorf("unknown node %q or %q", from, to)
+	case value < 0:
+		return consoleTx{}, fmt.Errorf("bad value %s", value)
+	}
+	select {
+	case <-t.held:
+		return consoleTx{}, errNoSimulation
+	default:
+	}
+	tx := Transaction{TxID: c.nextID, Sender: t.names[i], Receiver: t.names[j], Amount: value}
+	c.nextID++
+	c.txs[tx.TxID] = &consoleTx{ID: int64(tx.TxID), From: tx.Sender, To: tx.Receiver, Value: value, Status: "sent", Sent: time.Now()}
+	t.send(tx, getLabel(i, t.C))
+	return *c.txs[tx.TxID], nil
+}
+
+// partition describes the groups of the partition
+func (t *consoleTarget) partition() string {
+	groups := map[int32][]string{}
+	for i := range t.group {
+		g := t.group[i].Load()
+		groups[g] = append(groups[g], t.names[i])
+	}
+	if len(groups) < 2 {
+		return "none"
+	}
+	parts := []string{}
+	for g := range int32(len(t.names) + 1) {
+		if len(groups[g]) > 0 {
+			parts = append(parts, strings.Join(groups[g], " "))
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// send announces tx and hands it to every node of side, in the background so a paused simulation does
+// not block the console
+func (t *consoleTarget) send(tx Transaction, side string) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		select {
+		case <-t.done: // the simulation ended meanwhile
+			return
+		default:
+		}
+		t.bus.Publish(Event{Kind: EventSent, Tx: tx})
+		t.sent.Add(1)
+		for i := range t.inbox {
+			if getLabel(i, t.C) != side {
+				continue
+			}
+			select {
+			case t.inbox[i] <- []Transaction{tx}:
+			case <-t.done:
+				return
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,451 @@
+package sim
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- HTML Report ---
+
+/*
+	With -report <file.html> the tester turns the results file into a report once the suite stops: a
+	summary per simulation type (runs, mean txConfirmed %, mean time, corrupt wins), charts of
+	txConfirmed % against corrupt %, time against difficulty, and txConfirmed % of every simulation type
+	per test, and the full results table. The charts are SVG written here and everything is inline, so the
+	file opens anywhere without a network. The report covers every row of the results file, those of an
+	earlier run resumed with -resume included.
+*/
+
+// resultTable is the results file, every row as long as the header
+type resultTable struct {
+	header []string
+	rows   [][]string
+}
+
+func readResults(path string) (resultTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return resultTable{}, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // rows of older runs may lack the newer columns
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return resultTable{}, cmp.Or(err, fmt.Errorf("%s is empty", path))
+	}
+	t := resultTable{header: records[0]}
+	for _, row := range records[1:] {
+		t.rows = append(t.rows, append(row, make([]string, max(len(t.header)-len(row), 0))...)[:len(t.header)])
+	}
+	return t, nil
+}
+
+// column returns the values of the column name, "" for every row if there is none
+func (t resultTable) column(name string) []string {
+	k := slices.Index(t.header, name)
+	values := make([]string, len(t.rows))
+	for i, row := range t.rows {
+		if k >= 0 {
+			values[i] = row[k]
+		}
+	}
+	return values
+}
+
+// number returns the value of the column name in row i, false if it is not a number
+func (t resultTable) number(name string, i int) (float64, bool) {
+	k := slices.Index(t.header, name)
+	if k < 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(t.rows[i][k], 64)
+	return v, err == nil && !math.IsNaN(v)
+}
+
+// types returns the simulation types in the order they first appear
+func (t resultTable) types() []string {
+	types := []string{}
+	for _, typ := range t.column("Simulation Type") {
+		if !slices.Contains(types, typ) {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// scatter returns a chart of y against x with a series per simulation type
+func (t resultTable) scatter(title, x, y string) chart {
+	c := chart{title: title, xLabel: x, yLabel: y}
+	types := t.column("Simulation Type")
+	for _, typ := range t.types() {
+		s := chartSeries{name: typ}
+		for i := range t.rows {
+			vx, okx := t.number(x, i)
+			vy, oky := t.number(y, i)
+			if types[i] == typ && okx && oky {
+				s.points = append(s.points, [2]float64{vx, vy})
+			}
+		}
+		c.series = append(c.series, s)
+	}
+	return c
+}
+
+// byTest returns a bar chart of y per test, a test being a run of the simulation types on the same
+// N, C, R, D, p
+func (t resultTable) byTest(title, y string) chart {
+	c := chart{title: title, yLabel: y, bars: true}
+	tests := map[string]int{}
+	values := map[string]map[int]float64{}
+	types, n, corrupt := t.column("Simulation Type"), t.column("Total Nodes"), t.column("Corrupt Nodes")
+	r, d, p := t.column("Rounds"), t.column("Difficulty"), t.column("Broadcast Probability")
+	for i := range t.rows {
+		key := strings.Join([]string{n[i], corrupt[i], r[i], d[i], p[i]}, "/")
+		test, ok := tests[key]
+		if !ok {
+			test = len(c.groups)
+			tests[key] = test
+			c.groups = append(c.groups, n[i]+"/"+corrupt[i])
+		}
+		if v, ok := t.number(y, i); ok {
+			if values[types[i]] == nil {
+				values[types[i]] = map[int]float64{}
+			}
+			values[types[i]][test] = v
+		}
+	}
+	for _, typ := range t.types() {
+		s := chartSeries{name: typ}
+		for test, v := range values[typ] {
+			s.points = append(s.points, [2]float64{float64(test), v})
+		}
+		c.series = append(c.series, s)
+	}
+	return c
+}
+
+// mean returns a line chart of the mean of value over the rows of each simulation type with the same x,
+// skipping the rows value has none for
+func (t resultTable) mean(title, x, y string, value func(i int) (float64, bool)) chart {
+	c := chart{title: title, xLabel: x, yLabel: y, lines: true}
+	types := t.column("Simulation Type")
+	for _, typ := range t.types() {
+		sums, counts := map[float64]float64{}, map[float64]int{}
+		for i := range t.rows {
+			vx, okx := t.number(x, i)
+			v, ok := value(i)
+			if types[i] == typ && okx && ok {
+				sums[vx] += v
+				counts[vx]++
+			}
+		}
+		s := chartSeries{name: typ}
+		for vx, sum := range sums {
+			s.points = append(s.points, [2]float64{vx, sum / float64(counts[vx])})
+		}
+		c.series = append(c.series, s)
+	}
+	return c
+}
+
+// chartSeries is one color of a chart
+type chartSeries struct {
+	name   string
+	points [][2]float64 // x, y; for bars x is the group
+}
+
+// chart is a scatter plot, or grouped bars if bars
+type chart struct {
+	title, xLabel, yLabel string
+	series                []chartSeries
+	bars                  bool
+	groups                []string // labels of the bar groups
+	lines                 bool     // join the points of a series in x order
+}
+
+var chartColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// ticks returns round values covering lo to hi, about n of them
+func ticks(lo, hi float64, n int) []float64 {
+	if hi <= lo {
+		hi = lo + 1
+	}
+	step := math.Pow(10, math.Floor(math.Log10((hi-lo)/float64(n))))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if (hi-lo)/(step*m) <= float64(n) {
+			step *= m
+			break
+		}
+	}
+	values := []float64{}
+	first := math.Floor(lo / step)
+	for k := 0.0; (first+k)*step <= hi+step/2; k++ {
+		values = append(values, math.Round((first+k)*step*1e9)/1e9) // no 0.30000000000000004
+	}
+	return values
+}
+
+// svg draws the chart width by height
+func (c chart) svg(width, height int) string {
+	const left, right, top, bottom = 60, 120, 30, 45
+	plotW, plotH := float64(width-left-right), float64(height-top-bottom)
+	xlo, xhi, ylo, yhi := math.Inf(1), math.Inf(-1), 0.0, math.Inf(-1)
+	for _, s := range c.series {
+		for _, p := range s.points {
+			xlo, xhi = min(xlo, p[0]), max(xhi, p[0])
+			ylo, yhi = min(ylo, p[1]), max(yhi, p[1])
+		}
+	}
+	if math.IsInf(yhi, -1) {
+		xlo, xhi, yhi = 0, 1, 1
+	}
+	if c.bars {
+		xlo, xhi = -0.5, float64(len(c.groups))-0.5
+	} else if xhi == xlo {
+		xlo, xhi = xlo-1, xhi+1
+	}
+	yt := ticks(ylo, yhi, 5)
+	ylo, yhi = yt[0], yt[len(yt)-1]
+	if !c.bars {
+		xt := ticks(xlo, xhi, 6)
+		xlo, xhi = xt[0], xt[len(xt)-1]
+	}
+	px := func(x float64) float64 { return left + (x-xlo)/(xhi-xlo)*plotW }
+	py := func(y float64) float64 { return top + plotH - (y-ylo)/(yhi-ylo)*plotH }
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", width, height)
+	fmt.Fprintf(&sb, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(&sb, `<text x="%d" y="18" font-size="14" font-weight="bold">%s</text>`+"\n", left, template.HTMLEscapeString(c.title))
+	for _, y := range yt {
+		fmt.Fprintf(&sb, `<line x1="%d" x2="%.1f" y1="%.1f" y2="%.1f" stroke="#ddd"/><text x="%d" y="%.1f" text-anchor="end">%g</text>`+"\n", left, left+plotW, py(y), py(y), left-5, py(y)+4, y)
+	}
+	if c.bars {
+		for g, label := range c.groups {
+			fmt.Fprintf(&sb, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", px(float64(g)), top+plotH+15, template.HTMLEscapeString(label))
+		}
+	} else {
+		for _, x := range ticks(xlo, xhi, 6) {
+			fmt.Fprintf(&sb, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%.1f" stroke="#eee"/><text x="%.1f" y="%.1f" text-anchor="middle">%g</text>`+"\n", px(x), px(x), top, top+plotH, px(x), top+plotH+15, x)
+		}
+	}
+	fmt.Fprintf(&sb, `<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#333"/>`+"\n", left, top, plotW, plotH)
+	fmt.Fprintf(&sb, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n", left+plotW/2, height-8, template.HTMLEscapeString(c.xLabel))
+	fmt.Fprintf(&sb, `<text transform="translate(14 %.1f) rotate(-90)" text-anchor="middle">%s</text>`+"\n", top+plotH/2, template.HTMLEscapeString(c.yLabel))
+
+	barW := 0.8 / float64(max(len(c.series), 1))
+	for k, s := range c.series {
+		color := chartColors[k%len(chartColors)]
+		points := slices.Clone(s.points)
+		slices.SortFunc(points, func(a, b [2]float64) int { return cmp.Compare(a[0], b[0]) })
+		switch {
+		case c.bars:
+			for _, p := range points {
+				x := p[0] - 0.4 + barW*float64(k)
+				fmt.Fprintf(&sb, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %s: %.4g</title></rect>`+"\n",
+					px(x), py(max(p[1], 0)), px(x+barW)-px(x), math.Abs(py(p[1])-py(0)), color, template.HTMLEscapeString(s.name), template.HTMLEscapeString(c.groups[int(p[0])]), p[1])
+			}
+		default:
+			if c.lines && len(points) > 1 {
+				path := []string{}
+				for _, p := range points {
+					path = append(path, fmt.Sprintf("%.1f,%.1f", px(p[0]), py(p[1])))
+				}
+				fmt.Fprintf(&sb, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n", strings.Join(path, " "), color)
+			}
+			for _, p := range points {
+				fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="4" fill="%s" fill-opacity="0.7"><title>%s: %.4g, %.4g</title></circle>`+"\n", px(p[0]), py(p[1]), color, template.HTMLEscapeString(s.name), p[0], p[1])
+			}
+		}
+		fmt.Fprintf(&sb, `<rect x="%.1f" y="%d" width="10" height="10" fill="%s"/><text x="%.1f" y="%d">%s</text>`+"\n", left+plotW+12, top+k*16, color, left+plotW+26, top+k*16+9, template.HTMLEscapeString(s.name))
+	}
+	sb.WriteString("</svg>\n")
+	return sb.String()
+}
+
+// reportSummary is a row of the report's summary table
+type reportSummary struct {
+	Type                    string
+	Runs, CorruptWins       int
+	MeanConfirmed, MeanTime string
+}
+
+// writeReport writes the HTML report of the results file csvPath to path
+func writeReport(path, csvPath string) error {
+	t, err := readResults(csvPath)
+	if err != nil {
+		return err
+	}
+	types, winners := t.column("Simulation Type"), t.column("Winner")
+	summary := []reportSummary{}
+	for _, typ := range t.types() {
+		s := reportSummary{Type: typ}
+		confirmed, seconds, timed := 0.0, 0.0, 0
+		for i := range t.rows {
+			if types[i] != typ {
+				continue
+			}
+			s.Runs++
+			if winners[i] == "corrupt" {
+				s.CorruptWins++
+			}
+			if v, ok := t.number("txConfirmed %", i); ok {
+				confirmed += v
+			}
+			if v, ok := t.number("Time (s)", i); ok {
+				seconds += v
+				timed++
+			}
+		}
+		s.MeanConfirmed = fmt.Sprintf("%.2f", confirmed/float64(s.Runs))
+		s.MeanTime = fmt.Sprintf("%.3f", seconds/float64(max(timed, 1)))
+		summary = append(summary, s)
+	}
+
+	// the results table leaves out the columns no row filled
+	columns := []int{}
+	for k := range t.header {
+		for _, row := range t.rows {
+			if row[k] != "" {
+				columns = append(columns, k)
+				break
+			}
+		}
+	}
+	header, rows := []string{}, [][]string{}
+	for _, k := range columns {
+		header = append(header, t.header[k])
+	}
+	for _, row := range t.rows {
+		cells := []string{}
+		for _, k := range columns {
+			cells = append(cells, row[k])
+		}
+		rows = append(rows, cells)
+	}
+
+	charts := []template.HTML{}
+	for _, c := range []chart{
+		t.scatter("txConfirmed % vs corrupt %", "Corrupt %", "txConfirmed %"),
+		t.scatter("Duration vs difficulty", "Difficulty", "Time (s)"),
+		t.byTest("txConfirmed % per test (N/C)", "txConfirmed %"),
+		t.byTest("Duration per test (N/C)", "Time (s)"),
+	} {
+		charts = append(charts, template.HTML(c.svg(640, 360)))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return reportPage.Execute(file, map[string]any{
+		"Generated": time.Now().Format(time.RFC1123), "Source": csvPath, "Rows": len(t.rows),
+		"Summary": summary, "Charts": charts, "Header": header, "Table": rows,
+	})
+}
+
+var reportPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Benchmark report</title>
+<style>
+	body { font-family: sans-serif; margin: 1.5em; }
+	table { border-collapse: collapse; font-size: 0.85em; margin-bottom: 1.5em; }
+	th, td { padding: 2px 8px; border: 1px solid #ddd; text-align: right; }
+	th { background: #f4f4f4; }
+	td:first-child, th:first-child { text-align: left; }
+	.charts { display: flex; flex-wrap: wrap; gap: 1em; margin-bottom: 1.5em; }
+	.scroll { overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Benchmark report</h1>
+<p>{{.Rows}} simulations from {{.Source}}, generated {{.Generated}}.</p>
+<h2>Summary</h2>
+<table>
+	<tr><th>Simulation Type</th><th>Runs</th><th>Mean txConfirmed %</th><th>Mean Time (s)</th><th>Corrupt Wins</th></tr>
+	{{range .Summary}}<tr><td>{{.Type}}</td><td>{{.Runs}}</td><td>{{.MeanConfirmed}}</td><td>{{.MeanTime}}</td><td>{{.CorruptWins}}</td></tr>
+	{{end}}
+</table>
+<h2>Charts</h2>
+<div class="charts">
+	{{range .Charts}}<div>{{.}}</div>
+	{{end}}
+</div>
+<h2>Results</h2>
+<div class="scroll">
+<table>
+	<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+	{{range .Table}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+	{{end}}
+</table>
+</div>
+</body>
+</html>
+`))
+
+// --- Plots ---
+
+/*
+	With -plots <dir> the tester also writes charts of the results file to dir as SVG once the suite
+	stops, for sweeps that vary one parameter: corrupt-wins.svg is the share of the simulations of each
+	type the corrupt nodes won against corrupt %, latency-p.svg the mean confirmation latency (p50)
+	against the broadcast probability p, confirmed-corrupt.svg txConfirmed % against corrupt %, and
+	time-difficulty.svg the time against difficulty. The lines join the means of the simulations with
+	the same x. SVG is written directly, no plotting library; a browser or e.g. rsvg-convert turns it
+	into PNG.
+*/
+
+// plots returns the charts of -plots by file name
+func (t resultTable) plots() map[string]chart {
+	winners := t.column("Winner")
+	return map[string]chart{
+		"corrupt-wins.svg": t.mean("Corrupt win rate vs corrupt fraction", "Corrupt %", "Corrupt wins (%)", func(i int) (float64, bool) {
+			if winners[i] == "" {
+				return 0, false
+			}
+			if winners[i] == "corrupt" {
+				return 100, true
+			}
+			return 0, true
+		}),
+		"latency-p.svg": t.mean("Confirmation latency vs p", "Broadcast Probability", "Latency p50 (s)", func(i int) (float64, bool) {
+			return t.number("Latency p50 (s)", i)
+		}),
+		"confirmed-corrupt.svg": t.mean("txConfirmed % vs corrupt fraction", "Corrupt %", "txConfirmed %", func(i int) (float64, bool) {
+			return t.number("txConfirmed %", i)
+		}),
+		"time-difficulty.svg": t.mean("Duration vs difficulty", "Difficulty", "Time (s)", func(i int) (float64, bool) {
+			return t.number("Time (s)", i)
+		}),
+	}
+}
+
+// writePlots writes the charts of the results file csvPath to dir
+func writePlots(dir, csvPath string) error {
+	t, err := readResults(csvPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, c := range t.plots() {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(c.svg(640, 360)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
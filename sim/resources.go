@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof on http.DefaultServeMux
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Resource Usage ---
+
+/*
+	The tester measures what every simulation costs the process: the peak heap (live heap objects), peak
+	RSS and peak goroutine count, sampled every resourceSample, and the bytes and objects allocated, GC
+	cycles and stop-the-world pauses from runtime.MemStats before and after. RSS is read from
+	/proc/self/statm; where there is none it is the memory the Go runtime holds from the OS instead,
+	which leaves out what the OS swapped out but is otherwise close. The runtime returns memory to the OS
+	lazily, so a peak RSS may include what an earlier simulation left behind; heap and allocations do
+	not. The suite runs one simulation at a
+	time, so the process's numbers are the simulation's, plus the tester's own small share.
+
+	With -pprof <addr> the tester also serves the net/http/pprof endpoints at addr/debug/pprof while the
+	suite runs, e.g. go tool pprof http://localhost:6060/debug/pprof/heap during a large-N run.
+*/
+
+const resourceSample = 5 * time.Millisecond
+
+// ResourceStats is what a simulation cost the process, set by the tester
+type ResourceStats struct {
+	PeakHeap       uint64 // bytes
+	PeakRSS        uint64 // bytes
+	PeakGoroutines int
+	Allocated      uint64 // bytes
+	Allocations    uint64 // objects
+	GCCycles       int
+	GCPause        time.Duration // total
+	MaxGCPause     time.Duration
+}
+
+// sampleResources starts measuring, the returned function stops and reports
+func sampleResources() func() ResourceStats {
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}, {Name: "/sched/goroutines:goroutines"},
+		{Name: "/memory/classes/total:bytes"}, {Name: "/memory/classes/heap/released:bytes"}}
+	stop, done := make(chan struct{}), make(chan ResourceStats)
+	go func() {
+		var stats ResourceStats
+		ticker := time.NewTicker(resourceSample)
+		defer ticker.Stop()
+		for {
+			metrics.Read(samples)
+			stats.PeakHeap = max(stats.PeakHeap, samples[0].Value.Uint64())
+			stats.PeakGoroutines = max(stats.PeakGoroutines, int(samples[1].Value.Uint64())-1) // not the sampler
+			rss, ok := readRSS()
+			if !ok {
+				rss = samples[2].Value.Uint64() - samples[3].Value.Uint64()
+			}
+			stats.PeakRSS = max(stats.PeakRSS, rss)
+			select {
+			case <-ticker.C:
+			case <-stop:
+				done <- stats
+				return
+			}
+		}
+	}()
+	return func() ResourceStats {
+		close(stop)
+		stats := <-done
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		stats.Allocated, stats.Allocations = after.TotalAlloc-before.TotalAlloc, after.Mallocs-before.Mallocs
+		stats.GCCycles = int(after.NumGC - before.NumGC)
+		stats.GCPause = time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+		for k := range min(stats.GCCycles, len(after.PauseNs)) { // the ring holds the last 256 pauses
+			stats.MaxGCPause = max(stats.MaxGCPause, time.Duration(after.PauseNs[(int(after.NumGC)-1-k+len(after.PauseNs))%len(after.PauseNs)]))
+		}
+		return stats
+	}
+}
+
+// readRSS returns the resident set size of the process, false if the OS does not tell
+func readRSS() (uint64, bool) {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	return pages * uint64(os.Getpagesize()), err == nil
+}
+
+// servePprof serves the pprof endpoints at addr until ctx is done
+func servePprof(ctx context.Context, log *slog.Logger, addr string) {
+	server := &http.Server{Addr: addr, Handler: http.DefaultServeMux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	go func() {
+		log.Info("serving pprof", "url", "http://"+addr+"/debug/pprof/")
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error("pprof server failed", "addr", addr, "err", err)
+		}
+	}()
+}
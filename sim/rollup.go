@@ -0,0 +1,240 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Rollup ---
+
+/*
+	The rollup mode runs the PoW simulation as the L1 of a rollup. The users' transactions no longer go
+	to the miners: a sequencer node collects every round of them and posts a single commitment
+	transaction carrying the batch, which every L1 node mines like any other transaction. A user
+	transaction is confirmed once a commitment with it is in the winning chain, so txConfirmed, latency
+	and Confirmed TPS are the rollup's effective ones, and Compression is how many of them the winning
+	chain settled per L1 transaction.
+
+	The sequencer is honest1, unless SimConfig.Sequencer makes it corrupt (corrupt1):
+	- censor[:f]: it leaves out every honest user's transaction with probability f (default 1). The users
+	  notice, and force their transactions onto L1 directly with the next round, like the delayed inbox
+	  of Arbitrum or Optimism, so censoring only delays them, at the cost of one L1 transaction each.
+	- withhold[:f]: it posts a batch's commitment but withholds its data with probability f (default
+	  0.5). L1 accepts the commitment, but nobody can rebuild the state it commits to, so its
+	  transactions never settle, and the winning chain settles nothing for them.
+
+	Commitments take IDs from rollupTxIDs up, apart from the workload's (from 1), the spam's and the
+	channels' (below 0).
+*/
+
+// SimulateRollupCtx runs the PoW simulation as the L1 of a rollup, see SimConfig.Sequencer
+func SimulateRollupCtx(ctx context.Context, cfg SimConfig) SimResult {
+	cfg.Rollup = true
+	return SimulateBlockchainCtx(ctx, cfg)
+}
+
+// RollupStats reports the rollup mode
+type RollupStats struct {
+	Sequencer   string  // honest, censor or withhold
+	Batches     int     // commitments posted
+	Committed   int     // user transactions in them
+	Censored    int     // user transactions the sequencer left out
+	Forced      int     // censored transactions forced onto L1
+	Withheld    int     // commitments posted without their data
+	Lost        int     // user transactions in those
+	L1Txs       int     // distinct transactions of the winning chain, commitments included
+	Compression float64 // user transactions the winning chain settled per L1 transaction
+}
+
+// parseSequencer returns the behavior of spec and its probability
+func parseSequencer(spec string) (string, float64, error) {
+	name, arg, found := strings.Cut(spec, ":")
+	defaults := map[string]float64{"": 0, "honest": 0, "censor": 1, "withhold": 0.5}
+	f, ok := defaults[name]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown sequencer %q (honest, censor[:f] or withhold[:f])", spec)
+	}
+	if found {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil || f == 0 || v < 0 || v > 1 {
+			return "", 0, fmt.Errorf("sequencer %q: %q is not a probability", spec, arg)
+		}
+		f = v
+	}
+	if name == "" {
+		name = "honest"
+	}
+	return name, f, nil
+}
+
+// rollup is the sequencer of a rollup simulation, nil outside the rollup mode. Only the goroutine
+// sending the transactions touches it until the result.
+type rollup struct {
+	behavior string
+	rate     float64
+	name     string // the sequencer node
+	C        int    // corrupt nodes
+	next     TxID   // ID of the next commitment
+	batches  map[TxID][]Transaction
+	withheld map[TxID]bool
+	censored int
+	forced   int
+}
+
+func newRollup(cfg SimConfig, N, C int) *rollup {
+	if !cfg.Rollup {
+		return nil
+	}
+	behavior, rate, err := parseSequencer(cfg.Sequencer)
+	if err != nil || C == 0 { // no corrupt node to run a corrupt sequencer
+		behavior, rate = "honest", 0
+	}
+	r := &rollup{behavior: behavior, rate: rate, name: nodeName(C, C), C: C, next: rollupTxIDs, batches: make(map[TxID][]Transaction), withheld: make(map[TxID]bool)}
+	if behavior != "honest" {
+		r.name = nodeName(0, C)
+	}
+	return r
+}
+
+// commit batches the users' transactions of a round, returning the commitment (none for an empty
+// batch) and the transactions it censored
+func (r *rollup) commit(txs []Transaction) ([]Transaction, []Transaction) {
+	batch, censored := []Transaction{}, []Transaction{}
+	for _, tx := range txs {
+		if r.behavior == "censor" && getLabel(nodeIndex(tx.Sender, r.C), r.C) == "honest" && rand.Float64() < r.rate {
+			censored = append(censored, tx)
+		} else {
+			batch = append(batch, tx)
+		}
+	}
+	r.censored += len(censored)
+	if len(batch) == 0 {
+		return nil, censored
+	}
+	commitment := Transaction{TxID: r.next, Sender: r.name, Receiver: "rollup", Batch: batch}
+	r.next++
+	r.batches[commitment.TxID] = batch
+	if r.behavior == "withhold" && rand.Float64() < r.rate { // only the commitment, not the data
+		commitment.Batch = nil
+		r.withheld[commitment.TxID] = true
+	}
+	return []Transaction{commitment}, censored
+}
+
+// send replaces SendTransactions in the rollup mode: it announces the users' transactions of R rounds
+// of w as they reach the sequencer, and hands every node the round's commitment and the transactions
+// censored the round before
+func (r *rollup) send(ctx context.Context, N, R int, inboxes []chan []Transaction, w Workload, bus *EventBus, split func(node int, txs []Transaction) []Transaction) (txSent int) {
+	defer func() {
+		for i := range N {
+			close(inboxes[i])
+		}
+	}()
+	start := time.Now()
+	arrivals, _ := w.(arrivalWorkload)
+	deliver := func(l1 []Transaction) bool {
+		for i := range N {
+			txs := l1
+			if split != nil {
+				txs = split(i, txs)
+			}
+			select {
+			case inboxes[i] <- txs:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+	forced := []Transaction{}
+	for round := range R {
+		batch := w.Next(round)
+		if arrivals != nil && len(batch) > 0 { // the sequencer commits once the last one arrived
+			select {
+			case <-time.After(arrivals.arrival(batch[len(batch)-1]) - time.Since(start)):
+			case <-ctx.Done():
+				return txSent
+			}
+		}
+		for _, tx := range batch {
+			bus.Publish(Event{Kind: EventSent, Tx: tx})
+		}
+		txSent += len(batch)
+		commitment, censored := r.commit(batch)
+		l1 := append(forced, commitment...)
+		r.forced += len(forced)
+		forced = censored
+		if len(l1) > 0 && !deliver(l1) {
+			return txSent
+		}
+	}
+	if len(forced) > 0 { // censored in the last round
+		r.forced += len(forced)
+		deliver(forced)
+	}
+	return txSent
+}
+
+// settled returns chain as the users see it: every commitment replaced by the transactions it settles,
+// none if it withheld them
+func (r *rollup) settled(chain []Block) []Block {
+	if r == nil {
+		return chain
+	}
+	out := make([]Block, len(chain))
+	for k, b := range chain {
+		txs := []Transaction{}
+		for _, tx := range b.Transactions {
+			if _, ok := r.batches[tx.TxID]; ok {
+				txs = append(txs, tx.Batch...)
+			} else {
+				txs = append(txs, tx)
+			}
+		}
+		b.Transactions = txs
+		out[k] = b
+	}
+	return out
+}
+
+func (r *rollup) result(winner []Block, settled int) RollupStats {
+	if r == nil {
+		return RollupStats{}
+	}
+	stats := RollupStats{Sequencer: r.behavior, Batches: len(r.batches), Censored: r.censored, Forced: r.forced, Withheld: len(r.withheld)}
+	for id, batch := range r.batches {
+		stats.Committed += len(batch)
+		if r.withheld[id] {
+			stats.Lost += len(batch)
+		}
+	}
+	seen := make(map[TxID]bool)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			if !seen[tx.TxID] {
+				seen[tx.TxID] = true
+				stats.L1Txs++
+			}
+		}
+	}
+	if stats.L1Txs > 0 {
+		stats.Compression = float64(settled) / float64(stats.L1Txs)
+	}
+	return stats
+}
+
+func printRollupStats(stats RollupStats) {
+	fmt.Println("Sequencer          =", stats.Sequencer)
+	fmt.Println("Rollup batches     =", stats.Batches)
+	fmt.Println("Rollup committed   =", stats.Committed)
+	fmt.Println("Rollup censored    =", stats.Censored)
+	fmt.Println("Rollup forced      =", stats.Forced)
+	fmt.Println("Rollup withheld    =", stats.Withheld)
+	fmt.Println("Rollup lost        =", stats.Lost)
+	fmt.Println("L1 transactions    =", stats.L1Txs)
+	fmt.Printf("Compression        = %.2f\n", stats.Compression)
+}
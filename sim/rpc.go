@@ -0,0 +1,341 @@
+package sim
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// --- JSON-RPC ---
+
+/*
+	-rpc <addr> serves a minimal Ethereum-style JSON-RPC 2.0 API on the running PoW simulation, so web3
+	tooling can be pointed at the simulator for demos (chain ID 1337):
+	- eth_chainId, net_version;
+	- eth_accounts: an address per node of the simulation, "0x" and the first 20 bytes of the SHA-256 of
+	  its name;
+	- eth_blockNumber: the height of the longest chain any node has;
+	- eth_getBlockByNumber [tag, full]: a block of that chain by height, "latest", "earliest" or
+	  "pending" (the tip), with its transactions as hashes or, if full, objects;
+	- eth_sendTransaction [{from, to, value}]: injects a transaction like the console's tx (see repl.go),
+	  from and to are addresses or node names and value is in wei (1e18 to a unit of Amount); it returns
+	  the transaction's hash;
+	- eth_getTransactionReceipt [hash]: the block a transaction sent so went into, null while pending.
+	The chain is that of the last PoW simulation, kept until the next one starts; -hold keeps a
+	simulation taking transactions. Hashes are the simulator's with 0x, a transaction's hash is its TxID in
+	32 bytes. Blocks carry no gas, state or receipts root, those fields are zeros.
+*/
+
+// rpcChainID is the chain ID of the JSON-RPC API, that of local development chains
+const rpcChainID = 1337
+
+// rpcRequest is a JSON-RPC 2.0 request
+type rpcRequest struct {
+	JSONRPC string
+	ID      json.RawMessage
+	Method  string
+	Params  json.RawMessage
+}
+
+// rpcResponse is a JSON-RPC 2.0 response, Result or Error set
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ethAddress is the address of a node
+func ethAddress(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "0x" + hex.EncodeToString(sum[:20])
+}
+
+// ethHash is a hash of the simulator as Ethereum writes it
+func ethHash(hash string) string {
+	return "0x" + hash
+}
+
+// ethTxHash is the hash of the transaction of an ID
+func ethTxHash(id TxID) string {
+	return fmt.Sprintf("0x%064x", uint64(id))
+}
+
+func ethQuantity(n int64) string {
+	return "0x" + strconv.FormatInt(n, 16)
+}
+
+// ethTx is a transaction object of eth_getBlockByNumber
+func ethTx(tx Transaction, b Block, height, index int) map[string]any {
+	return map[string]any{
+		"hash":             ethTxHash(tx.TxID),
+		"from":             ethAddress(tx.Sender),
+		"to":               ethAddress(tx.Receiver),
+		"value":            "0x" + ethWei(tx.Amount).Text(16),
+		"blockHash":        ethHash(b.Hash),
+		"blockNumber":      ethQuantity(int64(height)),
+		"transactionIndex": ethQuantity(int64(index)),
+		"nonce":            ethQuantity(int64(tx.Nonce)),
+		"gas":              "0x0",
+		"gasPrice":         "0x0",
+		"input":            "0x",
+	}
+}
+
+// ethWei is a in wei, 1e9 to a minor unit
+func ethWei(a Amount) *big.Int {
+	return new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(1e18/amountScale))
+}
+
+// ethBlock is a block object of eth_getBlockByNumber
+func ethBlock(b Block, height int, full bool) map[string]any {
+	txs := []any{}
+	for k, tx := range b.Transactions {
+		if full {
+			txs = append(txs, ethTx(tx, b, height, k))
+		} else {
+			txs = append(txs, ethTxHash(tx.TxID))
+		}
+	}
+	zero := "0x" + strings.Repeat("0", 64)
+	parent := zero
+	if b.PrevHash != "" {
+		parent = ethHash(b.PrevHash)
+	}
+	miner := "0x" + strings.Repeat("0", 40)
+	if b.Miner != "" {
+		miner = ethAddress(b.Miner)
+	}
+	return map[string]any{
+		"number":           ethQuantity(int64(height)),
+		"hash":             ethHash(b.Hash),
+		"parentHash":       parent,
+		"nonce":            fmt.Sprintf("0x%016x", uint64(b.Nonce)),
+		"miner":            miner,
+		"difficulty":       ethQuantity(int64(b.Difficulty)),
+		"timestamp":        ethQuantity(b.Timestamp / 1e9),
+		"transactions":     txs,
+		"uncles":           []string{},
+		"sha3Uncles":       zero,
+		"stateRoot":        zero,
+		"transactionsRoot": zero,
+		"receiptsRoot":     zero,
+		"gasLimit":         "0x0",
+		"gasUsed":          "0x0",
+		"extraData":        "0x",
+		"size":             "0x0",
+	}
+}
+
+// blockAt returns the block at height of the chain ending at the tip; c.mu held
+func (c *console) blockAt(height int) (Block, bool) {
+	if c.blocks == nil || height < 0 || height > c.heights[c.tip] {
+		return Block{}, false
+	}
+	if height == 0 {
+		return c.genesis, true
+	}
+	b := c.blocks[c.tip]
+	for c.heights[b.Hash] > height {
+		b = c.blocks[b.PrevHash]
+	}
+	return b, true
+}
+
+// rpcErr is an error with its JSON-RPC code
+type rpcErr struct {
+	code int
+	err  error
+}
+
+func (e rpcErr) Error() string { return e.err.Error() }
+
+func invalidParams(format string, args ...any) error {
+	return rpcErr{-32602, fmt.Errorf(format, args...)}
+}
+
+// call runs a JSON-RPC method of the console
+func (c *console) call(method string, params json.RawMessage) (any, error) {
+	var args []json.RawMessage
+	if len(params) > 0 && json.Unmarshal(params, &args) != nil {
+		return nil, invalidParams("params must be an array")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch method {
+	case "eth_chainId":
+		return ethQuantity(rpcChainID), nil
+	case "net_version":
+		return strconv.Itoa(rpcChainID), nil
+	case "eth_accounts":
+		accounts := []string{}
+		if c.target != nil {
+			for _, name := range c.target.names {
+				accounts = append(accounts, ethAddress(name))
+			}
+		}
+		return accounts, nil
+	case "eth_blockNumber":
+		return ethQuantity(int64(c.heights[c.tip])), nil
+	case "eth_getBlockByNumber":
+		var tag string
+		var full bool
+		if len(args) < 1 || json.Unmarshal(args[0], &tag) != nil || (len(args) > 1 && json.Unmarshal(args[1], &full) != nil) {
+			return nil, invalidParams("expected [block number or tag, full transactions]")
+		}
+		height := c.heights[c.tip]
+		switch tag {
+		case "latest", "pending", "safe", "finalized":
+		case "earliest":
+			height = 0
+		default:
+			n, err := strconv.ParseInt(strings.TrimPrefix(tag, "0x"), 16, 64)
+			if err != nil || !strings.HasPrefix(tag, "0x") {
+				return nil, invalidParams("bad block number %q", tag)
+			}
+			height = int(n)
+		}
+		b, ok := c.blockAt(height)
+		if !ok {
+			return nil, nil // JSON null, as for a block not mined yet
+		}
+		return ethBlock(b, height, full), nil
+	case "eth_sendTransaction":
+		var tx struct{ From, To, Value string }
+		if len(args) < 1 || json.Unmarshal(args[0], &tx) != nil {
+			return nil, invalidParams("expected [{from, to, value}]")
+		}
+		var value Amount
+		if tx.Value != "" {
+			wei, ok := new(big.Int).SetString(strings.TrimPrefix(tx.Value, "0x"), 16)
+			if !ok || !strings.HasPrefix(tx.Value, "0x") {
+				return nil, invalidParams("bad value %q", tx.Value)
+			}
+			units := wei.Quo(wei, ethWei(1)) // wei below a minor unit are dropped
+			if !units.IsInt64() {
+				return nil, invalidParams("value %q is too large", tx.Value)
+			}
+			value = Amount(units.Int64())
+		}
+		sent, err := c.inject(c.rpcNode(tx.From), c.rpcNode(tx.To), value)
+		if err != nil {
+			return nil, err
+		}
+		return ethTxHash(TxID(sent.ID)), nil
+	case "eth_getTransactionReceipt":
+		var hash string
+		if len(args) < 1 || json.Unmarshal(args[0], &hash) != nil {
+			return nil, invalidParams("expected [transaction hash]")
+		}
+		bits, err := strconv.ParseUint(strings.TrimPrefix(hash, "0x"), 16, 64)
+		tx := c.txs[TxID(bits)]
+		if err != nil || tx == nil || tx.Block == "" {
+			return nil, nil
+		}
+		status := "0x1"
+		if tx.Status == "dropped" {
+			status = "0x0"
+		}
+		return map[string]any{
+			"transactionHash":   hash,
+			"blockHash":         ethHash(tx.Block),
+			"blockNumber":       ethQuantity(int64(tx.Height)),
+			"from":              ethAddress(tx.From),
+			"to":                ethAddress(tx.To),
+			"status":            status,
+			"gasUsed":           "0x0",
+			"cumulativeGasUsed": "0x0",
+			"logs":              []any{},
+		}, nil
+	}
+	return nil, rpcErr{-32601, fmt.Errorf("method %s not found", method)}
+}
+
+// rpcNode returns the name of the node of an address, or s if none has it; c.mu held
+func (c *console) rpcNode(s string) string {
+	if c.target == nil {
+		return s
+	}
+	for _, name := range c.target.names {
+		if strings.EqualFold(ethAddress(name), s) {
+			return name
+		}
+	}
+	return s
+}
+
+// serveRPC serves the JSON-RPC API of c at addr until ctx is done
+func serveRPC(ctx context.Context, log *slog.Logger, addr string, c *console) {
+	handle := func(req rpcRequest) rpcResponse {
+		res := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		if req.ID == nil {
+			res.ID = json.RawMessage("null")
+		}
+		result, err := c.call(req.Method, req.Params)
+		var coded rpcErr
+		switch {
+		case errors.As(err, &coded):
+			res.Error = &rpcError{coded.code, coded.Error()}
+		case err != nil:
+			res.Error = &rpcError{-32000, err.Error()}
+		case result == nil:
+			res.Result = json.RawMessage("null")
+		default:
+			res.Result = result
+		}
+		return res
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{-32700, "parse error: " + err.Error()}})
+			return
+		}
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) { // a batch
+			var reqs []rpcRequest
+			if err := json.Unmarshal(body, &reqs); err != nil || len(reqs) == 0 {
+				json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{-32600, "invalid batch"}})
+				return
+			}
+			responses := []rpcResponse{}
+			for _, req := range reqs {
+				responses = append(responses, handle(req))
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil || req.Method == "" {
+			json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{-32600, "invalid request"}})
+			return
+		}
+		json.NewEncoder(w).Encode(handle(req))
+	})
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	go func() {
+		log.Info("serving JSON-RPC", "url", "http://"+addr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error("JSON-RPC server failed", "addr", addr, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,131 @@
+package sim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+)
+
+// --- Adversarial Scheduler ---
+
+/*
+	With SimConfig.Schedule the adversary controls the network of the BFT simulation: every message
+	between two different nodes is held back for a delay the adversary picks, at most Delta, instead of
+	going straight to the receiver's mailbox. Delays also reorder messages. The schedules are
+
+	- random: a pseudo-random delay up to Delta per message.
+	- split: the honest nodes are split into two halves (even and odd node numbers) and every message
+	  between the halves takes Delta, while messages within a half and from corrupt nodes are instant.
+	- proposals: every proposal takes Delta and every vote is instant, so validators see the votes for
+	  a block before the block.
+
+	The delay of a message depends only on the ScheduleSeed and on who sends what to whom (sender,
+	receiver, height, round and step), not on the goroutine interleaving, so a schedule that broke a
+	run can be replayed with the same seed. Every delay is bounded, so the network is partially
+	synchronous: the step timeouts grow with the round until they outlast Delta, after which the
+	nodes can decide again. Safety must hold whatever the schedule.
+*/
+
+// Adversarial schedules of the BFT network (SimConfig.Schedule)
+const (
+	ScheduleRandom    = "random"
+	ScheduleSplit     = "split"
+	ScheduleProposals = "proposals"
+)
+
+// ScheduleStats reports what the adversarial scheduler did to the messages
+type ScheduleStats struct {
+	Schedule string
+	Delta    time.Duration
+	Seed     uint64
+	Messages int           // messages between two different nodes
+	Delayed  int           // messages held back
+	AvgDelay time.Duration // over all messages
+}
+
+// scheduler delays the messages of a simulation
+type scheduler struct {
+	schedule string
+	delta    time.Duration
+	seed     uint64
+	C        int
+	messages atomic.Int64
+	delayed  atomic.Int64
+	total    atomic.Int64 // sum of the delays in ns
+}
+
+// newScheduler returns nil without a schedule, the messages then go straight to the mailboxes
+func newScheduler(cfg SimConfig) *scheduler {
+	if cfg.Schedule == "" || cfg.Delta <= 0 {
+		return nil
+	}
+	return &scheduler{schedule: cfg.Schedule, delta: cfg.Delta, seed: cfg.ScheduleSeed, C: cfg.C}
+}
+
+// fraction is a number in [0, 1) that depends only on the seed and the message
+func (s *scheduler) fraction(from, to int, m bftMsg) float64 {
+	h := fnv.New64a()
+	for _, v := range []uint64{s.seed, uint64(from), uint64(to), uint64(m.Height), uint64(m.Round), uint64(m.Step)} {
+		h.Write(binary.LittleEndian.AppendUint64(nil, v))
+	}
+	return float64(h.Sum64()>>11) / (1 << 53)
+}
+
+// delay is how long the adversary holds m back on its way from node from to node to
+func (s *scheduler) delay(from, to int, m bftMsg) time.Duration {
+	switch s.schedule {
+	case ScheduleRandom:
+		return time.Duration(s.fraction(from, to, m) * float64(s.delta))
+	case ScheduleSplit:
+		if from >= s.C && to >= s.C && from%2 != to%2 {
+			return s.delta
+		}
+	case ScheduleProposals:
+		if m.Step == bftPropose {
+			return s.delta
+		}
+	}
+	return 0
+}
+
+// deliver calls send once m's delay has passed
+func (s *scheduler) deliver(from, to int, m bftMsg, send func()) {
+	if from == to {
+		send()
+		return
+	}
+	d := s.delay(from, to, m)
+	s.messages.Add(1)
+	s.total.Add(int64(d))
+	if d <= 0 {
+		send()
+		return
+	}
+	s.delayed.Add(1)
+	time.AfterFunc(d, send)
+}
+
+func (s *scheduler) result() ScheduleStats {
+	stats := ScheduleStats{
+		Schedule: s.schedule,
+		Delta:    s.delta,
+		Seed:     s.seed,
+		Messages: int(s.messages.Load()),
+		Delayed:  int(s.delayed.Load()),
+	}
+	if stats.Messages > 0 {
+		stats.AvgDelay = time.Duration(s.total.Load() / int64(stats.Messages))
+	}
+	return stats
+}
+
+func printScheduleStats(stats ScheduleStats) {
+	fmt.Println("Schedule           =", stats.Schedule)
+	fmt.Println("Delta              =", stats.Delta)
+	fmt.Println("Schedule seed      =", stats.Seed)
+	fmt.Println("Messages           =", stats.Messages)
+	fmt.Println("Delayed messages   =", stats.Delayed)
+	fmt.Println("Avg delay          =", stats.AvgDelay)
+}
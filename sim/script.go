@@ -0,0 +1,349 @@
+package sim
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Scripts ---
+
+/*
+	With SimConfig.Script every PoW transaction carries a tiny stack-based script that miners evaluate
+	before they include it, and that nodes evaluate again for every block they receive, so the cost of
+	validation shows in the block interval and the throughput. The spec is a comma separated list of
+	parts run one after the other, each a template or raw ops:
+	- multisig:<m>-of-<n>: m of n keys must sign, the sender's and those of the next n-1 nodes of its
+	  side. The sender's wallet collects the signatures of the first m.
+	- timelock:<h>: the transaction is not final before block height h. Miners keep it pending, and
+	  mine an empty block if nothing is final yet, so the chain gets there.
+	- hashes:<k>: k rounds of SHA-256, a knob for the validation cost.
+	- raw ops separated by spaces, e.g. "SENDER SIGNED VERIFY".
+
+	The ops work on a stack of strings, and anything that is not an op pushes itself. SENDER, RECEIVER
+	and HEIGHT push the sender, the receiver and the height of the block being validated. DUP, DROP,
+	EQUAL, ADD and VERIFY work as in Bitcoin. SIGNED replaces a name by 1 if it signed the transaction
+	(the sender always does), 0 if not. CHECKMULTISIGVERIFY pops n, n names and m, and fails unless m
+	of them signed. CHECKLOCKTIMEVERIFY pops h, and defers the transaction before height h. HASHES
+	pops k and hashes k times. A script passes if it does not fail and leaves no 0 on top of the stack,
+	within scriptMaxSteps ops (every round of HASHES counts).
+
+	Miners include the pending transactions in order up to the first one that is not final, drop the
+	ones that fail, and nodes reject received blocks with a transaction that does not pass. The spam
+	carries no script.
+*/
+
+// scriptMaxSteps bounds the ops a script runs
+const scriptMaxSteps = 1_000_000
+
+// ScriptStats reports the script validation of a PoW simulation
+type ScriptStats struct {
+	Spec     string
+	Runs     int           // scripts evaluated, over all nodes
+	Steps    int           // ops they ran
+	Failed   int           // pending transactions dropped because their script failed
+	Locked   int           // times a miner left a transaction out because it was not final yet
+	Rejected int           // received blocks rejected for a script that does not pass
+	Time     time.Duration // spent evaluating, over all nodes
+}
+
+// scriptVerdict is the outcome of a script
+type scriptVerdict int
+
+const (
+	scriptPassed scriptVerdict = iota
+	scriptFailed
+	scriptLocked // not final at this height
+)
+
+// scriptTemplate returns the ops of a part of the script of tx, and the keys that sign for it
+type scriptTemplate func(tx Transaction, N, C int) (ops, signers []string)
+
+// parseScript returns the templates of spec, nil for ""
+func parseScript(spec string) ([]scriptTemplate, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	templates := []scriptTemplate{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		name, arg, found := strings.Cut(part, ":")
+		if !found {
+			ops := strings.Fields(part)
+			if len(ops) == 0 {
+				return nil, fmt.Errorf("script %q: empty part", spec)
+			}
+			templates = append(templates, func(Transaction, int, int) ([]string, []string) { return ops, nil })
+			continue
+		}
+		switch name {
+		case "multisig":
+			ms, ns, _ := strings.Cut(arg, "-of-")
+			m, errM := strconv.Atoi(ms)
+			n, errN := strconv.Atoi(ns)
+			if errM != nil || errN != nil || m < 1 || n < m {
+				return nil, fmt.Errorf("script %q: multisig needs <m>-of-<n> with 1 <= m <= n", spec)
+			}
+			templates = append(templates, func(tx Transaction, N, C int) ([]string, []string) {
+				keys := multisigKeys(tx.Sender, n, N, C)
+				return slices.Concat([]string{strconv.Itoa(m)}, keys, []string{strconv.Itoa(n), "CHECKMULTISIGVERIFY"}), keys[:m]
+			})
+		case "timelock", "hashes":
+			v, err := strconv.Atoi(arg)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("script %q: %s needs a count of at least 0", spec, name)
+			}
+			op := map[string]string{"timelock": "CHECKLOCKTIMEVERIFY", "hashes": "HASHES"}[name]
+			templates = append(templates, func(Transaction, int, int) ([]string, []string) { return []string{arg, op}, nil })
+		default:
+			return nil, fmt.Errorf("unknown script %q (multisig:<m>-of-<n>, timelock:<h>, hashes:<k> or ops)", part)
+		}
+	}
+	return templates, nil
+}
+
+// multisigKeys returns the names of sender and the next n-1 nodes of its side, of an N, C simulation
+func multisigKeys(sender string, n, N, C int) []string {
+	first, size := C, N-C // the sides are 0 .. C-1 and C .. N-1
+	if getLabel(nodeIndex(sender, C), C) == "corrupt" {
+		first, size = 0, C
+	}
+	keys := []string{}
+	for j := range n {
+		keys = append(keys, nodeName(first+(nodeIndex(sender, C)-first+j)%size, C))
+	}
+	return keys
+}
+
+// runScript evaluates the script of tx in a block at height, and returns the verdict and the ops it ran
+func runScript(tx Transaction, height int) (scriptVerdict, int) {
+	stack := []string{}
+	pop := func() (string, bool) {
+		if len(stack) == 0 {
+			return "", false
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return top, true
+	}
+	popInt := func() (int, bool) {
+		top, ok := pop()
+		v, err := strconv.Atoi(top)
+		return v, ok && err == nil
+	}
+	truth := func(ok bool) string {
+		if ok {
+			return "1"
+		}
+		return "0"
+	}
+	signed := func(name string) bool { return name == tx.Sender || slices.Contains(tx.Witness, name) }
+	steps := 0
+	for _, op := range tx.Script {
+		if steps++; steps > scriptMaxSteps {
+			return scriptFailed, steps
+		}
+		switch op {
+		case "SENDER":
+			stack = append(stack, tx.Sender)
+		case "RECEIVER":
+			stack = append(stack, tx.Receiver)
+		case "HEIGHT":
+			stack = append(stack, strconv.Itoa(height))
+		case "DUP":
+			top, ok := pop()
+			if !ok {
+				return scriptFailed, steps
+			}
+			stack = append(stack, top, top)
+		case "DROP":
+			if _, ok := pop(); !ok {
+				return scriptFailed, steps
+			}
+		case "EQUAL":
+			a, okA := pop()
+			b, okB := pop()
+			if !okA || !okB {
+				return scriptFailed, steps
+			}
+			stack = append(stack, truth(a == b))
+		case "ADD":
+			a, okA := popInt()
+			b, okB := popInt()
+			if !okA || !okB {
+				return scriptFailed, steps
+			}
+			stack = append(stack, strconv.Itoa(a+b))
+		case "VERIFY":
+			top, ok := pop()
+			if !ok || top == "0" || top == "" {
+				return scriptFailed, steps
+			}
+		case "SIGNED":
+			name, ok := pop()
+			if !ok {
+				return scriptFailed, steps
+			}
+			stack = append(stack, truth(signed(name)))
+		case "CHECKMULTISIGVERIFY":
+			n, ok := popInt()
+			if !ok || n < 0 || n > len(stack) {
+				return scriptFailed, steps
+			}
+			keys := slices.Clone(stack[len(stack)-n:])
+			stack = stack[:len(stack)-n]
+			m, ok := popInt()
+			if !ok {
+				return scriptFailed, steps
+			}
+			for _, key := range keys {
+				if signed(key) {
+					m--
+				}
+			}
+			if m > 0 {
+				return scriptFailed, steps
+			}
+		case "CHECKLOCKTIMEVERIFY":
+			h, ok := popInt()
+			if !ok {
+				return scriptFailed, steps
+			}
+			if height < h {
+				return scriptLocked, steps
+			}
+		case "HASHES":
+			k, ok := popInt()
+			if !ok || k < 0 || steps+k > scriptMaxSteps {
+				return scriptFailed, steps
+			}
+			steps += k
+			digest := sha256.Sum256([]byte(tx.TxID.String()))
+			for range k {
+				digest = sha256.Sum256(digest[:])
+			}
+		default:
+			stack = append(stack, op)
+		}
+	}
+	if len(stack) > 0 && (stack[len(stack)-1] == "0" || stack[len(stack)-1] == "") {
+		return scriptFailed, steps
+	}
+	return scriptPassed, steps
+}
+
+// scriptEngine attaches and evaluates the scripts of a simulation, nil without SimConfig.Script
+type scriptEngine struct {
+	templates []scriptTemplate
+	N, C      int
+
+	mu    sync.Mutex
+	stats ScriptStats
+}
+
+func newScriptEngine(cfg SimConfig, N, C int) *scriptEngine {
+	templates, err := parseScript(cfg.Script)
+	if err != nil || templates == nil {
+		return nil
+	}
+	return &scriptEngine{templates: templates, N: N, C: C, stats: ScriptStats{Spec: cfg.Script}}
+}
+
+// attach is a split hook for SendTransactions: it gives every transaction its script and witness
+func (s *scriptEngine) attach(node int, txs []Transaction) []Transaction {
+	if s == nil {
+		return txs
+	}
+	out := make([]Transaction, len(txs))
+	for k, tx := range txs {
+		tx.Script, tx.Witness = nil, nil
+		for _, template := range s.templates {
+			ops, signers := template(tx, s.N, s.C)
+			tx.Script = append(tx.Script, ops...)
+			tx.Witness = append(tx.Witness, signers...)
+		}
+		out[k] = tx
+	}
+	return out
+}
+
+// run evaluates tx at height and counts it
+func (s *scriptEngine) run(tx Transaction, height int) scriptVerdict {
+	start := time.Now()
+	verdict, steps := runScript(tx, height)
+	s.mu.Lock()
+	s.stats.Runs++
+	s.stats.Steps += steps
+	s.stats.Time += time.Since(start)
+	s.mu.Unlock()
+	return verdict
+}
+
+// ready drops the pending transactions whose script fails in a block at height, and returns the rest
+// with how many of them, from the start, are final
+func (s *scriptEngine) ready(pending []Transaction, height int) ([]Transaction, int) {
+	if s == nil {
+		return pending, len(pending)
+	}
+	kept, final, failed, locked := pending[:0], -1, 0, 0
+	for _, tx := range pending {
+		switch s.run(tx, height) {
+		case scriptFailed:
+			failed++
+			continue
+		case scriptLocked:
+			locked++
+			if final < 0 {
+				final = len(kept)
+			}
+		}
+		kept = append(kept, tx)
+	}
+	if final < 0 {
+		final = len(kept)
+	}
+	s.mu.Lock()
+	s.stats.Failed += failed
+	s.stats.Locked += locked
+	s.mu.Unlock()
+	return kept, final
+}
+
+// validBlock tells whether every script of b, a block at height, passes
+func (s *scriptEngine) validBlock(b Block, height int) bool {
+	if s == nil {
+		return true
+	}
+	for _, tx := range b.Transactions {
+		if s.run(tx, height) != scriptPassed {
+			s.mu.Lock()
+			s.stats.Rejected++
+			s.mu.Unlock()
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scriptEngine) result() ScriptStats {
+	if s == nil {
+		return ScriptStats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func printScriptStats(stats ScriptStats) {
+	fmt.Println("Script             =", stats.Spec)
+	fmt.Println("Script runs        =", stats.Runs)
+	fmt.Println("Script steps       =", stats.Steps)
+	fmt.Println("Script failed      =", stats.Failed)
+	fmt.Println("Script locked      =", stats.Locked)
+	fmt.Println("Script rejected    =", stats.Rejected)
+	fmt.Printf("Script time (ms)   = %.2f\n", float64(stats.Time.Microseconds())/1000)
+}
@@ -0,0 +1,340 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Sharding ---
+
+/*
+	The shard mode splits the N nodes into SimConfig.Shards shards (default 4), each running a PoW chain
+	of its own, all at once. The nodes are dealt out to the shards at random, like a beacon chain samples
+	its committees, so the C corrupt nodes end up spread unevenly, and a shard whose nodes are mostly
+	corrupt falls to them even if C is well below half of N.
+
+	Every shard runs the workload between its own nodes, and each transaction crosses to another shard
+	with probability SimConfig.CrossShard: its receiver becomes a node of the other shard (of the
+	sender's side), and once the transaction is mined on its shard a relay hands the receiver a receipt
+	for it, naming the sender, which credits the receiver once mined on its shard. A cross-shard
+	transaction is confirmed once both it and its receipt are in the winning chains of their shards, and
+	its latency runs until the receipt is first mined.
+
+	The row adds the shards up: txSent and txConfirmed count the users' transactions (not the receipts),
+	Confirmed TPS is that of all shards together, latency is up to the first block that mined a
+	transaction (its receipt's for a cross-shard one), and the corrupt nodes are the Winner if they won
+	any shard, whose receipts the others would have to trust. Shard Takeover % estimates over shardTrials
+	random assignments the share of corrupt nodes at which some shard gets a corrupt majority half the
+	time, the bar an attacker has to clear instead of 50%.
+
+	The shards run shardRounds rounds past the workload's for the last receipts, waiting shardStep before
+	each while a cross-shard transaction still waits for its receipt. Receipts take IDs from receiptTxIDs
+	up.
+*/
+
+const (
+	shardRounds = 20
+	shardStep   = 50 * time.Millisecond
+	shardTrials = 1000
+)
+
+// ShardStats reports the shard mode
+type ShardStats struct {
+	Shards         int
+	Corrupt        string  // corrupt/all nodes of every shard, e.g. "1/3 0/3 2/2 1/2"
+	Captured       int     // shards the corrupt nodes won
+	MaxCorrupt     float64 // % of corrupt nodes in the most corrupt shard
+	Takeover       float64 // % of corrupt nodes at which some shard gets a corrupt majority half the time
+	CrossSent      int
+	CrossConfirmed int
+	CrossLatency   LatencyStats // from sending a cross-shard transaction to its receipt being mined
+}
+
+// crossTx is a cross-shard transaction
+type crossTx struct {
+	to       int // shard of the receiver
+	receipt  Transaction
+	sent     time.Time
+	relayed  bool
+	credited time.Time // receipt first mined, zero before
+}
+
+// shardNet connects the shards of the shard mode. Their event handlers and senders share it, hence the
+// mutex.
+type shardNet struct {
+	R          int // rounds of the workload
+	crossShard float64
+	N, C       []int // nodes and corrupt nodes of every shard
+
+	mu          sync.Mutex
+	cross       []map[TxID]*crossTx // shard -> ID -> its cross-shard transaction
+	receipts    []map[TxID]*crossTx // shard -> receipt ID -> the transaction it credits
+	queue       [][]Transaction     // shard -> receipts due with the next round
+	nextID      []TxID
+	sent        []map[TxID]time.Time
+	mined       []map[TxID]time.Time // first mined
+	final       []map[TxID]bool
+	outstanding int // cross-shard transactions without a receipt yet
+}
+
+// assignShards deals N nodes, the first C of them corrupt, out to S shards at random, returning the
+// nodes and the corrupt nodes of every shard
+func assignShards(N, C, S int) ([]int, []int) {
+	sizes, corrupt := make([]int, S), make([]int, S)
+	for k, i := range rand.Perm(N) {
+		sizes[k%S]++
+		if i < C {
+			corrupt[k%S]++
+		}
+	}
+	return sizes, corrupt
+}
+
+// takeoverShare estimates the % of N nodes that must be corrupt for a random assignment to S shards to
+// give some shard a corrupt majority half the time
+func takeoverShare(N, S int) float64 {
+	for c := range N + 1 {
+		captured := 0
+		for range shardTrials {
+			sizes, corrupt := assignShards(N, c, S)
+			for k := range S {
+				if 2*corrupt[k] > sizes[k] {
+					captured++
+					break
+				}
+			}
+		}
+		if 2*captured >= shardTrials {
+			return getPercentage(c, N)
+		}
+	}
+	return 100
+}
+
+func newShardNet(cfg SimConfig, sizes, corrupt []int) *shardNet {
+	s := &shardNet{R: cfg.R, crossShard: cfg.CrossShard, N: sizes, C: corrupt}
+	for range sizes {
+		s.cross = append(s.cross, make(map[TxID]*crossTx))
+		s.receipts = append(s.receipts, make(map[TxID]*crossTx))
+		s.queue = append(s.queue, nil)
+		s.nextID = append(s.nextID, receiptTxIDs)
+		s.sent = append(s.sent, make(map[TxID]time.Time))
+		s.mined = append(s.mined, make(map[TxID]time.Time))
+		s.final = append(s.final, make(map[TxID]bool))
+	}
+	return s
+}
+
+// receiver picks a node of shard on the side of sender, a node of shard from
+func (s *shardNet) receiver(shard int, sender string, from int) string {
+	first, size := s.C[shard], s.N[shard]-s.C[shard]
+	if getLabel(nodeIndex(sender, s.C[from]), s.C[from]) == "corrupt" {
+		first, size = 0, s.C[shard]
+	}
+	if size == 0 { // no node of that side
+		first, size = 0, s.N[shard]
+	}
+	return nodeName(first+rand.IntN(size), s.C[shard])
+}
+
+// next makes some of the workload's txs of shard cross-shard ones, and adds the receipts due, waiting
+// a step first past the workload's rounds while some are outstanding
+func (s *shardNet) next(ctx context.Context, shard, round int, txs []Transaction) []Transaction {
+	s.mu.Lock()
+	waiting := s.outstanding > 0
+	s.mu.Unlock()
+	if round >= s.R && waiting {
+		select {
+		case <-time.After(shardStep):
+		case <-ctx.Done():
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for j := range txs {
+		if len(s.N) < 2 || rand.Float64() >= s.crossShard {
+			continue
+		}
+		to := rand.IntN(len(s.N) - 1)
+		if to >= shard {
+			to++
+		}
+		receiver := s.receiver(to, txs[j].Sender, shard)
+		receipt := Transaction{TxID: s.nextID[to], Sender: receiver, Receiver: fmt.Sprintf("shard%d/%s", shard, txs[j].Sender), Amount: txs[j].Amount, Asset: txs[j].Asset}
+		c := &crossTx{to: to, receipt: receipt}
+		s.nextID[to]++
+		txs[j].Receiver = fmt.Sprintf("shard%d/%s", to, receiver)
+		s.cross[shard][txs[j].TxID] = c
+		s.receipts[to][c.receipt.TxID] = c
+		s.outstanding++
+	}
+	out := append(s.queue[shard], txs...)
+	s.queue[shard] = nil
+	return out
+}
+
+// watch relays the receipts of the cross-shard transactions shard mined, and records the times and the
+// final result of shard
+func (s *shardNet) watch(shard int) func(Event) {
+	return func(e Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch e.Kind {
+		case EventSent:
+			s.sent[shard][e.Tx.TxID] = e.Time
+			if c, ok := s.cross[shard][e.Tx.TxID]; ok {
+				c.sent = e.Time
+			}
+		case EventMined:
+			for _, tx := range e.Block.Transactions {
+				if _, seen := s.mined[shard][tx.TxID]; seen {
+					continue
+				}
+				s.mined[shard][tx.TxID] = e.Time
+				if c, ok := s.cross[shard][tx.TxID]; ok && !c.relayed {
+					c.relayed = true
+					s.queue[c.to] = append(s.queue[c.to], c.receipt)
+					s.outstanding--
+				}
+				if c, ok := s.receipts[shard][tx.TxID]; ok && c.credited.IsZero() {
+					c.credited = e.Time
+				}
+			}
+		case EventConfirmed:
+			s.final[shard][e.Tx.TxID] = true
+		}
+	}
+}
+
+// owns tells the receipts apart
+func (s *shardNet) owns(shard int) func(Transaction) bool {
+	return func(tx Transaction) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, ok := s.receipts[shard][tx.TxID]
+		return ok
+	}
+}
+
+// shardConfig returns the config of shard, whose transactions and events go through the net
+func (s *shardNet) shardConfig(ctx context.Context, cfg SimConfig, shard int) SimConfig {
+	bus := NewEventBus()
+	if cfg.Events != nil {
+		bus.Subscribe(cfg.Events.Publish)
+	}
+	bus.Subscribe(s.watch(shard), EventSent, EventMined, EventConfirmed)
+	cfg.Events = bus
+	cfg.N, cfg.C, cfg.Verbose = s.N[shard], s.C[shard], false
+	cfg.R += shardRounds
+	cfg.WrapWorkload = func(w Workload) Workload {
+		return extendWorkload(w, s.R, func(round int, txs []Transaction) []Transaction {
+			return s.next(ctx, shard, round, txs)
+		}, s.owns(shard))
+	}
+	return cfg
+}
+
+// SimulateShardCtx runs SimConfig.Shards PoW chains at once over a random split of the nodes, see
+// SimConfig.CrossShard
+func SimulateShardCtx(ctx context.Context, cfg SimConfig) SimResult {
+	S := cfg.Shards
+	if S <= 0 {
+		S = 4
+	}
+	S = min(S, cfg.N)
+	sizes, corrupt := assignShards(cfg.N, cfg.C, S)
+	net := newShardNet(cfg, sizes, corrupt)
+
+	results := make([]SimResult, S)
+	var wg sync.WaitGroup
+	for k := range S {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[k] = SimulateBlockchainCtx(ctx, net.shardConfig(ctx, cfg, k))
+		}()
+	}
+	wg.Wait()
+
+	res := net.result(cfg, results)
+	if cfg.Verbose {
+		printShardStats(res.Shard)
+	}
+	return res
+}
+
+// result adds up the shards
+func (s *shardNet) result(cfg SimConfig, results []SimResult) SimResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := SimResult{Type: "Shard", N: cfg.N, C: cfg.C, CorruptPercentage: getPercentage(cfg.C, cfg.N), R: cfg.R, D: cfg.D, P: cfg.P, Winner: "honest"}
+	stats := ShardStats{Shards: len(results), Takeover: takeoverShare(cfg.N, len(results))}
+	corrupt := []string{}
+	latencies, crossLatencies := []time.Duration{}, []time.Duration{}
+	var intervals time.Duration
+	for k, r := range results {
+		corrupt = append(corrupt, fmt.Sprintf("%d/%d", s.C[k], s.N[k]))
+		stats.MaxCorrupt = max(stats.MaxCorrupt, getPercentage(s.C[k], s.N[k]))
+		if r.Winner == "corrupt" {
+			stats.Captured++
+			res.Winner = "corrupt"
+		}
+		res.TxSent += r.TxSent
+		for _, c := range s.receipts[k] {
+			if c.relayed {
+				res.TxSent--
+			}
+		}
+		stats.CrossSent += len(s.cross[k])
+		for id := range s.final[k] {
+			if _, ok := s.receipts[k][id]; ok {
+				continue
+			}
+			sent, mined := s.sent[k][id], s.mined[k][id]
+			if c, ok := s.cross[k][id]; ok {
+				if !s.final[c.to][c.receipt.TxID] {
+					continue
+				}
+				stats.CrossConfirmed++
+				sent, mined = c.sent, c.credited
+				crossLatencies = append(crossLatencies, mined.Sub(sent))
+			}
+			res.TxConfirmed++
+			if !sent.IsZero() && !mined.IsZero() {
+				latencies = append(latencies, mined.Sub(sent))
+			}
+		}
+		res.Duration = max(res.Duration, r.Duration)
+		res.Throughput.BlocksMined += r.Throughput.BlocksMined
+		intervals += r.Throughput.AvgBlockInterval
+	}
+	stats.Corrupt = strings.Join(corrupt, " ")
+	stats.CrossLatency = summarizeLatencies(crossLatencies)
+	if res.TxSent > 0 {
+		res.TxConfirmedPercentage = getPercentage(res.TxConfirmed, res.TxSent)
+	}
+	res.Latency = summarizeLatencies(latencies)
+	res.Throughput.ConfirmedTPS = perSecond(res.TxConfirmed, res.Duration)
+	res.Throughput.BlocksPerSecond = perSecond(res.Throughput.BlocksMined, res.Duration)
+	if len(results) > 0 {
+		res.Throughput.AvgBlockInterval = intervals / time.Duration(len(results))
+	}
+	res.Shard = stats
+	return res
+}
+
+func printShardStats(stats ShardStats) {
+	fmt.Println("Shards             =", stats.Shards)
+	fmt.Println("Shard corrupt      =", stats.Corrupt)
+	fmt.Println("Shards captured    =", stats.Captured)
+	fmt.Printf("Max shard corrupt %% = %.2f\n", stats.MaxCorrupt)
+	fmt.Printf("Shard takeover %%   = %.2f\n", stats.Takeover)
+	fmt.Println("Cross-shard txs    =", stats.CrossSent)
+	fmt.Println("Cross-shard conf.  =", stats.CrossConfirmed)
+	fmt.Printf("Cross-shard p50 (s) = %.3f\n", stats.CrossLatency.P50.Seconds())
+	fmt.Printf("Cross-shard p95 (s) = %.3f\n", stats.CrossLatency.P95.Seconds())
+}
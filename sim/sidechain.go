@@ -0,0 +1,297 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Sidechain ---
+
+/*
+	The sidechain mode runs two PoW chains over the same N nodes at once: the main chain at difficulty D
+	and a fast sidechain at SimConfig.SideDifficulty (default D-1, at least 1). Every interval sidechain
+	blocks, once an honest node's sidechain is depth blocks past the height, an honest node commits the
+	hash of its block at that height to the main chain in a checkpoint transaction. SimConfig.Checkpoints
+	is "interval[:depth]", 5:2 by default.
+
+	Checkpoints do not bind the sidechain nodes, so the mode measures whether they would have had to:
+	- Reorgs Past Checkpoint counts the sidechain reorgs of honest nodes that left a checkpointed block
+	  after an honest main chain node mined its checkpoint.
+	- Checkpoints Violated counts the checkpoints of the winning main chain whose block is not in the
+	  winning sidechain at its height.
+	The row is the main chain's, checkpoint transactions included, and the main chain runs sideRounds
+	rounds past the workload's, waiting sideStep before each while the sidechain runs. Checkpoint
+	transactions take IDs from sidechainTxIDs up.
+*/
+
+const (
+	sideRounds = 400
+	sideStep   = 25 * time.Millisecond
+)
+
+// SidechainStats reports the sidechain mode
+type SidechainStats struct {
+	Difficulty  int
+	Interval    int
+	Depth       int
+	Blocks      int    // winning sidechain after genesis
+	Winner      string // of the sidechain
+	Reorgs      int    // of the sidechain, over all nodes
+	Checkpoints int    // checkpoint transactions sent
+	Confirmed   int    // checkpoints in the winning main chain
+	ReorgsPast  int    // honest sidechain reorgs that left a checkpointed block
+	Violated    int    // confirmed checkpoints whose block the winning sidechain does not hold
+}
+
+// parseCheckpoints returns the interval and depth of spec, the defaults for ""
+func parseCheckpoints(spec string) (int, int, error) {
+	interval, depth := 5, 2
+	if spec == "" {
+		return interval, depth, nil
+	}
+	is, ds, found := strings.Cut(spec, ":")
+	v, err := strconv.Atoi(is)
+	if err != nil || v < 1 {
+		return 0, 0, fmt.Errorf("checkpoints %q: %q is not a positive interval", spec, is)
+	}
+	interval = v
+	if found {
+		v, err := strconv.Atoi(ds)
+		if err != nil || v < 0 {
+			return 0, 0, fmt.Errorf("checkpoints %q: %q is not a depth of at least 0", spec, ds)
+		}
+		depth = v
+	}
+	return interval, depth, nil
+}
+
+// checkpoint is the sidechain block at a height, committed to the main chain
+type checkpoint struct {
+	height int
+	hash   string
+	mined  bool // an honest main chain node mined it
+}
+
+// sidechain follows the sidechain's blocks and commits its checkpoints to the main chain. The event
+// handlers of both chains and the main chain's sender share it, hence the mutex.
+type sidechain struct {
+	R               int // rounds of the workload
+	C               int
+	interval, depth int
+
+	mu          sync.Mutex
+	blocks      map[string]Block // hash -> sidechain block
+	heights     map[string]int
+	heads       map[string]string // honest node -> its sidechain tip
+	checkpoints map[TxID]*checkpoint
+	committed   map[int]bool // heights with a checkpoint
+	queue       []Transaction
+	nextID      TxID
+	chains      map[string][]Block // node -> its final sidechain
+	confirmed   map[TxID]bool      // checkpoints in the winning main chain
+	reorgsPast  int
+	done        bool // the sidechain finished
+}
+
+func newSidechain(cfg SimConfig) *sidechain {
+	interval, depth, err := parseCheckpoints(cfg.Checkpoints)
+	if err != nil {
+		interval, depth = 5, 2
+	}
+	return &sidechain{R: cfg.R, C: cfg.C, interval: interval, depth: depth, blocks: make(map[string]Block), heights: make(map[string]int),
+		heads: make(map[string]string), checkpoints: make(map[TxID]*checkpoint), committed: make(map[int]bool), nextID: sidechainTxIDs,
+		chains: make(map[string][]Block), confirmed: make(map[TxID]bool)}
+}
+
+// ancestor returns the hash of the block at height on the sidechain ending in hash, "" if unknown
+func (s *sidechain) ancestor(hash string, height int) string {
+	for {
+		h, ok := s.heights[hash]
+		if !ok || h < height {
+			return ""
+		}
+		if h == height {
+			return hash
+		}
+		hash = s.blocks[hash].PrevHash
+	}
+}
+
+// watchSide follows the sidechain's blocks, the honest nodes' tips and reorgs, and commits checkpoints
+func (s *sidechain) watchSide(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Kind {
+	case EventMined, EventAccepted:
+		s.blocks[e.Block.Hash], s.heights[e.Block.Hash] = e.Block, e.Height
+	case EventNodeDone:
+		s.chains[e.Node] = e.Chain
+		return
+	}
+	if !strings.HasPrefix(e.Node, "honest") {
+		return
+	}
+	switch e.Kind {
+	case EventMined:
+		s.heads[e.Node] = e.Block.Hash
+	case EventAccepted:
+		if e.Block.PrevHash == s.heads[e.Node] || s.heads[e.Node] == "" {
+			s.heads[e.Node] = e.Block.Hash
+		}
+	case EventReorg:
+		old := s.heads[e.Node]
+		s.heads[e.Node] = e.Block.Hash
+		for _, cp := range s.checkpoints {
+			if cp.mined && s.ancestor(old, cp.height) == cp.hash && s.ancestor(e.Block.Hash, cp.height) != cp.hash {
+				s.reorgsPast++
+				break
+			}
+		}
+	}
+	tip := s.heads[e.Node]
+	for height := s.interval; height <= s.heights[tip]-s.depth; height += s.interval {
+		if s.committed[height] {
+			continue
+		}
+		if hash := s.ancestor(tip, height); hash != "" {
+			s.committed[height] = true
+			s.checkpoints[s.nextID] = &checkpoint{height: height, hash: hash}
+			s.queue = append(s.queue, Transaction{TxID: s.nextID, Sender: nodeName(s.C, s.C), Receiver: "checkpoint"})
+			s.nextID++
+		}
+	}
+}
+
+// watchMain marks the checkpoints the honest main chain nodes mined and the winning main chain holds
+func (s *sidechain) watchMain(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Kind {
+	case EventMined, EventAccepted:
+		if !strings.HasPrefix(e.Node, "honest") {
+			return
+		}
+		for _, tx := range e.Block.Transactions {
+			if cp, ok := s.checkpoints[tx.TxID]; ok {
+				cp.mined = true
+			}
+		}
+	case EventConfirmed:
+		if _, ok := s.checkpoints[e.Tx.TxID]; ok {
+			s.confirmed[e.Tx.TxID] = true
+		}
+	}
+}
+
+// next adds the checkpoints due to the main chain's txs, waiting a step first past the workload's
+// rounds while the sidechain runs
+func (s *sidechain) next(ctx context.Context, round int, txs []Transaction) []Transaction {
+	s.mu.Lock()
+	running := !s.done
+	s.mu.Unlock()
+	if round >= s.R && running {
+		select {
+		case <-time.After(sideStep):
+		case <-ctx.Done():
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := append(s.queue, txs...)
+	s.queue = nil
+	return out
+}
+
+// owns tells the checkpoint transactions apart
+func (s *sidechain) owns(tx Transaction) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.checkpoints[tx.TxID]
+	return ok
+}
+
+// chainConfig returns the config of the main chain or the sidechain, whose events go through s
+func (s *sidechain) chainConfig(ctx context.Context, cfg SimConfig, side bool) SimConfig {
+	bus := NewEventBus()
+	if cfg.Events != nil {
+		bus.Subscribe(cfg.Events.Publish)
+	}
+	cfg.Events = bus
+	if side {
+		bus.Subscribe(s.watchSide, EventMined, EventAccepted, EventReorg, EventNodeDone)
+		cfg.D, cfg.Verbose = sideDifficulty(cfg), false
+		return cfg
+	}
+	bus.Subscribe(s.watchMain, EventMined, EventAccepted, EventConfirmed)
+	cfg.R += sideRounds
+	cfg.WrapWorkload = func(w Workload) Workload {
+		return extendWorkload(w, s.R, func(round int, txs []Transaction) []Transaction {
+			return s.next(ctx, round, txs)
+		}, s.owns)
+	}
+	return cfg
+}
+
+// sideDifficulty is the difficulty of the sidechain of cfg
+func sideDifficulty(cfg SimConfig) int {
+	if cfg.SideDifficulty > 0 {
+		return cfg.SideDifficulty
+	}
+	return max(cfg.D-1, 1)
+}
+
+// SimulateSidechainCtx runs a main chain and a sidechain that checkpoints to it, see SimConfig.Checkpoints
+func SimulateSidechainCtx(ctx context.Context, cfg SimConfig) SimResult {
+	s := newSidechain(cfg)
+	var side SimResult
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		side = SimulateBlockchainCtx(ctx, s.chainConfig(ctx, cfg, true))
+		s.mu.Lock()
+		s.done = true
+		s.mu.Unlock()
+	}()
+	res := SimulateBlockchainCtx(ctx, s.chainConfig(ctx, cfg, false))
+	wg.Wait()
+
+	res.Type, res.R = "Sidechain", cfg.R
+	res.Sidechain = s.result(cfg, side)
+	if cfg.Verbose {
+		printSidechainStats(res.Sidechain)
+	}
+	return res
+}
+
+// result checks the confirmed checkpoints against the winning sidechain
+func (s *sidechain) result(cfg SimConfig, side SimResult) SidechainStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := SidechainStats{Difficulty: sideDifficulty(cfg), Interval: s.interval, Depth: s.depth, Blocks: side.ChainLength, Winner: side.Winner,
+		Reorgs: side.Reorgs.Count, Checkpoints: len(s.checkpoints), Confirmed: len(s.confirmed), ReorgsPast: s.reorgsPast}
+	winner := winningChain(s.chains, side.Winner)
+	for id := range s.confirmed {
+		cp := s.checkpoints[id]
+		if cp.height >= len(winner) || winner[cp.height].Hash != cp.hash {
+			stats.Violated++
+		}
+	}
+	return stats
+}
+
+func printSidechainStats(stats SidechainStats) {
+	fmt.Println("Side difficulty    =", stats.Difficulty)
+	fmt.Printf("Checkpoints every  = %d blocks, %d deep\n", stats.Interval, stats.Depth)
+	fmt.Println("Side blocks        =", stats.Blocks)
+	fmt.Println("Side winner        =", stats.Winner)
+	fmt.Println("Side reorgs        =", stats.Reorgs)
+	fmt.Println("Checkpoints        =", stats.Checkpoints)
+	fmt.Println("Checkpoints conf.  =", stats.Confirmed)
+	fmt.Println("Reorgs past cp.    =", stats.ReorgsPast)
+	fmt.Println("Checkpoints broken =", stats.Violated)
+}
@@ -0,0 +1,464 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SimConfig holds the parameters of a single PoW or DAG simulation.
+// See SimulateBlockchain for the meaning of N, C, R, D and P.
+type SimConfig struct {
+	N       int
+	C       int
+	R       int
+	D       int
+	P       float64
+	Verbose bool
+	Timeout time.Duration // stop the simulation after this long (0 = no deadline)
+
+	// ReceiverBuffer is the capacity of each node's channel for blocks / mined transactions from peers.
+	// 0 keeps the simulator's default (N for PoW, unbuffered for DAG), a negative value forces unbuffered.
+	// Broadcasts never block, so a send to a full receiver is dropped (see SimResult.Drops).
+	ReceiverBuffer int
+
+	// DirectDelivery makes DAG nodes broadcast straight into the receiver channels like PoW nodes,
+	// dropping a transaction whenever the receiver is busy. By default each DAG node has an unbounded
+	// delivery queue so broadcasts are never dropped.
+	DirectDelivery bool
+
+	// Miners bounds how many nodes hash at the same time (0 = GOMAXPROCS).
+	// With hundreds of nodes, unbounded mining just time-slices the CPUs between them.
+	Miners int
+
+	// FastMining skips hashing: a PoW block is found after an exponentially distributed time with
+	// mean 16^difficulty / HashRate (hashes per second per node, default 1e6, see fastmining.go).
+	FastMining bool
+	HashRate   float64
+
+	// Hasher hashes the blocks and DAG transactions (nil = SHA-256, see hasher.go). NoMidstate makes
+	// SHA-256 mining hash the whole encoding per nonce instead of resuming from the midstate (see
+	// midstate.go).
+	Hasher     Hasher
+	NoMidstate bool
+
+	// StoreDir keeps each PoW node's blocks on disk under this directory, created if missing, instead
+	// of in memory. Store picks how: StoreBolt ("" or "bolt", a bbolt database per node) or StoreFile
+	// ("file", an append-only file of protobuf records, see store.go).
+	StoreDir string
+	Store    string
+
+	// ForkChoice picks the chain a PoW node mines on: ForkLongest ("longest"), ForkHeaviest ("heaviest",
+	// most total work) or ForkGHOST ("ghost", heaviest subtree, see ghostTree). "" is ForkHeaviest
+	// when difficulty is retargeted and ForkLongest otherwise.
+	ForkChoice string
+
+	// RetargetInterval makes PoW difficulty variable: every this many blocks a chain's difficulty moves
+	// towards TargetBlockTime (0 = always D, otherwise at least 2, see difficulty.go). TargetBlockTime
+	// defaults to 1ms.
+	RetargetInterval int
+	TargetBlockTime  time.Duration
+
+	// TimeWarp makes the corrupt PoW nodes stamp the last block of every retarget window this far in
+	// the future and the others as early as allowed, to drive their difficulty down. With MaxTimeDrift
+	// honest nodes reject blocks stamped more than that ahead of their clock (see timewarp.go).
+	TimeWarp     time.Duration
+	MaxTimeDrift time.Duration
+
+	// Uncles lets PoW blocks reference recently orphaned blocks, which earn their miners a partial
+	// reward on the winning chain (see uncles.go).
+	Uncles bool
+
+	// FinalityInterval runs a Casper-FFG style finality gadget on top of PoW with a checkpoint
+	// every this many blocks (0 = off, see finality.go).
+	FinalityInterval int
+
+	// Hybrid makes PoW blocks count toward the winning chain only once validators holding more than
+	// AttestQuorum of all stake (default 2/3) attested them (see hybrid.go). With NothingAtStake the
+	// corrupt validators attest the blocks of every fork they see, not only their own. SlashFraction
+	// is the share of its stake a validator loses for attesting two blocks at one height (0 = no slashing).
+	Hybrid         bool
+	AttestQuorum   float64
+	NothingAtStake bool
+	SlashFraction  float64
+
+	// LongRange ends a hybrid simulation with a long-range attack forking the winning chain this many
+	// blocks below its tip, signed with the keys of validators holding ExitedStake (default all honest
+	// stake) that exited since. New nodes trust a checkpoint WSPeriod blocks below the tip (default LongRange/2,
+	// see longrange.go).
+	LongRange   int
+	ExitedStake float64
+	WSPeriod    int
+
+	// Sybils is the number of extra identities the corrupt nodes of PoW and PoA spawn mid-simulation
+	// (see sybil.go).
+	Sybils int
+
+	// BribeRate is the probability that an honest PoW miner takes the corrupt nodes' bribe of BribeFee
+	// block rewards (default 1) per block to mine on the corrupt fork (see bribery.go).
+	BribeRate float64
+	BribeFee  float64
+
+	// SpamRate is the number of tiny self-transactions every corrupt PoW node floods the network with
+	// per round (see spam.go). MaxBlockTxs caps the transactions of a PoW or DES block (0 = no limit).
+	SpamRate    int
+	MaxBlockTxs int
+
+	// RBFRate is the probability that the sender of a PoW transaction rebroadcasts it with the next
+	// round, paying a higher fee that miners prefer (0 = never, see rbf.go).
+	RBFRate float64
+
+	// UTXO makes PoW transactions spend the outputs of earlier ones, which miners validate, instead of
+	// only naming the sender and receiver (see utxo.go).
+	UTXO bool
+
+	// Script is the script every PoW transaction carries, which miners evaluate before they include it
+	// and nodes for every block they receive: multisig:<m>-of-<n>, timelock:<h>, hashes:<k> or raw ops,
+	// comma separated (see script.go).
+	Script string
+
+	// Channels opens PoW payment channels, "n[:updates,window]": n pairs of nodes pay each other updates
+	// times per round off-chain and settle on-chain, disputes within window blocks (see channels.go).
+	Channels string
+
+	// Rollup runs PoW as the L1 of a rollup, set by the rollup mode, and Sequencer is how its sequencer
+	// behaves: honest (default), censor[:f] or withhold[:f] (see rollup.go).
+	Rollup    bool
+	Sequencer string
+
+	// Swaps is the atomic swaps of the swap mode, "n[:timeout]" (10 of 500ms by default), between a PoW
+	// chain with C corrupt nodes and a DAG tangle with SwapDAGCorrupt (see swap.go).
+	Swaps          string
+	SwapDAGCorrupt int
+
+	// Shards is the PoW chains of the shard mode (default 4), which the nodes are split into at random,
+	// and CrossShard the probability that a transaction pays a node of another shard, with a receipt
+	// there (see shard.go).
+	Shards     int
+	CrossShard float64
+
+	// SideDifficulty is the difficulty of the sidechain mode's sidechain (0 = D-1, at least 1), and
+	// Checkpoints how it commits to the main chain, "interval[:depth]" (5:2 by default, see sidechain.go).
+	SideDifficulty int
+	Checkpoints    string
+
+	// Withhold is when the corrupt PoW nodes release their withheld blocks: private (default), lead:<k>,
+	// selfish, stubborn or stubborn-eq (see withhold.go).
+	Withhold string
+
+	// ChurnRate is the probability that an honest PoW node goes offline, or comes back, every time a
+	// block is mined; the last Joiners honest nodes start offline and join through the churn (see churn.go).
+	ChurnRate float64
+	Joiners   int
+
+	// BanScore is the misbehavior score at which an honest PoW node bans a peer (0 = no banning), and
+	// InvalidBlocks the probability that a corrupt node sends a forged block with every block it
+	// mines (see peerscore.go).
+	BanScore      int
+	InvalidBlocks float64
+
+	// LatencyMatrix delays every PoW and DES block by the one-way delay between the regions of its
+	// sender and receiver: a preset (continents) or a CSV file of delays in ms (see latencymatrix.go).
+	LatencyMatrix string
+
+	// Bandwidth is the bytes per second of every link, so PoW and DES blocks take size / Bandwidth
+	// longer to arrive (0 = unlimited, see bandwidth.go).
+	Bandwidth float64
+
+	// TxGossip starts every PoW transaction at its sender's node, which relays it to TxGossip random
+	// peers, and they to theirs, instead of handing it to every node (0 = direct, see gossip.go).
+	TxGossip int
+
+	// Workload generates the transactions of every round: uniform (default, every pair of nodes of a side
+	// with probability P), poisson[:rate], zipf[:s], bursty[:k] or trace:<file> (see workload.go).
+	Workload string
+
+	// Amounts draws what the transactions transfer: fixed:<v>, lognormal[:mu,sigma] or
+	// pareto[:alpha[,xm]] ("" = nothing, see amounts.go).
+	Amounts string
+
+	// Assets lists the tokens the transactions move, with optional weights, e.g. "BTC:3,USDC"
+	// ("" = a single currency, see assets.go).
+	Assets string
+
+	// BFTTimeout is how long a BFT node waits in each step of round 0 before voting nil or moving
+	// to the next round; round r waits (r+1) times as long (default 20ms, see bft.go).
+	BFTTimeout time.Duration
+
+	// Schedule lets an adversary delay every BFT message by up to Delta: random, split or proposals,
+	// depending only on ScheduleSeed and the message so schedules can be replayed (see scheduler.go).
+	Schedule     string
+	Delta        time.Duration
+	ScheduleSeed uint64
+
+	// Seed, BlockTime and Latency drive the discrete-event PoW engine: the seed of all its randomness,
+	// the mean virtual time between blocks (default 10s) and the mean virtual delivery delay (default
+	// 1s, see des.go).
+	Seed      uint64
+	BlockTime time.Duration
+	Latency   time.Duration
+
+	// VRFLeaders elects BFT proposers by stake-weighted VRF sortition instead of round-robin; a corrupt
+	// leader tries up to GrindAttempts seeds for the next height to elect a corrupt leader (see vrf.go).
+	VRFLeaders    bool
+	GrindAttempts int
+
+	// CommitteeSize is the number of nodes drawn by VRF to vote in each round of the committee
+	// simulator (default N/3, see committee.go).
+	CommitteeSize int
+
+	// RaftTimeout is the shortest Raft election timeout, each node waits a random time between it and
+	// twice as long; the leader sends heartbeats every RaftTimeout/4 (default 20ms, see raft.go).
+	RaftTimeout time.Duration
+
+	// Delegates is the number of DPoS block producers (default N/3), elected by stake-weighted
+	// approval votes; each gets one block per SlotTime in turn (default 5ms, see dpos.go).
+	// CorruptStake is the share of all stake held by the corrupt nodes (0 = same stake for
+	// every node, see stake.go). SlotTime is also the block period of PoA signers (see poa.go).
+	Delegates    int
+	SlotTime     time.Duration
+	CorruptStake float64
+
+	// TipSelection picks how DAG nodes choose the parents of a new transaction:
+	// TipsUniform ("" or "uniform", any two transactions) or TipsMCMC (cumulative-weight random walk
+	// biased by TipAlpha, see tangleWalk).
+	TipSelection string
+	TipAlpha     float64
+
+	// DoubleSpend is the share of DAG transactions (and PoW ones with UTXO) whose sender also spends the
+	// same funds to another receiver (see conflicts.go). Half of the nodes receive the conflicting twin.
+	DoubleSpend float64
+
+	// SnapshotInterval makes every DAG node take a local snapshot after this many added transactions,
+	// pruning confirmed history (0 = never, see snapshot.go). SnapshotCheck also keeps the unpruned
+	// view and counts the nodes whose confidence results differ in SimResult.Snapshots.Mismatches.
+	SnapshotInterval int
+	SnapshotCheck    bool
+
+	// Solidify makes DAG nodes hold transactions with unknown parents and request the parents
+	// from their peers instead of rejecting them (see solidify.go).
+	Solidify bool
+
+	// Avalanche makes DAG nodes settle double spends by repeatedly querying AvalancheK random peers
+	// (default 10) until AvalancheBeta consecutive majorities (default 15) agree, instead of each
+	// keeping the heavier side (see avalanche.go).
+	Avalanche     bool
+	AvalancheK    int
+	AvalancheBeta int
+
+	Observer     Observer                  // optional callbacks while the simulation runs
+	Events       *EventBus                 // optional bus that receives every event of the simulation
+	WrapWorkload func(w Workload) Workload // optional, adds to the workload's transactions, e.g. the swap mode's
+
+	console *console // set by Run with -repl, -tx-api or -rpc, injects the commands typed at runtime into PoW (see repl.go)
+}
+
+// SimResult holds the metrics of a finished (or cut short) simulation.
+type SimResult struct {
+	Type                  string // "PoW", "Hybrid", "Rollup", "Swap", "Shard", "Sidechain", "DAG", "BFT", "Raft", "DPoS", "PoA", "Committee" or "DES"
+	N                     int
+	C                     int
+	CorruptPercentage     float64
+	R                     int
+	D                     int
+	P                     float64
+	ReceiverBuffer        int // actual receiver channel capacity used
+	TxSent                int
+	TxConfirmed           int
+	TxConfirmedPercentage float64
+	Winner                string
+	Duration              time.Duration
+	AvgConfHonest         float64 // DAG only
+	AvgConfCorrupt        float64 // DAG only
+	Latency               LatencyStats
+	Throughput            Throughput
+	Forks                 ForkStats         // PoW and PoA
+	Reorgs                ReorgStats        // PoW and PoA
+	OrphanPool            OrphanPoolStats   // PoW only
+	ForkChoice            string            // PoW only
+	ChainLength           int               // PoW only: blocks in the winning chain after genesis
+	ChainWork             float64           // PoW only: expected hashes behind the winning chain, see blockWork
+	Uncles                UncleStats        // PoW only: uncles and rewards of the winning chain
+	Finality              FinalityStats     // PoW only
+	Confirmations         ConfirmationStats // PoW only
+	Hybrid                HybridStats       // Hybrid only
+	LongRange             LongRangeStats    // Hybrid with SimConfig.LongRange
+	Sybils                SybilStats        // PoW and PoA with SimConfig.Sybils
+	Bribes                BribeStats        // PoW with SimConfig.BribeRate
+	Spam                  SpamStats         // PoW with SimConfig.SpamRate
+	Withholding           WithholdStats     // PoW with SimConfig.Withhold
+	TimeWarp              TimeWarpStats     // PoW with SimConfig.TimeWarp
+	Churn                 ChurnStats        // PoW with SimConfig.ChurnRate, Joiners
+	IBD                   IBDStats          // PoW with SimConfig.ChurnRate, Joiners
+	Bans                  BanStats          // PoW with SimConfig.BanScore, InvalidBlocks
+	LatencyMatrix         string            // PoW and DES
+	Regions               []RegionStats     // PoW and DES with SimConfig.LatencyMatrix
+	Propagation           PropagationStats  // PoW and DES
+	Gossip                GossipStats       // PoW with SimConfig.TxGossip
+	RBF                   RBFStats          // PoW with SimConfig.RBFRate
+	UTXO                  UTXOStats         // PoW with SimConfig.UTXO
+	Script                ScriptStats       // PoW with SimConfig.Script
+	Channels              ChannelStats      // PoW with SimConfig.Channels
+	Rollup                RollupStats       // Rollup only
+	Swap                  SwapStats         // Swap only
+	Shard                 ShardStats        // Shard only
+	Sidechain             SidechainStats    // Sidechain only
+	Workload              string            // SimConfig.Workload, set by the tester
+	Resources             ResourceStats     // set by the tester
+	Value                 ValueStats        // with SimConfig.Amounts or a trace with amounts
+	HashRate              float64           // PoW with SimConfig.FastMining: hashes per second per node
+	BFT                   BFTStats          // BFT only
+	Leaders               LeaderStats       // BFT with SimConfig.VRFLeaders, Committee
+	Schedule              ScheduleStats     // BFT with SimConfig.Schedule
+	Committee             CommitteeStats    // Committee only
+	Raft                  RaftStats         // Raft only
+	DPoS                  DPoSStats         // DPoS only
+	PoA                   PoAStats          // PoA only
+	DES                   DESStats          // DES only
+	Nodes                 []NodeStats
+	Drops                 DropStats
+	TimedOut              bool           // the deadline hit before the simulation finished, metrics are partial
+	TipSelection          string         // DAG only
+	TipAlpha              float64        // DAG only
+	Conflicts             ConflictStats  // DAG only
+	Snapshots             SnapshotStats  // DAG only
+	Solidification        SolidStats     // DAG only
+	Avalanche             AvalancheStats // DAG only, with SimConfig.Avalanche
+}
+
+// tipSelection resolves cfg.TipSelection against the default
+func tipSelection(cfg SimConfig) string {
+	if cfg.TipSelection == "" {
+		return TipsUniform
+	}
+	return cfg.TipSelection
+}
+
+// forkChoice resolves cfg.ForkChoice against the default
+func forkChoice(cfg SimConfig) string {
+	if cfg.ForkChoice == "" && cfg.RetargetInterval > 0 {
+		return ForkHeaviest
+	}
+	if cfg.ForkChoice == "" {
+		return ForkLongest
+	}
+	return cfg.ForkChoice
+}
+
+// withDeadline applies cfg.Timeout (if any) on top of ctx
+func withDeadline(ctx context.Context, cfg SimConfig) (context.Context, context.CancelFunc) {
+	if cfg.Timeout > 0 {
+		return context.WithTimeout(ctx, cfg.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func timedOut(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// receiverBuffer resolves cfg.ReceiverBuffer against the simulator's default
+func receiverBuffer(cfg SimConfig, defaultSize int) int {
+	switch {
+	case cfg.ReceiverBuffer > 0:
+		return cfg.ReceiverBuffer
+	case cfg.ReceiverBuffer < 0:
+		return 0
+	}
+	return defaultSize
+}
+
+// minerPool is a counting semaphore shared by the nodes of a simulation, see SimConfig.Miners
+type minerPool chan struct{}
+
+// newMinerPool returns nil, no limit, with cfg.FastMining: sampled mining takes no CPU
+func newMinerPool(cfg SimConfig) minerPool {
+	if cfg.FastMining {
+		return nil
+	}
+	size := cfg.Miners
+	if size <= 0 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	return make(minerPool, size)
+}
+
+// acquire waits for a free mining slot, it fails if ctx is cancelled first
+func (pool minerPool) acquire(ctx context.Context) bool {
+	if pool == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case pool <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (pool minerPool) release() {
+	if pool != nil {
+		<-pool
+	}
+}
+
+// commitWatch counts the distinct transactions committed by a simulator that runs until everything is
+// committed (BFT, Raft), so the simulation knows when to stop its nodes
+type commitWatch struct {
+	mu        sync.Mutex
+	txs       map[TxID]bool
+	committed atomic.Int64
+	progress  chan struct{} // signalled when committed grows
+}
+
+func newCommitWatch() *commitWatch {
+	return &commitWatch{txs: make(map[TxID]bool), progress: make(chan struct{}, 1)}
+}
+
+// add records the transactions of a committed block, call it from a bus subscriber
+func (w *commitWatch) add(b Block) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	grew := false
+	for _, tx := range b.Transactions {
+		if !w.txs[tx.TxID] {
+			w.txs[tx.TxID] = true
+			grew = true
+		}
+	}
+	if grew {
+		w.committed.Store(int64(len(w.txs)))
+		select {
+		case w.progress <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wait returns once txSent transactions are committed, nothing new was committed for idle, or ctx is done
+func (w *commitWatch) wait(ctx context.Context, txSent int, idle time.Duration) {
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+	for w.committed.Load() < int64(txSent) {
+		select {
+		case <-w.progress:
+			timer.Reset(idle)
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nodeNames caches the names of all N nodes so broadcasts don't format them per peer
+func nodeNames(N, C int) []string {
+	names := make([]string, N)
+	for i := range N {
+		names[i] = nodeName(i, C)
+	}
+	return names
+}
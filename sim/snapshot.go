@@ -0,0 +1,180 @@
+package sim
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// --- DAG Snapshots ---
+
+/*
+	Every SimConfig.SnapshotInterval added transactions a DAG node takes a local snapshot:
+	transactions that every current tip references (directly or indirectly) are confirmed, and
+	confirmed history is collapsed into a snapshot root. Pruned transactions leave HashMap and
+	Nodes, so they are no longer picked as parents. The node keeps only their hashes, so peers'
+	transactions that approve them are still accepted, plus a copy without parents and hash for
+	the final confident set.
+
+	A transaction is only pruned together with all of its ancestors, and transactions that are in
+	a conflict set at snapshot time are never pruned. Every unpruned transaction therefore keeps the
+	same tips and descendants, so its confidence and its cumulative weight are unchanged (unless the
+	other side of a double spend only arrives after the snapshot). SnapshotCheck keeps an unpruned
+	copy of the view to verify this at the end of the simulation.
+*/
+
+// SnapshotStats reports the local snapshots of a DAG simulation
+type SnapshotStats struct {
+	Snapshots  int   // snapshots taken by all nodes
+	Pruned     int   // transactions collapsed into snapshot roots by all nodes
+	BytesSaved int64 // estimated memory released by pruning
+	Mismatches int   // SnapshotCheck: nodes whose confident set or confidence differed from the unpruned view
+}
+
+type snapshotter struct {
+	interval int
+	added    int
+	pruned   map[string]struct{} // hashes collapsed into the snapshot root
+	history  []Transaction       // pruned transactions without Parents and Hash
+}
+
+func newSnapshotter(interval int) *snapshotter {
+	return &snapshotter{interval: interval, pruned: make(map[string]struct{})}
+}
+
+// known reports whether hash was pruned into the snapshot root
+func (s *snapshotter) known(hash string) bool {
+	_, ok := s.pruned[hash]
+	return ok
+}
+
+// due counts a newly added transaction and reports whether a snapshot should be taken
+func (s *snapshotter) due() bool {
+	if s.interval <= 0 {
+		return false
+	}
+	s.added++
+	return s.added%s.interval == 0
+}
+
+// snapshot prunes the confirmed history of HashMap and returns the remaining Nodes (in their original order)
+// together with the pruned transactions. Nothing is pruned if fewer than two transactions would remain.
+func (s *snapshotter) snapshot(HashMap map[string]Transaction, Nodes []Transaction) ([]Transaction, []Transaction) {
+	Confidence := confidenceScores(HashMap)
+	referenced := make(map[string]bool)
+	for _, tx := range HashMap {
+		for _, p := range tx.Parents {
+			referenced[p] = true
+		}
+	}
+	tips := 0
+	for hash := range HashMap {
+		if !referenced[hash] {
+			tips++
+		}
+	}
+
+	// Conflicting transactions (same TxID, different receiver) stay, see resolveConflicts
+	receivers := make(map[TxID]string)
+	conflicted := make(map[TxID]bool)
+	for _, tx := range HashMap {
+		if r, seen := receivers[tx.TxID]; seen && r != tx.Receiver {
+			conflicted[tx.TxID] = true
+		}
+		receivers[tx.TxID] = tx.Receiver
+	}
+
+	// Nodes lists parents before children, so a single pass keeps the pruned set closed under ancestors
+	prune := make(map[string]bool)
+	for _, tx := range Nodes {
+		ok := referenced[tx.Hash] && Confidence[tx.Hash] == tips && !conflicted[tx.TxID]
+		for _, p := range tx.Parents {
+			ok = ok && (prune[p] || s.known(p))
+		}
+		if ok {
+			prune[tx.Hash] = true
+		}
+	}
+	if len(Nodes)-len(prune) < 2 { // pickParents needs two transactions
+		return Nodes, nil
+	}
+
+	kept := make([]Transaction, 0, len(Nodes)-len(prune))
+	removed := []Transaction{}
+	for _, tx := range Nodes {
+		if !prune[tx.Hash] {
+			kept = append(kept, tx)
+			continue
+		}
+		if s.known(tx.Hash) { // Nodes may list a transaction twice
+			continue
+		}
+		removed = append(removed, tx)
+		delete(HashMap, tx.Hash)
+		s.pruned[tx.Hash] = struct{}{}
+		s.history = append(s.history, Transaction{TxID: tx.TxID, Sender: tx.Sender, Receiver: tx.Receiver})
+	}
+	return kept, removed
+}
+
+// prunedBytes estimates the memory a pruned transaction no longer takes: its parents, and its map and slice
+// entries minus the slim copy and the hash that are kept
+func prunedBytes(tx Transaction) int64 {
+	full := int64(2*unsafe.Sizeof(tx)) + int64(len(tx.Hash)) // HashMap value and Nodes entry
+	for _, p := range tx.Parents {
+		full += int64(len(p)) + int64(unsafe.Sizeof(p))
+	}
+	kept := int64(unsafe.Sizeof(tx)) + int64(len(tx.Hash))
+	return full - kept
+}
+
+// sameConfidence compares the outcome of the pruned view against the unpruned one: the same transactions
+// (by TxID and receiver) must be confident, and every unpruned transaction must keep its confidence
+func sameConfidence(full, pruned map[string]int, fullConfident, prunedConfident []Transaction) bool {
+	for hash, c := range pruned {
+		if full[hash] != c {
+			return false
+		}
+	}
+	key := func(tx Transaction) string { return fmt.Sprintf("%s>%s", tx.TxID, tx.Receiver) }
+	count := make(map[string]int)
+	for _, tx := range fullConfident {
+		count[key(tx)]++
+	}
+	for _, tx := range prunedConfident {
+		count[key(tx)]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshotTracker tallies the EventSnapshot events of a simulation
+type snapshotTracker struct {
+	mu    sync.Mutex
+	stats SnapshotStats
+}
+
+func trackSnapshots(bus *EventBus) *snapshotTracker {
+	t := &snapshotTracker{}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.stats.Snapshots++
+		t.stats.Pruned += len(e.Txs)
+		for _, tx := range e.Txs {
+			t.stats.BytesSaved += prunedBytes(tx)
+		}
+	}, EventSnapshot)
+	return t
+}
+
+func printSnapshotStats(stats SnapshotStats) {
+	fmt.Println("Snapshots          =", stats.Snapshots)
+	fmt.Println("Pruned txs         =", stats.Pruned)
+	fmt.Println("Bytes saved        =", stats.BytesSaved)
+	fmt.Println("Snapshot mismatch  =", stats.Mismatches)
+}
@@ -0,0 +1,118 @@
+package sim
+
+import (
+	"fmt"
+	"sync"
+)
+
+// --- DAG Solidification ---
+
+/*
+	A DAG node only adds a transaction once both parents are in its view (the transaction is solid).
+	Without solidification a transaction with an unknown parent is rejected, which happens when
+	broadcasts are dropped (-direct-delivery). With SimConfig.Solidify the node instead holds the
+	transaction, asks the peers that could have sent it for the missing parents, and attaches
+	everything that becomes solid once the responses arrive.
+
+	Requests and responses travel through a separate, never dropping, control mailbox per node.
+*/
+
+// solidMsg is a missing-parent request (Want) or the response to one (Tx)
+type solidMsg struct {
+	Want string // hash of the transaction asked for
+	From int    // node asking
+	Tx   Transaction
+}
+
+// SolidStats reports how many unsolid DAG transactions were recovered by requesting their parents
+type SolidStats struct {
+	Requests  int // missing parents asked for
+	Recovered int // transactions attached after fetching missing parents (including the fetched parents)
+	Rejected  int // transactions that never became solid
+}
+
+// solidifier keeps the unsolid transactions of a node
+type solidifier struct {
+	unsolid   map[string]Transaction // Hash -> transaction waiting for parents
+	waiting   map[string][]string    // missing parent -> unsolid children
+	requested map[string]bool        // missing parents already asked for
+}
+
+func newSolidifier() *solidifier {
+	return &solidifier{
+		unsolid:   make(map[string]Transaction),
+		waiting:   make(map[string][]string),
+		requested: make(map[string]bool),
+	}
+}
+
+// hold parks t until its missing parents arrive and returns the parents that still need to be requested
+func (s *solidifier) hold(t Transaction, missing []string) []string {
+	if _, held := s.unsolid[t.Hash]; held {
+		return nil
+	}
+	s.unsolid[t.Hash] = t
+	request := []string{}
+	for _, p := range missing {
+		s.waiting[p] = append(s.waiting[p], t.Hash)
+		if !s.requested[p] {
+			s.requested[p] = true
+			request = append(request, p)
+		}
+	}
+	return request
+}
+
+// arrived removes hash from the missing parents and returns the transactions that were waiting for it
+func (s *solidifier) arrived(hash string) []Transaction {
+	children := []Transaction{}
+	for _, c := range s.waiting[hash] {
+		if t, held := s.unsolid[c]; held {
+			delete(s.unsolid, c)
+			children = append(children, t)
+		}
+	}
+	delete(s.waiting, hash)
+	return children
+}
+
+// solidPeers lists the nodes that broadcast to node i, which are the ones worth asking for a missing parent
+func solidPeers(i, N, C int) []int {
+	peers := []int{}
+	for j := range N {
+		if j == i || (getLabel(j, C) == "corrupt" && getLabel(i, C) == "honest") {
+			continue
+		}
+		peers = append(peers, j)
+	}
+	return peers
+}
+
+// solidTracker tallies the solidification events of a simulation
+type solidTracker struct {
+	mu    sync.Mutex
+	stats SolidStats
+}
+
+func trackSolidification(bus *EventBus) *solidTracker {
+	t := &solidTracker{}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		switch e.Kind {
+		case EventRequested:
+			t.stats.Requests++
+		case EventSolidified:
+			t.stats.Recovered++
+		case EventRejected:
+			t.stats.Rejected++
+		}
+	}, EventRequested, EventSolidified, EventRejected)
+	return t
+}
+
+func printSolidStats(stats SolidStats) {
+	fmt.Println("Parent requests    =", stats.Requests)
+	fmt.Println("Recovered txs      =", stats.Recovered)
+	fmt.Println("Rejected txs       =", stats.Rejected)
+}
@@ -0,0 +1,100 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// --- Transaction Spam ---
+
+/*
+	With SimConfig.SpamRate = s every corrupt PoW node floods the network with s tiny
+	self-transactions (Sender = Receiver) per round of transactions, delivered ahead of the round's
+	real transactions to every node, honest or corrupt. Spam is never sent through EventSent, so it
+	does not count toward txSent, txConfirmed, latency or throughput: those now measure the real
+	transactions that still got through.
+
+	Spam alone only makes blocks bigger and slower to hash. With a block size limit (SimConfig.MaxBlockTxs)
+	nodes include their mempool first come, first served, so the real transactions of every round wait
+	behind all the spam that arrived before them.
+*/
+
+// SpamStats reports the spam of a PoW simulation
+type SpamStats struct {
+	Rate        int     // spam transactions per corrupt node and round
+	MaxBlockTxs int     // block size limit, 0 = none
+	Sent        int     // spam transactions sent
+	InWinner    int     // spam transactions in the winning chain
+	Share       float64 // % of the winning chain's transactions that are spam
+}
+
+// isSpam tells spam from real transactions, which never go from a node to itself
+func isSpam(tx Transaction) bool {
+	return tx.Sender == tx.Receiver
+}
+
+// spammer prepends a round of spam to every batch SendTransactions hands out
+type spammer struct {
+	rate, C int
+	limit   int
+	last    int // node of the previous batch, a lower one starts a new round
+	batch   []Transaction
+	sent    int
+}
+
+func newSpammer(cfg SimConfig) *spammer {
+	return &spammer{rate: max(0, cfg.SpamRate), C: cfg.C, limit: cfg.MaxBlockTxs, last: math.MaxInt}
+}
+
+// flood is a split hook for SendTransactions
+func (s *spammer) flood(node int, txs []Transaction) []Transaction {
+	if s.rate == 0 || s.C == 0 {
+		return txs
+	}
+	if node <= s.last { // new round
+		s.batch = make([]Transaction, 0, s.rate*s.C)
+		for i := range s.C {
+			name := nodeName(i, s.C)
+			for range s.rate {
+				s.sent++
+				s.batch = append(s.batch, Transaction{TxID: spamTxIDs + TxID(s.sent), Sender: name, Receiver: name})
+			}
+		}
+	}
+	s.last = node
+	return slices.Concat(s.batch, txs)
+}
+
+func (s *spammer) result(winner []Block) SpamStats {
+	stats := SpamStats{Rate: s.rate, MaxBlockTxs: s.limit, Sent: s.sent}
+	if s.C == 0 {
+		stats.Rate = 0
+	}
+	total := 0
+	seen := make(map[TxID]bool)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			if seen[tx.TxID] {
+				continue
+			}
+			seen[tx.TxID] = true
+			total++
+			if isSpam(tx) {
+				stats.InWinner++
+			}
+		}
+	}
+	if total > 0 {
+		stats.Share = getPercentage(stats.InWinner, total)
+	}
+	return stats
+}
+
+func printSpamStats(stats SpamStats) {
+	fmt.Println("Max block txs      =", stats.MaxBlockTxs)
+	fmt.Println("Spam rate          =", stats.Rate)
+	fmt.Println("Spam sent          =", stats.Sent)
+	fmt.Println("Spam in winner     =", stats.InWinner)
+	fmt.Println("Spam share %       =", stats.Share)
+}
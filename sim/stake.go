@@ -0,0 +1,35 @@
+package sim
+
+// --- Stake ---
+
+/*
+	The stake-based simulators weigh nodes by the stake they hold. By default every node holds the
+	same stake. With SimConfig.CorruptStake = s the corrupt nodes hold the share s of all stake between
+	them and the honest nodes the rest, split evenly within each group, so the corrupt share of stake
+	can be varied independently of the number of corrupt nodes.
+*/
+
+// nodeStakes returns each node's share of all stake, summing to 1
+func nodeStakes(N, C int, cfg SimConfig) []float64 {
+	stakes := make([]float64, N)
+	for i := range N {
+		switch {
+		case cfg.CorruptStake <= 0 || C == 0 || C == N:
+			stakes[i] = 1 / float64(N)
+		case i < C:
+			stakes[i] = cfg.CorruptStake / float64(C)
+		default:
+			stakes[i] = (1 - cfg.CorruptStake) / float64(N-C)
+		}
+	}
+	return stakes
+}
+
+// corruptShare sums the stake of the corrupt nodes
+func corruptShare(stakes []float64, C int) float64 {
+	total := 0.0
+	for _, s := range stakes[:C] {
+		total += s
+	}
+	return total
+}
@@ -0,0 +1,244 @@
+package sim
+
+import (
+	"fmt"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BlockStore holds the blocks a PoW node has seen, keyed by block hash.
+type BlockStore interface {
+	Get(hash string) (Block, bool)
+	Put(b Block)
+	Len() int
+	Close() error
+}
+
+// Block stores of SimConfig.Store
+const (
+	StoreBolt = "bolt"
+	StoreFile = "file"
+)
+
+// newBlockStore returns the block store of a node of cfg, and an in-memory one with the error if its
+// file cannot be created
+func newBlockStore(cfg SimConfig) (BlockStore, error) {
+	if cfg.StoreDir == "" {
+		return memBlockStore{}, nil
+	}
+	if err := os.MkdirAll(cfg.StoreDir, 0755); err != nil {
+		return memBlockStore{}, err
+	}
+	var store BlockStore
+	var err error
+	switch cfg.Store {
+	case "", StoreBolt:
+		store, err = newBoltBlockStore(cfg.StoreDir)
+	case StoreFile:
+		store, err = newDiskBlockStore(cfg.StoreDir)
+	default:
+		err = fmt.Errorf("unknown block store %q (bolt or file)", cfg.Store)
+	}
+	if err != nil {
+		return memBlockStore{}, err
+	}
+	return store, nil
+}
+
+// --- In-Memory Store ---
+
+type memBlockStore map[string]Block
+
+func (m memBlockStore) Get(hash string) (Block, bool) {
+	b, ok := m[hash]
+	return b, ok
+}
+
+func (m memBlockStore) Put(b Block) { m[b.Hash] = b }
+
+func (m memBlockStore) Len() int { return len(m) }
+
+func (m memBlockStore) Close() error { return nil }
+
+// --- Disk-Backed Store ---
+
+/*
+	Blocks are appended to a temporary file as protobuf records (see protobuf.go).
+	Only the hash -> (offset, size) index is kept in memory, so the transactions
+	of old blocks don't count against RAM. The file is removed on Close.
+	Every block comes back as it went in, NaN amounts and invalid UTF-8 included.
+	A block that cannot be written is kept in memory instead and a record that cannot be read is
+	reported missing; the first such I/O error is returned by Close.
+*/
+
+type diskRecord struct {
+	offset int64
+	size   int
+}
+
+type diskBlockStore struct {
+	file     *os.File
+	index    map[string]diskRecord
+	end      int64
+	unstored memBlockStore // blocks whose write failed
+	err      error         // the first I/O error
+}
+
+func newDiskBlockStore(dir string) (*diskBlockStore, error) {
+	file, err := os.CreateTemp(dir, "blocks-*.db")
+	if err != nil {
+		return nil, err
+	}
+	return &diskBlockStore{file: file, index: make(map[string]diskRecord), unstored: memBlockStore{}}, nil
+}
+
+func (s *diskBlockStore) Get(hash string) (Block, bool) {
+	rec, ok := s.index[hash]
+	if !ok {
+		return s.unstored.Get(hash)
+	}
+	data := make([]byte, rec.size)
+	if _, err := s.file.ReadAt(data, rec.offset); err != nil {
+		s.fail(err)
+		return Block{}, false
+	}
+	b, err := unmarshalBlock(data)
+	if err != nil {
+		s.fail(err)
+		return Block{}, false
+	}
+	return b, true
+}
+
+func (s *diskBlockStore) Put(b Block) {
+	data := appendBlock(nil, b)
+	if _, err := s.file.WriteAt(data, s.end); err != nil {
+		s.fail(err)
+		s.unstored.Put(b)
+		return
+	}
+	s.index[b.Hash] = diskRecord{offset: s.end, size: len(data)}
+	s.end += int64(len(data))
+}
+
+func (s *diskBlockStore) Len() int { return len(s.index) + s.unstored.Len() }
+
+// fail records err if it is the first I/O error of the store
+func (s *diskBlockStore) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *diskBlockStore) Close() error {
+	name := s.file.Name()
+	err := s.file.Close()
+	os.Remove(name)
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// --- BoltDB Store ---
+
+/*
+	Each node keeps its blocks in a bbolt database of its own, a temporary file in the store directory
+	with one bucket mapping a block hash to the protobuf record of the block. A block is written in a
+	transaction of its own; the database is removed on Close, so it is opened without fsync. Like the
+	file store, a block that cannot be written is kept in memory and the first I/O error is returned by
+	Close.
+*/
+
+var boltBlocks = []byte("blocks")
+
+type boltBlockStore struct {
+	db       *bolt.DB
+	n        int
+	unstored memBlockStore // blocks whose write failed
+	err      error         // the first I/O error
+}
+
+func newBoltBlockStore(dir string) (*boltBlockStore, error) {
+	file, err := os.CreateTemp(dir, "blocks-*.bolt")
+	if err != nil {
+		return nil, err
+	}
+	path := file.Name()
+	file.Close()
+	db, err := bolt.Open(path, 0600, &bolt.Options{NoSync: true, NoFreelistSync: true})
+	if err == nil {
+		err = db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucket(boltBlocks)
+			return err
+		})
+	}
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		os.Remove(path)
+		return nil, err
+	}
+	return &boltBlockStore{db: db, unstored: memBlockStore{}}, nil
+}
+
+func (s *boltBlockStore) Get(hash string) (Block, bool) {
+	var b Block
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBlocks).Get([]byte(hash))
+		if data == nil {
+			return nil
+		}
+		var err error
+		b, err = unmarshalBlock(data) // copies what it keeps, data is only valid in the transaction
+		found = err == nil
+		return err
+	})
+	if err != nil {
+		s.fail(err)
+		return Block{}, false
+	}
+	if !found {
+		return s.unstored.Get(hash)
+	}
+	return b, true
+}
+
+func (s *boltBlockStore) Put(b Block) {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBlocks)
+		added = bucket.Get([]byte(b.Hash)) == nil
+		return bucket.Put([]byte(b.Hash), appendBlock(nil, b))
+	})
+	if err != nil {
+		s.fail(err)
+		s.unstored.Put(b)
+		return
+	}
+	if added {
+		s.n++
+	}
+}
+
+func (s *boltBlockStore) Len() int { return s.n + s.unstored.Len() }
+
+// fail records err if it is the first I/O error of the store
+func (s *boltBlockStore) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *boltBlockStore) Close() error {
+	path := s.db.Path()
+	err := s.db.Close()
+	os.Remove(path)
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
@@ -0,0 +1,298 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Atomic Swaps ---
+
+/*
+	The swap mode runs a PoW chain and a DAG tangle side by side, and swaps coins between them with
+	hash-timelock contracts (HTLCs). Both have N nodes, the PoW chain C corrupt ones and the tangle
+	SimConfig.SwapDAGCorrupt. Every swap pairs two nodes honest on both, Alice and Bob, and runs the
+	usual four steps, each a transaction of the workload of its chain:
+	1. Alice locks her PoW coins for Bob behind the hash of her secret, refundable after 2*timeout.
+	2. Once the lock is mined, Bob locks his DAG coins for Alice behind the same hash, refundable after
+	   timeout, unless Alice's lock has less than timeout left.
+	3. Once Bob's lock is mined, Alice claims it before it expires, revealing the secret on the tangle.
+	4. Once her claim is mined, Bob claims Alice's lock with the secret before it expires.
+	Each party only acts on what the honest nodes mined (EventMined and EventAccepted), and the runs add
+	rounds after the workload's, swapRounds steps of timeout/4, for the swaps to finish.
+
+	At the end, a claim took effect if it is in the final result of its chain (the winning chain, the
+	confident DAG set), with its lock, and was mined before the lock expired. A swap succeeded if both
+	claims did, timed out if neither did (the locks are refunded), and broke if only one did: with a
+	corrupt majority on one side an honest claim may never settle there, and its party loses its coins.
+
+	SimConfig.Swaps is "n[:timeout]", 10 swaps of 500ms by default. Swap transactions take IDs from
+	swapTxIDs up.
+*/
+
+// swapRounds is how many rounds the swap mode adds after the workload's
+const swapRounds = 12
+
+// SwapStats reports the atomic swaps of the swap mode
+type SwapStats struct {
+	Swaps        int
+	Timeout      time.Duration // of Bob's lock, Alice's is twice as long
+	DAGCorrupt   int
+	DAGConfirmed float64 // txConfirmed % of the DAG
+	Succeeded    int
+	TimedOut     int     // neither claim took effect, both locks refunded
+	Broken       int     // only one claim took effect, its counterpart lost its coins
+	SuccessRate  float64 // % of the swaps
+	TimeoutRate  float64
+	AvgTime      float64 // seconds from Alice's lock to Bob's claim being mined, of the successful swaps
+}
+
+// parseSwaps returns the swaps and the timeout of spec, the defaults for ""
+func parseSwaps(spec string) (int, time.Duration, error) {
+	n, timeout := 10, 500*time.Millisecond
+	if spec == "" {
+		return n, timeout, nil
+	}
+	count, arg, found := strings.Cut(spec, ":")
+	v, err := strconv.Atoi(count)
+	if err != nil || v < 1 {
+		return 0, 0, fmt.Errorf("swaps %q: %q is not a positive count", spec, count)
+	}
+	n = v
+	if found {
+		d, err := time.ParseDuration(arg)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("swaps %q: %q is not a positive duration", spec, arg)
+		}
+		timeout = d
+	}
+	return n, timeout, nil
+}
+
+// swap is one atomic swap, its steps are the IDs of its transactions
+type swap struct {
+	alice, bob int
+	lockA      TxID // PoW
+	lockB      TxID // DAG
+	claimA     TxID // DAG
+	claimB     TxID // PoW
+	start      time.Time
+	deadlineA  time.Time          // Alice's lock expires
+	deadlineB  time.Time          // Bob's lock expires, zero until he locks
+	mined      map[TxID]time.Time // ID -> first mined by an honest node
+}
+
+// swapDesk runs the swaps of the swap mode. The event handlers of both chains and their senders
+// share it, hence the mutex.
+type swapDesk struct {
+	timeout time.Duration
+	R       int // rounds of the workload
+	Cp, Cd  int // corrupt nodes of the PoW chain and the tangle
+
+	mu      sync.Mutex
+	swaps   []*swap
+	byID    map[TxID]*swap
+	queue   map[string][]Transaction // "PoW" / "DAG" -> transactions due with the next round
+	final   map[string]map[TxID]bool
+	started bool
+}
+
+func newSwapDesk(cfg SimConfig, n int, timeout time.Duration) *swapDesk {
+	desk := &swapDesk{timeout: timeout, R: cfg.R, Cp: cfg.C, Cd: cfg.SwapDAGCorrupt, byID: make(map[TxID]*swap),
+		queue: map[string][]Transaction{}, final: map[string]map[TxID]bool{"PoW": {}, "DAG": {}}}
+	first := max(desk.Cp, desk.Cd) // honest on both chains
+	if cfg.N-first < 2 {
+		return desk
+	}
+	id := swapTxIDs
+	for range n {
+		alice := first + rand.IntN(cfg.N-first)
+		bob := first + rand.IntN(cfg.N-first-1)
+		if bob >= alice {
+			bob++
+		}
+		s := &swap{alice: alice, bob: bob, mined: make(map[TxID]time.Time)}
+		for _, step := range []*TxID{&s.lockA, &s.lockB, &s.claimA, &s.claimB} {
+			*step = id
+			desk.byID[id] = s
+			id++
+		}
+		desk.swaps = append(desk.swaps, s)
+	}
+	return desk
+}
+
+// start sends Alice's locks with the first round of the PoW chain
+func (d *swapDesk) start() []Transaction {
+	now := time.Now()
+	txs := []Transaction{}
+	for _, s := range d.swaps {
+		s.start, s.deadlineA = now, now.Add(2*d.timeout)
+		txs = append(txs, Transaction{Sender: nodeName(s.alice, d.Cp), Receiver: "htlc", TxID: s.lockA})
+	}
+	return txs
+}
+
+// watch moves the swaps along as the honest nodes of chain mine their transactions
+func (d *swapDesk) watch(chain string) func(Event) {
+	return func(e Event) {
+		if !strings.HasPrefix(e.Node, "honest") {
+			return
+		}
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, tx := range e.Block.Transactions {
+			s, ok := d.byID[tx.TxID]
+			if !ok {
+				continue
+			}
+			if _, seen := s.mined[tx.TxID]; seen {
+				continue
+			}
+			s.mined[tx.TxID] = e.Time
+			switch {
+			case tx.TxID == s.lockA && e.Time.Add(d.timeout).Before(s.deadlineA): // Bob locks
+				s.deadlineB = e.Time.Add(d.timeout)
+				d.queue["DAG"] = append(d.queue["DAG"], Transaction{Sender: nodeName(s.bob, d.Cd), Receiver: "htlc", TxID: s.lockB})
+			case tx.TxID == s.lockB && e.Time.Before(s.deadlineB): // Alice claims, revealing the secret
+				d.queue["DAG"] = append(d.queue["DAG"], Transaction{Sender: nodeName(s.alice, d.Cd), Receiver: "htlc", TxID: s.claimA})
+			case tx.TxID == s.claimA && e.Time.Before(s.deadlineA): // Bob claims with it
+				d.queue["PoW"] = append(d.queue["PoW"], Transaction{Sender: nodeName(s.bob, d.Cp), Receiver: "htlc", TxID: s.claimB})
+			}
+		}
+	}
+}
+
+// settle records the final result of chain
+func (d *swapDesk) settle(chain string) func(Event) {
+	return func(e Event) {
+		if _, ok := d.byID[e.Tx.TxID]; ok {
+			d.mu.Lock()
+			d.final[chain][e.Tx.TxID] = true
+			d.mu.Unlock()
+		}
+	}
+}
+
+// next adds the swap transactions due to the round of chain to its workload's txs, waiting a step first
+// past the workload's rounds
+func (d *swapDesk) next(ctx context.Context, chain string, round int, workload []Transaction) []Transaction {
+	if round >= d.R {
+		select {
+		case <-time.After(d.timeout / 4):
+		case <-ctx.Done():
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	txs := d.queue[chain]
+	d.queue[chain] = nil
+	if chain == "PoW" && !d.started {
+		d.started = true
+		txs = append(d.start(), txs...)
+	}
+	return append(txs, workload...)
+}
+
+// owns tells the swap transactions apart
+func (d *swapDesk) owns(tx Transaction) bool {
+	_, ok := d.byID[tx.TxID]
+	return ok
+}
+
+// chainConfig returns the config of one chain of the swap mode, whose transactions and events go
+// through the desk
+func (d *swapDesk) chainConfig(ctx context.Context, cfg SimConfig, chain string) SimConfig {
+	bus := NewEventBus()
+	if cfg.Events != nil {
+		bus.Subscribe(cfg.Events.Publish)
+	}
+	bus.Subscribe(d.watch(chain), EventMined, EventAccepted)
+	bus.Subscribe(d.settle(chain), EventConfirmed)
+	cfg.Events = bus
+	cfg.R += swapRounds
+	cfg.WrapWorkload = func(w Workload) Workload {
+		return extendWorkload(w, d.R, func(round int, txs []Transaction) []Transaction {
+			return d.next(ctx, chain, round, txs)
+		}, d.owns)
+	}
+	if chain == "DAG" {
+		cfg.C, cfg.Verbose = d.Cd, false
+	}
+	return cfg
+}
+
+// SimulateSwapCtx runs a PoW chain and a DAG tangle at once and swaps coins between them, see
+// SimConfig.Swaps and SwapDAGCorrupt
+func SimulateSwapCtx(ctx context.Context, cfg SimConfig) SimResult {
+	n, timeout, err := parseSwaps(cfg.Swaps)
+	if err != nil {
+		n, timeout = 0, time.Second
+	}
+	cfg.SwapDAGCorrupt = min(max(cfg.SwapDAGCorrupt, 0), cfg.N)
+	desk := newSwapDesk(cfg, n, timeout)
+
+	var dag SimResult
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dag = SimulateDAGCtx(ctx, desk.chainConfig(ctx, cfg, "DAG"))
+	}()
+	res := SimulateBlockchainCtx(ctx, desk.chainConfig(ctx, cfg, "PoW"))
+	wg.Wait()
+
+	res.Type, res.R = "Swap", cfg.R
+	res.Swap = desk.result(dag)
+	if cfg.Verbose {
+		printSwapStats(res.Swap)
+	}
+	return res
+}
+
+// result settles the swaps on the final results of both chains
+func (d *swapDesk) result(dag SimResult) SwapStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := SwapStats{Swaps: len(d.swaps), Timeout: d.timeout, DAGCorrupt: d.Cd, DAGConfirmed: dag.TxConfirmedPercentage}
+	pow, tangle := d.final["PoW"], d.final["DAG"]
+	total := 0.0
+	for _, s := range d.swaps {
+		minedA, okA := s.mined[s.claimA]
+		minedB, okB := s.mined[s.claimB]
+		claimA := okA && tangle[s.claimA] && tangle[s.lockB] && minedA.Before(s.deadlineB)
+		claimB := okB && pow[s.claimB] && pow[s.lockA] && minedB.Before(s.deadlineA)
+		switch {
+		case claimA && claimB:
+			stats.Succeeded++
+			total += minedB.Sub(s.start).Seconds()
+		case !claimA && !claimB:
+			stats.TimedOut++
+		default:
+			stats.Broken++
+		}
+	}
+	if stats.Swaps > 0 {
+		stats.SuccessRate = getPercentage(stats.Succeeded, stats.Swaps)
+		stats.TimeoutRate = getPercentage(stats.TimedOut, stats.Swaps)
+	}
+	if stats.Succeeded > 0 {
+		stats.AvgTime = total / float64(stats.Succeeded)
+	}
+	return stats
+}
+
+func printSwapStats(stats SwapStats) {
+	fmt.Println("Swaps              =", stats.Swaps)
+	fmt.Println("Swap timeout       =", stats.Timeout)
+	fmt.Println("DAG corrupt nodes  =", stats.DAGCorrupt)
+	fmt.Printf("DAG txConfirmed %%  = %.2f\n", stats.DAGConfirmed)
+	fmt.Printf("Swaps succeeded    = %d (%.2f%%)\n", stats.Succeeded, stats.SuccessRate)
+	fmt.Printf("Swaps timed out    = %d (%.2f%%)\n", stats.TimedOut, stats.TimeoutRate)
+	fmt.Println("Swaps broken       =", stats.Broken)
+	fmt.Printf("Swap time (s)      = %.3f\n", stats.AvgTime)
+}
@@ -0,0 +1,102 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// --- Sybil Identities ---
+
+/*
+	With SimConfig.Sybils = S the corrupt nodes spawn S more identities mid-simulation, once the
+	first block is mined (or every transaction is sent, if that comes first). The Sybils are named
+	like further corrupt nodes (corrupt<C+1> ...) and join with the corrupt nodes' transactions.
+
+	In PoW a Sybil is a full corrupt node: it mines and broadcasts to the other corrupt nodes. Nothing
+	in PoW looks at identities, but every identity hashes on its own goroutine in this simulator, so
+	the Sybils bring their own share of the CPU (the -miners slots) as a real attacker would have to
+	bring hardware. In PoA the signer set is fixed at genesis: a Sybil keeps signing blocks on its own
+	chain and every node rejects them, as the signer is not authorized. In the hybrid mode the Sybils
+	mine but hold no stake, so they cannot attest.
+*/
+
+// SybilStats reports what the Sybil identities achieved
+type SybilStats struct {
+	Identities int // Sybils spawned
+	Blocks     int // blocks the Sybils mined or signed
+	Rejected   int // times a node rejected a Sybil's block
+	InWinner   int // Sybil blocks in the winning chain
+}
+
+// sybilCount resolves cfg.Sybils, only corrupt nodes spawn Sybils
+func sybilCount(cfg SimConfig) int {
+	if cfg.C == 0 {
+		return 0
+	}
+	return max(0, cfg.Sybils)
+}
+
+// sybilNames are the names of the S Sybils of C corrupt nodes
+func sybilNames(C, S int) []string {
+	names := make([]string, S)
+	for k := range S {
+		names[k] = nodeName(C+k, C+S)
+	}
+	return names
+}
+
+// sybilSpawn wakes the Sybils once, closing joined
+type sybilSpawn struct {
+	once   sync.Once
+	joined chan struct{}
+}
+
+func newSybilSpawn() *sybilSpawn {
+	return &sybilSpawn{joined: make(chan struct{})}
+}
+
+func (s *sybilSpawn) spawn() {
+	s.once.Do(func() { close(s.joined) })
+}
+
+// sybilTracker counts the Sybils' blocks and their rejections
+type sybilTracker struct {
+	mu    sync.Mutex
+	names []string
+	stats SybilStats
+}
+
+func trackSybils(bus *EventBus, names []string) *sybilTracker {
+	t := &sybilTracker{names: names, stats: SybilStats{Identities: len(names)}}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if !slices.Contains(names, e.Block.Miner) {
+			return
+		}
+		if e.Kind == EventDelivered { // once per block, PoA Sybils publish no EventMined to stay out of the fork stats
+			t.stats.Blocks++
+		} else {
+			t.stats.Rejected++
+		}
+	}, EventDelivered, EventRejected)
+	return t
+}
+
+func (t *sybilTracker) result(winner []Block) SybilStats {
+	stats := t.stats
+	for _, b := range winner {
+		if slices.Contains(t.names, b.Miner) {
+			stats.InWinner++
+		}
+	}
+	return stats
+}
+
+func printSybilStats(stats SybilStats) {
+	fmt.Println("Sybil identities   =", stats.Identities)
+	fmt.Println("Sybil blocks       =", stats.Blocks)
+	fmt.Println("Sybil rejections   =", stats.Rejected)
+	fmt.Println("Sybil blocks won   =", stats.InWinner)
+}
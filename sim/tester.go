@@ -0,0 +1,1333 @@
+package sim
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+	terminal command to run main():
+	"go run *.go"
+*/
+
+type BenchmarkConfig struct {
+	N       int
+	C       int
+	R       int
+	D       int
+	p       float64
+	Timeout time.Duration // per simulation, a slower run is recorded as "timeout" (0 = use -timeout)
+	Buffer  int           // receiver channel capacity, see SimConfig.ReceiverBuffer (0 = use -buffer)
+}
+
+// simulators are the simulation modes a test can run, see Options.Modes
+var simulators = map[string]func(context.Context, SimConfig) SimResult{
+	"pow":       SimulateBlockchainCtx,
+	"dag":       SimulateDAGCtx,
+	"bft":       SimulateBFTCtx,
+	"raft":      SimulateRaftCtx,
+	"dpos":      SimulateDPoSCtx,
+	"poa":       SimulatePoACtx,
+	"committee": SimulateCommitteeCtx,
+	"hybrid":    SimulateHybridCtx,
+	"rollup":    SimulateRollupCtx,
+	"swap":      SimulateSwapCtx,
+	"shard":     SimulateShardCtx,
+	"sidechain": SimulateSidechainCtx,
+	"des":       SimulateDESCtx,
+}
+
+// Options are the settings of a run of the suite; main.go reads them from its flags, named after each
+// field in the comments
+type Options struct {
+	// Config is the SimConfig of every simulation, the test sets its N, C, R, D, P, Timeout and
+	// ReceiverBuffer; a negative SwapDAGCorrupt is the test's C
+	Config SimConfig
+
+	Timeout     time.Duration // -timeout: per simulation of tests without their own Timeout (0 = no limit)
+	Buffer      int           // -buffer: receiver capacity of tests without their own Buffer (0 = simulator default)
+	Modes       []string      // -modes: the simulators every test runs, in this order
+	Test        int           // -test: run only this test of the suite (1-based) if set
+	RoundBudget time.Duration // -round-budget: wall-clock time per test, see budget.go
+	Calibrate   bool          // -calibrate: choose the tests' D from Config.TargetBlockTime, see calibrate.go
+
+	Out                string        // -out: CSV the rows go to, see checkpoint.go
+	Append             bool          // -append: add the rows to the end of Out instead of replacing it
+	OutTimestamp       bool          // -out-timestamp: put the start time in the name of Out
+	Resume             bool          // -resume: skip the tests the checkpoint of Out records and append to Out
+	Long               string        // -long: also write the rows in long format to this CSV, see tidy.go
+	Parquet            string        // -parquet: also write Out as Parquet to this file, see parquet.go
+	TimeSeries         string        // -timeseries: also write the time series of every simulation to this CSV
+	TimeSeriesInterval time.Duration // -timeseries-interval: time between the rows of TimeSeries
+	NodeStats          string        // -node-stats: also write per-node statistics to this CSV
+	BlockTree          string        // -block-tree: also write every block tree to this directory
+	BlockTreeFormat    string        // -block-tree-format: dot ("") or mermaid
+	ConfirmationReport string        // -confirmation-report: reversal probability per confirmation depth
+	Report             string        // -report: HTML report of Out, see report.go
+	Plots              string        // -plots: SVG charts of Out, see report.go
+	Chains             string        // -chains: also write every winning chain to this directory, see explorer.go
+
+	Check        bool          // -check: assert the invariants of every simulation, see check.go
+	Record       string        // -record: write a trace of every simulation to this file, see trace.go
+	Replay       string        // -replay: replay this trace instead of running the simulations
+	TUI          bool          // -tui: a live dashboard instead of the logs, see tui.go
+	EventsAddr   string        // -events-ws: stream the events over a WebSocket, see websocket.go
+	OTLPEndpoint string        // -otlp: export the spans of every block, see otel.go
+	PprofAddr    string        // -pprof: serve the pprof endpoints, see resources.go
+	ControlAddr  string        // -control: serve the endpoints pausing the simulations, see control.go
+	Paused       bool          // -paused: start the suite paused
+	Repl         bool          // -repl: read console commands from stdin, see repl.go
+	TxAPIAddr    string        // -tx-api: serve the transaction API, see txapi.go
+	RPCAddr      string        // -rpc: serve the JSON-RPC API, see rpc.go
+	Hold         time.Duration // -hold: keep PoW simulations taking console transactions this long
+
+	Args  []string          // the command line, the first line of a Record trace
+	Flags map[string]string // the value of every flag, for the run metadata, see metadata.go
+}
+
+// DefaultOptions are the Options of a run without flags
+func DefaultOptions() Options {
+	return Options{
+		Config: SimConfig{
+			TargetBlockTime: time.Millisecond,
+			Delta:           50 * time.Millisecond,
+			ScheduleSeed:    1,
+			Seed:            1,
+			SwapDAGCorrupt:  -1,
+			Shards:          4,
+			CrossShard:      0.3,
+			GrindAttempts:   16,
+		},
+		Modes:              []string{"pow", "dag"},
+		Out:                "benchmark_results.csv",
+		TimeSeriesInterval: 10 * time.Millisecond,
+	}
+}
+
+// validate reports the first setting of o the simulators would not understand
+func (o Options) validate() error {
+	cfg := o.Config
+	for _, mode := range o.Modes {
+		if simulators[mode] == nil {
+			return fmt.Errorf("unknown simulator %q", mode)
+		}
+	}
+	switch {
+	case cfg.TipSelection != "" && cfg.TipSelection != TipsUniform && cfg.TipSelection != TipsMCMC:
+		return fmt.Errorf("unknown tip selection %q", cfg.TipSelection)
+	case cfg.ForkChoice != "" && cfg.ForkChoice != ForkLongest && cfg.ForkChoice != ForkHeaviest && cfg.ForkChoice != ForkGHOST:
+		return fmt.Errorf("unknown fork choice %q", cfg.ForkChoice)
+	case cfg.Schedule != "" && cfg.Schedule != ScheduleRandom && cfg.Schedule != ScheduleSplit && cfg.Schedule != ScheduleProposals:
+		return fmt.Errorf("unknown schedule %q", cfg.Schedule)
+	case cfg.Store != "" && cfg.Store != StoreBolt && cfg.Store != StoreFile:
+		return fmt.Errorf("unknown block store %q (bolt or file)", cfg.Store)
+	case cfg.RetargetInterval < 0 || cfg.RetargetInterval == 1:
+		return fmt.Errorf("retarget interval %d: a window needs at least 2 blocks to time (0 = fixed difficulty)", cfg.RetargetInterval)
+	case cfg.SideDifficulty > maxDifficulty:
+		return fmt.Errorf("side difficulty %d is more than the %d hex digits of a hash", cfg.SideDifficulty, maxDifficulty)
+	case o.BlockTreeFormat != "" && o.BlockTreeFormat != "dot" && o.BlockTreeFormat != "mermaid":
+		return fmt.Errorf("unknown block tree format %q (dot or mermaid)", o.BlockTreeFormat)
+	}
+	specs := []struct {
+		spec  string
+		parse func(string) error
+	}{
+		{cfg.Workload, func(s string) error { _, err := parseWorkload(s, 0, 0, 1, 0, nil); return err }},
+		{cfg.Script, func(s string) error { _, err := parseScript(s); return err }},
+		{cfg.Channels, func(s string) error { _, _, _, err := parseChannels(s); return err }},
+		{cfg.Sequencer, func(s string) error { _, _, err := parseSequencer(s); return err }},
+		{cfg.Swaps, func(s string) error { _, _, err := parseSwaps(s); return err }},
+		{cfg.Checkpoints, func(s string) error { _, _, err := parseCheckpoints(s); return err }},
+		{cfg.Amounts, func(s string) error { _, err := parseAmounts(s, nil); return err }},
+		{cfg.Assets, func(s string) error { _, _, err := parseAssets(s); return err }},
+		{cfg.LatencyMatrix, func(s string) error { _, err := parseLatencyMatrix(s); return err }},
+		{cfg.Withhold, func(s string) error { _, _, err := parseWithhold(s); return err }},
+	}
+	for _, s := range specs {
+		if s.spec == "" {
+			continue
+		}
+		if err := s.parse(s.spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run runs the suite with o until it finishes or ctx is done. Finished tests are kept when ctx is done,
+// for Resume; the error is that of a setting or a file the suite needs, or the simulations that broke
+// their invariants with Check.
+func Run(ctx context.Context, o Options) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	log := componentLogger("tester")
+
+	if o.PprofAddr != "" {
+		servePprof(ctx, log, o.PprofAddr)
+	}
+
+	// simulations that broke an invariant with -check
+	violated := 0
+
+	var stream *eventStream
+	if o.EventsAddr != "" {
+		stream = newEventStream()
+		serveEvents(ctx, log, o.EventsAddr, stream)
+		defer stream.flush(time.Second) // let the clients get the last messages
+	}
+
+	var control *simControl
+	if o.ControlAddr != "" {
+		control = newSimControl(ctx, o.Paused)
+		serveControl(ctx, log, o.ControlAddr, control)
+	}
+
+	var commands *console
+	if o.Repl || o.TxAPIAddr != "" || o.RPCAddr != "" {
+		commands = newConsole(os.Stdout)
+		commands.hold = o.Hold
+	}
+	if o.Repl {
+		go commands.run(ctx, os.Stdin)
+	}
+	if o.TxAPIAddr != "" {
+		serveTxAPI(ctx, log, o.TxAPIAddr, commands)
+	}
+	if o.RPCAddr != "" {
+		serveRPC(ctx, log, o.RPCAddr, commands)
+	}
+
+	var recorder *traceRecorder
+	if o.Record != "" {
+		r, err := newTraceRecorder(o.Record, o.Args)
+		if err != nil {
+			return fmt.Errorf("recording trace: %w", err)
+		}
+		recorder = r
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Error("recording trace failed", "file", o.Record, "err", err)
+			}
+			if recorder.skipped > 0 {
+				log.Warn("events left out of the trace, JSON cannot encode them", "file", o.Record, "events", recorder.skipped)
+			}
+		}()
+	}
+	var replay *traceReplay
+	if o.Replay != "" {
+		r, err := openTrace(o.Replay, log)
+		if err != nil {
+			return fmt.Errorf("reading trace: %w", err)
+		}
+		replay = r
+		defer replay.Close()
+	}
+
+	start := time.Now()
+	resultsFile := o.Out
+	if o.OutTimestamp {
+		if o.Resume {
+			return errors.New("-resume continues a fixed -out, it cannot be combined with -out-timestamp")
+		}
+		resultsFile = timestampedPath(resultsFile, start)
+	}
+
+	tests := []BenchmarkConfig{
+		{N: 10, C: 2, R: 3, D: 1, p: 0.8},
+		{N: 10, C: 4, R: 3, D: 2, p: 0.8},
+		{N: 15, C: 5, R: 2, D: 1, p: 0.6},
+		{N: 15, C: 10, R: 2, D: 2, p: 0.6},
+		{N: 20, C: 10, R: 2, D: 1, p: 0.4},
+		{N: 20, C: 15, R: 2, D: 2, p: 0.4},
+		{N: 25, C: 10, R: 1, D: 1, p: 0.2},
+		{N: 25, C: 15, R: 1, D: 2, p: 0.2},
+	}
+	if o.Calibrate {
+		rate := hashRate(SimConfig{HashRate: o.Config.HashRate})
+		if !o.Config.FastMining {
+			rate = measureHashRate(o.Config.hashing(), calibrationTime)
+		}
+		log.Info("calibrating difficulty", "hash_rate", int(rate), "target_block_time", o.Config.TargetBlockTime)
+		for k, t := range tests {
+			tests[k].D = calibratedDifficulty(rate, t.N, o.Config.TargetBlockTime, o.Config.FastMining)
+			log.Debug("calibrated test", "test", k+1, "N", t.N, "D", tests[k].D,
+				"expected_block_time", expectedBlockTime(rate, t.N, tests[k].D, o.Config.FastMining))
+		}
+	}
+
+	// Resume from the last finished test if a checkpoint exists
+	completed := 0
+	if o.Resume {
+		completed = loadCheckpoint(resultsFile)
+	}
+
+	appending := completed > 0 || o.Append
+	if info, err := os.Stat(resultsFile); !appending && err == nil && info.Size() > 0 {
+		log.Warn("overwriting earlier results, -append or -out-timestamp keeps them", "file", resultsFile)
+	}
+	file, rows, err := openResults(resultsFile, appending)
+	if err != nil {
+		return fmt.Errorf("opening results: %w", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+	meta := newRunMetadata(start, o, tests)
+	meta.FirstRow = rows + 1
+	var long *csv.Writer
+	if o.Long != "" {
+		longOut, w, err := openLong(o.Long, appending)
+		if err != nil {
+			return fmt.Errorf("opening long results: %w", err)
+		}
+		defer longOut.Close()
+		long = w
+		defer long.Flush()
+	}
+
+	// Headers
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		writeHeaders(writer)
+	}
+
+	var dash *dashboard
+	if o.TUI {
+		if logLevel.Level() < slog.LevelWarn {
+			logLevel.Set(slog.LevelWarn)
+		}
+		dash = newDashboard(os.Stdout, (len(tests)-completed)*len(o.Modes))
+		var interrupt context.CancelFunc
+		ctx, interrupt = context.WithCancel(ctx) // tcell gets Ctrl-C as a key instead of a signal
+		defer interrupt()
+		dashCtx, stopDash := context.WithCancel(ctx)
+		drawn := make(chan struct{})
+		go func() {
+			defer close(drawn)
+			dash.run(dashCtx, interrupt)
+		}()
+		defer func() { stopDash(); <-drawn }()
+	}
+
+	num := 0
+	finished := []BenchmarkConfig{}
+	depths := []DepthCount{} // confirmation depths of every PoW simulation, see -confirmation-report
+	log.Info("starting benchmark suite", "tests", len(tests))
+	if completed > 0 {
+		log.Info("resuming from checkpoint", "after_test", completed)
+	}
+	for _, t := range tests {
+		num += 1
+		if num <= completed || (o.Test > 0 && num != o.Test) {
+			continue
+		}
+		log.Info("running test", "test", num, "N", t.N, "C", t.C, "R", t.R, "D", t.D, "p", t.p)
+
+		cfg := o.config(t)
+		cfg.console = commands
+
+		results := []SimResult{}
+		for _, mode := range o.Modes {
+			if replay != nil && !replay.has(num, mode) { // not in the trace
+				continue
+			}
+			modeCfg := cfg
+			t := t // R is the mode's own with -round-budget
+			if o.RoundBudget > 0 && replay == nil {
+				rounds, pilot := budgetRounds(ctx, mode, modeCfg, o.RoundBudget/time.Duration(len(o.Modes)))
+				modeCfg.R, t.R = rounds, rounds
+				log.Info("rounds chosen for the budget", "test", num, "mode", mode, "R", rounds, "pilots", pilot)
+			}
+			var tree *blockTree
+			ends := []func(SimResult){}
+			if o.BlockTree != "" || stream != nil || dash != nil || o.OTLPEndpoint != "" || o.Check || recorder != nil || control != nil || o.Chains != "" || o.TimeSeries != "" {
+				modeCfg.Events = NewEventBus()
+			}
+			if control != nil {
+				ends = append(ends, control.watch(modeCfg.Events, num, mode, t))
+			}
+			if o.BlockTree != "" {
+				tree = trackBlockTree(modeCfg.Events)
+			}
+			if stream != nil {
+				ends = append(ends, stream.watch(modeCfg.Events, num, mode, t))
+			}
+			if dash != nil {
+				ends = append(ends, dash.watch(modeCfg.Events, num, mode, t))
+			}
+			if o.OTLPEndpoint != "" {
+				tracer := traceBlocks(modeCfg.Events, num, mode, t)
+				ends = append(ends, func(SimResult) {
+					sent, err := tracer.export(o.OTLPEndpoint)
+					if err != nil {
+						log.Error("exporting spans failed", "endpoint", o.OTLPEndpoint, "sent", sent, "err", err)
+						return
+					}
+					log.Info("spans exported", "test", num, "mode", mode, "spans", sent)
+				})
+			}
+			if o.Check {
+				checker := checkInvariants(modeCfg.Events, modeCfg)
+				ends = append(ends, func(res SimResult) {
+					if ctx.Err() != nil {
+						return // a cancelled simulation stops halfway
+					}
+					found := checker.violations(res)
+					if len(found) == 0 {
+						return
+					}
+					violated++
+					repro := fmt.Sprintf("-test %d -modes %s", num, mode)
+					if mode == "des" {
+						repro += fmt.Sprintf(" -seed %d", modeCfg.Seed)
+					}
+					for _, v := range found[:min(len(found), checkMaxViolations)] {
+						log.Error("invariant violated", "test", num, "mode", mode, "violation", v)
+					}
+					log.Error("simulation broke its invariants", "test", num, "mode", mode, "violations", len(found),
+						"N", t.N, "C", t.C, "R", t.R, "D", t.D, "p", t.p, "repro", repro)
+				})
+			}
+			if recorder != nil {
+				ends = append(ends, recorder.record(modeCfg.Events, num, mode, t))
+			}
+			if o.Chains != "" {
+				chains := collectChains(modeCfg.Events)
+				ends = append(ends, func(res SimResult) {
+					if err := writeChain(o.Chains, num, mode, res, chains); err != nil {
+						log.Error("writing chain failed", "dir", o.Chains, "err", err)
+					}
+				})
+			}
+			if o.TimeSeries != "" {
+				series := trackTimeSeries(modeCfg.Events)
+				ends = append(ends, func(res SimResult) {
+					if err := writeTimeSeries(o.TimeSeries, num, res, series, o.TimeSeriesInterval); err != nil {
+						log.Error("writing time series failed", "file", o.TimeSeries, "err", err)
+					}
+				})
+			}
+			run := simulators[mode]
+			if replay != nil {
+				run = replay.sim(num, mode)
+			}
+			sample := sampleResources()
+			res := run(ctx, modeCfg)
+			if resources := sample(); replay == nil { // a replay keeps the recorded ones
+				res.Resources = resources
+			}
+			for _, end := range ends {
+				end(res)
+			}
+			res.Workload = cmp.Or(cfg.Workload, "uniform") // every simulator takes its transactions from it
+			results = append(results, res)
+			if tree != nil {
+				if err := writeBlockTree(o.BlockTree, o.BlockTreeFormat, num, mode, res, tree); err != nil {
+					log.Error("writing block tree failed", "dir", o.BlockTree, "err", err)
+				}
+			}
+		}
+
+		// Partial results of an interrupted test are discarded so -resume can rerun it
+		if ctx.Err() != nil {
+			break
+		}
+		for _, res := range results {
+			if res.TimedOut {
+				log.Warn("simulation timed out, recording partial metrics", "test", num, "type", res.Type, "timeout", cfg.Timeout)
+			}
+			writer.Write(resultRow(res))
+			meta.Rows++
+			if long != nil {
+				for _, row := range longRows(num, res) {
+					long.Write(row)
+				}
+			}
+			if o.NodeStats != "" {
+				if err := writeNodeStats(o.NodeStats, num, res); err != nil {
+					log.Error("writing node stats failed", "file", o.NodeStats, "err", err)
+				}
+			}
+		}
+
+		for _, res := range results {
+			depths = mergeDepths(depths, res.Confirmations.ByDepth) // PoW only
+		}
+
+		// Flush finished rows and record progress so an interrupted suite can resume here. A single test
+		// leaves the checkpoint alone, the tests before it did not run.
+		writer.Flush()
+		if long != nil {
+			long.Flush()
+		}
+		if o.Test == 0 {
+			if err := saveCheckpoint(resultsFile, num); err != nil {
+				log.Error("saving checkpoint failed, -resume would rerun this test", "file", checkpointPath(resultsFile), "test", num, "err", err)
+			}
+		}
+		finished = append(finished, t)
+	}
+
+	meta.Finished, meta.Interrupted = time.Now(), ctx.Err() != nil
+	if err := writeMetadata(resultsFile, meta, appending); err != nil {
+		log.Error("writing run metadata failed", "file", metadataPath(resultsFile), "err", err)
+	}
+
+	if o.ConfirmationReport != "" {
+		if err := writeConfirmationReport(o.ConfirmationReport, depths); err != nil {
+			log.Error("writing confirmation report failed", "file", o.ConfirmationReport, "err", err)
+		}
+	}
+
+	if o.Report != "" {
+		if err := writeReport(o.Report, resultsFile); err != nil {
+			log.Error("writing report failed", "file", o.Report, "err", err)
+		}
+	}
+	if o.Parquet != "" {
+		if err := writeParquet(o.Parquet, resultsFile, parquetGroupRows); err != nil {
+			log.Error("writing parquet failed", "file", o.Parquet, "err", err)
+		}
+	}
+	if o.Plots != "" {
+		if err := writePlots(o.Plots, resultsFile); err != nil {
+			log.Error("writing plots failed", "dir", o.Plots, "err", err)
+		}
+	}
+
+	duration := time.Since(start)
+	if violated > 0 {
+		log.Error("invariants violated", "simulations", violated)
+	}
+	if ctx.Err() != nil {
+		printInterruptSummary(log, resultsFile, finished, num, len(tests))
+		log.Info("benchmark suite stopped", "duration", duration)
+		return invariantsErr(violated)
+	}
+
+	// The whole suite finished, a later run should start over
+	if o.Test == 0 {
+		clearCheckpoint(resultsFile)
+	}
+
+	log.Info("benchmark suite finished", "duration", duration)
+	return invariantsErr(violated)
+}
+
+func printInterruptSummary(log *slog.Logger, resultsFile string, finished []BenchmarkConfig, interrupted, total int) {
+	log.Warn("interrupted", "during_test", interrupted, "finished", len(finished), "total", total)
+	for _, t := range finished {
+		log.Info("finished test", "N", t.N, "C", t.C, "R", t.R, "D", t.D, "p", t.p)
+	}
+	log.Info("results saved, rerun with -resume to continue", "file", resultsFile)
+}
+
+// invariantsErr is the error of a run in which violated simulations broke their invariants, nil if none did
+func invariantsErr(violated int) error {
+	if violated == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d simulations broke their invariants", violated)
+}
+
+// config returns the SimConfig of t with the settings of o
+func (o Options) config(t BenchmarkConfig) SimConfig {
+	cfg := o.Config
+	cfg.N, cfg.C, cfg.R, cfg.D, cfg.P, cfg.Timeout = t.N, t.C, t.R, t.D, t.p, t.Timeout
+	if cfg.Timeout == 0 {
+		cfg.Timeout = o.Timeout
+	}
+	if cfg.SwapDAGCorrupt < 0 {
+		cfg.SwapDAGCorrupt = cfg.C
+	}
+	cfg.ReceiverBuffer = t.Buffer
+	if cfg.ReceiverBuffer == 0 {
+		cfg.ReceiverBuffer = o.Buffer
+	}
+	return cfg
+}
+
+// resultRow formats a simulation result as a CSV row matching writeHeaders
+func resultRow(res SimResult) []string {
+	row := []string{
+		res.Type,
+		strconv.Itoa(res.N),
+		strconv.Itoa(res.C),
+		fmt.Sprintf("%.2f", res.CorruptPercentage),
+		strconv.Itoa(res.R),
+		fmt.Sprintf("%.2f", res.P),
+		strconv.Itoa(res.D),
+		strconv.Itoa(res.TxSent),
+		strconv.Itoa(res.TxConfirmed),
+		fmt.Sprintf("%.2f", res.TxConfirmedPercentage),
+		fmt.Sprintf("%.2f", res.Duration.Seconds()),
+		res.Winner,
+	}
+	if res.Type == "DAG" {
+		row = append(row,
+			fmt.Sprintf("%.2f", res.AvgConfHonest),
+			fmt.Sprintf("%.2f", res.AvgConfCorrupt),
+		)
+	} else {
+		row = append(row, "", "")
+	}
+	status := "ok"
+	if res.TimedOut {
+		status = "timeout"
+	}
+	return append(row,
+		status,
+		fmt.Sprintf("%.3f", res.Latency.P50.Seconds()),
+		fmt.Sprintf("%.3f", res.Latency.P95.Seconds()),
+		fmt.Sprintf("%.3f", res.Latency.Max.Seconds()),
+		fmt.Sprintf("%.2f", res.Throughput.ConfirmedTPS),
+		strconv.Itoa(res.Throughput.BlocksMined),
+		fmt.Sprintf("%.2f", res.Throughput.BlocksPerSecond),
+		fmt.Sprintf("%.3f", res.Throughput.AvgBlockInterval.Seconds()),
+		forkOnly(res, strconv.Itoa(res.Forks.Orphans)),
+		forkOnly(res, fmt.Sprintf("%.2f", res.Forks.OrphanRate)),
+		forkOnly(res, strconv.Itoa(res.Forks.MaxForkDepth)),
+		forkOnly(res, strconv.Itoa(res.Reorgs.Count)),
+		forkOnly(res, strconv.Itoa(res.Reorgs.MaxDepth)),
+		strconv.Itoa(res.Drops.Broadcasts),
+		strconv.Itoa(res.Drops.Dropped),
+		fmt.Sprintf("%.2f", res.Drops.DropRate),
+		strconv.Itoa(res.ReceiverBuffer),
+		fmt.Sprintf("%.2f", res.Drops.Coverage),
+		dagOnly(res, res.TipSelection),
+		dagOnly(res, fmt.Sprintf("%.2f", res.TipAlpha)),
+		dagOnly(res, strconv.Itoa(res.Conflicts.Injected)),
+		dagOnly(res, strconv.Itoa(res.Conflicts.Detected)),
+		dagOnly(res, fmt.Sprintf("%.2f", res.Conflicts.Agreement)),
+		dagOnly(res, strconv.Itoa(res.Snapshots.Snapshots)),
+		dagOnly(res, strconv.Itoa(res.Snapshots.Pruned)),
+		dagOnly(res, strconv.FormatInt(res.Snapshots.BytesSaved, 10)),
+		dagOnly(res, strconv.Itoa(res.Snapshots.Mismatches)),
+		dagOnly(res, strconv.Itoa(res.Solidification.Requests)),
+		dagOnly(res, strconv.Itoa(res.Solidification.Recovered)),
+		dagOnly(res, strconv.Itoa(res.Solidification.Rejected)),
+		powOnly(res, strconv.Itoa(res.OrphanPool.Held)),
+		powOnly(res, strconv.Itoa(res.OrphanPool.Adopted)),
+		powOnly(res, fmt.Sprintf("%.2f", res.OrphanPool.HitRate)),
+		powOnly(res, res.ForkChoice),
+		powOnly(res, strconv.Itoa(res.ChainLength)),
+		powOnly(res, fmt.Sprintf("%.0f", res.ChainWork)),
+		powOnly(res, strconv.Itoa(res.Uncles.Included)),
+		powOnly(res, fmt.Sprintf("%.2f", res.Uncles.UncleRate)),
+		powOnly(res, fmt.Sprintf("%.2f", res.Uncles.CorruptRewardShare)),
+		powOnly(res, strconv.Itoa(res.Finality.Finalized)),
+		powOnly(res, strconv.FormatInt(res.Finality.AvgTimeToFinality.Milliseconds(), 10)),
+		powOnly(res, strconv.Itoa(res.Finality.Equivocations)),
+		powOnly(res, strconv.Itoa(res.Finality.Conflicting)),
+		powOnly(res, fmt.Sprintf("%.2f", res.Confirmations.AvgConfirmations)),
+		powOnly(res, strconv.Itoa(res.Confirmations.MaxReversedDepth)),
+		bftOnly(res, strconv.Itoa(res.BFT.Heights)),
+		bftOnly(res, strconv.Itoa(res.BFT.Rounds)),
+		bftOnly(res, strconv.Itoa(res.BFT.Stalls)),
+		bftOnly(res, strconv.Itoa(res.BFT.Equivocations)),
+		bftOnly(res, strconv.Itoa(res.BFT.SafetyViolations)),
+		raftOnly(res, strconv.Itoa(res.Raft.Terms)),
+		raftOnly(res, strconv.Itoa(res.Raft.Elections)),
+		raftOnly(res, strconv.Itoa(res.Raft.Crashed)),
+		raftOnly(res, strconv.Itoa(res.Raft.Delayed)),
+		raftOnly(res, strconv.Itoa(res.Raft.LogConflicts)),
+		dposOnly(res, strconv.Itoa(res.DPoS.Delegates)),
+		dposOnly(res, strconv.Itoa(res.DPoS.CorruptDelegates)),
+		dposOnly(res, fmt.Sprintf("%.2f", res.DPoS.CorruptStake)),
+		dposOnly(res, fmt.Sprintf("%.2f", res.DPoS.CaptureStake)),
+		dposOnly(res, fmt.Sprintf("%.2f", res.DPoS.CorruptBlocks)),
+		poaOnly(res, strconv.Itoa(res.PoA.InTurn)),
+		poaOnly(res, strconv.Itoa(res.PoA.OutOfTurn)),
+		poaOnly(res, fmt.Sprintf("%.2f", res.PoA.CorruptBlocks)),
+		poaOnly(res, strconv.Itoa(res.PoA.Invalid)),
+		dagOnly(res, strconv.Itoa(res.Avalanche.Finalized)),
+		dagOnly(res, strconv.Itoa(res.Avalanche.Undecided)),
+		dagOnly(res, strconv.Itoa(res.Avalanche.Split)),
+		dagOnly(res, fmt.Sprintf("%.1f", res.Avalanche.AvgRounds)),
+		dagOnly(res, strconv.FormatInt(res.Avalanche.ConvergenceTime.Milliseconds(), 10)),
+		leaderOnly(res, fmt.Sprintf("%.2f", res.Leaders.CorruptShare)),
+		leaderOnly(res, fmt.Sprintf("%.2f", res.Leaders.FairShare)),
+		leaderOnly(res, strconv.Itoa(res.Leaders.Grinds)),
+		committeeOnly(res, strconv.Itoa(res.Committee.Size)),
+		committeeOnly(res, strconv.Itoa(res.Committee.Committees)),
+		committeeOnly(res, fmt.Sprintf("%.2f", res.Committee.Observed)),
+		committeeOnly(res, fmt.Sprintf("%.2f", res.Committee.Predicted)),
+		committeeOnly(res, strconv.Itoa(res.Committee.Forged)),
+		hybridOnly(res, fmt.Sprintf("%.2f", res.Hybrid.Quorum)),
+		hybridOnly(res, fmt.Sprintf("%.2f", res.Hybrid.CorruptStake)),
+		hybridOnly(res, strconv.Itoa(res.Hybrid.Attested)),
+		hybridOnly(res, strconv.Itoa(res.Hybrid.Unattested)),
+		hybridOnly(res, res.Hybrid.PoWWinner),
+		hybridOnly(res, strconv.Itoa(res.Hybrid.Equivocators)),
+		hybridOnly(res, strconv.Itoa(res.Hybrid.CorruptEquiv)),
+		hybridOnly(res, strconv.Itoa(res.Hybrid.Slashed)),
+		hybridOnly(res, fmt.Sprintf("%.2f", res.Hybrid.SlashedStake)),
+		hybridOnly(res, fmt.Sprintf("%.2f", res.Hybrid.CorruptLeft)),
+		longRangeOnly(res, strconv.Itoa(res.LongRange.ForkHeight)),
+		longRangeOnly(res, fmt.Sprintf("%.2f", res.LongRange.KeyStake)),
+		longRangeOnly(res, strconv.FormatBool(res.LongRange.Fooled)),
+		longRangeOnly(res, strconv.Itoa(res.LongRange.Checkpoint)),
+		longRangeOnly(res, strconv.FormatBool(res.LongRange.FooledWS)),
+		sybilOnly(res, strconv.Itoa(res.Sybils.Identities)),
+		sybilOnly(res, strconv.Itoa(res.Sybils.Blocks)),
+		sybilOnly(res, strconv.Itoa(res.Sybils.Rejected)),
+		sybilOnly(res, strconv.Itoa(res.Sybils.InWinner)),
+		bribeOnly(res, strconv.FormatFloat(res.Bribes.Rate, 'f', -1, 64)),
+		bribeOnly(res, strconv.Itoa(res.Bribes.Bribed)),
+		bribeOnly(res, strconv.Itoa(res.Bribes.BribedBlocks)),
+		bribeOnly(res, strconv.FormatFloat(res.Bribes.Budget, 'f', -1, 64)),
+		bribeOnly(res, strconv.FormatBool(res.Bribes.Flipped)),
+		powOnly(res, strconv.Itoa(res.Spam.MaxBlockTxs)),
+		spamOnly(res, strconv.Itoa(res.Spam.Rate)),
+		spamOnly(res, strconv.Itoa(res.Spam.Sent)),
+		spamOnly(res, strconv.Itoa(res.Spam.InWinner)),
+		spamOnly(res, fmt.Sprintf("%.2f", res.Spam.Share)),
+		res.Withholding.Strategy,
+		withholdOnly(res, strconv.Itoa(res.Withholding.Releases)),
+		withholdOnly(res, strconv.Itoa(res.Withholding.Released)),
+		withholdOnly(res, strconv.Itoa(res.Withholding.Matches)),
+		withholdOnly(res, strconv.Itoa(res.Withholding.Overrides)),
+		timeWarpOnly(res, res.TimeWarp.Warp.String()),
+		timeWarpOnly(res, strconv.Itoa(res.TimeWarp.CorruptBlocks)),
+		timeWarpOnly(res, strconv.Itoa(res.TimeWarp.HonestBlocks)),
+		timeWarpOnly(res, fmt.Sprintf("%.2f", res.TimeWarp.CorruptDifficulty)),
+		timeWarpOnly(res, fmt.Sprintf("%.2f", res.TimeWarp.HonestDifficulty)),
+		timeWarpOnly(res, strconv.Itoa(res.TimeWarp.Rejected)),
+		res.Schedule.Schedule,
+		scheduleOnly(res, res.Schedule.Delta.String()),
+		scheduleOnly(res, strconv.FormatUint(res.Schedule.Seed, 10)),
+		scheduleOnly(res, strconv.Itoa(res.Schedule.Delayed)),
+		scheduleOnly(res, fmt.Sprintf("%.2f", res.Schedule.AvgDelay.Seconds()*1000)),
+		desOnly(res, strconv.FormatUint(res.DES.Seed, 10)),
+		desOnly(res, fmt.Sprintf("%.2f", res.DES.VirtualTime.Seconds())),
+		desOnly(res, strconv.Itoa(res.DES.Events)),
+		desOnly(res, strconv.Itoa(res.DES.Orphans)),
+		fastMiningOnly(res, fmt.Sprintf("%g", res.HashRate)),
+		churnOnly(res, fmt.Sprintf("%g", res.Churn.Rate)),
+		churnOnly(res, strconv.Itoa(res.Churn.Joiners)),
+		churnOnly(res, strconv.Itoa(res.Churn.Left)),
+		churnOnly(res, strconv.Itoa(res.Churn.Joined)),
+		churnOnly(res, strconv.Itoa(res.IBD.Syncs)),
+		churnOnly(res, strconv.Itoa(res.IBD.Blocks)),
+		churnOnly(res, fmt.Sprintf("%.2f", res.IBD.AvgHeight)),
+		churnOnly(res, fmt.Sprintf("%.2f", res.IBD.AvgTime.Seconds()*1000)),
+		churnOnly(res, fmt.Sprintf("%.2f", res.IBD.MaxTime.Seconds()*1000)),
+		banOnly(res, strconv.Itoa(res.Bans.Threshold)),
+		banOnly(res, strconv.Itoa(res.Bans.Forged)),
+		banOnly(res, strconv.Itoa(res.Bans.Invalid)),
+		banOnly(res, strconv.Itoa(res.Bans.Bans)),
+		banOnly(res, strconv.Itoa(res.Bans.HonestBans)),
+		banOnly(res, fmt.Sprintf("%.2f", res.Bans.Isolated)),
+		banOnly(res, fmt.Sprintf("%.3f", res.Bans.IsolationTime.Seconds())),
+		res.LatencyMatrix,
+		formatRegions(res.Regions),
+		propagationOnly(res, fmt.Sprintf("%g", res.Propagation.Bandwidth)),
+		propagationOnly(res, fmt.Sprintf("%.0f", res.Propagation.AvgBlockBytes)),
+		propagationOnly(res, fmt.Sprintf("%.2f", res.Propagation.AvgPropagation.Seconds()*1000)),
+		propagationOnly(res, fmt.Sprintf("%.2f", res.Propagation.MaxPropagation.Seconds()*1000)),
+		gossipOnly(res, fmt.Sprint(res.Gossip.Fanout)),
+		gossipOnly(res, fmt.Sprint(res.Gossip.Relays)),
+		gossipOnly(res, fmt.Sprint(res.Gossip.Coverage)),
+		gossipOnly(res, fmt.Sprint(res.Gossip.Full)),
+		res.Workload,
+		valueOnly(res, res.Value.Sent.String()),
+		valueOnly(res, res.Value.Confirmed.String()),
+		valueOnly(res, fmt.Sprintf("%.2f", res.Value.Percent)),
+		formatAssets(res.Value.Assets),
+		rbfOnly(res, fmt.Sprint(res.RBF.Rate)),
+		rbfOnly(res, strconv.Itoa(res.RBF.Replacements)),
+		rbfOnly(res, strconv.Itoa(res.RBF.Swapped)),
+		rbfOnly(res, strconv.Itoa(res.RBF.ConfirmedReplacement)),
+		rbfOnly(res, strconv.Itoa(res.RBF.ConfirmedOriginal)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.Funded)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.Unfunded)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.Dropped)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.Rejected)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.DoubleSpends)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.Twins)),
+		utxoOnly(res, strconv.Itoa(res.UTXO.Unspent)),
+		res.Script.Spec,
+		scriptOnly(res, strconv.Itoa(res.Script.Runs)),
+		scriptOnly(res, strconv.Itoa(res.Script.Steps)),
+		scriptOnly(res, strconv.Itoa(res.Script.Failed)),
+		scriptOnly(res, strconv.Itoa(res.Script.Locked)),
+		scriptOnly(res, strconv.Itoa(res.Script.Rejected)),
+		scriptOnly(res, strconv.FormatFloat(float64(res.Script.Time.Microseconds())/1000, 'f', 2, 64)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Channels)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Updates)),
+		channelsOnly(res, strconv.Itoa(res.Channels.OnChain)),
+		channelsOnly(res, strconv.FormatFloat(res.Channels.Reduction, 'f', 2, 64)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Settled)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Disputes)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Honest)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Penalized)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Cheated)),
+		channelsOnly(res, strconv.Itoa(res.Channels.Unsettled)),
+		channelsOnly(res, strconv.FormatFloat(res.Channels.Stolen, 'f', 2, 64)),
+		res.Rollup.Sequencer,
+		rollupOnly(res, strconv.Itoa(res.Rollup.Batches)),
+		rollupOnly(res, strconv.Itoa(res.Rollup.Committed)),
+		rollupOnly(res, strconv.Itoa(res.Rollup.Censored)),
+		rollupOnly(res, strconv.Itoa(res.Rollup.Forced)),
+		rollupOnly(res, strconv.Itoa(res.Rollup.Withheld)),
+		rollupOnly(res, strconv.Itoa(res.Rollup.Lost)),
+		rollupOnly(res, strconv.Itoa(res.Rollup.L1Txs)),
+		rollupOnly(res, strconv.FormatFloat(res.Rollup.Compression, 'f', 2, 64)),
+		swapOnly(res, strconv.Itoa(res.Swap.DAGCorrupt)),
+		swapOnly(res, strconv.Itoa(res.Swap.Swaps)),
+		swapOnly(res, res.Swap.Timeout.String()),
+		swapOnly(res, strconv.FormatFloat(res.Swap.DAGConfirmed, 'f', 2, 64)),
+		swapOnly(res, strconv.Itoa(res.Swap.Succeeded)),
+		swapOnly(res, strconv.Itoa(res.Swap.TimedOut)),
+		swapOnly(res, strconv.Itoa(res.Swap.Broken)),
+		swapOnly(res, strconv.FormatFloat(res.Swap.SuccessRate, 'f', 2, 64)),
+		swapOnly(res, strconv.FormatFloat(res.Swap.TimeoutRate, 'f', 2, 64)),
+		swapOnly(res, strconv.FormatFloat(res.Swap.AvgTime, 'f', 3, 64)),
+		shardOnly(res, strconv.Itoa(res.Shard.Shards)),
+		res.Shard.Corrupt,
+		shardOnly(res, strconv.Itoa(res.Shard.Captured)),
+		shardOnly(res, strconv.FormatFloat(res.Shard.MaxCorrupt, 'f', 2, 64)),
+		shardOnly(res, strconv.FormatFloat(res.Shard.Takeover, 'f', 2, 64)),
+		shardOnly(res, strconv.Itoa(res.Shard.CrossSent)),
+		shardOnly(res, strconv.Itoa(res.Shard.CrossConfirmed)),
+		shardOnly(res, strconv.FormatFloat(res.Shard.CrossLatency.P50.Seconds(), 'f', 3, 64)),
+		shardOnly(res, strconv.FormatFloat(res.Shard.CrossLatency.P95.Seconds(), 'f', 3, 64)),
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.Difficulty)),
+		sidechainOnly(res, fmt.Sprintf("%d:%d", res.Sidechain.Interval, res.Sidechain.Depth)),
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.Blocks)),
+		res.Sidechain.Winner,
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.Reorgs)),
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.Checkpoints)),
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.Confirmed)),
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.ReorgsPast)),
+		sidechainOnly(res, strconv.Itoa(res.Sidechain.Violated)),
+		strconv.FormatFloat(float64(res.Resources.PeakHeap)/(1<<20), 'f', 2, 64),
+		strconv.Itoa(res.Resources.PeakGoroutines),
+		strconv.Itoa(res.Resources.GCCycles),
+		strconv.FormatFloat(res.Resources.GCPause.Seconds()*1000, 'f', 3, 64),
+		strconv.FormatFloat(res.Resources.MaxGCPause.Seconds()*1000, 'f', 3, 64),
+		strconv.FormatFloat(float64(res.Resources.PeakRSS)/(1<<20), 'f', 2, 64),
+		strconv.FormatFloat(float64(res.Resources.Allocated)/(1<<20), 'f', 2, 64),
+		strconv.FormatUint(res.Resources.Allocations, 10),
+	)
+}
+
+// powOnly blanks out a column that only PoW (hybrid or not) fills in
+func powOnly(res SimResult, value string) string {
+	if res.Type != "PoW" && res.Type != "Hybrid" && res.Type != "Rollup" && res.Type != "Swap" && res.Type != "Sidechain" {
+		return ""
+	}
+	return value
+}
+
+// forkOnly blanks out the fork and reorg columns of simulators without competing chains
+func forkOnly(res SimResult, value string) string {
+	if res.Type != "PoW" && res.Type != "Hybrid" && res.Type != "Rollup" && res.Type != "Swap" && res.Type != "Sidechain" && res.Type != "PoA" {
+		return ""
+	}
+	return value
+}
+
+// dagOnly blanks out a column that has no meaning for PoW
+func dagOnly(res SimResult, value string) string {
+	if res.Type != "DAG" {
+		return ""
+	}
+	return value
+}
+
+// bftOnly blanks out a column that only the BFT simulator fills in
+func bftOnly(res SimResult, value string) string {
+	if res.Type != "BFT" {
+		return ""
+	}
+	return value
+}
+
+// raftOnly blanks out a column that only the Raft simulator fills in
+func raftOnly(res SimResult, value string) string {
+	if res.Type != "Raft" {
+		return ""
+	}
+	return value
+}
+
+// dposOnly blanks out a column that only the DPoS simulator fills in
+func dposOnly(res SimResult, value string) string {
+	if res.Type != "DPoS" {
+		return ""
+	}
+	return value
+}
+
+// leaderOnly blanks out the VRF leader columns of simulators that did not elect leaders by VRF
+func leaderOnly(res SimResult, value string) string {
+	if res.Leaders.Elections == 0 {
+		return ""
+	}
+	return value
+}
+
+// committeeOnly blanks out a column that only the committee simulator fills in
+func committeeOnly(res SimResult, value string) string {
+	if res.Type != "Committee" {
+		return ""
+	}
+	return value
+}
+
+// hybridOnly blanks out a column that only the hybrid PoW/PoS simulator fills in
+func hybridOnly(res SimResult, value string) string {
+	if res.Type != "Hybrid" {
+		return ""
+	}
+	return value
+}
+
+// longRangeOnly blanks out the long-range attack columns of simulations without the attack
+func longRangeOnly(res SimResult, value string) string {
+	if res.LongRange.AttackBlocks == 0 {
+		return ""
+	}
+	return value
+}
+
+// sybilOnly blanks out the Sybil columns of simulations without Sybils
+func sybilOnly(res SimResult, value string) string {
+	if res.Sybils.Identities == 0 {
+		return ""
+	}
+	return value
+}
+
+// bribeOnly blanks out the bribery columns of simulations without bribes
+func bribeOnly(res SimResult, value string) string {
+	if res.Bribes.Rate == 0 {
+		return ""
+	}
+	return value
+}
+
+// spamOnly blanks out the spam columns of simulations without spam
+func spamOnly(res SimResult, value string) string {
+	if res.Spam.Rate == 0 {
+		return ""
+	}
+	return value
+}
+
+// withholdOnly blanks out the release columns of simulations without a withholding strategy
+func withholdOnly(res SimResult, value string) string {
+	if res.Withholding.Strategy == "" {
+		return ""
+	}
+	return value
+}
+
+// timeWarpOnly blanks out the time-warp columns of simulations without the attack
+func timeWarpOnly(res SimResult, value string) string {
+	if res.TimeWarp.Warp == 0 {
+		return ""
+	}
+	return value
+}
+
+// scheduleOnly blanks out the scheduler columns of simulations without an adversarial schedule
+func scheduleOnly(res SimResult, value string) string {
+	if res.Schedule.Schedule == "" {
+		return ""
+	}
+	return value
+}
+
+// fastMiningOnly blanks out the hash rate of simulations that hash for real
+func fastMiningOnly(res SimResult, value string) string {
+	if res.HashRate == 0 {
+		return ""
+	}
+	return value
+}
+
+// churnOnly blanks out the churn columns of simulations without churn or joiners
+func churnOnly(res SimResult, value string) string {
+	if res.Churn.Rate == 0 && res.Churn.Joiners == 0 {
+		return ""
+	}
+	return value
+}
+
+// banOnly blanks out the peer scoring columns of simulations without banning or forged blocks
+func banOnly(res SimResult, value string) string {
+	if res.Bans.Threshold == 0 && res.Bans.Forged == 0 {
+		return ""
+	}
+	return value
+}
+
+// propagationOnly blanks out the propagation columns of simulations whose blocks arrive at once
+func propagationOnly(res SimResult, value string) string {
+	if res.Propagation.Blocks == 0 {
+		return ""
+	}
+	return value
+}
+
+// gossipOnly blanks out the relay columns of simulations that hand the transactions to every node
+func gossipOnly(res SimResult, value string) string {
+	if res.Gossip.Fanout == 0 {
+		return ""
+	}
+	return value
+}
+
+// valueOnly blanks out a column of transaction values when the transactions carried none
+func valueOnly(res SimResult, value string) string {
+	if res.Value.Sent == 0 {
+		return ""
+	}
+	return value
+}
+
+// rbfOnly blanks out a column of the fee bumps, which only PoW makes
+func rbfOnly(res SimResult, value string) string {
+	if res.RBF.Rate == 0 {
+		return ""
+	}
+	return value
+}
+
+// utxoOnly blanks out a column of the UTXO spends, which only PoW makes
+func utxoOnly(res SimResult, value string) string {
+	if res.UTXO.Funded+res.UTXO.Unfunded == 0 {
+		return ""
+	}
+	return value
+}
+
+// scriptOnly blanks out a column of the script validation, which only PoW does
+func scriptOnly(res SimResult, value string) string {
+	if res.Script.Spec == "" {
+		return ""
+	}
+	return value
+}
+
+// channelsOnly blanks out a column of the payment channels, which only PoW opens
+func channelsOnly(res SimResult, value string) string {
+	if res.Channels.Channels == 0 {
+		return ""
+	}
+	return value
+}
+
+// rollupOnly blanks out a column that only the rollup mode fills in
+func rollupOnly(res SimResult, value string) string {
+	if res.Type != "Rollup" {
+		return ""
+	}
+	return value
+}
+
+// swapOnly blanks out a column that only the swap mode fills in
+func swapOnly(res SimResult, value string) string {
+	if res.Type != "Swap" {
+		return ""
+	}
+	return value
+}
+
+// shardOnly blanks out a column that only the shard mode fills in
+func shardOnly(res SimResult, value string) string {
+	if res.Type != "Shard" {
+		return ""
+	}
+	return value
+}
+
+// sidechainOnly blanks out a column that only the sidechain mode fills in
+func sidechainOnly(res SimResult, value string) string {
+	if res.Type != "Sidechain" {
+		return ""
+	}
+	return value
+}
+
+// poaOnly blanks out a column that only the PoA simulator fills in
+func poaOnly(res SimResult, value string) string {
+	if res.Type != "PoA" {
+		return ""
+	}
+	return value
+}
+
+// desOnly blanks out a column that only the discrete-event engine fills in
+func desOnly(res SimResult, value string) string {
+	if res.Type != "DES" {
+		return ""
+	}
+	return value
+}
+
+func writeHeaders(writer *csv.Writer) {
+	writer.Write(resultHeaders())
+}
+
+// resultHeaders names the columns of resultRow
+func resultHeaders() []string {
+	return []string{
+		"Simulation Type",
+		"Total Nodes",
+		"Corrupt Nodes",
+		"Corrupt %",
+		"Rounds",
+		"Broadcast Probability",
+		"Difficulty",
+		"txSent",
+		"txConfirmed",
+		"txConfirmed %",
+		"Time (s)",
+		"Winner",
+		"avgConf_Honest",
+		"avgConf_Corrupt",
+		"Status",
+		"Latency p50 (s)",
+		"Latency p95 (s)",
+		"Latency max (s)",
+		"Confirmed TPS",
+		"Blocks Mined",
+		"Blocks/s",
+		"Avg Block Interval (s)",
+		"Orphans",
+		"Orphan %",
+		"Max Fork Depth",
+		"Reorgs",
+		"Max Reorg Depth",
+		"Broadcasts",
+		"Dropped",
+		"Drop %",
+		"Receiver Buffer",
+		"Propagation Coverage %",
+		"Tip Selection",
+		"Alpha",
+		"Double Spends",
+		"Conflicts Detected",
+		"Conflict Agreement %",
+		"Snapshots",
+		"Pruned Txs",
+		"Snapshot Bytes Saved",
+		"Snapshot Mismatches",
+		"Parent Requests",
+		"Recovered Txs",
+		"Rejected Txs",
+		"Orphan Pool Held",
+		"Orphan Pool Hits",
+		"Orphan Pool Hit %",
+		"Fork Choice",
+		"Chain Length",
+		"Chain Work",
+		"Uncles",
+		"Uncle Rate %",
+		"Corrupt Reward %",
+		"Finalized Checkpoints",
+		"Time To Finality (ms)",
+		"Equivocations",
+		"Conflicting Finalized",
+		"Avg Confirmations",
+		"Max Reversed Depth",
+		"BFT Heights",
+		"BFT Rounds",
+		"Liveness Stalls",
+		"BFT Equivocations",
+		"Safety Violations",
+		"Raft Terms",
+		"Raft Elections",
+		"Crashed Nodes",
+		"Delayed Nodes",
+		"Log Conflicts",
+		"Delegates",
+		"Corrupt Delegates",
+		"Corrupt Stake %",
+		"Capture Stake %",
+		"Corrupt Block %",
+		"In-Turn Blocks",
+		"Out-Of-Turn Blocks",
+		"Corrupt Signed %",
+		"Invalid Blocks",
+		"Avalanche Finalized",
+		"Avalanche Undecided",
+		"Avalanche Split",
+		"Avg Query Rounds",
+		"Convergence (ms)",
+		"Corrupt Leader %",
+		"Fair Leader %",
+		"Ground Seeds",
+		"Committee Size",
+		"Committees",
+		"Corrupt Majority %",
+		"Predicted Majority %",
+		"Forged Blocks",
+		"Attest Quorum %",
+		"Attest Corrupt Stake %",
+		"Attested Blocks",
+		"Unattested Blocks",
+		"PoW Winner",
+		"Equivocators",
+		"Corrupt Equivocators",
+		"Slashed Validators",
+		"Slashed Stake %",
+		"Corrupt Stake Left %",
+		"Long-Range Fork Height",
+		"Long-Range Key Stake %",
+		"New Node Fooled",
+		"WS Checkpoint Height",
+		"Fooled With WS",
+		"Sybils",
+		"Sybil Blocks",
+		"Sybil Rejections",
+		"Sybil Blocks Won",
+		"Bribe Rate",
+		"Bribed Miners",
+		"Bribed Blocks",
+		"Bribe Budget",
+		"Winner Flipped",
+		"Max Block Txs",
+		"Spam Rate",
+		"Spam Sent",
+		"Spam In Winner",
+		"Spam Share %",
+		"Withholding",
+		"Releases",
+		"Released Blocks",
+		"Release Matches",
+		"Release Overrides",
+		"Time Warp",
+		"Corrupt Blocks Mined",
+		"Honest Blocks Mined",
+		"Corrupt Avg Difficulty",
+		"Honest Avg Difficulty",
+		"Timestamp Rejections",
+		"Schedule",
+		"Delta",
+		"Schedule Seed",
+		"Delayed Messages",
+		"Avg Delay (ms)",
+		"DES Seed",
+		"Virtual Time (s)",
+		"DES Events",
+		"DES Orphans",
+		"Fast Mining Hash Rate",
+		"Churn Rate",
+		"Late Joiners",
+		"Churn Leaves",
+		"Churn Joins",
+		"Syncs",
+		"Synced Blocks",
+		"Avg Sync Height",
+		"Avg Sync Time (ms)",
+		"Max Sync Time (ms)",
+		"Ban Score",
+		"Forgeries Sent",
+		"Invalid Rejected",
+		"Bans",
+		"Honest Bans",
+		"Isolated %",
+		"Isolation Time (s)",
+		"Latency Matrix",
+		"Region Blocks",
+		"Bandwidth (B/s)",
+		"Avg Block Bytes",
+		"Avg Propagation (ms)",
+		"Max Propagation (ms)",
+		"Gossip Fanout",
+		"Tx Relays",
+		"Tx Coverage %",
+		"Full Tx Coverage %",
+		"Workload",
+		"Value Sent",
+		"Value Confirmed",
+		"Value Confirmed %",
+		"Assets",
+		"RBF Rate",
+		"RBF Replacements",
+		"RBF Swapped",
+		"RBF Confirmed Replacement",
+		"RBF Confirmed Original",
+		"UTXO Funded",
+		"UTXO Unfunded",
+		"UTXO Dropped",
+		"UTXO Rejected",
+		"UTXO Double Spends",
+		"UTXO Twins",
+		"UTXO Unspent",
+		"Script",
+		"Script Runs",
+		"Script Steps",
+		"Script Failed",
+		"Script Locked",
+		"Script Rejected",
+		"Script Time (ms)",
+		"Channels",
+		"Channel Updates",
+		"Channel Txs",
+		"Channel Load Reduction %",
+		"Channels Settled",
+		"Channel Disputes",
+		"Disputes Honest",
+		"Disputes Penalized",
+		"Disputes Cheated",
+		"Channels Unsettled",
+		"Channel Stolen",
+		"Sequencer",
+		"Rollup Batches",
+		"Rollup Committed",
+		"Rollup Censored",
+		"Rollup Forced",
+		"Rollup Withheld",
+		"Rollup Lost",
+		"Rollup L1 Txs",
+		"Rollup Compression",
+		"Swap DAG Corrupt",
+		"Swaps",
+		"Swap Timeout",
+		"Swap DAG txConfirmed %",
+		"Swaps Succeeded",
+		"Swaps Timed Out",
+		"Swaps Broken",
+		"Swap Success %",
+		"Swap Timeout %",
+		"Swap Time (s)",
+		"Shards",
+		"Shard Corrupt",
+		"Shards Captured",
+		"Max Shard Corrupt %",
+		"Shard Takeover %",
+		"Cross-Shard Txs",
+		"Cross-Shard Confirmed",
+		"Cross-Shard p50 (s)",
+		"Cross-Shard p95 (s)",
+		"Side Difficulty",
+		"Checkpoints",
+		"Side Blocks",
+		"Side Winner",
+		"Side Reorgs",
+		"Checkpoint Txs",
+		"Checkpoints Confirmed",
+		"Reorgs Past Checkpoint",
+		"Checkpoints Violated",
+		"Peak Heap (MB)",
+		"Peak Goroutines",
+		"GC Cycles",
+		"GC Pause (ms)",
+		"Max GC Pause (ms)",
+		"Peak RSS (MB)",
+		"Allocated (MB)",
+		"Allocations",
+	}
+}
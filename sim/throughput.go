@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Throughput compares how fast each simulator turns transactions into confirmed state
+type Throughput struct {
+	ConfirmedTPS     float64       // confirmed transactions per second of simulation time
+	BlocksMined      int           // blocks (DAG: vertices) mined by all nodes, including ones that were later orphaned
+	BlocksPerSecond  float64       // BlocksMined per second of simulation time
+	AvgBlockInterval time.Duration // PoW: between consecutive blocks of the winning chain, DAG: between consecutive vertices network-wide
+}
+
+// perSecond guards against a zero duration
+func perSecond(count int, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(count) / duration.Seconds()
+}
+
+// averageInterval is the mean gap between consecutive times
+func averageInterval(times []time.Time) time.Duration {
+	if len(times) < 2 {
+		return 0
+	}
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	return times[len(times)-1].Sub(times[0]) / time.Duration(len(times)-1)
+}
+
+// chainThroughput computes the PoW throughput, block intervals are taken along the winning chain
+func (t *timingTracker) chainThroughput(chain []Block, txConfirmed int, duration time.Duration) Throughput {
+	times := []time.Time{}
+	for _, b := range chain {
+		if mined, ok := t.minedBlock[b.Hash]; ok {
+			times = append(times, mined)
+		}
+	}
+	return Throughput{
+		ConfirmedTPS:     perSecond(txConfirmed, duration),
+		BlocksMined:      len(t.minedBlock),
+		BlocksPerSecond:  perSecond(len(t.minedBlock), duration),
+		AvgBlockInterval: averageInterval(times),
+	}
+}
+
+// tangleThroughput computes the DAG throughput, every mined vertex counts towards the interval
+func (t *timingTracker) tangleThroughput(txConfirmed int, duration time.Duration) Throughput {
+	times := []time.Time{}
+	for _, mined := range t.minedBlock {
+		times = append(times, mined)
+	}
+	return Throughput{
+		ConfirmedTPS:     perSecond(txConfirmed, duration),
+		BlocksMined:      len(t.minedBlock),
+		BlocksPerSecond:  perSecond(len(t.minedBlock), duration),
+		AvgBlockInterval: averageInterval(times),
+	}
+}
+
+func printThroughput(tp Throughput) {
+	fmt.Printf("Confirmed TPS       = %.2f\n", tp.ConfirmedTPS)
+	fmt.Println("Blocks mined       =", tp.BlocksMined)
+	fmt.Printf("Blocks/s            = %.2f\n", tp.BlocksPerSecond)
+	fmt.Printf("Block interval (s)  = %.3f\n", tp.AvgBlockInterval.Seconds())
+}
@@ -0,0 +1,55 @@
+package sim
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// --- Long Format ---
+
+/*
+	-long <file> also writes the results in long ("tidy") format, one metric of one simulation per row:
+	config_id (the test number), sim_type, metric (the column of the wide CSV) and value, e.g.
+	"2,PoW,Orphan %,12.50". Columns a mode leaves blank, the metrics of other modes, get no row, so the
+	file only holds values that were measured, and pandas (pivot / groupby on metric), R or ggplot take
+	it as it is instead of hundreds of mostly empty columns. Values are written as in the wide CSV; a
+	few metrics (Winner, Tip Selection, ...) are text. The file is replaced or, with -append and
+	-resume, added to like -out.
+*/
+
+var longHeaders = []string{"config_id", "sim_type", "metric", "value"}
+
+// openLong opens path for the long rows, at its end if appending, and writes the header if it is empty
+func openLong(path string, appending bool) (*os.File, *csv.Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appending {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	writer := csv.NewWriter(file)
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		writer.Write(longHeaders)
+	}
+	return file, writer, nil
+}
+
+// longRows are the rows of res of test in long format, one per column it has a value for
+func longRows(test int, res SimResult) [][]string {
+	rows := [][]string{}
+	row := resultRow(res)
+	for k, metric := range resultHeaders() {
+		if metric == "Simulation Type" || row[k] == "" {
+			continue
+		}
+		rows = append(rows, []string{strconv.Itoa(test), res.Type, metric, row[k]})
+	}
+	return rows
+}
@@ -0,0 +1,173 @@
+package sim
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --- Time Series ---
+
+/*
+	-timeseries <file> writes how every simulation unfolded, not just where it ended: a row per
+	-timeseries-interval (default 10ms) from its first event to its last, each counting everything up
+	to the end of its interval:
+	- Round: the last workload round sent;
+	- Height: the highest block any node had (PoW, PoA, BFT...; a DAG has none);
+	- Blocks Mined: blocks (DAG: vertices) mined or committed;
+	- Sent, Included, Mempool: transactions sent, carried by a mined or committed block, and the
+	  difference, those still waiting;
+	- Confirmed: transactions of the final result whose first block was mined by then, so the last row
+	  is txConfirmed and the curve shows when the confirmed ones got in;
+	- Forks, Reorgs: blocks attached off a node's tip, tip switches.
+	Like -node-stats, rows are appended, with the header if the file is new. The rows are worked out
+	from the events once the simulation has finished, so sampling costs the nodes nothing; DES rows
+	are in its virtual time.
+*/
+
+// timeSeries records the event times a simulation's rows are worked out from
+type timeSeries struct {
+	mu        sync.Mutex
+	sent      []sentAt
+	mined     []time.Time
+	forks     []time.Time
+	reorgs    []time.Time
+	heights   []heightAt // each time the highest block rose
+	included  map[TxID]time.Time
+	confirmed []TxID
+}
+
+type sentAt struct {
+	time  time.Time
+	round int
+}
+
+type heightAt struct {
+	time   time.Time
+	height int
+}
+
+// trackTimeSeries records the events of bus for writeTimeSeries
+func trackTimeSeries(bus *EventBus) *timeSeries {
+	s := &timeSeries{included: make(map[TxID]time.Time)}
+	bus.Subscribe(func(e Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch e.Kind {
+		case EventSent:
+			s.sent = append(s.sent, sentAt{e.Time, e.Round})
+			return
+		case EventConfirmed:
+			s.confirmed = append(s.confirmed, e.Tx.TxID)
+			return
+		case EventFork:
+			s.forks = append(s.forks, e.Time)
+			return
+		case EventReorg:
+			s.reorgs = append(s.reorgs, e.Time)
+			return
+		case EventMined, EventCommit:
+			s.mined = append(s.mined, e.Time)
+			for _, tx := range e.Block.Transactions {
+				if _, seen := s.included[tx.TxID]; !seen {
+					s.included[tx.TxID] = e.Time
+				}
+			}
+		}
+		if n := len(s.heights); e.Height > 0 && (n == 0 || e.Height > s.heights[n-1].height) {
+			s.heights = append(s.heights, heightAt{e.Time, e.Height})
+		}
+	}, EventSent, EventConfirmed, EventFork, EventReorg, EventMined, EventCommit, EventAccepted)
+	return s
+}
+
+// countUntil is how many of the sorted times are not after t
+func countUntil(times []time.Time, t time.Time) int {
+	k, _ := slices.BinarySearchFunc(times, t, func(a, t time.Time) int {
+		if a.After(t) {
+			return 1
+		}
+		return -1
+	})
+	return k
+}
+
+// writeTimeSeries appends the rows of the simulation of test that ended in res to path, writing the
+// header if the file is new
+func writeTimeSeries(path string, test int, res SimResult, s *timeSeries, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval %s is not positive", interval)
+	}
+	sortTimes := func(times []time.Time) { slices.SortFunc(times, time.Time.Compare) }
+	included, confirmed := []time.Time{}, []time.Time{}
+	for _, t := range s.included {
+		included = append(included, t)
+	}
+	for _, id := range s.confirmed {
+		if t, ok := s.included[id]; ok {
+			confirmed = append(confirmed, t)
+		}
+	}
+	slices.SortStableFunc(s.sent, func(a, b sentAt) int { return a.time.Compare(b.time) })
+	slices.SortStableFunc(s.heights, func(a, b heightAt) int { return a.time.Compare(b.time) })
+	sentTimes := []time.Time{}
+	for _, e := range s.sent {
+		sentTimes = append(sentTimes, e.time)
+	}
+	for _, times := range [][]time.Time{included, confirmed, s.mined, s.forks, s.reorgs} {
+		sortTimes(times)
+	}
+	all := slices.Concat(sentTimes, s.mined, included, s.forks, s.reorgs)
+	if len(all) == 0 {
+		return nil // nothing happened, e.g. a simulation cut short before it started
+	}
+	first, last := slices.MinFunc(all, time.Time.Compare), slices.MaxFunc(all, time.Time.Compare)
+
+	_, statErr := os.Stat(path)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	if os.IsNotExist(statErr) {
+		writer.Write([]string{"Test", "Simulation Type", "Time (s)", "Round", "Height", "Blocks Mined", "Sent", "Included", "Mempool", "Confirmed", "Forks", "Reorgs"})
+	}
+	sent, round, height, nextHeight := 0, 0, 0, 0
+	for at := first.Add(interval); ; at = at.Add(interval) {
+		end := at
+		if last.Before(at) {
+			end = last // the last row ends with the last event
+		}
+		for ; sent < len(s.sent) && !s.sent[sent].time.After(end); sent++ {
+			round = max(round, s.sent[sent].round)
+		}
+		for ; nextHeight < len(s.heights) && !s.heights[nextHeight].time.After(end); nextHeight++ {
+			height = max(height, s.heights[nextHeight].height)
+		}
+		in := countUntil(included, end)
+		writer.Write([]string{
+			strconv.Itoa(test),
+			res.Type,
+			fmt.Sprintf("%.3f", end.Sub(first).Seconds()),
+			strconv.Itoa(round),
+			strconv.Itoa(height),
+			strconv.Itoa(countUntil(s.mined, end)),
+			strconv.Itoa(sent),
+			strconv.Itoa(in),
+			strconv.Itoa(max(sent-in, 0)),
+			strconv.Itoa(countUntil(confirmed, end)),
+			strconv.Itoa(countUntil(s.forks, end)),
+			strconv.Itoa(countUntil(s.reorgs, end)),
+		})
+		if !at.Before(last) {
+			break
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,129 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Timestamp Manipulation ---
+
+/*
+	With SimConfig.TimeWarp and difficulty retargeting the corrupt PoW nodes lie about the timestamps
+	of the blocks on their chain to drive its difficulty down (a time-warp attack). A retarget looks at
+	the timestamps of the first and last block of its window, so the corrupt nodes stamp the last block
+	of every window TimeWarp in the future and every other block as early as the rules allow, just
+	after the median time past (the median timestamp of the last MedianTimeSpan blocks). Every window
+	then seems to have taken far longer than it did and the difficulty steps down.
+
+	With SimConfig.MaxTimeDrift every honest node checks the timestamps of the blocks it receives, like
+	Bitcoin does: a block must be stamped after the median time past of its parent and no more than
+	MaxTimeDrift ahead of the node's clock. A time warp within the drift passes the check.
+
+	Lower difficulty means more blocks for the same hashing but less work per block, so the warped
+	chain only wins with the longest-chain rule (-fork-choice longest), never by most work. The
+	advantage shows in the blocks the corrupt nodes mine and in their average difficulty against the
+	honest nodes'.
+*/
+
+// MedianTimeSpan is the number of ancestors whose median timestamp a block must exceed
+const MedianTimeSpan = 11
+
+// TimeWarpStats compares the blocks mined on the warped chain with the honest ones
+type TimeWarpStats struct {
+	Warp              time.Duration // how far in the future the corrupt nodes stamp the last block of a window
+	CorruptBlocks     int           // blocks mined by corrupt nodes
+	HonestBlocks      int           // blocks mined by honest nodes
+	CorruptDifficulty float64       // average difficulty of the corrupt blocks
+	HonestDifficulty  float64       // average difficulty of the honest blocks
+	Rejected          int           // times a node rejected a block for its timestamp
+}
+
+// medianTimePast is the median timestamp of prev and its ancestors, up to MedianTimeSpan blocks
+func medianTimePast(HashMap BlockStore, prev string) int64 {
+	stamps := []int64{}
+	for h := prev; len(stamps) < MedianTimeSpan; {
+		b, ok := HashMap.Get(h)
+		if !ok { // genesis
+			break
+		}
+		stamps = append(stamps, b.Timestamp)
+		h = b.PrevHash
+	}
+	if len(stamps) == 0 {
+		return 0
+	}
+	slices.Sort(stamps)
+	return stamps[len(stamps)/2]
+}
+
+// validTimestamp checks b against cfg.MaxTimeDrift, b's parent must be known
+func validTimestamp(HashMap BlockStore, b Block, cfg SimConfig) bool {
+	if cfg.MaxTimeDrift <= 0 {
+		return true
+	}
+	return b.Timestamp > medianTimePast(HashMap, b.PrevHash) && b.Timestamp <= time.Now().Add(cfg.MaxTimeDrift).UnixNano()
+}
+
+// warpedTimestamp is the timestamp a corrupt node puts on a block mined on prev
+func warpedTimestamp(HashMap BlockStore, Counts map[string]int, prev string, cfg SimConfig) int64 {
+	now := time.Now()
+	if cfg.TimeWarp <= 0 || cfg.RetargetInterval < 2 {
+		return now.UnixNano()
+	}
+	if (Counts[prev]+1)%cfg.RetargetInterval == 0 { // last block of a retarget window
+		return now.Add(cfg.TimeWarp).UnixNano()
+	}
+	return medianTimePast(HashMap, prev) + 1
+}
+
+// timeWarpTracker tallies the mined blocks by side and the timestamp rejections
+type timeWarpTracker struct {
+	mu                      sync.Mutex
+	stats                   TimeWarpStats
+	corruptDiff, honestDiff int
+}
+
+func trackTimeWarp(bus *EventBus, C int, warp time.Duration) *timeWarpTracker {
+	t := &timeWarpTracker{stats: TimeWarpStats{Warp: warp}}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if e.Kind == EventRejected {
+			if e.Reason == RejectTimestamp {
+				t.stats.Rejected++
+			}
+			return
+		}
+		if getLabel(nodeIndex(e.Node, C), C) == "corrupt" {
+			t.stats.CorruptBlocks++
+			t.corruptDiff += e.Block.Difficulty
+		} else {
+			t.stats.HonestBlocks++
+			t.honestDiff += e.Block.Difficulty
+		}
+	}, EventMined, EventRejected)
+	return t
+}
+
+func (t *timeWarpTracker) result() TimeWarpStats {
+	stats := t.stats
+	if stats.CorruptBlocks > 0 {
+		stats.CorruptDifficulty = math.Round(100*float64(t.corruptDiff)/float64(stats.CorruptBlocks)) / 100
+	}
+	if stats.HonestBlocks > 0 {
+		stats.HonestDifficulty = math.Round(100*float64(t.honestDiff)/float64(stats.HonestBlocks)) / 100
+	}
+	return stats
+}
+
+func printTimeWarpStats(stats TimeWarpStats) {
+	fmt.Println("Time warp          =", stats.Warp)
+	fmt.Println("Corrupt blocks     =", stats.CorruptBlocks)
+	fmt.Println("Honest blocks      =", stats.HonestBlocks)
+	fmt.Println("Corrupt difficulty =", stats.CorruptDifficulty)
+	fmt.Println("Honest difficulty  =", stats.HonestDifficulty)
+	fmt.Println("Timestamp rejects  =", stats.Rejected)
+}
@@ -0,0 +1,116 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Tip selection strategies of the DAG simulation (SimConfig.TipSelection)
+const (
+	TipsUniform = "uniform" // pickParents: any two transactions of the node's view
+	TipsMCMC    = "mcmc"    // weighted random walk towards the tips, see tangleWalk
+)
+
+// tangleWalk selects parents with an IOTA-style Markov chain Monte Carlo random walk.
+//
+// Every transaction has a cumulative weight: 1 plus the number of transactions that approve it
+// directly or indirectly. A walk starts at a genesis transaction and repeatedly steps to one of
+// the current transaction's approvers y with probability proportional to exp(-alpha * (H_x - H_y)),
+// until it reaches a tip. alpha = 0 is an unbiased walk, a large alpha follows the heaviest branch,
+// so lazy tips approving old transactions are rarely picked up.
+//
+// Keeping the weights up to date costs one pass over the ancestors of every added transaction.
+type tangleWalk struct {
+	alpha    float64
+	genesis  []string
+	children map[string][]string // Hash -> approving transactions
+	weight   map[string]int      // Hash -> cumulative weight
+	parents  map[string][]string
+}
+
+func newTangleWalk(alpha float64, genesis ...Transaction) *tangleWalk {
+	w := &tangleWalk{
+		alpha:    alpha,
+		children: make(map[string][]string),
+		weight:   make(map[string]int),
+		parents:  make(map[string][]string),
+	}
+	for _, g := range genesis {
+		w.genesis = append(w.genesis, g.Hash)
+		w.weight[g.Hash] = 1
+	}
+	return w
+}
+
+// add records t, whose parents must already be known, and raises the weight of everything it approves
+func (w *tangleWalk) add(t Transaction) {
+	if _, known := w.weight[t.Hash]; known {
+		return
+	}
+	w.weight[t.Hash] = 1
+	w.parents[t.Hash] = t.Parents
+
+	visited := make(map[string]struct{})
+	stack := []string{}
+	for _, p := range t.Parents {
+		if _, seen := visited[p]; !seen {
+			visited[p] = struct{}{}
+			w.children[p] = append(w.children[p], t.Hash)
+			stack = append(stack, p)
+		}
+	}
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		w.weight[h]++
+		for _, p := range w.parents[h] {
+			if _, seen := visited[p]; !seen {
+				visited[p] = struct{}{}
+				stack = append(stack, p)
+			}
+		}
+	}
+}
+
+// walk returns the tip reached from a random genesis transaction
+func (w *tangleWalk) walk() string {
+	x := w.genesis[rand.Intn(len(w.genesis))]
+	for {
+		approvers := w.children[x]
+		if len(approvers) == 0 {
+			return x
+		}
+
+		// exp(-alpha * (H_x - H_y)), shifted by the heaviest approver so large weights don't underflow
+		heaviest := 0
+		for _, y := range approvers {
+			heaviest = max(heaviest, w.weight[y])
+		}
+		probs := make([]float64, len(approvers))
+		total := 0.0
+		for k, y := range approvers {
+			probs[k] = math.Exp(-w.alpha * float64(heaviest-w.weight[y]))
+			total += probs[k]
+		}
+		r := rand.Float64() * total
+		next := approvers[len(approvers)-1]
+		for k, y := range approvers {
+			r -= probs[k]
+			if r < 0 {
+				next = y
+				break
+			}
+		}
+		x = next
+	}
+}
+
+// pickParents runs two walks, retrying the second a few times so the parents differ when possible
+func (w *tangleWalk) pickParents() []string {
+	first := w.walk()
+	second := w.walk()
+	for try := 0; second == first && try < 10; try++ {
+		second = w.walk()
+	}
+	return []string{first, second}
+}
@@ -0,0 +1,275 @@
+package sim
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// --- Trace Recording and Replay ---
+
+/*
+	With -record <file> the tester writes every simulation to a trace, one JSON object per line: the
+	flags of the run first, then for each simulation its test and mode, every event it published in order
+	(each block mined and the nonce that mined it, each delivery, drop, acceptance, reorg, vote and
+	commit, each transaction sent and confirmed), and its result.
+
+	-replay <file> replays a trace instead of running the simulations: the recorded flags apply again
+	(flags given with -replay override them), and each recorded simulation publishes its events, with
+	their recorded times, to whatever the tester attaches to a simulation (-check, -block-tree,
+	-events-ws, -tui, -otlp) and writes its recorded row, so a rare anomaly can be inspected again and
+	again with the tools of a later run. Replay goes as fast as the events can be read.
+
+	The goroutine simulators race their nodes against each other and the clock, so replaying them cannot
+	run their code again: it replays what they did. The des simulator is deterministic, so replay also
+	reruns it with the recorded flags and compares the run with the trace event by event, logging the
+	first event that differs, e.g. after a change to its code.
+
+	A trace of the default suite is large (the dag's hundreds of thousands of acceptances), record
+	single tests with -test and -modes.
+*/
+
+// traceLine is a line of a trace, one of its fields set
+type traceLine struct {
+	Args   []string   `json:",omitempty"` // first line
+	Sim    *traceSim  `json:",omitempty"` // starts a simulation
+	Event  *Event     `json:",omitempty"`
+	Result *SimResult `json:",omitempty"` // ends the simulation
+}
+
+// traceSim is the simulation the following lines belong to
+type traceSim struct {
+	Test int
+	Mode string
+	N    int
+	C    int
+	R    int
+	D    int
+	P    float64
+}
+
+// traceRecorder writes the simulations of the suite to a trace
+type traceRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	skipped int // events JSON cannot encode, e.g. a NaN amount
+	err     error
+}
+
+// recordedArgs are the flags of the run without -record, -replay and -resume
+func recordedArgs(args []string) []string {
+	out := []string{}
+	for k := 0; k < len(args); k++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[k], "-"), "=")
+		switch name {
+		case "record", "replay":
+			if !hasValue {
+				k++ // the value is the next argument
+			}
+		case "resume":
+		default:
+			out = append(out, args[k])
+		}
+	}
+	return out
+}
+
+func newTraceRecorder(path string, args []string) (*traceRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &traceRecorder{file: file, w: bufio.NewWriterSize(file, 1<<20)}
+	r.write(traceLine{Args: recordedArgs(args)})
+	return r, r.err
+}
+
+func (r *traceRecorder) write(line traceLine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.Marshal(line)
+	if err != nil {
+		r.skipped++
+		return
+	}
+	if _, err := r.w.Write(append(data, '\n')); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// record writes the simulation of mode in test, publishing to bus, to the trace
+func (r *traceRecorder) record(bus *EventBus, test int, mode string, t BenchmarkConfig) func(SimResult) {
+	r.write(traceLine{Sim: &traceSim{Test: test, Mode: mode, N: t.N, C: t.C, R: t.R, D: t.D, P: t.p}})
+	unsubscribe := bus.Subscribe(func(e Event) { r.write(traceLine{Event: &e}) })
+	return func(res SimResult) {
+		unsubscribe()
+		r.write(traceLine{Result: &res})
+	}
+}
+
+// Close flushes the trace, reporting the first error writing it
+func (r *traceRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Join(r.err, r.w.Flush(), r.file.Close())
+}
+
+// traceReplay reads the simulations of a trace
+type traceReplay struct {
+	file *os.File
+	sims map[traceKey]int64 // where the events of each simulation start
+	log  *slog.Logger
+}
+
+type traceKey struct {
+	test int
+	mode string
+}
+
+// TraceArgs returns the recorded flags of the trace at path, which -replay parses again
+func TraceArgs(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var first traceLine
+	if err := json.NewDecoder(file).Decode(&first); err != nil {
+		return nil, fmt.Errorf("trace %s: %w", path, err)
+	}
+	return first.Args, nil
+}
+
+func openTrace(path string, log *slog.Logger) (*traceReplay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &traceReplay{file: file, sims: make(map[traceKey]int64), log: log}
+	reader, offset := bufio.NewReaderSize(file, 1<<20), int64(0)
+	for {
+		data, err := reader.ReadBytes('\n')
+		offset += int64(len(data))
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("trace %s: %w", path, err)
+		}
+		if !bytes.HasPrefix(data, []byte(`{"Sim":`)) {
+			continue
+		}
+		var line traceLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("trace %s: %w", path, err)
+		}
+		r.sims[traceKey{line.Sim.Test, line.Sim.Mode}] = offset
+	}
+	return r, nil
+}
+
+// has reports whether the trace holds the simulation of mode in test
+func (r *traceReplay) has(test int, mode string) bool {
+	_, ok := r.sims[traceKey{test, mode}]
+	return ok
+}
+
+// sim returns a simulator replaying the simulation of mode in test to cfg.Events, returning its
+// recorded result. A des simulation is also rerun and compared with the trace.
+func (r *traceReplay) sim(test int, mode string) func(ctx context.Context, cfg SimConfig) SimResult {
+	return func(ctx context.Context, cfg SimConfig) SimResult {
+		return r.run(ctx, cfg, test, mode)
+	}
+}
+
+func (r *traceReplay) run(ctx context.Context, cfg SimConfig, test int, mode string) SimResult {
+	sim := traceSim{Test: test, Mode: mode}
+	if _, err := r.file.Seek(r.sims[traceKey{test, mode}], io.SeekStart); err != nil {
+		r.log.Error("reading trace failed", "err", err)
+		return SimResult{}
+	}
+	dec := json.NewDecoder(bufio.NewReaderSize(r.file, 1<<20))
+	var rerun []Event
+	if sim.Mode == "des" {
+		cfg := cfg
+		cfg.Events = NewEventBus()
+		cfg.Events.Subscribe(func(e Event) { rerun = append(rerun, e) })
+		simulators["des"](ctx, cfg)
+	}
+
+	var res SimResult
+	events, diverged := 0, false
+	for ctx.Err() == nil {
+		var line traceLine
+		if err := dec.Decode(&line); err != nil {
+			r.log.Warn("trace ends inside a simulation", "test", sim.Test, "mode", sim.Mode, "err", err)
+			break
+		}
+		if line.Result != nil {
+			res = *line.Result
+			break
+		}
+		if line.Event == nil {
+			continue
+		}
+		if sim.Mode == "des" && !diverged {
+			if events >= len(rerun) || !sameEvent(*line.Event, rerun[events]) {
+				diverged = true
+				r.log.Warn("des rerun differs from the trace", "test", sim.Test, "event", events, "recorded", describeEvent(*line.Event), "rerun", rerunEvent(rerun, events))
+			}
+		}
+		if cfg.Events != nil {
+			cfg.Events.publishAt(*line.Event)
+		}
+		events++
+	}
+	if sim.Mode == "des" && !diverged && ctx.Err() == nil {
+		if events != len(rerun) {
+			r.log.Warn("des rerun differs from the trace", "test", sim.Test, "event", events, "recorded", "end", "rerun", rerunEvent(rerun, events))
+		} else {
+			r.log.Info("des rerun matches the trace", "test", sim.Test, "events", events)
+		}
+	}
+	return res
+}
+
+// sameEvent reports whether a and b are the same event, the time they were published aside
+func sameEvent(a, b Event) bool {
+	a.Time = b.Time
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+// describeEvent names e for a log line
+func describeEvent(e Event) string {
+	s := e.Kind.String()
+	if e.Node != "" {
+		s += " " + e.Node
+	}
+	if e.Block.Hash != "" {
+		s += " block " + shortHash(e.Block.Hash)
+	} else if e.Kind == EventSent || e.Kind == EventConfirmed {
+		s += fmt.Sprintf(" tx %s %s->%s", e.Tx.TxID, e.Tx.Sender, e.Tx.Receiver)
+	}
+	return s
+}
+
+func rerunEvent(rerun []Event, k int) string {
+	if k >= len(rerun) {
+		return "end"
+	}
+	return describeEvent(rerun[k])
+}
+
+func (r *traceReplay) Close() error { return r.file.Close() }
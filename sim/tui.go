@@ -0,0 +1,250 @@
+package sim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// --- Terminal Dashboard ---
+
+/*
+	With -tui the suite redraws a dashboard in the terminal every tuiRefresh instead of logging: a
+	progress bar over its simulations, the test and mode running, and a row per node with its chain
+	height (a DAG node: the vertices it holds), the blocks it mined and accepted, and its pending
+	transactions. Pending is an estimate from the events, as the nodes do not publish their pools: the
+	transactions sent to the node's side less those in the chain up to its tip (a DAG node: less the
+	vertices of them it holds). Below the nodes it shows the winner and txConfirmed % of the last simulation.
+
+	It draws on the terminal with tcell, in the alternate screen, redrawing whole on a resize; Ctrl-C or q
+	stops the suite like an interrupt, since tcell takes the keys from the terminal. Once the suite
+	stops the terminal is restored and the last state is printed, so it stays after the run. Without a
+	terminal only that last state is printed. The info logs would scroll it away, so -tui raises the log
+	level to warn.
+*/
+
+const (
+	tuiRefresh  = 200 * time.Millisecond
+	tuiMaxNodes = 30 // rows, the rest are summed up in one line
+	tuiBarWidth = 30
+)
+
+// tuiNode is a node as the dashboard shows it
+type tuiNode struct {
+	height, mined, accepted int
+	tip                     string
+	vertices                int // DAG
+	sideVertices            int // DAG: of transactions sent to the node's side
+}
+
+// dashboard follows the running simulation. The nodes' events and the redraw loop reach it from
+// different goroutines, hence the mutex.
+type dashboard struct {
+	out   io.Writer
+	total int // simulations of the suite
+
+	mu       sync.Mutex
+	finished int
+	test     int
+	mode     string
+	t        BenchmarkConfig
+	started  time.Time
+	suite    time.Time
+	nodes    map[string]*tuiNode
+	sent     map[string]int // side -> transactions sent to it
+	onChain  map[string]int // block hash -> transactions in the chain up to it
+	last     string
+}
+
+func newDashboard(out io.Writer, total int) *dashboard {
+	return &dashboard{out: out, total: total, suite: time.Now()}
+}
+
+// watch follows the events of bus, a simulation of mode in test, until the returned function ends it
+func (d *dashboard) watch(bus *EventBus, test int, mode string, t BenchmarkConfig) func(SimResult) {
+	d.reset(test, mode, t)
+	unsubscribe := bus.Subscribe(d.handle, tuiKinds...)
+	return func(res SimResult) {
+		unsubscribe()
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.finished++
+		d.last = fmt.Sprintf("test %d %s: winner %s, %.2f%% confirmed in %.2fs", test, mode, res.Winner, res.TxConfirmedPercentage, time.Since(d.started).Seconds())
+	}
+}
+
+// tuiKinds are the events the dashboard follows
+var tuiKinds = []EventKind{EventMined, EventAccepted, EventReorg, EventSent}
+
+// reset starts following the simulation of mode in test
+func (d *dashboard) reset(test int, mode string, t BenchmarkConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.test, d.mode, d.t, d.started = test, mode, t, time.Now()
+	d.nodes, d.sent, d.onChain = make(map[string]*tuiNode), make(map[string]int), make(map[string]int)
+}
+
+func (d *dashboard) handle(e Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e.Kind == EventSent {
+		d.sent[getLabel(nodeIndex(e.Tx.Sender, d.t.C), d.t.C)]++
+		return
+	}
+	if e.Node == "" {
+		return
+	}
+	n, ok := d.nodes[e.Node]
+	if !ok {
+		n = &tuiNode{}
+		d.nodes[e.Node] = n
+	}
+	b := e.Block
+	dag := len(b.Transactions) == 1 && b.PrevHash == "" && b.Miner == ""
+	if !dag {
+		d.onChain[b.Hash] = d.onChain[b.PrevHash] + len(b.Transactions)
+	}
+	switch e.Kind {
+	case EventMined:
+		n.mined++
+	case EventAccepted:
+		n.accepted++
+	}
+	switch {
+	case dag:
+		n.vertices++
+		n.height = n.vertices
+		if getLabel(nodeIndex(b.Transactions[0].Sender, d.t.C), d.t.C) == nodeSide(e.Node) {
+			n.sideVertices++
+		}
+	case e.Kind == EventReorg || e.Height >= n.height:
+		n.height, n.tip = e.Height, b.Hash
+	}
+}
+
+// pending estimates the transactions node has not got on its chain yet
+func (d *dashboard) pending(name string, n *tuiNode) int {
+	included := d.onChain[n.tip]
+	if n.vertices > 0 {
+		included = n.sideVertices
+	}
+	return max(d.sent[nodeSide(name)]-included, 0)
+}
+
+// nodeSide is "honest" or "corrupt"
+func nodeSide(name string) string {
+	if strings.HasPrefix(name, "corrupt") {
+		return "corrupt"
+	}
+	return "honest"
+}
+
+// progressBar draws done of total in width cells
+func progressBar(done, total, width int) string {
+	filled := width
+	if total > 0 {
+		filled = min(done*width/total, width)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// draw renders the dashboard to a string
+func (d *dashboard) draw() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Benchmark suite  %s  %d/%d simulations  %s\n\n", progressBar(d.finished, d.total, tuiBarWidth), d.finished, d.total, time.Since(d.suite).Round(time.Second))
+	if d.mode == "" {
+		return sb.String()
+	}
+	t := d.t
+	fmt.Fprintf(&sb, "Test %d  %s  N=%d C=%d R=%d D=%d p=%.2f  %.1fs\n\n", d.test, d.mode, t.N, t.C, t.R, t.D, t.p, time.Since(d.started).Seconds())
+
+	names := []string{}
+	tallest := 1
+	for name, n := range d.nodes {
+		names = append(names, name)
+		tallest = max(tallest, n.height)
+	}
+	slices.SortFunc(names, func(a, b string) int { // honest first, then by number
+		if c := strings.Compare(b[:1], a[:1]); c != 0 {
+			return c
+		}
+		return cmpNodeNames(a, b)
+	})
+	fmt.Fprintf(&sb, "%-12s %7s %7s %9s %8s\n", "Node", "Height", "Mined", "Accepted", "Pending")
+	for k, name := range names {
+		if k == tuiMaxNodes {
+			fmt.Fprintf(&sb, "... %d more nodes\n", len(names)-k)
+			break
+		}
+		n := d.nodes[name]
+		fmt.Fprintf(&sb, "%-12s %7d %7d %9d %8d  %s\n", name, n.height, n.mined, n.accepted, d.pending(name, n), strings.Repeat("▇", n.height*tuiBarWidth/tallest))
+	}
+	if d.last != "" {
+		fmt.Fprintf(&sb, "\nLast: %s\n", d.last)
+	}
+	return sb.String()
+}
+
+// cmpNodeNames orders the nodes of one side by their number
+func cmpNodeNames(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// run shows the dashboard on the terminal until ctx is done, calling stop on Ctrl-C or q, then writes
+// its last state to out
+func (d *dashboard) run(ctx context.Context, stop func()) {
+	defer func() { fmt.Fprint(d.out, d.draw()) }()
+	screen, err := tcell.NewScreen()
+	if err == nil {
+		err = screen.Init()
+	}
+	if err != nil {
+		componentLogger("tui").Warn("no terminal for the dashboard, showing the end only", "err", err)
+		<-ctx.Done()
+		return
+	}
+	defer screen.Fini()
+	screen.HideCursor()
+	events, quit := make(chan tcell.Event), make(chan struct{})
+	go screen.ChannelEvents(events, quit)
+	defer close(quit)
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+	for {
+		d.show(screen)
+		select {
+		case <-ticker.C:
+		case ev := <-events:
+			switch ev := ev.(type) {
+			case *tcell.EventKey:
+				if ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
+					stop()
+				}
+			case *tcell.EventResize:
+				screen.Sync()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// show draws the dashboard on screen
+func (d *dashboard) show(screen tcell.Screen) {
+	screen.Clear()
+	for y, line := range strings.Split(d.draw(), "\n") {
+		screen.PutStr(0, y, line)
+	}
+	screen.Show()
+}
@@ -0,0 +1,87 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// --- Transaction API ---
+
+/*
+	-tx-api <addr> serves an HTTP API injecting transactions into the running PoW simulation like the
+	console's tx command (see repl.go), so scripts, curl or a frontend can use the simulator as a mock
+	blockchain backend:
+	- POST /tx with {"From": "honest1", "To": "honest3", "Value": 5} sends a transaction, answering
+	  202 Accepted with it and its ID, or 503 if no PoW simulation takes transactions;
+	- GET /tx/{id} is the transaction and its status: sent, mined (a node included it in a block, which
+	  may still be orphaned), confirmed (on the winning chain once the simulation ended) or dropped
+	  (the simulation ended without it on the winning chain).
+	A simulation ends once its workload is mined, which leaves a client little time; -hold keeps each PoW
+	simulation taking transactions for that long after its workload was sent, so -test 1 -modes pow
+	-hold 10m runs a backend for ten minutes. The nodes mine whatever they are sent meanwhile.
+*/
+
+// txRequest is the body of POST /tx
+type txRequest struct {
+	From  string
+	To    string
+	Value Amount
+}
+
+// serveTxAPI serves the transaction API of c at addr until ctx is done
+func serveTxAPI(ctx context.Context, log *slog.Logger, addr string, c *console) {
+	mux := http.NewServeMux()
+	answer := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("POST /tx", func(w http.ResponseWriter, r *http.Request) {
+		var req txRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		tx, err := c.inject(req.From, req.To, req.Value)
+		c.mu.Unlock()
+		switch {
+		case errors.Is(err, errNoSimulation):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			answer(w, http.StatusAccepted, tx)
+		}
+	})
+	mux.HandleFunc("GET /tx/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		c.mu.Lock()
+		tx, ok := c.txs[TxID(id)]
+		var found consoleTx
+		if ok {
+			found = *tx
+		}
+		c.mu.Unlock()
+		if err != nil || !ok {
+			http.Error(w, "no transaction "+r.PathValue("id"), http.StatusNotFound)
+			return
+		}
+		answer(w, http.StatusOK, found)
+	})
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	go func() {
+		log.Info("serving the transaction API", "url", "http://"+addr+"/tx")
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error("transaction API failed", "addr", addr, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,128 @@
+package sim
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+)
+
+// --- Uncle Blocks ---
+
+/*
+	With SimConfig.Uncles a PoW block may reference up to MaxUncles recently orphaned blocks (uncles),
+	Ethereum style: the uncle's parent must be one of the 2nd to 7th ancestors of the new block, and the
+	uncle must not be on the chain or referenced by it already.
+
+	Rewards are paid on the winning chain: every block earns its miner 1, plus 1/32 per uncle it
+	references, and each uncle earns its miner (8 - distance)/8, where distance is how many blocks
+	later the uncle was referenced. Orphaned work therefore still pays, which changes how profitable
+	withholding blocks is.
+*/
+
+const (
+	MaxUncles      = 2       // uncles a block may reference
+	MaxUncleDepth  = 6       // an uncle is at most this many blocks older than the block referencing it
+	UncleInclusion = 1. / 32 // reward for referencing an uncle, in block rewards
+)
+
+// UncleStats reports the uncles referenced by the winning chain and the rewards it pays out
+type UncleStats struct {
+	Included           int                // uncles referenced by the winning chain
+	UncleRate          float64            // Included as a percentage of the winning chain's blocks
+	Rewards            map[string]float64 // node -> block, inclusion and uncle rewards
+	CorruptRewardShare float64            // % of all rewards paid to corrupt nodes
+}
+
+// uncleIndex remembers the children of every block a node knows, to find uncle candidates
+type uncleIndex map[string][]string
+
+func (idx uncleIndex) add(b Block) {
+	idx[b.PrevHash] = append(idx[b.PrevHash], b.Hash)
+}
+
+// pick returns the uncles a block mined on tip may reference
+func (idx uncleIndex) pick(HashMap BlockStore, tip string) []string {
+	ancestors := []string{tip} // ancestors[k] is the (k+1)th ancestor of the new block
+	onChain := map[string]bool{tip: true}
+	referenced := make(map[string]bool)
+	for h := tip; h != "" && len(ancestors) <= MaxUncleDepth; {
+		b, ok := HashMap.Get(h)
+		if !ok {
+			break
+		}
+		for _, u := range b.Uncles {
+			referenced[u] = true
+		}
+		h = b.PrevHash
+		ancestors = append(ancestors, h)
+		onChain[h] = true
+	}
+
+	uncles := []string{}
+	for _, parent := range ancestors[1:] {
+		for _, c := range idx[parent] {
+			if len(uncles) < MaxUncles && !onChain[c] && !referenced[c] {
+				uncles = append(uncles, c)
+			}
+		}
+	}
+	return uncles
+}
+
+// uncleTracker records the miner and height of every mined block, to pay the uncles of the winning chain
+type uncleTracker struct {
+	mu     sync.Mutex
+	miner  map[string]string
+	height map[string]int
+}
+
+func trackUncles(bus *EventBus) *uncleTracker {
+	t := &uncleTracker{miner: make(map[string]string), height: make(map[string]int)}
+	bus.Subscribe(func(e Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.miner[e.Block.Hash] = e.Node
+		t.height[e.Block.Hash] = e.Height
+	}, EventMined)
+	return t
+}
+
+func (t *uncleTracker) result(chain []Block, C int) UncleStats {
+	stats := UncleStats{Rewards: make(map[string]float64)}
+	for h, b := range chain {
+		if h == 0 { // genesis
+			continue
+		}
+		stats.Rewards[b.Miner] += 1 + UncleInclusion*float64(len(b.Uncles))
+		for _, u := range b.Uncles {
+			stats.Included++
+			stats.Rewards[t.miner[u]] += float64(8-(h-t.height[u])) / 8
+		}
+	}
+	if len(chain) > 1 {
+		stats.UncleRate = getPercentage(stats.Included, len(chain)-1)
+	}
+
+	total, corrupt := 0.0, 0.0
+	for node, r := range stats.Rewards {
+		total += r
+		if getLabel(nodeIndex(node, C), C) == "corrupt" {
+			corrupt += r
+		}
+	}
+	if total > 0 {
+		stats.CorruptRewardShare = math.Round(10000*corrupt/total) / 100
+	}
+	return stats
+}
+
+func printUncleStats(stats UncleStats) {
+	fmt.Println("Uncles included    =", stats.Included)
+	fmt.Println("Uncle %            =", stats.UncleRate)
+	fmt.Println("Corrupt reward %   =", stats.CorruptRewardShare)
+	for _, node := range slices.Sorted(maps.Keys(stats.Rewards)) {
+		fmt.Printf("  %-16s reward = %.3f\n", node, stats.Rewards[node])
+	}
+}
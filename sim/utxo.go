@@ -0,0 +1,289 @@
+package sim
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// --- UTXO Transactions ---
+
+/*
+	By default a transaction only names its sender, receiver and ID, and a double spend is a convention:
+	two transactions with the same ID (see conflicts.go). With SimConfig.UTXO every PoW transaction
+	spends outputs instead: its Inputs reference unspent outputs of earlier transactions (by ID and
+	index) that belong to the sender, and its Outputs pay the receiver the transaction's Value (1 if
+	it has none) and the change back to the sender. Every node starts out with one output of utxoFunds
+	(of every asset, see assets.go).
+
+	The senders' wallets fund each transaction from the outputs they have not spent yet, the unconfirmed
+	ones included, so a transaction may spend the output of one still pending. Miners validate: a block
+	only includes transactions whose inputs are unspent on the chain it extends (or created earlier in
+	the block), owned by the sender and worth at least the outputs, and pending transactions that fail
+	are dropped, including the ones already on the chain. A node that switches to another branch mines
+	the transactions of the blocks it left behind again, ahead of its pending ones, like a mempool. A
+	received block that spends an output twice, or one that does not exist on its chain, is rejected.
+	Transactions without inputs, like the spam, are invalid.
+
+	So double spends are structural: with SimConfig.DoubleSpend every other node gets a twin that spends
+	the same inputs to another receiver, and whichever a miner includes first makes the other invalid
+	on that chain. The winning chain never spends an output twice, and Twins counts the double spends
+	it settled for the twin rather than the original.
+*/
+
+// utxoFunds is what every node owns at the start
+const utxoFunds Amount = 1000 * amountScale
+
+// Outpoint references output Index of the transaction with ID Tx
+type Outpoint struct {
+	Tx    TxID
+	Index int
+}
+
+// TxOut is an output of a transaction
+type TxOut struct {
+	Owner string
+	Value Amount
+	Asset string `json:",omitempty"` // with SimConfig.Assets, see assets.go
+}
+
+// UTXOStats reports the spends of a PoW simulation with SimConfig.UTXO
+type UTXOStats struct {
+	Funded       int // transactions the wallets funded
+	Unfunded     int // transactions sent without inputs, for lack of funds
+	Dropped      int // pending transactions the nodes dropped as invalid, over all nodes
+	Rejected     int // received blocks rejected for an invalid spend, over all nodes
+	DoubleSpends int // transactions whose sender also sent a twin
+	Twins        int // double spends the winning chain settled for the twin
+	Unspent      int // outputs left unspent at the tip of the winning chain
+}
+
+// utxoSet maps the unspent outputs to their owner and value
+type utxoSet map[Outpoint]TxOut
+
+// genesisOutputs gives each of the N nodes of an N, C simulation utxoFunds of every asset
+func genesisOutputs(N, C int, assets []string) utxoSet {
+	set := make(utxoSet)
+	for i := range N {
+		for k, asset := range assets {
+			set[Outpoint{Index: i*len(assets) + k}] = TxOut{Owner: nodeName(i, C), Value: utxoFunds, Asset: asset}
+		}
+	}
+	return set
+}
+
+// spend applies tx to s if it is valid: it has inputs, each unspent, spent once and owned by the sender,
+// all of them and the outputs are of the transaction's asset, and the inputs are worth at least the
+// outputs
+func (s utxoSet) spend(tx Transaction) bool {
+	if len(tx.Inputs) == 0 {
+		return false
+	}
+	var in, out Amount
+	for k, op := range tx.Inputs {
+		o, ok := s[op]
+		if !ok || o.Owner != tx.Sender || o.Asset != tx.Asset || slices.Contains(tx.Inputs[:k], op) {
+			return false
+		}
+		in += o.Value
+	}
+	for _, o := range tx.Outputs {
+		if o.Asset != tx.Asset {
+			return false
+		}
+		out += o.Value
+	}
+	if out > in {
+		return false
+	}
+	for _, op := range tx.Inputs {
+		delete(s, op)
+	}
+	for k, o := range tx.Outputs {
+		s[Outpoint{Tx: tx.TxID, Index: k}] = o
+	}
+	return true
+}
+
+// utxoLedger holds the outputs at the start and the spend counters of a simulation, nil without UTXO
+type utxoLedger struct {
+	genesis utxoSet
+	wallet  utxoSet              // what the senders believe is unspent, only used by the fund hook
+	funded  map[TxID]Transaction // ID -> the funded transaction, only used by the fund hook
+
+	mu       sync.Mutex
+	unfunded int
+	dropped  int
+	rejected int
+}
+
+func newUTXOLedger(cfg SimConfig, N, C int) *utxoLedger {
+	if !cfg.UTXO {
+		return nil
+	}
+	assets, _, _ := parseAssets(cfg.Assets)
+	if assets == nil {
+		assets = []string{""}
+	}
+	return &utxoLedger{genesis: genesisOutputs(N, C, assets), wallet: genesisOutputs(N, C, assets), funded: make(map[TxID]Transaction)}
+}
+
+// fund is a split hook for SendTransactions: it turns the transactions into spends of their senders'
+// outputs, the same for every node
+func (l *utxoLedger) fund(node int, txs []Transaction) []Transaction {
+	if l == nil {
+		return txs
+	}
+	out := make([]Transaction, len(txs))
+	for k, tx := range txs {
+		if _, ok := l.funded[tx.TxID]; !ok {
+			l.funded[tx.TxID] = l.pay(tx)
+		}
+		out[k] = l.funded[tx.TxID]
+	}
+	return out
+}
+
+// pay funds tx from the sender's outputs, oldest first, leaving it without inputs if they do not cover it
+func (l *utxoLedger) pay(tx Transaction) Transaction {
+	value := tx.Amount
+	if value == 0 {
+		value = amountScale
+	}
+	owned := []Outpoint{}
+	for op, o := range l.wallet {
+		if o.Owner == tx.Sender && o.Asset == tx.Asset {
+			owned = append(owned, op)
+		}
+	}
+	slices.SortFunc(owned, func(a, b Outpoint) int { return cmp.Or(cmp.Compare(a.Tx, b.Tx), a.Index-b.Index) })
+	var in Amount
+	for _, op := range owned {
+		if in >= value {
+			break
+		}
+		tx.Inputs = append(tx.Inputs, op)
+		in += l.wallet[op].Value
+	}
+	if in < value {
+		l.mu.Lock()
+		l.unfunded++
+		l.mu.Unlock()
+		tx.Inputs = nil
+		return tx
+	}
+	tx.Outputs = []TxOut{{Owner: tx.Receiver, Value: value, Asset: tx.Asset}}
+	if in > value {
+		tx.Outputs = append(tx.Outputs, TxOut{Owner: tx.Sender, Value: in - value, Asset: tx.Asset})
+	}
+	l.wallet.spend(tx)
+	return tx
+}
+
+// chain returns the unspent outputs at the tip of the chain ending in head
+func (l *utxoLedger) chain(HashMap BlockStore, G Block, head string) utxoSet {
+	set := maps.Clone(l.genesis)
+	for _, b := range buildBlockChain(HashMap, G, head) {
+		for _, tx := range b.Transactions {
+			set.spend(tx)
+		}
+	}
+	return set
+}
+
+// spendable keeps the pending transactions a miner on head can include, in order, and drops the rest
+func (l *utxoLedger) spendable(HashMap BlockStore, G Block, head string, pending []Transaction) []Transaction {
+	if l == nil {
+		return pending
+	}
+	set := l.chain(HashMap, G, head)
+	valid := pending[:0]
+	for _, tx := range pending {
+		if set.spend(tx) {
+			valid = append(valid, tx)
+		}
+	}
+	l.mu.Lock()
+	l.dropped += len(pending) - len(valid)
+	l.mu.Unlock()
+	return valid
+}
+
+// abandoned returns the transactions of the blocks a switch from oldTip to newTip leaves behind, in
+// chain order, for the node to mine again
+func (l *utxoLedger) abandoned(HashMap BlockStore, Counts map[string]int, oldTip, newTip string) []Transaction {
+	if l == nil {
+		return nil
+	}
+	blocks := []Block{}
+	for oldTip != newTip && (Counts[oldTip] > 0 || Counts[newTip] > 0) {
+		if Counts[oldTip] >= Counts[newTip] {
+			b, _ := HashMap.Get(oldTip)
+			blocks = append(blocks, b)
+			oldTip = b.PrevHash
+		} else {
+			b, _ := HashMap.Get(newTip)
+			newTip = b.PrevHash
+		}
+	}
+	txs := []Transaction{}
+	for k := len(blocks) - 1; k >= 0; k-- {
+		txs = append(txs, blocks[k].Transactions...)
+	}
+	return txs
+}
+
+// validBlock tells whether every transaction of b spends outputs unspent on the chain b extends
+func (l *utxoLedger) validBlock(HashMap BlockStore, G Block, b Block) bool {
+	if l == nil {
+		return true
+	}
+	set := l.chain(HashMap, G, b.PrevHash)
+	for _, tx := range b.Transactions {
+		if !set.spend(tx) {
+			l.mu.Lock()
+			l.rejected++
+			l.mu.Unlock()
+			return false
+		}
+	}
+	return true
+}
+
+// result checks the winning chain against the transactions the wallets funded
+func (l *utxoLedger) result(winner []Block, doubleSpends int) UTXOStats {
+	if l == nil {
+		return UTXOStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := UTXOStats{
+		Funded:       len(l.funded) - l.unfunded,
+		Unfunded:     l.unfunded,
+		Dropped:      l.dropped,
+		Rejected:     l.rejected,
+		DoubleSpends: doubleSpends,
+	}
+	set := maps.Clone(l.genesis)
+	for _, b := range winner {
+		for _, tx := range b.Transactions {
+			if set.spend(tx) && tx.Receiver != l.funded[tx.TxID].Receiver { // paid to the twin's receiver
+				stats.Twins++
+			}
+		}
+	}
+	stats.Unspent = len(set)
+	return stats
+}
+
+func printUTXOStats(stats UTXOStats) {
+	fmt.Println("UTXO funded        =", stats.Funded)
+	fmt.Println("UTXO unfunded      =", stats.Unfunded)
+	fmt.Println("UTXO dropped       =", stats.Dropped)
+	fmt.Println("UTXO rejected      =", stats.Rejected)
+	fmt.Println("Double spends      =", stats.DoubleSpends)
+	fmt.Println("Twins confirmed    =", stats.Twins)
+	fmt.Println("Unspent outputs    =", stats.Unspent)
+}
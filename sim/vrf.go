@@ -0,0 +1,177 @@
+package sim
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+)
+
+// --- VRF Leader Election ---
+
+/*
+	vrfElection picks leaders and committees the way VRF-based proof-of-stake protocols do. Every
+	node evaluates a verifiable random function on the seed of a height and the round: the output
+	is unpredictable before the seed is known, but anyone can check it against the node's key. Here
+	the VRF is a SHA-256 of seed, round and node index, which gives the same unpredictability without
+	the proofs. The output u in (0,1) becomes a ticket -ln(u)/stake and the lowest ticket leads,
+	which elects each node with probability proportional to its stake (see stake.go).
+
+	The seed of the next height hashes the seed with the VRF output of the height's round 0 leader,
+	plus a nonce the leader picks. Honest leaders use nonce 0. A corrupt leader grinds: it tries up
+	to GrindAttempts nonces and keeps the first seed that makes a corrupt node lead the next height,
+	so corrupt nodes lead more often than their share of stake. Leaders and seeds are computed on
+	demand and cached, so every node sees the same schedule.
+*/
+
+// LeaderStats reports how often corrupt nodes were elected leader
+type LeaderStats struct {
+	Elections      int     // (height, round) leaders elected
+	CorruptLeaders int     // elections won by corrupt nodes
+	CorruptShare   float64 // % of elections won by corrupt nodes
+	FairShare      float64 // % of all stake held by corrupt nodes, their share without grinding
+	Grinds         int     // heights at which a corrupt leader ground the next seed to a corrupt leader
+}
+
+type vrfKey struct {
+	Height int
+	Round  int
+}
+
+type vrfElection struct {
+	mu      sync.Mutex
+	stakes  []float64
+	C       int
+	grind   int
+	seeds   []string // seeds[h] is the seed of height h
+	leaders map[vrfKey]int
+	stats   LeaderStats
+}
+
+func newVRFElection(stakes []float64, C, grind int) *vrfElection {
+	return &vrfElection{
+		stakes:  stakes,
+		C:       C,
+		grind:   grind,
+		seeds:   []string{fmt.Sprint(rand.Uint64())}, // nobody knows the first seed in advance
+		leaders: make(map[vrfKey]int),
+		stats:   LeaderStats{FairShare: math.Round(10000*corruptShare(stakes, C)) / 100},
+	}
+}
+
+// vrfOutput is node's VRF output for round on seed
+func vrfOutput(seed string, round int, node int) []byte {
+	h := sha256.Sum256(fmt.Appendf(nil, "%s|%d|%d", seed, round, node))
+	return h[:]
+}
+
+// vrfTicket maps a VRF output to a ticket, the lowest ticket wins with probability proportional to stake
+func vrfTicket(output []byte, stake float64) float64 {
+	u := (float64(binary.BigEndian.Uint64(output)>>11) + 0.5) / (1 << 53) // uniform in (0,1)
+	return -math.Log(u) / stake
+}
+
+// sortition returns the node with the lowest ticket for round on seed
+func (e *vrfElection) sortition(seed string, round int) int {
+	best, leader := math.Inf(1), 0
+	for i, stake := range e.stakes {
+		if stake <= 0 {
+			continue
+		}
+		if t := vrfTicket(vrfOutput(seed, round, i), stake); t < best {
+			best, leader = t, i
+		}
+	}
+	return leader
+}
+
+// seed returns the seed of height, extending the chain of seeds as needed
+func (e *vrfElection) seed(height int) string {
+	for len(e.seeds) <= height {
+		h := len(e.seeds) - 1
+		prev := e.seeds[h]
+		leader := e.sortition(prev, 0)
+		output := hex.EncodeToString(vrfOutput(prev, 0, leader))
+		next := func(nonce int) string {
+			s := sha256.Sum256(fmt.Appendf(nil, "%s|%s|%d", prev, output, nonce))
+			return hex.EncodeToString(s[:])
+		}
+		seed := next(0)
+		if leader < e.C && e.sortition(seed, 0) >= e.C {
+			for nonce := 1; nonce < e.grind; nonce++ {
+				if s := next(nonce); e.sortition(s, 0) < e.C {
+					seed = s
+					e.stats.Grinds++
+					break
+				}
+			}
+		}
+		e.seeds = append(e.seeds, seed)
+	}
+	return e.seeds[height]
+}
+
+// leader returns the node that leads round of height
+func (e *vrfElection) leader(height, round int) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	k := vrfKey{height, round}
+	if l, ok := e.leaders[k]; ok {
+		return l
+	}
+	l := e.sortition(e.seed(height), round)
+	e.leaders[k] = l
+	e.stats.Elections++
+	if l < e.C {
+		e.stats.CorruptLeaders++
+	}
+	return l
+}
+
+// committee returns the size nodes with the lowest tickets for round of height, lowest first
+func (e *vrfElection) committee(height, round, size int) []int {
+	e.mu.Lock()
+	seed := e.seed(height)
+	e.mu.Unlock()
+	tickets := make([]float64, len(e.stakes))
+	members := []int{}
+	for i, stake := range e.stakes {
+		if stake > 0 {
+			tickets[i] = vrfTicket(vrfOutput(seed, round, i), stake)
+			members = append(members, i)
+		}
+	}
+	slices.SortFunc(members, func(a, b int) int { return cmp.Compare(tickets[a], tickets[b]) })
+	return members[:min(size, len(members))]
+}
+
+// rounds lists every (height, round) a leader was elected for
+func (e *vrfElection) rounds() []vrfKey {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Collect(maps.Keys(e.leaders))
+}
+
+func (e *vrfElection) result() LeaderStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := e.stats
+	if stats.Elections > 0 {
+		stats.CorruptShare = getPercentage(stats.CorruptLeaders, stats.Elections)
+	}
+	return stats
+}
+
+func printLeaderStats(stats LeaderStats) {
+	fmt.Println("Leader elections   =", stats.Elections)
+	fmt.Println("Corrupt leaders    =", stats.CorruptLeaders)
+	fmt.Println("Corrupt leader %   =", stats.CorruptShare)
+	fmt.Println("Fair share %       =", stats.FairShare)
+	fmt.Println("Ground seeds       =", stats.Grinds)
+}
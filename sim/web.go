@@ -0,0 +1,360 @@
+package sim
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --- Web UI ---
+
+/*
+	With -web <addr> the tester serves a page at addr instead of running the suite, so course users need
+	no terminal (cmd/web serves it at localhost:8080 with the default settings): a form for N, C, R, D, p
+	and the simulator runs one simulation with the settings of the other flags, and the page shows its
+	metrics (the filled columns of its CSV row) and its block tree (see blocktree.go), a chain in layers by height and a DAG force-directed. The winning chain or
+	confident set is green, the corrupt nodes' blocks have a red border.
+
+	GET /run?mode=pow&N=10&C=2&R=3&D=1&p=0.8 runs a simulation and answers JSON, the page's own API.
+	Simulations run one at a time, for at most webTimeout (or -timeout if shorter), on at most
+	webMaxNodes nodes.
+*/
+
+const (
+	webTimeout  = time.Minute
+	webMaxNodes = 200
+)
+
+// webNode and webEdge are the block tree of a simulation as the page draws it
+type webNode struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Winning bool   `json:"winning"`
+	Corrupt bool   `json:"corrupt"`
+}
+
+type webEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Dashed bool   `json:"dashed"`
+}
+
+// webRun is the answer to /run
+type webRun struct {
+	Type    string      `json:"type"`
+	Metrics [][2]string `json:"metrics"` // column, value
+	Nodes   []webNode   `json:"nodes"`
+	Edges   []webEdge   `json:"edges"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// webParams reads the test and the simulator of a /run request, which runs for at most timeout if set
+func webParams(r *http.Request, timeout time.Duration) (BenchmarkConfig, string, error) {
+	q := r.URL.Query()
+	mode := q.Get("mode")
+	if simulators[mode] == nil {
+		return BenchmarkConfig{}, "", fmt.Errorf("unknown simulator %q", mode)
+	}
+	ints := map[string]*int{}
+	t := BenchmarkConfig{}
+	ints["N"], ints["C"], ints["R"], ints["D"] = &t.N, &t.C, &t.R, &t.D
+	for name, v := range ints {
+		n, err := strconv.Atoi(q.Get(name))
+		if err != nil {
+			return t, "", fmt.Errorf("%s: %q is not a whole number", name, q.Get(name))
+		}
+		*v = n
+	}
+	p, err := strconv.ParseFloat(q.Get("p"), 64)
+	switch {
+	case t.N < 1 || t.N > webMaxNodes:
+		return t, "", fmt.Errorf("N must be between 1 and %d", webMaxNodes)
+	case t.C < 0 || t.C > t.N:
+		return t, "", errors.New("C must be between 0 and N")
+	case t.R < 1 || t.D < 1:
+		return t, "", errors.New("R and D must be at least 1")
+	case t.D > maxDifficulty:
+		return t, "", fmt.Errorf("D must be at most %d, the hex digits of a hash", maxDifficulty)
+	case err != nil || p < 0 || p > 1:
+		return t, "", errors.New("p must be a probability")
+	}
+	t.p = p
+	t.Timeout = webTimeout
+	if timeout > 0 {
+		t.Timeout = min(t.Timeout, timeout)
+	}
+	return t, mode, nil
+}
+
+// ServeWeb serves the web UI at addr until ctx is done, running the simulations with the settings of o
+func ServeWeb(ctx context.Context, addr string, o Options) error {
+	log := componentLogger("web")
+	modes := []string{}
+	for mode := range simulators {
+		modes = append(modes, mode)
+	}
+	slices.Sort(modes)
+	var running sync.Mutex // one simulation at a time
+	stream := newEventStream()
+	runs := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		if err := webPage.Execute(w, modes); err != nil {
+			log.Error("rendering the page failed", "err", err)
+		}
+	})
+	mux.Handle("GET /events", stream)
+	mux.HandleFunc("GET /run", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		t, mode, err := webParams(r, o.Timeout)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(webRun{Error: err.Error()})
+			return
+		}
+		running.Lock()
+		defer running.Unlock()
+		log.Info("running simulation", "mode", mode, "N", t.N, "C", t.C, "R", t.R, "D", t.D, "p", t.p)
+		cfg := o.config(t)
+		cfg.Events = NewEventBus()
+		tree := trackBlockTree(cfg.Events)
+		runs++
+		end := stream.watch(cfg.Events, runs, mode, t)
+		sample := sampleResources()
+		res := simulators[mode](r.Context(), cfg)
+		res.Resources = sample()
+		end(res)
+		res.Workload = cmp.Or(cfg.Workload, "uniform")
+		json.NewEncoder(w).Encode(newWebRun(res, tree))
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	log.Info("serving the web UI", "addr", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newWebRun collects the filled columns and the block tree of res
+func newWebRun(res SimResult, tree *blockTree) webRun {
+	run := webRun{Type: res.Type, Metrics: [][2]string{}, Nodes: []webNode{}, Edges: []webEdge{}}
+	for k, value := range resultRow(res) {
+		if value != "" {
+			run.Metrics = append(run.Metrics, [2]string{resultHeaders()[k], value})
+		}
+	}
+	nodes, edges := tree.layout(res.Winner)
+	for _, n := range nodes {
+		run.Nodes = append(run.Nodes, webNode{ID: n.id, Label: n.label, Winning: n.winning, Corrupt: n.corrupt})
+	}
+	for _, e := range edges {
+		run.Edges = append(run.Edges, webEdge{From: e.from, To: e.to, Dashed: e.dashed})
+	}
+	return run
+}
+
+var webPage = template.Must(template.New("web").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Blockchain simulator</title>
+<style>
+	body { font-family: sans-serif; margin: 1.5em; }
+	form label { margin-right: 1em; }
+	input { width: 4em; }
+	#status { margin: 0.8em 0; color: #555; }
+	#main { display: flex; gap: 1.5em; align-items: flex-start; }
+	canvas { border: 1px solid #ccc; }
+	table { border-collapse: collapse; font-size: 0.85em; }
+	td { padding: 1px 8px; border-bottom: 1px solid #eee; }
+	#tip { position: absolute; background: #ffe; border: 1px solid #999; padding: 2px 5px; font-size: 0.8em; display: none; }
+</style>
+</head>
+<body>
+<h2>Blockchain simulator</h2>
+<form id="form">
+	<label>Simulator <select name="mode">{{range .}}<option{{if eq . "pow"}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+	<label>N <input name="N" value="10"></label>
+	<label>C <input name="C" value="2"></label>
+	<label>R <input name="R" value="3"></label>
+	<label>D <input name="D" value="1"></label>
+	<label>p <input name="p" value="0.8"></label>
+	<button>Run</button>
+</form>
+<div id="status">Set the parameters and run a simulation.</div>
+<div id="main">
+	<canvas id="graph" width="900" height="600"></canvas>
+	<table id="metrics"></table>
+</div>
+<div id="tip"></div>
+<script>
+const canvas = document.getElementById("graph"), ctx = canvas.getContext("2d");
+const tip = document.getElementById("tip");
+let nodes = [], edges = [], frame = 0, running = false, mined = 0, confirmed = 0;
+
+// the live event stream counts the blocks of the running simulation
+const events = new WebSocket("ws://" + location.host + "/events");
+events.onmessage = msg => {
+	const e = JSON.parse(msg.data);
+	if (e.kind === "start") mined = confirmed = 0;
+	if (e.kind === "mined") mined++;
+	if (e.kind === "confirmed") confirmed++;
+	if (running) document.getElementById("status").textContent = "Running... " + mined + " blocks mined, " + confirmed + " transactions confirmed";
+};
+
+document.getElementById("form").addEventListener("submit", async ev => {
+	ev.preventDefault();
+	const status = document.getElementById("status");
+	status.textContent = "Running...";
+	running = true;
+	const res = await fetch("/run?" + new URLSearchParams(new FormData(ev.target)));
+	const run = await res.json();
+	running = false;
+	if (run.error) {
+		status.textContent = "Error: " + run.error;
+		return;
+	}
+	status.textContent = run.type + ": " + run.nodes.length + " blocks, " + run.edges.length + " links";
+	const table = document.getElementById("metrics");
+	table.innerHTML = "";
+	for (const [name, value] of run.metrics) {
+		const row = table.insertRow();
+		row.insertCell().textContent = name;
+		row.insertCell().textContent = value;
+	}
+	show(run.nodes, run.edges);
+});
+
+// show lays out a chain in layers by height, anything else (a DAG) force-directed
+function show(ns, es) {
+	cancelAnimationFrame(frame);
+	const byId = new Map(ns.map(n => [n.id, n]));
+	nodes = ns;
+	edges = es.filter(e => byId.has(e.from) && byId.has(e.to)).map(e => ({from: byId.get(e.from), to: byId.get(e.to), dashed: e.dashed}));
+	const parents = new Map();
+	let tree = true;
+	for (const e of edges) {
+		if (e.dashed) continue;
+		if (parents.has(e.from)) tree = false;
+		parents.set(e.from, e.to);
+	}
+	if (tree) {
+		const depth = n => n.depth !== undefined ? n.depth : (n.depth = parents.has(n) ? depth(parents.get(n)) + 1 : 0);
+		const lanes = [];
+		for (const n of [...nodes].sort((a, b) => b.winning - a.winning)) {
+			const d = depth(n);
+			lanes[d] = (lanes[d] || 0) + 1;
+			n.x = d * 60;
+			n.y = (lanes[d] - 1) * 40;
+		}
+		draw();
+		return;
+	}
+	for (const n of nodes) {
+		n.x = Math.random() * canvas.width;
+		n.y = Math.random() * canvas.height;
+	}
+	let temperature = 50;
+	const step = () => {
+		force(temperature);
+		temperature *= 0.97;
+		draw();
+		if (temperature > 0.5) frame = requestAnimationFrame(step);
+	};
+	step();
+}
+
+// force moves the nodes one step of a Fruchterman-Reingold layout
+function force(temperature) {
+	const k = Math.sqrt(canvas.width * canvas.height / Math.max(nodes.length, 1)) * 0.5;
+	for (const n of nodes) n.dx = n.dy = 0;
+	for (let i = 0; i < nodes.length; i++) {
+		for (let j = i + 1; j < nodes.length; j++) {
+			const a = nodes[i], b = nodes[j];
+			let dx = a.x - b.x, dy = a.y - b.y;
+			const d2 = Math.max(dx * dx + dy * dy, 0.01), f = k * k / d2;
+			a.dx += dx * f; a.dy += dy * f;
+			b.dx -= dx * f; b.dy -= dy * f;
+		}
+	}
+	for (const e of edges) {
+		const dx = e.from.x - e.to.x, dy = e.from.y - e.to.y, d = Math.sqrt(dx * dx + dy * dy) || 0.1, f = d / k;
+		e.from.dx -= dx * f; e.from.dy -= dy * f;
+		e.to.dx += dx * f; e.to.dy += dy * f;
+	}
+	for (const n of nodes) {
+		const d = Math.sqrt(n.dx * n.dx + n.dy * n.dy) || 1;
+		n.x += n.dx / d * Math.min(d, temperature);
+		n.y += n.dy / d * Math.min(d, temperature);
+	}
+}
+
+// view fits the nodes into the canvas
+function view() {
+	const xs = nodes.map(n => n.x), ys = nodes.map(n => n.y);
+	const minX = Math.min(...xs), minY = Math.min(...ys);
+	const scale = Math.min(1.5, (canvas.width - 60) / Math.max(Math.max(...xs) - minX, 1), (canvas.height - 60) / Math.max(Math.max(...ys) - minY, 1));
+	return n => [30 + (n.x - minX) * scale, 30 + (n.y - minY) * scale];
+}
+
+function draw() {
+	ctx.clearRect(0, 0, canvas.width, canvas.height);
+	if (nodes.length === 0) return;
+	const at = view();
+	for (const e of edges) {
+		const [x1, y1] = at(e.from), [x2, y2] = at(e.to);
+		ctx.setLineDash(e.dashed ? [4, 3] : []);
+		ctx.strokeStyle = "#999";
+		ctx.beginPath(); ctx.moveTo(x1, y1); ctx.lineTo(x2, y2); ctx.stroke();
+		const a = Math.atan2(y2 - y1, x2 - x1);
+		ctx.beginPath();
+		ctx.moveTo(x2 - 7 * Math.cos(a), y2 - 7 * Math.sin(a));
+		ctx.lineTo(x2 - 13 * Math.cos(a - 0.4), y2 - 13 * Math.sin(a - 0.4));
+		ctx.lineTo(x2 - 13 * Math.cos(a + 0.4), y2 - 13 * Math.sin(a + 0.4));
+		ctx.fillStyle = "#999"; ctx.fill();
+	}
+	ctx.setLineDash([]);
+	for (const n of nodes) {
+		const [x, y] = at(n);
+		ctx.beginPath(); ctx.arc(x, y, 6, 0, 2 * Math.PI);
+		ctx.fillStyle = n.winning ? "#98fb98" : "#fff"; ctx.fill();
+		ctx.lineWidth = n.corrupt ? 2.5 : 1;
+		ctx.strokeStyle = n.corrupt ? "#e00" : "#333"; ctx.stroke();
+		ctx.lineWidth = 1;
+		if (nodes.length <= 60) {
+			ctx.fillStyle = "#333"; ctx.font = "10px sans-serif";
+			ctx.fillText(n.label, x + 8, y - 6);
+		}
+	}
+}
+
+canvas.addEventListener("mousemove", ev => {
+	if (nodes.length === 0) return;
+	const at = view(), r = canvas.getBoundingClientRect();
+	const mx = ev.clientX - r.left, my = ev.clientY - r.top;
+	const near = nodes.find(n => { const [x, y] = at(n); return (x - mx) ** 2 + (y - my) ** 2 < 64; });
+	tip.style.display = near ? "block" : "none";
+	if (near) {
+		tip.textContent = near.label;
+		tip.style.left = ev.pageX + 12 + "px";
+		tip.style.top = ev.pageY + 12 + "px";
+	}
+});
+</script>
+</body>
+</html>
+`))
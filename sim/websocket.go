@@ -0,0 +1,275 @@
+package sim
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Live Event Stream ---
+
+/*
+	/events streams the events of the running simulation over a WebSocket, one JSON message each, for
+	dashboards that animate block propagation as it happens. The web UI serves it next to its page (and
+	uses it to count blocks while a run is going), and -events-ws <addr> serves it at addr while the suite
+	runs. Every simulation starts with a "start" message (test, mode, N, C, R, D, p) and ends with an "end"
+	message (the winner); between them come the mined, accepted, reorg and confirmed events of every
+	node, e.g.
+
+		{"kind":"mined","sim":"PoW","time":"...","node":"honest3","hash":"00a1...","prev":"007c...",
+		 "height":4,"miner":"honest3","txs":12}
+
+	A DAG vertex has "parents" instead of "prev", and confirmed events carry "tx". The bus must not
+	block on a slow client, so a client that falls wsBuffer messages behind misses the newer ones.
+
+	The WebSocket server side (RFC 6455) is implemented here on net/http's hijacked connections to keep
+	the tester free of dependencies: text frames out, and the client's frames are only read to notice
+	when it closes.
+*/
+
+const wsBuffer = 4096
+
+// wsMessage is an event as streamed
+type wsMessage struct {
+	Kind    string    `json:"kind"`
+	Sim     string    `json:"sim,omitempty"`
+	Time    time.Time `json:"time"`
+	Node    string    `json:"node,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+	Prev    string    `json:"prev,omitempty"`
+	Parents []string  `json:"parents,omitempty"`
+	Height  int       `json:"height,omitempty"`
+	Depth   int       `json:"depth,omitempty"`
+	Miner   string    `json:"miner,omitempty"`
+	Txs     int       `json:"txs,omitempty"`
+	Tx      *wsTx     `json:"tx,omitempty"`
+
+	// start and end of a simulation
+	Test   int     `json:"test,omitempty"`
+	Mode   string  `json:"mode,omitempty"`
+	N      int     `json:"N,omitempty"`
+	C      int     `json:"C,omitempty"`
+	R      int     `json:"R,omitempty"`
+	D      int     `json:"D,omitempty"`
+	P      float64 `json:"p,omitempty"`
+	Winner string  `json:"winner,omitempty"`
+}
+
+type wsTx struct {
+	ID       TxID   `json:"id"`
+	Sender   string `json:"sender"`
+	Receiver string `json:"receiver"`
+	Amount   Amount `json:"amount"`
+}
+
+// eventStream fans the messages out to the connected clients
+type eventStream struct {
+	mu      sync.Mutex
+	nextID  int
+	clients map[int]chan []byte
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{clients: make(map[int]chan []byte)}
+}
+
+// send queues m for every client, dropping it for those that are behind
+func (s *eventStream) send(m wsMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 {
+		return
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	for _, ch := range s.clients {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+// watch streams the events of bus, a simulation of mode in test, until the returned function ends it
+func (s *eventStream) watch(bus *EventBus, test int, mode string, t BenchmarkConfig) func(SimResult) {
+	s.send(wsMessage{Kind: "start", Time: time.Now(), Test: test, Mode: mode, N: t.N, C: t.C, R: t.R, D: t.D, P: t.p})
+	unsubscribe := bus.Subscribe(func(e Event) {
+		m := wsMessage{Kind: e.Kind.String(), Sim: e.Sim, Time: e.Time, Node: e.Node, Height: e.Height, Depth: e.Depth}
+		switch {
+		case e.Kind == EventConfirmed:
+			m.Tx = &wsTx{ID: e.Tx.TxID, Sender: e.Tx.Sender, Receiver: e.Tx.Receiver, Amount: e.Tx.Amount}
+		case len(e.Block.Transactions) == 1 && e.Block.PrevHash == "" && e.Block.Miner == "": // a DAG vertex
+			m.Hash, m.Parents, m.Txs = e.Block.Hash, e.Block.Transactions[0].Parents, 1
+		default:
+			m.Hash, m.Prev, m.Miner, m.Txs = e.Block.Hash, e.Block.PrevHash, e.Block.Miner, len(e.Block.Transactions)
+		}
+		s.send(m)
+	}, EventMined, EventAccepted, EventReorg, EventConfirmed)
+	return func(res SimResult) {
+		unsubscribe()
+		s.send(wsMessage{Kind: "end", Time: time.Now(), Test: test, Mode: mode, Sim: res.Type, Winner: res.Winner})
+	}
+}
+
+// flush waits up to timeout for the clients to be sent their queued messages
+func (s *eventStream) flush(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		queued := 0
+		for _, ch := range s.clients {
+			queued += len(ch)
+		}
+		s.mu.Unlock()
+		if queued == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket and streams the messages to it until either side closes
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, rw, err := wsAccept(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	ch := make(chan []byte, wsBuffer)
+	s.mu.Lock()
+	id := s.nextID
+	s.nextID++
+	s.clients[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, id)
+		s.mu.Unlock()
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		wsDrain(rw.Reader)
+	}()
+	for {
+		select {
+		case data := <-ch:
+			if err := wsWrite(rw.Writer, 0x1, data); err != nil {
+				return
+			}
+		case <-closed:
+			wsWrite(rw.Writer, 0x8, nil)
+			return
+		case <-r.Context().Done():
+			wsWrite(rw.Writer, 0x8, nil)
+			return
+		}
+	}
+}
+
+// wsAccept answers the opening handshake of a WebSocket and takes over its connection
+func wsAccept(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		return nil, nil, errors.New("not a WebSocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be taken over")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// wsWrite sends one unmasked frame of opcode (0x1 text, 0x8 close)
+func wsWrite(w *bufio.Writer, opcode byte, data []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(data); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = binary.BigEndian.AppendUint16(append(header, 126), uint16(n))
+	default:
+		header = binary.BigEndian.AppendUint64(append(header, 127), uint64(n))
+	}
+	w.Write(header)
+	w.Write(data)
+	return w.Flush()
+}
+
+// wsDrain reads the client's frames until it closes the connection or sends a close frame
+func wsDrain(r *bufio.Reader) {
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		if header[0]&0x0f == 0x8 {
+			return
+		}
+		n := uint64(header[1] & 0x7f)
+		switch n {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(ext)
+		}
+		if header[1]&0x80 != 0 {
+			n += 4 // masking key
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(n)); err != nil {
+			return
+		}
+	}
+}
+
+// serveEvents serves stream at addr/events until ctx is done
+func serveEvents(ctx context.Context, log *slog.Logger, addr string, stream *eventStream) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /events", stream)
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	go func() {
+		log.Info("streaming events", "url", "ws://"+addr+"/events")
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Error("event stream failed", "addr", addr, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,159 @@
+package sim
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// --- Block Withholding ---
+
+/*
+	SimConfig.Withhold picks when the corrupt PoW nodes release their withheld blocks to the honest
+	nodes. The corrupt nodes always mine on their own chain and share it among themselves; corrupt0
+	acts as the pool operator that decides what to release. It knows the public chain from the honest
+	blocks it receives and the blocks it released.
+
+	- private (default): never release, the corrupt chain only wins if it is the longest at the end.
+	- lead:k: release the whole private chain once it leads the public chain by k blocks.
+	- selfish: selfish mining (Eyal and Sirer). When the honest nodes find a block, release the private
+	  block at the same height (match), the whole private chain if it led by 2 (override) or if it led
+	  by 1 (race), and once the pool wins a block on top of a race, release it to settle the race.
+	- stubborn: lead-stubborn mining (Nayak et al.), like selfish but never override, only match.
+	- stubborn-eq: equal-fork stubborn mining, like selfish but keep the block won on top of a race.
+
+	Every release is broadcast to all honest nodes, who follow the longest chain and keep the chain
+	they saw first on a tie. The Corrupt Reward % of the winning chain against the Corrupt % of the
+	nodes (their hash power) gives the revenue curve of a strategy.
+*/
+
+// Withholding strategies of the corrupt PoW nodes (SimConfig.Withhold)
+const (
+	WithholdPrivate    = "private"
+	WithholdLead       = "lead"
+	WithholdSelfish    = "selfish"
+	WithholdStubborn   = "stubborn"
+	WithholdStubbornEq = "stubborn-eq"
+)
+
+// WithholdStats reports the releases of a withholding strategy
+type WithholdStats struct {
+	Strategy  string
+	Releases  int // times the pool released blocks
+	Released  int // blocks released
+	Matches   int // releases that tied the public chain
+	Overrides int // releases that made the private chain the longest public one
+}
+
+// parseWithhold splits a strategy into its kind and the lead of lead:k
+func parseWithhold(s string) (kind string, lead int, err error) {
+	if s == "" {
+		return WithholdPrivate, 0, nil
+	}
+	if k, ok := strings.CutPrefix(s, WithholdLead+":"); ok {
+		lead, err = strconv.Atoi(k)
+		if err != nil || lead < 1 {
+			return "", 0, fmt.Errorf("lead:k needs a lead k of at least 1, not %q", k)
+		}
+		return WithholdLead, lead, nil
+	}
+	switch s {
+	case WithholdPrivate, WithholdSelfish, WithholdStubborn, WithholdStubbornEq:
+		return s, 0, nil
+	}
+	return "", 0, fmt.Errorf("unknown withholding strategy %q", s)
+}
+
+// withholder is the pool operator's view of the public chain
+type withholder struct {
+	kind   string
+	lead   int
+	public map[string]bool // blocks the honest nodes know
+	pubLen int             // height of the longest public chain
+	raced  bool            // the whole private chain was released to tie the public chain
+	stats  WithholdStats
+}
+
+// newWithholder returns nil for the private strategy
+func newWithholder(cfg SimConfig) *withholder {
+	kind, lead, err := parseWithhold(cfg.Withhold)
+	if err != nil || kind == WithholdPrivate {
+		return nil
+	}
+	return &withholder{kind: kind, lead: lead, public: make(map[string]bool), stats: WithholdStats{Strategy: cfg.Withhold}}
+}
+
+// honestBlock records a block the honest nodes mined and returns the height to release the private
+// chain up to (0 = nothing) given the height of the private chain
+func (w *withholder) honestBlock(hash string, height, priv int) int {
+	w.public[hash] = true
+	if height <= w.pubLen { // a stale block, the public chain did not grow
+		return 0
+	}
+	w.pubLen = height
+	w.raced = false
+	lead := priv - w.pubLen
+	switch {
+	case w.kind == WithholdLead || lead < 0:
+		return 0
+	case lead == 0:
+		w.raced = true
+		return priv
+	case lead == 1 && w.kind != WithholdStubborn:
+		return priv
+	}
+	return w.pubLen
+}
+
+// privateBlock returns the height to release the private chain up to once it grew to priv
+func (w *withholder) privateBlock(priv int) int {
+	switch {
+	case w.kind == WithholdLead && priv-w.pubLen >= w.lead:
+		return priv
+	case w.raced && w.kind != WithholdStubbornEq && priv > w.pubLen:
+		w.raced = false
+		return priv
+	}
+	return 0
+}
+
+// unreleased returns the blocks of the chain ending at head up to height upTo that the honest nodes
+// do not know, oldest first, and marks them public
+func (w *withholder) unreleased(HashMap BlockStore, Counts map[string]int, head string, upTo int) []Block {
+	blocks := []Block{}
+	for h := head; h != "" && !w.public[h]; {
+		b, ok := HashMap.Get(h)
+		if !ok { // genesis
+			break
+		}
+		if Counts[h] <= upTo {
+			blocks = append(blocks, b)
+		}
+		h = b.PrevHash
+	}
+	slices.Reverse(blocks)
+	for _, b := range blocks {
+		w.public[b.Hash] = true
+	}
+	if len(blocks) > 0 {
+		w.stats.Releases++
+		w.stats.Released += len(blocks)
+		switch top := Counts[blocks[len(blocks)-1].Hash]; {
+		case top > w.pubLen:
+			w.stats.Overrides++
+			w.pubLen = top
+		case top == w.pubLen:
+			w.stats.Matches++
+		}
+	}
+	return blocks
+}
+
+func printWithholdStats(stats WithholdStats) {
+	fmt.Println("Withholding        =", stats.Strategy)
+	fmt.Println("Releases           =", stats.Releases)
+	fmt.Println("Released blocks    =", stats.Released)
+	fmt.Println("Release matches    =", stats.Matches)
+	fmt.Println("Release overrides  =", stats.Overrides)
+}
@@ -0,0 +1,692 @@
+package sim
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Workloads ---
+
+/*
+	A Workload generates the transactions of every round, which SendTransactions hands to the nodes of
+	the sender's side (and the discrete-event engine sends at the start of the round). SimConfig.Workload
+	picks one of the built-in workloads:
+	- uniform (the default): every node sends every other node of its side a transaction with
+	  probability P, the same transactions as before workloads existed.
+	- poisson: every round carries a Poisson distributed number of transactions per side, with the mean
+	  of uniform (P times the pairs), between random pairs of nodes of the side.
+	- poisson:<rate>: transactions arrive one at a time as a Poisson process of rate transactions per
+	  second of simulated time, from a random node to a random other node of its side. Round r holds the
+	  arrivals of second r, so R is the seconds of arrivals, and every transaction reaches its side at
+	  its own arrival time: paced in real time by SendTransactions, in virtual time by the
+	  discrete-event engine. Transactions then queue for blocks like customers for a server, e.g. in
+	  des, where blocks come at exponential intervals of BlockTime, a transaction waits BlockTime for the
+	  next one on average whatever the rate (until MaxBlockTxs caps the blocks).
+	- zipf[:s]: poisson, but senders and receivers are drawn from a Zipf distribution over the nodes of
+	  the side, the k-th node sending and receiving in proportion to 1/k^s (s > 1, default 1.2), so a
+	  few hot accounts (honest1, corrupt1, ...) carry most of the traffic, like real payment graphs.
+	- bursty[:k]: uniform, but every k-th round (default 5) carries the traffic of the k rounds up to the
+	  next burst and the rounds between carry none, so the total is that of uniform.
+	- trace:<file>: replays a CSV file of round,sender,receiver lines, where round counts from 0, or
+	  of timestamp,sender,receiver,amount lines, e.g. exported from a public dataset. A header line may
+	  name the columns instead (round or timestamp/time, sender/from, receiver/to, amount/value and
+	  asset/token), and a .json file holds an array, or a stream, of objects with such fields.
+	  Timestamps are seconds (e.g. Unix time) or RFC 3339, and replay like poisson:<rate> arrivals: the
+	  first payment arrives at 0, every other one as many seconds later as the trace says. Senders and
+	  receivers that are node names like honest3 or corrupt1 stand for those nodes, any other addresses
+	  are mapped onto the honest nodes by activity (see traceNodes), and the amount and asset become the
+	  transaction's Amount and Asset. Rounds from R on, lines naming a node the simulation does not
+	  have, and payments between two addresses that map to the same node are left out.
+
+	Every transaction has a unique TxID, as the nodes and trackers identify transactions by it: the
+	workloads number them 1, 2, 3 and so on (uniform skips the numbers of the pairs that send nothing, as
+	before), and the simulators number the transactions they add themselves from bases of their own far
+	above (see the TxIDs constants below), channel and forged transactions below 0. A custom traffic
+	pattern only has to implement Next.
+
+	What a transaction transfers is its Amount, an integer count of minor units, amountScale of them to
+	1, so sums are exact and 2.18 does not become 2.179999999999997. It is written as a decimal, in JSON
+	too, and read from one exactly; an Amount holds up to 9.2e9.
+*/
+
+// TxID identifies a transaction
+type TxID int64
+
+// The first IDs of the transactions the simulators add to the workload's, far above any workload's
+const (
+	genesisTxIDs   TxID = (iota + 1) << 40 // the DAG's genesis transactions, see dag.go
+	spamTxIDs                              // see spam.go
+	rollupTxIDs                            // rollup commitments, see rollup.go
+	sidechainTxIDs                         // sidechain checkpoints, see sidechain.go
+	swapTxIDs                              // the steps of atomic swaps, see swap.go
+	receiptTxIDs                           // cross-shard receipts, see shard.go
+)
+
+func (id TxID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// Amount is what a transaction transfers in minor units, amountScale to 1
+type Amount int64
+
+const amountScale = 1_000_000_000
+
+// amountOf is v in minor units, rounded
+func amountOf(v float64) Amount {
+	return Amount(math.Round(v * amountScale))
+}
+
+// Float is a in units, for arithmetic and charts only
+func (a Amount) Float() float64 {
+	return float64(a) / amountScale
+}
+
+// String writes a as an exact decimal, e.g. 2.18
+func (a Amount) String() string {
+	sign, u := "", uint64(a)
+	if a < 0 {
+		sign, u = "-", -u
+	}
+	s := fmt.Sprintf("%s%d.%09d", sign, u/amountScale, u%amountScale)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// parseAmount reads a decimal like String writes, or any float ParseFloat takes, rounded to a minor unit
+func parseAmount(s string) (Amount, error) {
+	whole, frac, dot := strings.Cut(strings.TrimSpace(s), ".")
+	digits := strings.TrimLeft(whole, "+-") != "" || frac != ""
+	if digits && !strings.ContainsAny(s, "eEnN") && (!dot || frac != "") && len(frac) <= 9 {
+		if units, err := strconv.ParseInt(whole+frac+strings.Repeat("0", 9-len(frac)), 10, 64); err == nil {
+			return Amount(units), nil
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(v) || math.Abs(v*amountScale) >= math.MaxInt64 {
+		return 0, fmt.Errorf("amount %q is not a decimal of at most %d", s, math.MaxInt64/amountScale)
+	}
+	return amountOf(v), nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := parseAmount(string(data))
+	*a = v
+	return err
+}
+
+// Workload generates the transactions of round (0 .. R-1), honest and corrupt ones alike
+type Workload interface {
+	Next(round int) []Transaction
+}
+
+// arrivalWorkload is a Workload whose transactions arrive one at a time, at their arrival time since the
+// start of the simulation, rather than a round at a time
+type arrivalWorkload interface {
+	Workload
+	arrival(tx Transaction) time.Duration
+}
+
+// txIDs hands out the unique IDs of a workload's transactions
+type txIDs struct {
+	last TxID
+}
+
+func (ids *txIDs) id() TxID {
+	ids.last++
+	return ids.last
+}
+
+// uniformWorkload is the all-pairs pattern SendTransactions always had
+type uniformWorkload struct {
+	N, C  int
+	p     float64
+	float func() float64
+	ids   txIDs
+}
+
+func (w *uniformWorkload) Next(round int) []Transaction {
+	honest, corrupt := []Transaction{}, []Transaction{}
+	for i := range w.N {
+		for j := range w.N {
+			if i == j {
+				continue
+			}
+			l1, l2 := getLabel(i, w.C), getLabel(j, w.C)
+			if l1 != l2 {
+				continue
+			}
+			id := w.ids.id()
+			if w.float() <= w.p {
+				tx := Transaction{TxID: id, Sender: nodeName(i, w.C), Receiver: nodeName(j, w.C)}
+				if l1 == "honest" {
+					honest = append(honest, tx)
+				} else {
+					corrupt = append(corrupt, tx)
+				}
+			}
+		}
+	}
+	return append(honest, corrupt...)
+}
+
+// poissonWorkload draws the number of transactions of every side and round, and random pairs for them
+// (Zipf distributed if zipf is set)
+type poissonWorkload struct {
+	N, C  int
+	p     float64
+	rng   *rand.Rand
+	ids   txIDs
+	zipf  float64
+	ranks map[int]*rand.Zipf // side size -> distribution of the nodes' ranks
+}
+
+// pick draws one of n nodes
+func (w *poissonWorkload) pick(n int) int {
+	if w.zipf == 0 {
+		return w.rng.IntN(n)
+	}
+	if w.ranks[n] == nil {
+		w.ranks[n] = rand.NewZipf(w.rng, w.zipf, 1, uint64(n-1))
+	}
+	return int(w.ranks[n].Uint64())
+}
+
+func (w *poissonWorkload) Next(round int) []Transaction {
+	txs := []Transaction{}
+	for _, side := range [][2]int{{w.C, w.N}, {0, w.C}} { // honest first, like uniform
+		lo, n := side[0], side[1]-side[0]
+		if n < 2 {
+			continue
+		}
+		for range poisson(w.p*float64(n*(n-1)), w.rng) {
+			i, j := lo+w.pick(n), lo+w.pick(n)
+			for j == i { // any node of the side but i
+				j = lo + w.pick(n)
+			}
+			txs = append(txs, Transaction{TxID: w.ids.id(), Sender: nodeName(i, w.C), Receiver: nodeName(j, w.C)})
+		}
+	}
+	return txs
+}
+
+// poissonProcess is a Poisson process of rate transactions per second of simulated time
+type poissonProcess struct {
+	C       int
+	senders []int // nodes with another node on their side
+	sides   [][]int
+	rate    float64
+	rng     *rand.Rand
+	ids     txIDs
+	next    time.Duration          // arrival of the next transaction
+	at      map[TxID]time.Duration // tx -> arrival
+}
+
+func newPoissonProcess(N, C int, rate float64, rng *rand.Rand) *poissonProcess {
+	w := &poissonProcess{C: C, rate: rate, rng: rng, at: make(map[TxID]time.Duration)}
+	for _, side := range [][2]int{{0, C}, {C, N}} {
+		nodes := []int{}
+		for i := side[0]; i < side[1]; i++ {
+			nodes = append(nodes, i)
+		}
+		w.sides = append(w.sides, nodes)
+		if len(nodes) >= 2 {
+			w.senders = append(w.senders, nodes...)
+		}
+	}
+	w.next = w.gap()
+	return w
+}
+
+// gap is the exponentially distributed time between two arrivals
+func (w *poissonProcess) gap() time.Duration {
+	return time.Duration(w.rng.ExpFloat64() / w.rate * float64(time.Second))
+}
+
+func (w *poissonProcess) Next(round int) []Transaction {
+	txs := []Transaction{}
+	for w.next < time.Duration(round+1)*time.Second && len(w.senders) > 0 {
+		i := w.senders[w.rng.IntN(len(w.senders))]
+		side := w.sides[0]
+		if i >= w.C {
+			side = w.sides[1]
+		}
+		k := w.rng.IntN(len(side) - 1)
+		if k >= i-side[0] { // any node of the side but i
+			k++
+		}
+		tx := Transaction{TxID: w.ids.id(), Sender: nodeName(i, w.C), Receiver: nodeName(side[k], w.C)}
+		w.at[tx.TxID] = w.next
+		txs = append(txs, tx)
+		w.next += w.gap()
+	}
+	return txs
+}
+
+func (w *poissonProcess) arrival(tx Transaction) time.Duration {
+	return w.at[tx.TxID]
+}
+
+// poisson samples a Poisson distributed count with the given mean
+func poisson(mean float64, rng *rand.Rand) int {
+	if mean <= 0 {
+		return 0
+	}
+	if mean > 30 { // Knuth's method underflows, the normal approximation is close enough
+		return max(0, int(math.Round(mean+math.Sqrt(mean)*rng.NormFloat64())))
+	}
+	limit, k, prod := math.Exp(-mean), 0, rng.Float64()
+	for prod > limit {
+		k++
+		prod *= rng.Float64()
+	}
+	return k
+}
+
+// burstyWorkload saves up the uniform traffic of k rounds for every k-th round
+type burstyWorkload struct {
+	uniform *uniformWorkload
+	k, R    int
+}
+
+func (w *burstyWorkload) Next(round int) []Transaction {
+	if round%w.k != 0 {
+		return nil
+	}
+	txs := []Transaction{}
+	for range min(w.k, w.R-round) {
+		txs = append(txs, w.uniform.Next(round)...)
+	}
+	return txs
+}
+
+// traceWorkload replays the rounds of a trace file
+type traceWorkload struct {
+	rounds map[int][]Transaction
+}
+
+func (w *traceWorkload) Next(round int) []Transaction {
+	return w.rounds[round]
+}
+
+// timedTrace replays a trace with timestamps, every transaction at its own arrival time
+type timedTrace struct {
+	traceWorkload
+	at map[TxID]time.Duration // tx -> arrival
+}
+
+func (w *timedTrace) arrival(tx Transaction) time.Duration {
+	return w.at[tx.TxID]
+}
+
+// tracePayment is one line of a trace file
+type tracePayment struct {
+	at               float64 // round, or seconds for a timed trace
+	sender, receiver string
+	value            Amount
+	asset            string
+}
+
+// traceColumns are the names a trace's columns or JSON fields may have
+var traceColumns = map[string]string{
+	"round": "round", "timestamp": "timestamp", "time": "timestamp",
+	"sender": "sender", "from": "sender", "receiver": "receiver", "to": "receiver",
+	"amount": "amount", "value": "amount", "asset": "asset", "token": "asset",
+}
+
+// readTrace reads the trace at path and maps its payments onto the nodes of an N, C simulation
+func readTrace(path string, N, C int) (Workload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("trace %s: %w", path, err)
+	}
+	defer f.Close()
+	var payments []tracePayment
+	var timed bool
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		payments, timed, err = readJSONTrace(f)
+	} else {
+		payments, timed, err = readCSVTrace(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trace %s: %w", path, err)
+	}
+	slices.SortStableFunc(payments, func(a, b tracePayment) int { return cmp.Compare(a.at, b.at) })
+
+	node := traceNodes(payments, N, C)
+	w, ids := &timedTrace{traceWorkload{rounds: make(map[int][]Transaction)}, make(map[TxID]time.Duration)}, txIDs{}
+	for _, p := range payments {
+		sender, ok1 := node[p.sender]
+		receiver, ok2 := node[p.receiver]
+		if !ok1 || !ok2 { // not in this simulation
+			continue
+		}
+		if sender == receiver && p.sender != p.receiver { // two addresses of the same node
+			continue
+		}
+		tx := Transaction{TxID: ids.id(), Sender: sender, Receiver: receiver, Amount: p.value, Asset: p.asset}
+		round := int(p.at)
+		if timed {
+			at := p.at - payments[0].at
+			round = int(at)
+			w.at[tx.TxID] = time.Duration(at * float64(time.Second))
+		}
+		w.rounds[round] = append(w.rounds[round], tx)
+	}
+	if !timed {
+		return &w.traceWorkload, nil
+	}
+	return w, nil
+}
+
+// traceNodes maps the addresses of a trace to node names. Node names like honest3 or corrupt1 stand for
+// themselves (and are left out if the simulation does not have the node), while a trace with other
+// addresses, e.g. exported from a public chain, is spread over the honest nodes: the busiest address
+// becomes honest1, the next honest2 and so on, wrapping around.
+func traceNodes(payments []tracePayment, N, C int) map[string]string {
+	activity, addresses := make(map[string]int), []string{}
+	for _, p := range payments {
+		for _, addr := range []string{p.sender, p.receiver} {
+			if activity[addr] == 0 {
+				addresses = append(addresses, addr)
+			}
+			activity[addr]++
+		}
+	}
+	names := make(map[string]string)
+	external := slices.ContainsFunc(addresses, func(addr string) bool { return !isNodeName(addr) })
+	if !external {
+		for _, addr := range addresses {
+			if i := nodeIndex(addr, C); i < N && nodeName(i, C) == addr {
+				names[addr] = addr
+			}
+		}
+		return names
+	}
+	if N-C < 2 {
+		return names
+	}
+	slices.SortStableFunc(addresses, func(a, b string) int { return activity[b] - activity[a] })
+	for k, addr := range addresses {
+		names[addr] = nodeName(C+k%(N-C), C)
+	}
+	return names
+}
+
+// isNodeName tells whether name is a node name like honest3 or corrupt1
+func isNodeName(name string) bool {
+	num, ok := strings.CutPrefix(name, "honest")
+	if !ok {
+		num, ok = strings.CutPrefix(name, "corrupt")
+	}
+	n, err := strconv.Atoi(num)
+	return ok && err == nil && n >= 1
+}
+
+// readCSVTrace reads round,sender,receiver or timestamp,sender,receiver,amount lines, or the columns named
+// by a header line
+func readCSVTrace(f io.Reader) ([]tracePayment, bool, error) {
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, false, err
+	}
+	columns := []string{"round", "sender", "receiver"}
+	if len(rows[0]) >= 4 {
+		columns = []string{"timestamp", "sender", "receiver", "amount"}
+	}
+	first := 1
+	if _, err := traceTime(rows[0][0]); err != nil { // a header
+		columns, first = nil, 2
+		for _, name := range rows[0] {
+			columns = append(columns, traceColumns[strings.ToLower(strings.TrimSpace(name))])
+		}
+		rows = rows[1:]
+	}
+	payments := []tracePayment{}
+	for n, row := range rows {
+		fields := make(map[string]any)
+		for k, v := range row {
+			if k < len(columns) && columns[k] != "" {
+				fields[columns[k]] = v
+			}
+		}
+		p, err := tracePaymentOf(fields)
+		if err != nil {
+			return nil, false, fmt.Errorf("line %d: %w", n+first, err)
+		}
+		payments = append(payments, p)
+	}
+	return payments, !slices.Contains(columns, "round"), nil
+}
+
+// readJSONTrace reads an array, or a stream, of objects with timestamp (or round), sender, receiver and
+// amount fields
+func readJSONTrace(f io.Reader) ([]tracePayment, bool, error) {
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	payments, timed := []tracePayment{}, true
+	for {
+		var v any
+		if err := dec.Decode(&v); err == io.EOF {
+			return payments, timed, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		objects, ok := v.([]any)
+		if !ok {
+			objects = []any{v}
+		}
+		for _, o := range objects {
+			object, ok := o.(map[string]any)
+			if !ok {
+				return nil, false, fmt.Errorf("payment %d is not an object", len(payments)+1)
+			}
+			fields := make(map[string]any)
+			for name, v := range object {
+				if column := traceColumns[strings.ToLower(name)]; column != "" {
+					fields[column] = v
+				}
+			}
+			if _, ok := fields["round"]; ok {
+				timed = false
+			}
+			p, err := tracePaymentOf(fields)
+			if err != nil {
+				return nil, false, fmt.Errorf("payment %d: %w", len(payments)+1, err)
+			}
+			payments = append(payments, p)
+		}
+	}
+}
+
+// tracePaymentOf builds a payment of the fields of a line or object
+func tracePaymentOf(fields map[string]any) (tracePayment, error) {
+	text := func(name string) string { return strings.TrimSpace(fmt.Sprint(fields[name])) }
+	p := tracePayment{sender: text("sender"), receiver: text("receiver")}
+	if fields["sender"] == nil || fields["receiver"] == nil || p.sender == "" || p.receiver == "" {
+		return p, fmt.Errorf("missing sender or receiver")
+	}
+	if _, ok := fields["round"]; ok {
+		round, err := strconv.Atoi(text("round"))
+		if err != nil || round < 0 {
+			return p, fmt.Errorf("%q is not a round", text("round"))
+		}
+		p.at = float64(round)
+	} else {
+		at, err := traceTime(text("timestamp"))
+		if err != nil {
+			return p, err
+		}
+		p.at = at
+	}
+	if fields["amount"] != nil && text("amount") != "" {
+		v, err := parseAmount(text("amount"))
+		if err != nil || v < 0 {
+			return p, fmt.Errorf("%q is not an amount", text("amount"))
+		}
+		p.value = v
+	}
+	if fields["asset"] != nil {
+		p.asset = text("asset")
+	}
+	return p, nil
+}
+
+// traceTime parses a timestamp in seconds (e.g. Unix time) or RFC 3339
+func traceTime(s string) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(v) && !math.IsInf(v, 0) {
+		return v, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return float64(t.UnixNano()) / float64(time.Second), nil
+	}
+	return 0, fmt.Errorf("%q is not a timestamp", s)
+}
+
+// parseWorkload builds the workload of spec for an N, C simulation, drawing from rng (the global
+// source if nil); "" is uniform
+func parseWorkload(spec string, N, C, R int, p float64, rng *rand.Rand) (Workload, error) {
+	float := rand.Float64
+	if rng != nil {
+		float = rng.Float64
+	} else {
+		rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	name, arg, _ := strings.Cut(spec, ":")
+	switch name {
+	case "", "uniform":
+		return &uniformWorkload{N: N, C: C, p: p, float: float}, nil
+	case "poisson":
+		if arg == "" {
+			return &poissonWorkload{N: N, C: C, p: p, rng: rng}, nil
+		}
+		rate, err := strconv.ParseFloat(arg, 64)
+		if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("workload %q: the rate must be a positive number of transactions per second", spec)
+		}
+		return newPoissonProcess(N, C, rate, rng), nil
+	case "zipf":
+		s := 1.2
+		if arg != "" {
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil || !(v > 1) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("workload %q: the Zipf exponent must be a number above 1", spec)
+			}
+			s = v
+		}
+		return &poissonWorkload{N: N, C: C, p: p, rng: rng, zipf: s, ranks: make(map[int]*rand.Zipf)}, nil
+	case "bursty":
+		k := 5
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("workload %q: the burst interval must be a positive number of rounds", spec)
+			}
+			k = n
+		}
+		return &burstyWorkload{uniform: &uniformWorkload{N: N, C: C, p: p, float: float}, k: k, R: R}, nil
+	case "trace":
+		return readTrace(arg, N, C)
+	}
+	return nil, fmt.Errorf("unknown workload %q (uniform, poisson[:rate], zipf[:s], bursty[:k] or trace:<file>)", spec)
+}
+
+// mappedWorkload rewrites every transaction of a workload
+type mappedWorkload struct {
+	Workload
+	fn func(tx *Transaction)
+}
+
+func (w *mappedWorkload) Next(round int) []Transaction {
+	txs := w.Workload.Next(round)
+	for k := range txs {
+		w.fn(&txs[k])
+	}
+	return txs
+}
+
+// mappedArrivals is a mappedWorkload of an arrivalWorkload
+type mappedArrivals struct {
+	mappedWorkload
+}
+
+func (w *mappedArrivals) arrival(tx Transaction) time.Duration {
+	return w.Workload.(arrivalWorkload).arrival(tx)
+}
+
+// mapWorkload applies fn to every transaction of w, keeping w an arrivalWorkload if it is one
+func mapWorkload(w Workload, fn func(tx *Transaction)) Workload {
+	if _, ok := w.(arrivalWorkload); ok {
+		return &mappedArrivals{mappedWorkload{w, fn}}
+	}
+	return &mappedWorkload{w, fn}
+}
+
+// extendedWorkload hands every batch of a workload, none past its R rounds, to fn for the batch to send
+type extendedWorkload struct {
+	Workload
+	R   int
+	fn  func(round int, txs []Transaction) []Transaction
+	own func(tx Transaction) bool // the transactions fn adds
+}
+
+func (w *extendedWorkload) Next(round int) []Transaction {
+	var txs []Transaction
+	if round < w.R {
+		txs = w.Workload.Next(round)
+	}
+	return w.fn(round, txs)
+}
+
+// extendedArrivals is an extendedWorkload of an arrivalWorkload, the transactions fn adds arrive right away
+type extendedArrivals struct {
+	extendedWorkload
+}
+
+func (w *extendedArrivals) arrival(tx Transaction) time.Duration {
+	if w.own(tx) {
+		return 0
+	}
+	return w.Workload.(arrivalWorkload).arrival(tx)
+}
+
+// extendWorkload lets fn add to (or change) every round of w, and to the rounds past its R ones that the
+// simulation runs on, e.g. with transactions reacting to what the nodes mined; own tells them apart
+func extendWorkload(w Workload, R int, fn func(round int, txs []Transaction) []Transaction, own func(tx Transaction) bool) Workload {
+	if _, ok := w.(arrivalWorkload); ok {
+		return &extendedArrivals{extendedWorkload{w, R, fn, own}}
+	}
+	return &extendedWorkload{w, R, fn, own}
+}
+
+// newWorkload returns the workload of cfg, the tester already checked the spec
+func newWorkload(cfg SimConfig, N, C int, rng *rand.Rand) Workload {
+	w, err := parseWorkload(cfg.Workload, N, C, cfg.R, cfg.P, rng)
+	if err != nil {
+		return &traceWorkload{} // no transactions rather than a crash
+	}
+	w = withAssets(withAmounts(w, cfg, rng), cfg, rng)
+	if cfg.WrapWorkload != nil {
+		w = cfg.WrapWorkload(w)
+	}
+	return w
+}